@@ -0,0 +1,145 @@
+// Package bankimport reads bank statements and categorises their lines.
+//
+// Statements come in as CSV (with a caller-supplied column mapping) or OFX
+// (1.x SGML or 2.x XML). Both are turned into Lines with signed amounts in
+// minor units, so the caller can store and deduplicate them the same way.
+// Rules are matched here too; loading and saving them is the caller's job.
+package bankimport
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Statement formats
+const (
+	FormatCSV = "csv"
+	FormatOFX = "ofx"
+)
+
+// ErrNoLines is returned when a statement has no transaction lines
+var ErrNoLines = errors.New("statement has no transaction lines")
+
+// Line is one statement line. Amount is in minor units (kobo) and signed:
+// negative is money out, positive is money in.
+type Line struct {
+	LineNo       int       `json:"line_no"`
+	PostedAt     time.Time `json:"posted_at"`
+	Amount       int       `json:"amount"`
+	Narration    string    `json:"narration"`
+	Counterparty string    `json:"counterparty,omitempty"`
+	Reference    string    `json:"reference,omitempty"`
+	// ExternalID is the bank's own transaction ID (OFX FITID), when there is one
+	ExternalID string `json:"external_id,omitempty"`
+	Hash       string `json:"hash"`
+}
+
+// Debit reports whether the line is money out
+func (l Line) Debit() bool {
+	return l.Amount < 0
+}
+
+// AssignHashes sets each line's Hash. The hash identifies the line across
+// re-imports and overlapping statements of the same account: the bank's
+// transaction ID when it has one, otherwise date, amount, narration and
+// reference. Identical lines within one statement (two equal card payments
+// on the same day) are told apart by how many came before them.
+func AssignHashes(lines []Line, account, currency string) {
+	seen := map[string]int{}
+	for i := range lines {
+		l := &lines[i]
+		var key string
+		if l.ExternalID != "" {
+			key = "id|" + l.ExternalID
+		} else {
+			key = fmt.Sprintf("line|%s|%d|%s|%s", l.PostedAt.UTC().Format("2006-01-02"), l.Amount,
+				normalise(l.Narration), normalise(l.Reference))
+		}
+		occurrence := seen[key]
+		seen[key]++
+
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s|%d", normalise(account), strings.ToUpper(currency), key, occurrence)))
+		l.Hash = hex.EncodeToString(sum[:])
+	}
+}
+
+// normalise lower-cases s and collapses runs of whitespace
+func normalise(s string) string {
+	return strings.ToLower(strings.Join(strings.Fields(s), " "))
+}
+
+// ParseAmount parses a statement amount into minor units without going
+// through floating point. It accepts thousands separators, currency symbols
+// or codes, a leading minus, accounting parentheses and DR/CR suffixes
+// ("1,234.50", "(20.00)", "₦5,000", "750.00 DR").
+func ParseAmount(value string) (int, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	if s == "" {
+		return 0, errors.New("empty amount")
+	}
+
+	negative := false
+	if strings.HasSuffix(s, "DR") {
+		negative = true
+		s = strings.TrimSuffix(s, "DR")
+	} else if strings.HasSuffix(s, "CR") {
+		s = strings.TrimSuffix(s, "CR")
+	}
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative = !negative
+		s = s[1 : len(s)-1]
+	}
+
+	var digits strings.Builder
+	fraction := -1
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			if fraction >= 0 {
+				if fraction == 2 {
+					return 0, fmt.Errorf("invalid amount %q: more than two decimal places", value)
+				}
+				fraction++
+			}
+			digits.WriteRune(r)
+		case r == '.':
+			if fraction >= 0 {
+				return 0, fmt.Errorf("invalid amount %q", value)
+			}
+			fraction = 0
+		case r == '-':
+			if digits.Len() > 0 {
+				return 0, fmt.Errorf("invalid amount %q", value)
+			}
+			negative = !negative
+		case r == '+', r == ',', r == ' ', r == '\u00a0':
+		case r >= 'A' && r <= 'Z', r > 127:
+			// currency codes and symbols
+		default:
+			return 0, fmt.Errorf("invalid amount %q", value)
+		}
+	}
+	if digits.Len() == 0 {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	if fraction < 0 {
+		fraction = 0
+	}
+
+	amount := 0
+	for _, r := range digits.String() {
+		amount = amount*10 + int(r-'0')
+	}
+	for ; fraction < 2; fraction++ {
+		amount *= 10
+	}
+	if negative {
+		amount = -amount
+	}
+	return amount, nil
+}
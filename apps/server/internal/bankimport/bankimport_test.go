@@ -0,0 +1,160 @@
+package bankimport
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAmount(t *testing.T) {
+	cases := map[string]int{
+		"1,234.50":  123450,
+		"-20":       -2000,
+		"(20.00)":   -2000,
+		"₦5,000":    500000,
+		"NGN 7.5":   750,
+		"750.00 DR": -75000,
+		"750.00CR":  75000,
+		"+0.05":     5,
+		"1 000":     100000,
+	}
+	for in, want := range cases {
+		got, err := ParseAmount(in)
+		if err != nil || got != want {
+			t.Errorf("ParseAmount(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "abc", "1.234", "1.2.3", "12-"} {
+		if _, err := ParseAmount(in); err == nil {
+			t.Errorf("ParseAmount(%q) should fail", in)
+		}
+	}
+}
+
+func TestParseCSVDebitCredit(t *testing.T) {
+	statement := "Account statement,,,\n" +
+		"Trans Date,Narration,Debit,Credit\n" +
+		"03/02/2025,POS PURCHASE SHOPRITE,\"12,500.00\",\n" +
+		",Opening balance,,\n" +
+		"04/02/2025,TRANSFER FROM ADA,0.00,\"50,000.00\"\n"
+	lines, err := ParseCSV(strings.NewReader(statement), CSVMapping{
+		Date: "trans date", Narration: "Narration", Debit: "DEBIT", Credit: "credit", SkipRows: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+	if lines[0].Amount != -1250000 || !lines[0].PostedAt.Equal(time.Date(2025, 2, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("first line = %+v", lines[0])
+	}
+	if lines[1].Amount != 5000000 || lines[1].LineNo != 5 {
+		t.Errorf("second line = %+v", lines[1])
+	}
+}
+
+func TestParseCSVDirectionAndFormat(t *testing.T) {
+	statement := "12-Mar-25;Diesel;40000;DR\n13-Mar-25;Refund;500;CR\n"
+	lines, err := ParseCSV(strings.NewReader(statement), CSVMapping{
+		Date: "1", DateFormat: "DD-MMM-YY", Narration: "2", Amount: "3", Direction: "4",
+		NoHeader: true, Delimiter: ";",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines[0].Amount != -4000000 || lines[1].Amount != 50000 {
+		t.Errorf("amounts = %d, %d", lines[0].Amount, lines[1].Amount)
+	}
+	if lines[0].PostedAt.Day() != 12 || lines[0].PostedAt.Month() != time.March {
+		t.Errorf("date = %v", lines[0].PostedAt)
+	}
+
+	if _, err := ParseCSV(strings.NewReader("a,b\n"), CSVMapping{Date: "a", Narration: "b", Amount: "missing"}); err == nil {
+		t.Error("expected an error for a column missing from the header")
+	}
+}
+
+func TestParseOFX(t *testing.T) {
+	statement := `OFXHEADER:100
+DATA:OFXSGML
+
+<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS>
+<CURDEF>ngn
+<BANKACCTFROM><ACCTID>0123456789</BANKACCTFROM>
+<BANKTRANLIST>
+<STMTTRN>
+<TRNTYPE>DEBIT
+<DTPOSTED>20250301120000.000[+1:WAT]
+<TRNAMT>-1500.00
+<FITID>T1
+<NAME>Ikeja Electric
+<MEMO>Prepaid token &amp; fees
+</STMTTRN>
+<STMTTRN><TRNTYPE>CREDIT</TRNTYPE><DTPOSTED>20250302</DTPOSTED><TRNAMT>200</TRNAMT><FITID>T2</FITID><NAME>Ada</NAME></STMTTRN>
+</BANKTRANLIST></STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>`
+
+	st, err := ParseOFX(strings.NewReader(statement))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Currency != "NGN" || st.AccountID != "0123456789" || len(st.Lines) != 2 {
+		t.Fatalf("statement = %+v", st)
+	}
+	first := st.Lines[0]
+	if first.Amount != -150000 || first.ExternalID != "T1" || first.Narration != "Prepaid token & fees" || first.Counterparty != "Ikeja Electric" {
+		t.Errorf("first line = %+v", first)
+	}
+	if !first.PostedAt.Equal(time.Date(2025, 3, 1, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("posted = %v", first.PostedAt)
+	}
+	if st.Lines[1].Amount != 20000 || st.Lines[1].Narration != "Ada" {
+		t.Errorf("second line = %+v", st.Lines[1])
+	}
+}
+
+func TestAssignHashes(t *testing.T) {
+	day := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	lines := []Line{
+		{PostedAt: day, Amount: -500, Narration: "POS  Chicken Republic"},
+		{PostedAt: day, Amount: -500, Narration: "pos chicken republic"},
+	}
+	AssignHashes(lines, "GTB 0123", "ngn")
+	if lines[0].Hash == lines[1].Hash {
+		t.Error("identical lines in one statement should hash differently")
+	}
+
+	again := []Line{{PostedAt: day, Amount: -500, Narration: "POS Chicken Republic"}}
+	AssignHashes(again, "gtb 0123", "NGN")
+	if again[0].Hash != lines[0].Hash {
+		t.Error("re-importing a line should give the same hash")
+	}
+}
+
+func TestRules(t *testing.T) {
+	min := 100000
+	rules := []*Rule{
+		{Name: "fuel", NarrationRegex: `diesel|petrol`, MinAmount: &min, Category: "fuel"},
+		{Name: "food", NarrationContains: "chicken", Category: "meals"},
+	}
+	for _, r := range rules {
+		if err := r.Compile(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if r := FirstMatch(rules, Line{Amount: -250000, Narration: "DIESEL purchase"}); r == nil || r.Category != "fuel" {
+		t.Errorf("large diesel line matched %v", r)
+	}
+	if r := FirstMatch(rules, Line{Amount: -5000, Narration: "diesel top-up"}); r != nil {
+		t.Errorf("small diesel line matched %q", r.Name)
+	}
+	if r := FirstMatch(rules, Line{Amount: -5000, Narration: "POS Chicken Republic"}); r == nil || r.Category != "meals" {
+		t.Errorf("chicken line matched %v", r)
+	}
+
+	bad := &Rule{NarrationRegex: "(", Category: "x"}
+	if err := bad.Compile(); err == nil {
+		t.Error("expected an error for an invalid regex")
+	}
+}
@@ -0,0 +1,279 @@
+package bankimport
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVMapping says which columns of a CSV statement hold what. Columns are
+// named by header text (case-insensitive) or, when the file has no header
+// row, by 1-based position ("3").
+//
+// Amounts come either from one signed Amount column, from separate Debit and
+// Credit columns, or from an unsigned Amount column plus a Direction column
+// whose values start with D (debit) or C (credit).
+type CSVMapping struct {
+	Date         string `json:"date"`
+	DateFormat   string `json:"date_format,omitempty"`
+	Narration    string `json:"narration"`
+	Amount       string `json:"amount,omitempty"`
+	Debit        string `json:"debit,omitempty"`
+	Credit       string `json:"credit,omitempty"`
+	Direction    string `json:"direction,omitempty"`
+	Counterparty string `json:"counterparty,omitempty"`
+	Reference    string `json:"reference,omitempty"`
+	// DebitsPositive is set when a signed Amount column shows money out as
+	// positive numbers
+	DebitsPositive bool `json:"debits_positive,omitempty"`
+	// NoHeader is set when the first row is already a transaction
+	NoHeader bool `json:"no_header,omitempty"`
+	// SkipRows is how many rows of bank preamble come before the header
+	SkipRows  int    `json:"skip_rows,omitempty"`
+	Delimiter string `json:"delimiter,omitempty"`
+}
+
+// Validate checks that the mapping names the columns it needs
+func (m CSVMapping) Validate() error {
+	if m.Date == "" || m.Narration == "" {
+		return errors.New("mapping needs date and narration columns")
+	}
+	switch {
+	case m.Amount != "" && (m.Debit != "" || m.Credit != ""):
+		return errors.New("mapping takes either amount or debit/credit columns, not both")
+	case m.Amount == "" && (m.Debit == "" || m.Credit == ""):
+		return errors.New("mapping needs an amount column or both debit and credit columns")
+	case m.Direction != "" && m.Amount == "":
+		return errors.New("direction only applies with an amount column")
+	}
+	if m.Delimiter != "" && len([]rune(m.Delimiter)) != 1 {
+		return errors.New("delimiter must be a single character")
+	}
+	if m.SkipRows < 0 {
+		return errors.New("skip_rows must not be negative")
+	}
+	return nil
+}
+
+// defaultDateLayouts are tried in order when the mapping has no date_format.
+// Day-first formats come before month-first ones, as on Nigerian statements.
+var defaultDateLayouts = []string{
+	"2006-01-02", "2006-01-02 15:04:05", "2006-01-02T15:04:05Z07:00",
+	"02/01/2006", "02/01/2006 15:04:05", "02/01/2006 15:04", "02-01-2006", "02.01.2006",
+	"02-Jan-2006", "02 Jan 2006", "02-Jan-06", "2 Jan 2006", "Jan 2, 2006",
+	"01/02/2006",
+}
+
+// ParseCSV reads a CSV statement with the given mapping. Rows with an empty
+// date are skipped (banks add opening balance and total rows); any other row
+// that cannot be read is an error naming the row.
+func ParseCSV(r io.Reader, m CSVMapping) ([]Line, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	layouts := defaultDateLayouts
+	if m.DateFormat != "" {
+		layouts = []string{DateLayout(m.DateFormat)}
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.LazyQuotes = true
+	if m.Delimiter != "" {
+		reader.Comma = []rune(m.Delimiter)[0]
+	}
+
+	row := 0
+	next := func() ([]string, error) {
+		record, err := reader.Read()
+		row++
+		return record, err
+	}
+
+	for i := 0; i < m.SkipRows; i++ {
+		if _, err := next(); err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+	}
+
+	index := map[string]int{}
+	if !m.NoHeader {
+		header, err := next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read header row: %w", err)
+		}
+		for i, name := range header {
+			name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+			if _, dup := index[name]; !dup {
+				index[name] = i
+			}
+		}
+	}
+	column := func(name string) (int, error) {
+		if name == "" {
+			return -1, nil
+		}
+		if m.NoHeader {
+			n, err := strconv.Atoi(name)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("column %q must be a 1-based position when there is no header", name)
+			}
+			return n - 1, nil
+		}
+		i, ok := index[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return 0, fmt.Errorf("column %q is not in the header", name)
+		}
+		return i, nil
+	}
+
+	cols := map[string]int{}
+	for field, name := range map[string]string{
+		"date": m.Date, "narration": m.Narration, "amount": m.Amount, "debit": m.Debit, "credit": m.Credit,
+		"direction": m.Direction, "counterparty": m.Counterparty, "reference": m.Reference,
+	} {
+		i, err := column(name)
+		if err != nil {
+			return nil, err
+		}
+		cols[field] = i
+	}
+
+	lines := []Line{}
+	for {
+		record, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		get := func(field string) string {
+			i := cols[field]
+			if i < 0 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		dateValue := get("date")
+		if dateValue == "" {
+			continue
+		}
+		posted, err := parseDate(dateValue, layouts)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		amount, err := csvAmount(m, get)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+
+		lines = append(lines, Line{
+			LineNo:       row,
+			PostedAt:     posted,
+			Amount:       amount,
+			Narration:    get("narration"),
+			Counterparty: get("counterparty"),
+			Reference:    get("reference"),
+		})
+	}
+
+	if len(lines) == 0 {
+		return nil, ErrNoLines
+	}
+	return lines, nil
+}
+
+// csvAmount reads a row's signed amount according to the mapping
+func csvAmount(m CSVMapping, get func(string) string) (int, error) {
+	if m.Amount == "" {
+		debit, credit := get("debit"), get("credit")
+		switch {
+		case debit != "" && !isZero(debit):
+			amount, err := ParseAmount(debit)
+			return -abs(amount), err
+		case credit != "":
+			amount, err := ParseAmount(credit)
+			return abs(amount), err
+		}
+		return 0, errors.New("row has neither a debit nor a credit amount")
+	}
+
+	amount, err := ParseAmount(get("amount"))
+	if err != nil {
+		return 0, err
+	}
+	if m.Direction != "" {
+		switch d := strings.ToUpper(get("direction")); {
+		case strings.HasPrefix(d, "D"):
+			return -abs(amount), nil
+		case strings.HasPrefix(d, "C"):
+			return abs(amount), nil
+		default:
+			return 0, fmt.Errorf("direction %q is neither debit nor credit", get("direction"))
+		}
+	}
+	if m.DebitsPositive {
+		return -amount, nil
+	}
+	return amount, nil
+}
+
+// isZero reports whether an amount cell is a zero placeholder ("0.00", "-")
+func isZero(value string) bool {
+	amount, err := ParseAmount(value)
+	return err != nil || amount == 0
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func parseDate(value string, layouts []string) (time.Time, error) {
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date %q", value)
+}
+
+// dateTokens maps spreadsheet-style date tokens to Go layout elements,
+// longest first so "YYYY" wins over "YY"
+var dateTokens = []struct{ token, layout string }{
+	{"YYYY", "2006"}, {"YY", "06"},
+	{"MMMM", "January"}, {"MMM", "Jan"}, {"MM", "01"}, {"M", "1"},
+	{"DD", "02"}, {"D", "2"},
+	{"HH", "15"}, {"hh", "03"}, {"mm", "04"}, {"ss", "05"},
+}
+
+// DateLayout converts a date format such as "DD/MM/YYYY" or "DD-MMM-YY" to a
+// Go time layout. Other characters are kept as they are.
+func DateLayout(format string) string {
+	var b strings.Builder
+	for i := 0; i < len(format); {
+		matched := false
+		for _, t := range dateTokens {
+			if strings.HasPrefix(format[i:], t.token) {
+				b.WriteString(t.layout)
+				i += len(t.token)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			b.WriteByte(format[i])
+			i++
+		}
+	}
+	return b.String()
+}
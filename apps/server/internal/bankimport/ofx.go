@@ -0,0 +1,131 @@
+package bankimport
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// OFXStatement is what ParseOFX reads from an OFX file
+type OFXStatement struct {
+	Currency  string
+	AccountID string
+	Lines     []Line
+}
+
+var ofxTransactionPattern = regexp.MustCompile(`(?is)<STMTTRN>(.*?)</STMTTRN>`)
+
+// ofxTagPatterns holds a pattern for every leaf element ParseOFX reads
+var ofxTagPatterns = func() map[string]*regexp.Regexp {
+	patterns := map[string]*regexp.Regexp{}
+	for _, name := range []string{"CURDEF", "ACCTID", "DTPOSTED", "TRNAMT", "NAME", "MEMO", "REFNUM", "CHECKNUM", "FITID"} {
+		patterns[name] = regexp.MustCompile(`(?i)<` + name + `>([^<\r\n]*)`)
+	}
+	return patterns
+}()
+
+// ofxTag returns the value of a leaf element. It works for SGML, where leaf
+// elements are not closed, as well as XML.
+func ofxTag(block, name string) string {
+	match := ofxTagPatterns[name].FindStringSubmatch(block)
+	if match == nil {
+		return ""
+	}
+	return strings.TrimSpace(html.UnescapeString(match[1]))
+}
+
+// ParseOFX reads the bank or credit card transactions of an OFX statement.
+// Only the first statement in the file is read.
+func ParseOFX(r io.Reader) (*OFXStatement, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	content := string(body)
+
+	st := &OFXStatement{
+		Currency:  strings.ToUpper(ofxTag(content, "CURDEF")),
+		AccountID: ofxTag(content, "ACCTID"),
+		Lines:     []Line{},
+	}
+
+	for i, match := range ofxTransactionPattern.FindAllStringSubmatch(content, -1) {
+		block := match[1]
+		posted, err := parseOFXTime(ofxTag(block, "DTPOSTED"))
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", i+1, err)
+		}
+		amount, err := ParseAmount(ofxTag(block, "TRNAMT"))
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", i+1, err)
+		}
+
+		name := ofxTag(block, "NAME")
+		memo := ofxTag(block, "MEMO")
+		narration := memo
+		if narration == "" {
+			narration = name
+		}
+		st.Lines = append(st.Lines, Line{
+			LineNo:       i + 1,
+			PostedAt:     posted,
+			Amount:       amount,
+			Narration:    narration,
+			Counterparty: name,
+			Reference:    firstNonEmpty(ofxTag(block, "REFNUM"), ofxTag(block, "CHECKNUM")),
+			ExternalID:   ofxTag(block, "FITID"),
+		})
+	}
+
+	if len(st.Lines) == 0 {
+		return nil, ErrNoLines
+	}
+	return st, nil
+}
+
+// parseOFXTime parses an OFX datetime: YYYYMMDD, optionally followed by
+// HHMMSS, milliseconds and a [offset:TZ] suffix
+func parseOFXTime(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("invalid OFX date %q", value)
+	}
+
+	offset := 0
+	if i := strings.Index(value, "["); i >= 0 {
+		tz := strings.TrimSuffix(value[i+1:], "]")
+		if j := strings.Index(tz, ":"); j >= 0 {
+			tz = tz[:j]
+		}
+		var hours float64
+		if _, err := fmt.Sscanf(tz, "%g", &hours); err == nil {
+			offset = int(hours * 3600)
+		}
+		value = value[:i]
+	}
+	if i := strings.Index(value, "."); i >= 0 {
+		value = value[:i]
+	}
+
+	layout := "20060102150405"
+	if len(value) < len(layout) {
+		layout = layout[:8]
+		value = value[:8]
+	}
+	t, err := time.ParseInLocation(layout, value[:len(layout)], time.FixedZone("", offset))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid OFX date %q", value)
+	}
+	return t.UTC(), nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
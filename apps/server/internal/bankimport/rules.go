@@ -0,0 +1,86 @@
+package bankimport
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Rule assigns a category and budget to lines it matches. Every condition
+// that is set must hold; text conditions ignore case and amount bounds apply
+// to the absolute amount in minor units.
+type Rule struct {
+	ID                   int    `json:"id"`
+	Name                 string `json:"name"`
+	Priority             int    `json:"priority"`
+	NarrationContains    string `json:"narration_contains,omitempty"`
+	NarrationRegex       string `json:"narration_regex,omitempty"`
+	CounterpartyContains string `json:"counterparty_contains,omitempty"`
+	MinAmount            *int   `json:"min_amount,omitempty"`
+	MaxAmount            *int   `json:"max_amount,omitempty"`
+	Category             string `json:"category,omitempty"`
+	BudgetLimitID        *int   `json:"budget_limit_id,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// Compile validates the rule and prepares its regex. It must be called
+// before Matches.
+func (r *Rule) Compile() error {
+	if r.NarrationContains == "" && r.NarrationRegex == "" && r.CounterpartyContains == "" &&
+		r.MinAmount == nil && r.MaxAmount == nil {
+		return errors.New("rule needs at least one condition")
+	}
+	if r.Category == "" && r.BudgetLimitID == nil {
+		return errors.New("rule needs a category or budget_limit_id to assign")
+	}
+	if r.MinAmount != nil && r.MaxAmount != nil && *r.MinAmount > *r.MaxAmount {
+		return errors.New("min_amount must not be greater than max_amount")
+	}
+	r.pattern = nil
+	if r.NarrationRegex != "" {
+		pattern, err := regexp.Compile("(?i)" + r.NarrationRegex)
+		if err != nil {
+			return fmt.Errorf("invalid narration_regex: %w", err)
+		}
+		r.pattern = pattern
+	}
+	return nil
+}
+
+// Matches reports whether the line meets every condition of the rule
+func (r *Rule) Matches(l Line) bool {
+	if r.NarrationContains != "" && !containsFold(l.Narration, r.NarrationContains) {
+		return false
+	}
+	if r.pattern != nil && !r.pattern.MatchString(l.Narration) {
+		return false
+	}
+	if r.CounterpartyContains != "" && !containsFold(l.Counterparty, r.CounterpartyContains) {
+		return false
+	}
+	amount := abs(l.Amount)
+	if r.MinAmount != nil && amount < *r.MinAmount {
+		return false
+	}
+	if r.MaxAmount != nil && amount > *r.MaxAmount {
+		return false
+	}
+	return true
+}
+
+// FirstMatch returns the first rule that matches the line, or nil. Rules
+// should already be in priority order.
+func FirstMatch(rules []*Rule, l Line) *Rule {
+	for _, r := range rules {
+		if r.Matches(l) {
+			return r
+		}
+	}
+	return nil
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(normalise(s), normalise(substr))
+}
@@ -0,0 +1,38 @@
+// Package bureau defines the credit bureau provider interface used by the
+// verdict subsystem to fetch external credit reports.
+//
+// Providers only know how to fetch a report for an email address. Caching,
+// freshness and merging reports into the local credit_profiles table are the
+// verdict handler's responsibility.
+package bureau
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrReportNotFound is returned when a bureau has no report for the subject
+var ErrReportNotFound = errors.New("credit report not found")
+
+// CreditBureau fetches credit reports from an external provider
+type CreditBureau interface {
+	// Name identifies the provider in cached reports and logs
+	Name() string
+	// FetchReport returns the latest report for the given email
+	FetchReport(email string) (*Report, error)
+}
+
+// Report is a normalised credit report returned by a bureau.
+// All amounts are in kobo for consistency with the rest of the system.
+type Report struct {
+	Email               string    `json:"email"`
+	Name                string    `json:"name,omitempty"`
+	Phone               string    `json:"phone,omitempty"`
+	ProfileType         string    `json:"profile_type,omitempty"`
+	CreditScore         int       `json:"credit_score"`
+	TotalDebt           int       `json:"total_debt"`
+	PaymentHistoryScore int       `json:"payment_history_score"`
+	AccountAgeMonths    int       `json:"account_age_months"`
+	MonthlyIncome       int       `json:"monthly_income,omitempty"`
+	ReportedAt          time.Time `json:"reported_at"`
+}
@@ -0,0 +1,47 @@
+package bureau
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// FileBureau is an offline stand-in bureau backed by a JSON file containing
+// an array of reports. The file is re-read on every fetch so fixtures can be
+// edited while the server is running.
+type FileBureau struct {
+	path string
+}
+
+// NewFileBureau creates a bureau that serves reports from the given file
+func NewFileBureau(path string) *FileBureau {
+	return &FileBureau{path: path}
+}
+
+// Name returns the provider name
+func (b *FileBureau) Name() string {
+	return "file"
+}
+
+// FetchReport looks up a report by email (case-insensitive)
+func (b *FileBureau) FetchReport(email string) (*Report, error) {
+	data, err := os.ReadFile(b.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bureau file: %w", err)
+	}
+
+	var reports []Report
+	if err := json.Unmarshal(data, &reports); err != nil {
+		return nil, fmt.Errorf("failed to parse bureau file: %w", err)
+	}
+
+	for _, report := range reports {
+		if strings.EqualFold(report.Email, email) {
+			r := report
+			return &r, nil
+		}
+	}
+
+	return nil, ErrReportNotFound
+}
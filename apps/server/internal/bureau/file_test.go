@@ -0,0 +1,35 @@
+package bureau
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFileBureauFetchReport(t *testing.T) {
+	b := NewFileBureau("testdata/reports.json")
+
+	report, err := b.FetchReport("John.Doe@example.com")
+	if err != nil {
+		t.Fatalf("Failed to fetch report: %v", err)
+	}
+
+	if report.CreditScore != 765 {
+		t.Errorf("Expected credit score 765, got %d", report.CreditScore)
+	}
+	if report.ReportedAt.IsZero() {
+		t.Error("Expected reported_at to be set")
+	}
+
+	_, err = b.FetchReport("nobody@example.com")
+	if !errors.Is(err, ErrReportNotFound) {
+		t.Fatalf("Expected ErrReportNotFound, got %v", err)
+	}
+}
+
+func TestFileBureauMissingFile(t *testing.T) {
+	b := NewFileBureau("testdata/does-not-exist.json")
+
+	if _, err := b.FetchReport("john.doe@example.com"); err == nil {
+		t.Fatal("Expected error for missing bureau file")
+	}
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -12,6 +15,79 @@ type Config struct {
 	PaystackSecretKey string
 	ServerPort        string
 	DatabasePath      string
+
+	// CreditBureauFile points the verdict system at a file-backed credit bureau.
+	// When empty, verdicts use local credit profiles only.
+	CreditBureauFile string
+	// CreditBureauTTL is how long a fetched credit report is considered fresh
+	CreditBureauTTL time.Duration
+
+	// RecipientNameVerifyThreshold is the minimum name match score (0-1) for a
+	// resolved bank account to count as verified
+	RecipientNameVerifyThreshold float64
+	// RecipientNameRejectThreshold is the score below which recipient creation
+	// is rejected; scores in between are created but flagged
+	RecipientNameRejectThreshold float64
+
+	// RecipientSyncInterval is how often recipients are synced from Paystack
+	// in the background. Zero disables the scheduled sync.
+	RecipientSyncInterval time.Duration
+
+	// CustomerSyncInterval is how often new Paystack customers are pulled into
+	// the local customer directory. Zero disables the scheduled sync.
+	CustomerSyncInterval time.Duration
+
+	// LedgerSyncInterval is how often Paystack transactions and transfers are
+	// pulled into the local ledger. Zero disables the scheduled sync.
+	LedgerSyncInterval time.Duration
+
+	// ReconcileInterval is how often local records are reconciled with the
+	// ledger over the last 30 days. Zero disables the scheduled run.
+	ReconcileInterval time.Duration
+
+	// SnapshotCacheTTL is how long an account snapshot is served from cache.
+	// Zero disables caching.
+	SnapshotCacheTTL time.Duration
+
+	// BankDirectoryTTL is how long the cached bank list is used before it is
+	// refreshed from Paystack
+	BankDirectoryTTL time.Duration
+	// BankDirectoryCountries are the Paystack countries whose banks are cached
+	BankDirectoryCountries []string
+	// BankDirectoryRefreshInterval is how often the background job refreshes the
+	// bank directory once it is older than BankDirectoryTTL. Zero disables the job.
+	BankDirectoryRefreshInterval time.Duration
+
+	// InvoicePollInterval is how often the background poller looks for invoices
+	// due a status check, and the base delay between checks of one invoice.
+	// Zero disables the poller.
+	InvoicePollInterval time.Duration
+	// InvoicePollMaxBackoff caps the delay between checks of an unchanged invoice
+	InvoicePollMaxBackoff time.Duration
+
+	// Business details printed on rendered invoices
+	BusinessName    string
+	BusinessAddress string
+	BusinessEmail   string
+	BusinessPhone   string
+	// InvoiceFooter is printed at the bottom of rendered invoices (payment terms, bank details)
+	InvoiceFooter string
+	// InvoiceTemplateFile overrides the built-in HTML invoice template
+	InvoiceTemplateFile string
+
+	// DunningInterval is how often the dunning scheduler looks for invoice
+	// reminders that are due. Zero disables the scheduler.
+	DunningInterval time.Duration
+	// DunningNotifier selects how reminders are delivered: log, paystack or smtp.
+	// log only writes reminders to the server log and records them as logged.
+	DunningNotifier string
+
+	// SMTP settings for the smtp dunning notifier
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
 }
 
 // Load loads configuration from environment variables
@@ -43,5 +119,97 @@ func Load() *Config {
 		PaystackSecretKey: apiKey,
 		ServerPort:        port,
 		DatabasePath:      dbPath,
+		CreditBureauFile:  os.Getenv("CREDIT_BUREAU_FILE"),
+		CreditBureauTTL:   getDuration("CREDIT_BUREAU_TTL", 24*time.Hour),
+
+		RecipientNameVerifyThreshold: getFloat("RECIPIENT_NAME_VERIFY_THRESHOLD", 0.85),
+		RecipientNameRejectThreshold: getFloat("RECIPIENT_NAME_REJECT_THRESHOLD", 0.5),
+		RecipientSyncInterval:        getDuration("RECIPIENT_SYNC_INTERVAL", 6*time.Hour),
+
+		CustomerSyncInterval: getDuration("CUSTOMER_SYNC_INTERVAL", time.Hour),
+		LedgerSyncInterval:   getDuration("LEDGER_SYNC_INTERVAL", 15*time.Minute),
+		ReconcileInterval:    getDuration("RECONCILE_INTERVAL", time.Hour),
+
+		SnapshotCacheTTL: getDuration("SNAPSHOT_CACHE_TTL", 30*time.Second),
+
+		BankDirectoryTTL:             getDuration("BANK_DIRECTORY_TTL", 24*time.Hour),
+		BankDirectoryCountries:       getList("BANK_DIRECTORY_COUNTRIES", []string{"nigeria"}),
+		BankDirectoryRefreshInterval: getDuration("BANK_DIRECTORY_REFRESH_INTERVAL", time.Hour),
+
+		InvoicePollInterval:   getDuration("INVOICE_POLL_INTERVAL", 5*time.Minute),
+		InvoicePollMaxBackoff: getDuration("INVOICE_POLL_MAX_BACKOFF", 24*time.Hour),
+
+		BusinessName:        getString("BUSINESS_NAME", "Moniewave"),
+		BusinessAddress:     os.Getenv("BUSINESS_ADDRESS"),
+		BusinessEmail:       os.Getenv("BUSINESS_EMAIL"),
+		BusinessPhone:       os.Getenv("BUSINESS_PHONE"),
+		InvoiceFooter:       os.Getenv("INVOICE_FOOTER"),
+		InvoiceTemplateFile: os.Getenv("INVOICE_TEMPLATE_FILE"),
+
+		DunningInterval: getDuration("DUNNING_INTERVAL", time.Hour),
+		DunningNotifier: getString("DUNNING_NOTIFIER", "log"),
+		SMTPHost:        os.Getenv("SMTP_HOST"),
+		SMTPPort:        getString("SMTP_PORT", "587"),
+		SMTPUsername:    os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:    os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:        os.Getenv("SMTP_FROM"),
+	}
+}
+
+// getString reads a string from the environment, falling back to the default
+// when unset
+func getString(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getDuration reads a duration (e.g. "24h", "15m") from the environment,
+// falling back to the default when unset or invalid
+func getDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid %s %q, using default %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+// getFloat reads a float from the environment, falling back to the default
+// when unset or invalid
+func getFloat(key string, fallback float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("Invalid %s %q, using default %v", key, value, fallback)
+		return fallback
+	}
+	return f
+}
+
+// getList reads a comma-separated list from the environment, falling back to
+// the default when unset
+func getList(key string, fallback []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
 }
@@ -309,6 +309,939 @@ func runMigrations() error {
 
 	log.Println("Budget limit indexes created successfully")
 
+	// Create credit_reports table (cache of external credit bureau reports)
+	createCreditReportsTable := `
+	CREATE TABLE IF NOT EXISTS credit_reports (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		email TEXT NOT NULL UNIQUE,
+		provider TEXT NOT NULL,
+		credit_score INTEGER NOT NULL,
+		total_debt INTEGER NOT NULL,
+		payment_history_score INTEGER NOT NULL,
+		account_age_months INTEGER NOT NULL,
+		raw_report TEXT NOT NULL,
+		reported_at DATETIME,
+		fetched_at DATETIME NOT NULL,
+		expires_at DATETIME NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := DB.Exec(createCreditReportsTable); err != nil {
+		return err
+	}
+
+	log.Println("Credit reports table created successfully")
+
+	// Track where each credit profile's bureau fields came from
+	addProfileSourceColumn := `ALTER TABLE credit_profiles ADD COLUMN source TEXT DEFAULT 'local';`
+	addProfileRefreshedColumn := `ALTER TABLE credit_profiles ADD COLUMN bureau_refreshed_at DATETIME;`
+
+	// Try to add columns (will fail silently if already exists)
+	DB.Exec(addProfileSourceColumn)
+	DB.Exec(addProfileRefreshedColumn)
+
+	log.Println("Credit profile bureau columns added successfully")
+
+	// Add bank account verification columns to recipients table
+	addResolvedNameColumn := `ALTER TABLE recipients ADD COLUMN resolved_account_name TEXT;`
+	addVerificationStatusColumn := `ALTER TABLE recipients ADD COLUMN verification_status TEXT DEFAULT 'unverified';`
+	addNameMatchScoreColumn := `ALTER TABLE recipients ADD COLUMN name_match_score REAL;`
+	addVerifiedAtColumn := `ALTER TABLE recipients ADD COLUMN verified_at DATETIME;`
+
+	// Try to add columns (will fail silently if already exists)
+	DB.Exec(addResolvedNameColumn)
+	DB.Exec(addVerificationStatusColumn)
+	DB.Exec(addNameMatchScoreColumn)
+	DB.Exec(addVerifiedAtColumn)
+
+	log.Println("Recipient verification columns added successfully")
+
+	// Add Paystack sync columns to recipients table
+	addRecipientOriginColumn := `ALTER TABLE recipients ADD COLUMN origin TEXT DEFAULT 'paystack';`
+	addRecipientDeletedColumn := `ALTER TABLE recipients ADD COLUMN deleted_upstream INTEGER DEFAULT 0;`
+	addRecipientSyncedColumn := `ALTER TABLE recipients ADD COLUMN last_synced_at DATETIME;`
+
+	// Try to add columns (will fail silently if already exists)
+	DB.Exec(addRecipientOriginColumn)
+	DB.Exec(addRecipientDeletedColumn)
+	DB.Exec(addRecipientSyncedColumn)
+
+	// The default service provider recipient only exists locally
+	markLocalRecipient := `UPDATE recipients SET origin = 'local' WHERE recipient_code = 'RCP_serviceprovider';`
+	if _, err := DB.Exec(markLocalRecipient); err != nil {
+		return err
+	}
+
+	log.Println("Recipient sync columns added successfully")
+
+	// Add contact and lifecycle columns to recipients table
+	addRecipientEmailColumn := `ALTER TABLE recipients ADD COLUMN email TEXT;`
+	addRecipientMetadataColumn := `ALTER TABLE recipients ADD COLUMN metadata TEXT;`
+	addRecipientActiveColumn := `ALTER TABLE recipients ADD COLUMN active INTEGER DEFAULT 1;`
+	addRecipientDeactivatedColumn := `ALTER TABLE recipients ADD COLUMN deactivated_at DATETIME;`
+
+	// Try to add columns (will fail silently if already exists)
+	DB.Exec(addRecipientEmailColumn)
+	DB.Exec(addRecipientMetadataColumn)
+	DB.Exec(addRecipientActiveColumn)
+	DB.Exec(addRecipientDeactivatedColumn)
+
+	log.Println("Recipient lifecycle columns added successfully")
+
+	// Create sync_runs table (history of background and on-demand sync jobs)
+	createSyncRunsTable := `
+	CREATE TABLE IF NOT EXISTS sync_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		job TEXT NOT NULL,
+		status TEXT NOT NULL,
+		summary TEXT,
+		error TEXT,
+		started_at DATETIME NOT NULL,
+		finished_at DATETIME
+	);`
+
+	if _, err := DB.Exec(createSyncRunsTable); err != nil {
+		return err
+	}
+
+	createSyncRunsJobIndex := `CREATE INDEX IF NOT EXISTS idx_sync_runs_job ON sync_runs(job, started_at);`
+	if _, err := DB.Exec(createSyncRunsJobIndex); err != nil {
+		return err
+	}
+
+	log.Println("Sync runs table created successfully")
+
+	// Create beneficiary_limits table (per-recipient daily/weekly/monthly caps)
+	createBeneficiaryLimitsTable := `
+	CREATE TABLE IF NOT EXISTS beneficiary_limits (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		recipient_code TEXT NOT NULL UNIQUE,
+		daily_limit INTEGER,
+		weekly_limit INTEGER,
+		monthly_limit INTEGER,
+		notes TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (recipient_code) REFERENCES recipients(recipient_code)
+	);`
+
+	if _, err := DB.Exec(createBeneficiaryLimitsTable); err != nil {
+		return err
+	}
+
+	log.Println("Beneficiary limits table created successfully")
+
+	// Create transfers table (local record of transfers initiated through this server)
+	createTransfersTable := `
+	CREATE TABLE IF NOT EXISTS transfers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		transfer_code TEXT UNIQUE,
+		reference TEXT UNIQUE,
+		recipient_code TEXT NOT NULL,
+		amount INTEGER NOT NULL,
+		currency TEXT DEFAULT 'NGN',
+		source TEXT,
+		reason TEXT,
+		status TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := DB.Exec(createTransfersTable); err != nil {
+		return err
+	}
+
+	createTransferRecipientIndex := `CREATE INDEX IF NOT EXISTS idx_transfers_recipient ON transfers(recipient_code, created_at);`
+	if _, err := DB.Exec(createTransferRecipientIndex); err != nil {
+		return err
+	}
+
+	log.Println("Transfers table created successfully")
+
+	// Create account_limits table (account-wide outflow caps with effective dates)
+	createAccountLimitsTable := `
+	CREATE TABLE IF NOT EXISTS account_limits (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		limit_type TEXT NOT NULL,
+		amount INTEGER NOT NULL,
+		currency TEXT DEFAULT 'NGN',
+		effective_from DATETIME NOT NULL,
+		notes TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := DB.Exec(createAccountLimitsTable); err != nil {
+		return err
+	}
+
+	createAccountLimitsIndex := `CREATE INDEX IF NOT EXISTS idx_account_limits_type ON account_limits(limit_type, currency, effective_from);`
+	if _, err := DB.Exec(createAccountLimitsIndex); err != nil {
+		return err
+	}
+
+	log.Println("Account limits table created successfully")
+
+	// Create banks table (cached Paystack bank directory)
+	createBanksTable := `
+	CREATE TABLE IF NOT EXISTS banks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		paystack_id INTEGER NOT NULL UNIQUE,
+		name TEXT NOT NULL,
+		slug TEXT,
+		code TEXT NOT NULL,
+		long_code TEXT,
+		gateway TEXT,
+		country TEXT,
+		currency TEXT,
+		type TEXT,
+		pay_with_bank INTEGER DEFAULT 0,
+		supports_transfer INTEGER DEFAULT 1,
+		active INTEGER DEFAULT 1,
+		fetched_at DATETIME NOT NULL
+	);`
+
+	if _, err := DB.Exec(createBanksTable); err != nil {
+		return err
+	}
+
+	createBanksCodeIndex := `CREATE INDEX IF NOT EXISTS idx_banks_code ON banks(code, currency);`
+	if _, err := DB.Exec(createBanksCodeIndex); err != nil {
+		return err
+	}
+
+	log.Println("Banks table created successfully")
+
+	// Add payment tracking and polling columns to invoices table
+	addInvoicePaidAmountColumn := `ALTER TABLE invoices ADD COLUMN paid_amount INTEGER DEFAULT 0;`
+	addInvoicePaidAtColumn := `ALTER TABLE invoices ADD COLUMN paid_at DATETIME;`
+	addInvoiceLastCheckedColumn := `ALTER TABLE invoices ADD COLUMN last_checked_at DATETIME;`
+	addInvoiceNextCheckColumn := `ALTER TABLE invoices ADD COLUMN next_check_at DATETIME;`
+	addInvoicePollAttemptsColumn := `ALTER TABLE invoices ADD COLUMN poll_attempts INTEGER DEFAULT 0;`
+
+	// Try to add columns (will fail silently if already exists)
+	DB.Exec(addInvoicePaidAmountColumn)
+	DB.Exec(addInvoicePaidAtColumn)
+	DB.Exec(addInvoiceLastCheckedColumn)
+	DB.Exec(addInvoiceNextCheckColumn)
+	DB.Exec(addInvoicePollAttemptsColumn)
+
+	log.Println("Invoice polling columns added successfully")
+
+	// Create invoice_events table (status change history)
+	createInvoiceEventsTable := `
+	CREATE TABLE IF NOT EXISTS invoice_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		invoice_code TEXT NOT NULL,
+		event_type TEXT NOT NULL,
+		from_status TEXT,
+		to_status TEXT,
+		paid_amount INTEGER,
+		source TEXT NOT NULL,
+		details TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (invoice_code) REFERENCES invoices(invoice_code)
+	);`
+
+	if _, err := DB.Exec(createInvoiceEventsTable); err != nil {
+		return err
+	}
+
+	createInvoiceEventsIndex := `CREATE INDEX IF NOT EXISTS idx_invoice_events_code ON invoice_events(invoice_code, created_at);`
+	if _, err := DB.Exec(createInvoiceEventsIndex); err != nil {
+		return err
+	}
+
+	log.Println("Invoice events table created successfully")
+
+	// Add document columns to invoices table (used for local rendering)
+	addInvoiceDescriptionColumn := `ALTER TABLE invoices ADD COLUMN description TEXT;`
+	addInvoiceCurrencyColumn := `ALTER TABLE invoices ADD COLUMN currency TEXT DEFAULT 'NGN';`
+	addInvoiceDueDateColumn := `ALTER TABLE invoices ADD COLUMN due_date DATETIME;`
+
+	// Try to add columns (will fail silently if already exists)
+	DB.Exec(addInvoiceDescriptionColumn)
+	DB.Exec(addInvoiceCurrencyColumn)
+	DB.Exec(addInvoiceDueDateColumn)
+
+	log.Println("Invoice document columns added successfully")
+
+	// Create invoice_line_items table
+	createInvoiceLineItemsTable := `
+	CREATE TABLE IF NOT EXISTS invoice_line_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		invoice_code TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		quantity INTEGER NOT NULL DEFAULT 1,
+		unit_amount INTEGER NOT NULL,
+		amount INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (invoice_code) REFERENCES invoices(invoice_code),
+		UNIQUE(invoice_code, position)
+	);`
+
+	if _, err := DB.Exec(createInvoiceLineItemsTable); err != nil {
+		return err
+	}
+
+	log.Println("Invoice line items table created successfully")
+
+	// Create dunning_policies table (invoice reminder schedules)
+	createDunningPoliciesTable := `
+	CREATE TABLE IF NOT EXISTS dunning_policies (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		offsets TEXT NOT NULL,
+		repeat_every_days INTEGER DEFAULT 0,
+		max_repeats INTEGER DEFAULT 0,
+		is_default INTEGER DEFAULT 0,
+		active INTEGER DEFAULT 1,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := DB.Exec(createDunningPoliciesTable); err != nil {
+		return err
+	}
+
+	// Seed the standard policy: 3 days before due, on the due date, then weekly
+	seedDunningPolicy := `
+	INSERT OR IGNORE INTO dunning_policies (name, offsets, repeat_every_days, is_default)
+	VALUES ('standard', '[-3,0]', 7, 1);`
+
+	if _, err := DB.Exec(seedDunningPolicy); err != nil {
+		return err
+	}
+
+	log.Println("Dunning policies table created successfully")
+
+	// Add dunning columns to invoices table
+	addInvoiceCustomerEmailColumn := `ALTER TABLE invoices ADD COLUMN customer_email TEXT;`
+	addInvoiceDunningPolicyColumn := `ALTER TABLE invoices ADD COLUMN dunning_policy_id INTEGER REFERENCES dunning_policies(id);`
+	addInvoiceDunningPausedColumn := `ALTER TABLE invoices ADD COLUMN dunning_paused INTEGER DEFAULT 0;`
+
+	// Try to add columns (will fail silently if already exists)
+	DB.Exec(addInvoiceCustomerEmailColumn)
+	DB.Exec(addInvoiceDunningPolicyColumn)
+	DB.Exec(addInvoiceDunningPausedColumn)
+
+	log.Println("Invoice dunning columns added successfully")
+
+	// Create invoice_reminders table (every reminder attempted)
+	createInvoiceRemindersTable := `
+	CREATE TABLE IF NOT EXISTS invoice_reminders (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		invoice_code TEXT NOT NULL,
+		policy_id INTEGER,
+		offset_days INTEGER NOT NULL,
+		stage TEXT NOT NULL,
+		channel TEXT NOT NULL,
+		status TEXT NOT NULL,
+		error TEXT,
+		scheduled_for DATETIME NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (invoice_code) REFERENCES invoices(invoice_code),
+		FOREIGN KEY (policy_id) REFERENCES dunning_policies(id)
+	);`
+
+	if _, err := DB.Exec(createInvoiceRemindersTable); err != nil {
+		return err
+	}
+
+	// A reminder step is only ever sent once per invoice
+	createInvoiceRemindersIndex := `CREATE UNIQUE INDEX IF NOT EXISTS idx_invoice_reminders_sent ON invoice_reminders(invoice_code, offset_days) WHERE status = 'sent';`
+	if _, err := DB.Exec(createInvoiceRemindersIndex); err != nil {
+		return err
+	}
+
+	log.Println("Invoice reminders table created successfully")
+
+	// Create invoice_payments table (payments ledger per invoice)
+	createInvoicePaymentsTable := `
+	CREATE TABLE IF NOT EXISTS invoice_payments (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		invoice_code TEXT NOT NULL,
+		source TEXT NOT NULL,
+		amount INTEGER NOT NULL,
+		reference TEXT NOT NULL UNIQUE,
+		method TEXT,
+		notes TEXT,
+		paid_at DATETIME NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (invoice_code) REFERENCES invoices(invoice_code)
+	);`
+
+	if _, err := DB.Exec(createInvoicePaymentsTable); err != nil {
+		return err
+	}
+
+	createInvoicePaymentsIndex := `CREATE INDEX IF NOT EXISTS idx_invoice_payments_code ON invoice_payments(invoice_code, paid_at);`
+	if _, err := DB.Exec(createInvoicePaymentsIndex); err != nil {
+		return err
+	}
+
+	// Invoices settled before the ledger existed kept Paystack's "success" (or
+	// "paid") status with no paid amount; treat them as paid in full so
+	// receivables and statements do not report them as outstanding
+	settleLegacyPaidInvoices := `
+	UPDATE invoices
+	SET paid_amount = amount, status = 'paid'
+	WHERE status IN ('success', 'paid')
+	AND COALESCE(paid_amount, 0) < amount
+	AND invoice_code NOT IN (SELECT invoice_code FROM invoice_payments);`
+
+	if _, err := DB.Exec(settleLegacyPaidInvoices); err != nil {
+		return err
+	}
+
+	// Backfill the ledger from paid amounts cached before it existed
+	backfillInvoicePayments := `
+	INSERT OR IGNORE INTO invoice_payments (invoice_code, source, amount, reference, paid_at)
+	SELECT invoice_code, 'paystack', paid_amount, invoice_code || '-' || paid_amount, COALESCE(paid_at, updated_at)
+	FROM invoices
+	WHERE COALESCE(paid_amount, 0) > 0
+	AND invoice_code NOT IN (SELECT invoice_code FROM invoice_payments);`
+
+	if _, err := DB.Exec(backfillInvoicePayments); err != nil {
+		return err
+	}
+
+	log.Println("Invoice payments table created successfully")
+
+	// Create number_sequences table (document numbering, e.g. credit notes)
+	createNumberSequencesTable := `
+	CREATE TABLE IF NOT EXISTS number_sequences (
+		name TEXT PRIMARY KEY,
+		prefix TEXT NOT NULL DEFAULT '',
+		padding INTEGER NOT NULL DEFAULT 6,
+		last_value INTEGER NOT NULL DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := DB.Exec(createNumberSequencesTable); err != nil {
+		return err
+	}
+
+	insertCreditNoteSequence := `
+	INSERT OR IGNORE INTO number_sequences (name, prefix, padding)
+	VALUES ('credit_note', 'CN-', 6);`
+
+	if _, err := DB.Exec(insertCreditNoteSequence); err != nil {
+		return err
+	}
+
+	log.Println("Number sequences table created successfully")
+
+	// Create credit_notes table (adjustments that reduce an invoice balance)
+	createCreditNotesTable := `
+	CREATE TABLE IF NOT EXISTS credit_notes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		credit_note_number TEXT NOT NULL UNIQUE,
+		invoice_code TEXT NOT NULL,
+		amount INTEGER NOT NULL,
+		currency TEXT DEFAULT 'NGN',
+		reason TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (invoice_code) REFERENCES invoices(invoice_code)
+	);`
+
+	if _, err := DB.Exec(createCreditNotesTable); err != nil {
+		return err
+	}
+
+	createCreditNotesIndex := `CREATE INDEX IF NOT EXISTS idx_credit_notes_invoice ON credit_notes(invoice_code);`
+	if _, err := DB.Exec(createCreditNotesIndex); err != nil {
+		return err
+	}
+
+	// Try to add column (will fail silently if already exists)
+	addInvoiceCreditedAmountColumn := `ALTER TABLE invoices ADD COLUMN credited_amount INTEGER DEFAULT 0;`
+	DB.Exec(addInvoiceCreditedAmountColumn)
+
+	log.Println("Credit notes table created successfully")
+
+	// Create quotes tables (estimates that convert to invoices)
+	createQuotesTable := `
+	CREATE TABLE IF NOT EXISTS quotes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		quote_number TEXT NOT NULL UNIQUE,
+		customer_id TEXT NOT NULL,
+		customer_name TEXT NOT NULL,
+		customer_email TEXT,
+		amount INTEGER NOT NULL,
+		currency TEXT DEFAULT 'NGN',
+		description TEXT,
+		notes TEXT,
+		valid_until DATETIME NOT NULL,
+		status TEXT NOT NULL DEFAULT 'draft',
+		invoice_code TEXT,
+		decline_reason TEXT,
+		sent_at DATETIME,
+		accepted_at DATETIME,
+		declined_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (invoice_code) REFERENCES invoices(invoice_code)
+	);`
+
+	if _, err := DB.Exec(createQuotesTable); err != nil {
+		return err
+	}
+
+	createQuoteLineItemsTable := `
+	CREATE TABLE IF NOT EXISTS quote_line_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		quote_id INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		quantity INTEGER NOT NULL DEFAULT 1,
+		unit_amount INTEGER NOT NULL,
+		amount INTEGER NOT NULL,
+		FOREIGN KEY (quote_id) REFERENCES quotes(id),
+		UNIQUE(quote_id, position)
+	);`
+
+	if _, err := DB.Exec(createQuoteLineItemsTable); err != nil {
+		return err
+	}
+
+	createQuotesIndex := `CREATE INDEX IF NOT EXISTS idx_quotes_customer ON quotes(customer_id, status);`
+	if _, err := DB.Exec(createQuotesIndex); err != nil {
+		return err
+	}
+
+	insertQuoteSequence := `
+	INSERT OR IGNORE INTO number_sequences (name, prefix, padding)
+	VALUES ('quote', 'QT-', 6);`
+
+	if _, err := DB.Exec(insertQuoteSequence); err != nil {
+		return err
+	}
+
+	// Try to add column (will fail silently if already exists)
+	addInvoiceQuoteNumberColumn := `ALTER TABLE invoices ADD COLUMN quote_number TEXT;`
+	DB.Exec(addInvoiceQuoteNumberColumn)
+
+	log.Println("Quotes tables created successfully")
+
+	// Try to add columns (will fail silently if already exists)
+	addSequenceResetYearlyColumn := `ALTER TABLE number_sequences ADD COLUMN reset_yearly INTEGER DEFAULT 0;`
+	DB.Exec(addSequenceResetYearlyColumn)
+
+	addSequenceCurrentYearColumn := `ALTER TABLE number_sequences ADD COLUMN current_year INTEGER;`
+	DB.Exec(addSequenceCurrentYearColumn)
+
+	insertInvoiceSequence := `
+	INSERT OR IGNORE INTO number_sequences (name, prefix, padding, reset_yearly)
+	VALUES ('invoice', 'INV-{year}-', 5, 1);`
+
+	if _, err := DB.Exec(insertInvoiceSequence); err != nil {
+		return err
+	}
+
+	// Try to add columns (will fail silently if already exists)
+	addInvoiceNumberColumn := `ALTER TABLE invoices ADD COLUMN invoice_number TEXT;`
+	DB.Exec(addInvoiceNumberColumn)
+
+	addInvoiceNotesColumn := `ALTER TABLE invoices ADD COLUMN notes TEXT;`
+	DB.Exec(addInvoiceNotesColumn)
+
+	addInvoiceTemplateColumn := `ALTER TABLE invoices ADD COLUMN template_id INTEGER;`
+	DB.Exec(addInvoiceTemplateColumn)
+
+	createInvoiceNumberIndex := `CREATE UNIQUE INDEX IF NOT EXISTS idx_invoices_number ON invoices(invoice_number) WHERE invoice_number IS NOT NULL;`
+	if _, err := DB.Exec(createInvoiceNumberIndex); err != nil {
+		return err
+	}
+
+	log.Println("Invoice number sequence created successfully")
+
+	// Create invoice_templates table (reusable invoice defaults)
+	createInvoiceTemplatesTable := `
+	CREATE TABLE IF NOT EXISTS invoice_templates (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		description TEXT,
+		currency TEXT DEFAULT 'NGN',
+		due_in_days INTEGER DEFAULT 0,
+		notes TEXT,
+		line_items TEXT NOT NULL DEFAULT '[]',
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := DB.Exec(createInvoiceTemplatesTable); err != nil {
+		return err
+	}
+
+	log.Println("Invoice templates table created successfully")
+
+	// Create invoice batch tables (bulk invoicing and bill splitting)
+	createInvoiceBatchesTable := `
+	CREATE TABLE IF NOT EXISTS invoice_batches (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		batch_id TEXT NOT NULL UNIQUE,
+		description TEXT,
+		currency TEXT DEFAULT 'NGN',
+		split_method TEXT NOT NULL,
+		total_amount INTEGER NOT NULL,
+		status TEXT NOT NULL DEFAULT 'processing',
+		requested INTEGER NOT NULL DEFAULT 0,
+		succeeded INTEGER NOT NULL DEFAULT 0,
+		failed INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		completed_at DATETIME
+	);`
+
+	if _, err := DB.Exec(createInvoiceBatchesTable); err != nil {
+		return err
+	}
+
+	createInvoiceBatchItemsTable := `
+	CREATE TABLE IF NOT EXISTS invoice_batch_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		batch_id TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		customer_id TEXT NOT NULL,
+		amount INTEGER NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		invoice_code TEXT,
+		invoice_number TEXT,
+		error TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (batch_id) REFERENCES invoice_batches(batch_id),
+		UNIQUE(batch_id, position)
+	);`
+
+	if _, err := DB.Exec(createInvoiceBatchItemsTable); err != nil {
+		return err
+	}
+
+	insertInvoiceBatchSequence := `
+	INSERT OR IGNORE INTO number_sequences (name, prefix, padding)
+	VALUES ('invoice_batch', 'BATCH-', 6);`
+
+	if _, err := DB.Exec(insertInvoiceBatchSequence); err != nil {
+		return err
+	}
+
+	// Try to add column (will fail silently if already exists)
+	addInvoiceBatchColumn := `ALTER TABLE invoices ADD COLUMN batch_id TEXT;`
+	DB.Exec(addInvoiceBatchColumn)
+
+	log.Println("Invoice batch tables created successfully")
+
+	// Create sync_cursors table (where incremental sync jobs resume from)
+	createSyncCursorsTable := `
+	CREATE TABLE IF NOT EXISTS sync_cursors (
+		job TEXT PRIMARY KEY,
+		cursor TEXT NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := DB.Exec(createSyncCursorsTable); err != nil {
+		return err
+	}
+
+	log.Println("Sync cursors table created successfully")
+
+	// Create customers table (local mirror of Paystack customers)
+	createCustomersTable := `
+	CREATE TABLE IF NOT EXISTS customers (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		customer_code TEXT NOT NULL UNIQUE,
+		paystack_id INTEGER,
+		email TEXT,
+		first_name TEXT,
+		last_name TEXT,
+		phone TEXT,
+		risk_action TEXT,
+		notes TEXT,
+		upstream_created_at DATETIME,
+		upstream_updated_at DATETIME,
+		last_synced_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := DB.Exec(createCustomersTable); err != nil {
+		return err
+	}
+
+	createCustomersEmailIndex := `CREATE INDEX IF NOT EXISTS idx_customers_email ON customers(email);`
+	if _, err := DB.Exec(createCustomersEmailIndex); err != nil {
+		return err
+	}
+
+	createCustomerTagsTable := `
+	CREATE TABLE IF NOT EXISTS customer_tags (
+		customer_code TEXT NOT NULL,
+		tag TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (customer_code, tag),
+		FOREIGN KEY (customer_code) REFERENCES customers(customer_code)
+	);`
+
+	if _, err := DB.Exec(createCustomerTagsTable); err != nil {
+		return err
+	}
+
+	createCustomerTagsIndex := `CREATE INDEX IF NOT EXISTS idx_customer_tags_tag ON customer_tags(tag);`
+	if _, err := DB.Exec(createCustomerTagsIndex); err != nil {
+		return err
+	}
+
+	// Create customers_fts (FTS4 index for customer search, keyed by customers.id)
+	createCustomersFTSTable := `
+	CREATE VIRTUAL TABLE IF NOT EXISTS customers_fts USING fts4(
+		name, email, phone, customer_code, tokenize=unicode61
+	);`
+
+	if _, err := DB.Exec(createCustomersFTSTable); err != nil {
+		return err
+	}
+
+	// Triggers keep the index in step with every write to customers
+	createCustomersFTSTriggers := []string{
+		`CREATE TRIGGER IF NOT EXISTS customers_fts_insert AFTER INSERT ON customers BEGIN
+			INSERT INTO customers_fts (rowid, name, email, phone, customer_code)
+			VALUES (new.id, COALESCE(new.first_name, '') || ' ' || COALESCE(new.last_name, ''),
+				COALESCE(new.email, ''), COALESCE(new.phone, ''), new.customer_code);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS customers_fts_update AFTER UPDATE ON customers BEGIN
+			DELETE FROM customers_fts WHERE rowid = old.id;
+			INSERT INTO customers_fts (rowid, name, email, phone, customer_code)
+			VALUES (new.id, COALESCE(new.first_name, '') || ' ' || COALESCE(new.last_name, ''),
+				COALESCE(new.email, ''), COALESCE(new.phone, ''), new.customer_code);
+		END;`,
+		`CREATE TRIGGER IF NOT EXISTS customers_fts_delete AFTER DELETE ON customers BEGIN
+			DELETE FROM customers_fts WHERE rowid = old.id;
+		END;`,
+	}
+
+	for _, trigger := range createCustomersFTSTriggers {
+		if _, err := DB.Exec(trigger); err != nil {
+			return err
+		}
+	}
+
+	// Index customers cached before the FTS table existed
+	backfillCustomersFTS := `
+	INSERT INTO customers_fts (rowid, name, email, phone, customer_code)
+	SELECT id, COALESCE(first_name, '') || ' ' || COALESCE(last_name, ''),
+		COALESCE(email, ''), COALESCE(phone, ''), customer_code
+	FROM customers
+	WHERE id NOT IN (SELECT rowid FROM customers_fts);`
+
+	if _, err := DB.Exec(backfillCustomersFTS); err != nil {
+		return err
+	}
+
+	log.Println("Customers table created successfully")
+
+	// Create transactions table (local ledger of Paystack charges and transfers)
+	createTransactionsTable := `
+	CREATE TABLE IF NOT EXISTS transactions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		type TEXT NOT NULL,
+		reference TEXT NOT NULL,
+		paystack_id INTEGER,
+		amount INTEGER NOT NULL,
+		fees INTEGER DEFAULT 0,
+		currency TEXT DEFAULT 'NGN',
+		status TEXT,
+		channel TEXT,
+		customer_code TEXT,
+		customer_email TEXT,
+		recipient_code TEXT,
+		recipient_name TEXT,
+		description TEXT,
+		metadata TEXT,
+		occurred_at DATETIME NOT NULL,
+		upstream_created_at DATETIME,
+		upstream_updated_at DATETIME,
+		synced_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (type, reference)
+	);`
+
+	if _, err := DB.Exec(createTransactionsTable); err != nil {
+		return err
+	}
+
+	createTransactionsOccurredIndex := `CREATE INDEX IF NOT EXISTS idx_transactions_occurred ON transactions(occurred_at);`
+	createTransactionsCustomerIndex := `CREATE INDEX IF NOT EXISTS idx_transactions_customer ON transactions(customer_email, occurred_at);`
+	createTransactionsStatusIndex := `CREATE INDEX IF NOT EXISTS idx_transactions_type_status ON transactions(type, status);`
+
+	if _, err := DB.Exec(createTransactionsOccurredIndex); err != nil {
+		return err
+	}
+
+	if _, err := DB.Exec(createTransactionsCustomerIndex); err != nil {
+		return err
+	}
+
+	if _, err := DB.Exec(createTransactionsStatusIndex); err != nil {
+		return err
+	}
+
+	log.Println("Transactions table created successfully")
+
+	// Bank statement imports: expenses that did not go through Paystack
+	addSourceColumnToExpenses := `ALTER TABLE expenses ADD COLUMN source TEXT DEFAULT 'manual';`
+	addImportColumnToExpenses := `ALTER TABLE expenses ADD COLUMN import_id INTEGER;`
+	addImportHashColumnToExpenses := `ALTER TABLE expenses ADD COLUMN import_hash TEXT;`
+
+	// Try to add columns (will fail silently if already exists)
+	DB.Exec(addSourceColumnToExpenses)
+	DB.Exec(addImportColumnToExpenses)
+	DB.Exec(addImportHashColumnToExpenses)
+
+	createExpensesImportHashIndex := `CREATE UNIQUE INDEX IF NOT EXISTS idx_expenses_import_hash ON expenses(import_hash) WHERE import_hash IS NOT NULL;`
+	if _, err := DB.Exec(createExpensesImportHashIndex); err != nil {
+		return err
+	}
+
+	createBankImportsTable := `
+	CREATE TABLE IF NOT EXISTS bank_imports (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		filename TEXT,
+		format TEXT NOT NULL,
+		account TEXT NOT NULL,
+		currency TEXT DEFAULT 'NGN',
+		status TEXT DEFAULT 'preview',
+		line_count INTEGER DEFAULT 0,
+		imported_count INTEGER DEFAULT 0,
+		imported_amount INTEGER DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		committed_at DATETIME,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := DB.Exec(createBankImportsTable); err != nil {
+		return err
+	}
+
+	createBankImportLinesTable := `
+	CREATE TABLE IF NOT EXISTS bank_import_lines (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		import_id INTEGER NOT NULL,
+		line_no INTEGER NOT NULL,
+		hash TEXT NOT NULL,
+		posted_at DATETIME NOT NULL,
+		amount INTEGER NOT NULL,
+		narration TEXT,
+		counterparty TEXT,
+		reference TEXT,
+		external_id TEXT,
+		category TEXT,
+		budget_limit_id INTEGER,
+		overridden BOOLEAN DEFAULT 0,
+		skip BOOLEAN DEFAULT 0,
+		expense_id INTEGER,
+		UNIQUE (import_id, line_no),
+		FOREIGN KEY (import_id) REFERENCES bank_imports(id)
+	);`
+
+	if _, err := DB.Exec(createBankImportLinesTable); err != nil {
+		return err
+	}
+
+	createImportRulesTable := `
+	CREATE TABLE IF NOT EXISTS import_rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		priority INTEGER DEFAULT 100,
+		narration_contains TEXT,
+		narration_regex TEXT,
+		counterparty_contains TEXT,
+		min_amount INTEGER,
+		max_amount INTEGER,
+		category TEXT,
+		budget_limit_id INTEGER,
+		active BOOLEAN DEFAULT 1,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := DB.Exec(createImportRulesTable); err != nil {
+		return err
+	}
+
+	log.Println("Bank import tables created successfully")
+
+	// Reconciliation of local records against the Paystack ledger
+	createReconciliationRunsTable := `
+	CREATE TABLE IF NOT EXISTS reconciliation_runs (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		triggered_by TEXT NOT NULL,
+		period_from DATETIME NOT NULL,
+		period_to DATETIME NOT NULL,
+		status TEXT NOT NULL,
+		summary TEXT,
+		started_at DATETIME NOT NULL,
+		finished_at DATETIME
+	);`
+
+	if _, err := DB.Exec(createReconciliationRunsTable); err != nil {
+		return err
+	}
+
+	createReconciliationItemsTable := `
+	CREATE TABLE IF NOT EXISTS reconciliation_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		run_id INTEGER NOT NULL,
+		kind TEXT NOT NULL,
+		local_type TEXT,
+		local_id INTEGER,
+		local_reference TEXT,
+		local_amount INTEGER,
+		local_status TEXT,
+		local_date DATETIME,
+		ledger_id INTEGER,
+		paystack_type TEXT,
+		paystack_reference TEXT,
+		paystack_amount INTEGER,
+		paystack_status TEXT,
+		paystack_date DATETIME,
+		currency TEXT,
+		match_method TEXT,
+		details TEXT,
+		FOREIGN KEY (run_id) REFERENCES reconciliation_runs(id)
+	);`
+
+	if _, err := DB.Exec(createReconciliationItemsTable); err != nil {
+		return err
+	}
+
+	createReconciliationItemsIndex := `CREATE INDEX IF NOT EXISTS idx_reconciliation_items_run ON reconciliation_items(run_id, kind);`
+	if _, err := DB.Exec(createReconciliationItemsIndex); err != nil {
+		return err
+	}
+
+	createReconciliationOverridesTable := `
+	CREATE TABLE IF NOT EXISTS reconciliation_overrides (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		local_type TEXT NOT NULL,
+		local_id INTEGER NOT NULL,
+		ledger_id INTEGER NOT NULL,
+		action TEXT NOT NULL,
+		note TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		UNIQUE (local_type, local_id, ledger_id),
+		FOREIGN KEY (ledger_id) REFERENCES transactions(id)
+	);`
+
+	if _, err := DB.Exec(createReconciliationOverridesTable); err != nil {
+		return err
+	}
+
+	log.Println("Reconciliation tables created successfully")
+
 	return nil
 }
 
@@ -0,0 +1,336 @@
+// Package document renders business documents (invoices, customer statements)
+// as HTML and PDF.
+//
+// Rendering is entirely offline: HTML comes from an html/template (a built-in
+// default, or a file configured by the operator) and PDF is drawn directly with
+// the standard Helvetica fonts, so no browser, font files or network access are
+// needed. Callers load the data; this package only lays it out.
+package document
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"paystack.mpc.proxy/internal/money"
+)
+
+//go:embed templates/invoice.html
+var defaultInvoiceTemplate string
+
+// Business holds the issuer details printed on every document
+type Business struct {
+	Name    string `json:"name"`
+	Address string `json:"address,omitempty"`
+	Email   string `json:"email,omitempty"`
+	Phone   string `json:"phone,omitempty"`
+	// Footer is printed at the bottom of every invoice (payment terms, bank details, ...)
+	Footer string `json:"footer,omitempty"`
+}
+
+// AddressLines splits the address on newlines for multi-line layouts
+func (b Business) AddressLines() []string {
+	return splitLines(b.Address)
+}
+
+// LineItem is one row of an invoice. Amounts are in kobo.
+type LineItem struct {
+	Name       string
+	Quantity   int
+	UnitAmount int
+	Amount     int
+}
+
+// Invoice is everything needed to render an invoice. Amounts are in kobo.
+type Invoice struct {
+	Number       string
+	Business     Business
+	CustomerName string
+	CustomerID   string
+	Description  string
+	Currency     string
+	IssuedAt     time.Time
+	DueDate      *time.Time
+	Status       string
+	PaidAt       *time.Time
+	LineItems    []LineItem
+	Subtotal     int
+	Total        int
+	PaidAmount   int
+	// Notes are free text printed under the totals (terms, thank-you, ...)
+	Notes string
+	// CreditedAmount is the total of credit notes issued against the invoice
+	CreditedAmount int
+	BalanceDue     int
+	GeneratedAt    time.Time
+}
+
+// PaymentStatus is the human-readable payment state shown on the document
+func (inv *Invoice) PaymentStatus() string {
+	switch strings.ToLower(inv.Status) {
+	case "cancelled", "void", "archived", "failed":
+		return "Cancelled"
+	case "success", "paid":
+		return "Paid"
+	case "credited":
+		return "Credited"
+	}
+
+	switch {
+	case inv.Total > 0 && inv.BalanceDue <= 0 && inv.PaidAmount == 0 && inv.CreditedAmount > 0:
+		return "Credited"
+	case inv.Total > 0 && inv.BalanceDue <= 0:
+		return "Paid"
+	case inv.PaidAmount > 0:
+		return "Partially paid"
+	case inv.DueDate != nil && inv.GeneratedAt.After(*inv.DueDate):
+		return "Overdue"
+	default:
+		return "Unpaid"
+	}
+}
+
+// Renderer renders invoices. When templatePath is set the HTML template is
+// re-read on every render so it can be edited while the server is running.
+type Renderer struct {
+	business     Business
+	templatePath string
+}
+
+// NewRenderer creates a renderer for the given issuer. An empty templatePath
+// uses the built-in HTML template.
+func NewRenderer(business Business, templatePath string) *Renderer {
+	return &Renderer{business: business, templatePath: templatePath}
+}
+
+// Business returns the issuer details used by this renderer
+func (r *Renderer) Business() Business {
+	return r.business
+}
+
+// HTML renders the invoice with the configured HTML template
+func (r *Renderer) HTML(w io.Writer, inv *Invoice) error {
+	source := defaultInvoiceTemplate
+	if r.templatePath != "" {
+		data, err := os.ReadFile(r.templatePath)
+		if err != nil {
+			return fmt.Errorf("failed to read invoice template: %w", err)
+		}
+		source = string(data)
+	}
+
+	tmpl, err := template.New("invoice").Funcs(template.FuncMap{
+		"money":    money.Format,
+		"date":     formatDate,
+		"subtract": func(a, b int) int { return a - b },
+	}).Parse(source)
+	if err != nil {
+		return fmt.Errorf("failed to parse invoice template: %w", err)
+	}
+
+	// Render to a buffer first so a template error never sends half a page
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, inv); err != nil {
+		return fmt.Errorf("failed to render invoice template: %w", err)
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// PDF renders the invoice as an A4 PDF
+func (r *Renderer) PDF(w io.Writer, inv *Invoice) error {
+	_, err := w.Write(invoicePDF(inv))
+	return err
+}
+
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2 Jan 2006")
+}
+
+func splitLines(s string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// PDF layout, in points
+const (
+	pdfMargin     = 50.0
+	pdfLineHeight = 14.0
+	pdfColQty     = 330.0
+	pdfColUnit    = 440.0
+)
+
+// invoicePDF lays out the invoice: issuer and invoice details, bill-to, the
+// line item table (repeating its header on each page), totals and footer
+func invoicePDF(inv *Invoice) []byte {
+	p := newPDFWriter()
+	right := pageWidth - pdfMargin
+	y := pageHeight - pdfMargin
+
+	// Issuer on the left, title and invoice details on the right
+	p.text(pdfMargin, y-4, 16, true, truncate(inv.Business.Name, 16, true, 280))
+	p.textRight(right, y-4, 22, true, "INVOICE")
+	y -= 24
+
+	leftY := y
+	for _, line := range inv.Business.AddressLines() {
+		p.text(pdfMargin, leftY, 9, false, truncate(line, 9, false, 280))
+		leftY -= 12
+	}
+	for _, line := range []string{inv.Business.Email, inv.Business.Phone} {
+		if line != "" {
+			p.text(pdfMargin, leftY, 9, false, line)
+			leftY -= 12
+		}
+	}
+
+	rightY := y
+	details := [][2]string{
+		{"Invoice", inv.Number},
+		{"Issued", formatDate(inv.IssuedAt)},
+	}
+	if inv.DueDate != nil {
+		details = append(details, [2]string{"Due", formatDate(*inv.DueDate)})
+	}
+	details = append(details, [2]string{"Status", inv.PaymentStatus()})
+	if inv.PaidAt != nil {
+		details = append(details, [2]string{"Paid on", formatDate(*inv.PaidAt)})
+	}
+	for _, d := range details {
+		p.textRight(right-110, rightY, 9, true, d[0])
+		p.textRight(right, rightY, 9, false, d[1])
+		rightY -= 12
+	}
+
+	y = min(leftY, rightY) - 16
+
+	// Bill to
+	p.text(pdfMargin, y, 9, true, "BILL TO")
+	y -= pdfLineHeight
+	p.text(pdfMargin, y, 11, false, truncate(inv.CustomerName, 11, false, right-pdfMargin))
+	y -= pdfLineHeight
+	if inv.CustomerID != "" && inv.CustomerID != inv.CustomerName {
+		p.text(pdfMargin, y, 9, false, inv.CustomerID)
+		y -= pdfLineHeight
+	}
+
+	if inv.Description != "" {
+		y -= 6
+		for _, line := range wrapText(inv.Description, 10, false, right-pdfMargin) {
+			p.text(pdfMargin, y, 10, false, line)
+			y -= pdfLineHeight
+		}
+	}
+	y -= 10
+
+	tableHeader := func() {
+		p.fillRect(pdfMargin, y-5, right-pdfMargin, 18, 0.92)
+		p.text(pdfMargin+6, y, 9, true, "Item")
+		p.textRight(pdfColQty, y, 9, true, "Qty")
+		p.textRight(pdfColUnit, y, 9, true, "Unit price")
+		p.textRight(right-6, y, 9, true, "Amount")
+		y -= 20
+	}
+	tableHeader()
+
+	for _, item := range inv.LineItems {
+		if y < pdfMargin+40 {
+			p.addPage()
+			y = pageHeight - pdfMargin
+			tableHeader()
+		}
+		p.text(pdfMargin+6, y, 10, false, truncate(item.Name, 10, false, pdfColQty-pdfMargin-50))
+		p.textRight(pdfColQty, y, 10, false, fmt.Sprintf("%d", item.Quantity))
+		p.textRight(pdfColUnit, y, 10, false, money.Format(item.UnitAmount, inv.Currency))
+		p.textRight(right-6, y, 10, false, money.Format(item.Amount, inv.Currency))
+		y -= 6
+		p.line(pdfMargin, y, right, y, 0.3)
+		y -= pdfLineHeight
+	}
+
+	// Totals block needs room for up to six rows
+	if y < pdfMargin+104 {
+		p.addPage()
+		y = pageHeight - pdfMargin
+	}
+	y -= 4
+	totals := [][2]string{{"Subtotal", money.Format(inv.Subtotal, inv.Currency)}}
+	if inv.Total != inv.Subtotal {
+		totals = append(totals, [2]string{"Adjustments", money.Format(inv.Total-inv.Subtotal, inv.Currency)})
+	}
+	totals = append(totals,
+		[2]string{"Total", money.Format(inv.Total, inv.Currency)},
+		[2]string{"Paid", money.Format(inv.PaidAmount, inv.Currency)},
+	)
+	if inv.CreditedAmount > 0 {
+		totals = append(totals, [2]string{"Credited", money.Format(inv.CreditedAmount, inv.Currency)})
+	}
+	for _, t := range totals {
+		p.textRight(pdfColUnit, y, 10, false, t[0])
+		p.textRight(right-6, y, 10, false, t[1])
+		y -= pdfLineHeight
+	}
+	p.line(pdfColUnit-80, y+8, right, y+8, 0.8)
+	y -= 4
+	p.textRight(pdfColUnit, y, 11, true, "Balance due")
+	p.textRight(right-6, y, 11, true, money.Format(inv.BalanceDue, inv.Currency))
+
+	if inv.Notes != "" {
+		y -= 2 * pdfLineHeight
+		for _, line := range wrapText(inv.Notes, 10, false, right-pdfMargin) {
+			if y < pdfMargin+40 {
+				p.addPage()
+				y = pageHeight - pdfMargin
+			}
+			p.text(pdfMargin, y, 10, false, line)
+			y -= pdfLineHeight
+		}
+	}
+
+	// Footer and generation stamp sit at the bottom of the last page
+	footer := splitLines(inv.Business.Footer)
+	footerY := pdfMargin + 12 + 11*float64(len(footer))
+	for _, line := range footer {
+		footerY -= 11
+		p.text(pdfMargin, footerY, 8, false, truncate(line, 8, false, right-pdfMargin))
+	}
+	p.text(pdfMargin, pdfMargin-10, 7, false, "Generated "+inv.GeneratedAt.Format("2 Jan 2006 15:04 MST"))
+
+	return p.bytes()
+}
+
+// wrapText breaks s into lines no wider than maxWidth, splitting on spaces
+func wrapText(s string, size float64, bold bool, maxWidth float64) []string {
+	lines := []string{}
+	for _, paragraph := range splitLines(s) {
+		current := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if current != "" {
+				candidate = current + " " + word
+			}
+			if current != "" && textWidth(candidate, size, bold) > maxWidth {
+				lines = append(lines, current)
+				candidate = word
+			}
+			current = candidate
+		}
+		if current != "" {
+			lines = append(lines, truncate(current, size, bold, maxWidth))
+		}
+	}
+	return lines
+}
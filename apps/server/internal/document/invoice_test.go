@@ -0,0 +1,105 @@
+package document
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleInvoice() *Invoice {
+	due := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	return &Invoice{
+		Number:       "PRQ_test123",
+		Business:     Business{Name: "Moniewave Ltd", Address: "1 Marina\nLagos", Footer: "Thank you (really)"},
+		CustomerName: "Ada Obi",
+		CustomerID:   "CUS_abc",
+		Currency:     "NGN",
+		IssuedAt:     time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+		DueDate:      &due,
+		LineItems: []LineItem{
+			{Name: "Consulting <day rate>", Quantity: 2, UnitAmount: 5000000, Amount: 10000000},
+			{Name: "Travel", Quantity: 1, UnitAmount: 1500050, Amount: 1500050},
+		},
+		Subtotal:    11500050,
+		Total:       11500050,
+		PaidAmount:  1500050,
+		BalanceDue:  10000000,
+		GeneratedAt: time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestPaymentStatus(t *testing.T) {
+	inv := sampleInvoice()
+	if got := inv.PaymentStatus(); got != "Partially paid" {
+		t.Errorf("Expected Partially paid, got %q", got)
+	}
+
+	inv.PaidAmount, inv.BalanceDue = 0, inv.Total
+	inv.GeneratedAt = inv.DueDate.Add(time.Hour)
+	if got := inv.PaymentStatus(); got != "Overdue" {
+		t.Errorf("Expected Overdue, got %q", got)
+	}
+
+	inv.Status = "success"
+	if got := inv.PaymentStatus(); got != "Paid" {
+		t.Errorf("Expected Paid, got %q", got)
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := NewRenderer(Business{}, "").HTML(&buf, sampleInvoice()); err != nil {
+		t.Fatalf("Failed to render HTML: %v", err)
+	}
+
+	html := buf.String()
+	for _, want := range []string{"PRQ_test123", "Consulting &lt;day rate&gt;", "NGN 100,000.00", "NGN 15,000.50", "1 Mar 2026", "Partially paid"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Expected HTML to contain %q", want)
+		}
+	}
+}
+
+func TestRenderPDF(t *testing.T) {
+	inv := sampleInvoice()
+	// Enough rows to force a second page
+	for i := 0; i < 40; i++ {
+		inv.LineItems = append(inv.LineItems, LineItem{Name: "Item", Quantity: 1, UnitAmount: 100, Amount: 100})
+	}
+
+	var buf bytes.Buffer
+	if err := NewRenderer(Business{}, "").PDF(&buf, inv); err != nil {
+		t.Fatalf("Failed to render PDF: %v", err)
+	}
+	pdf := buf.Bytes()
+
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatal("Expected a PDF header and trailer")
+	}
+	if !bytes.Contains(pdf, []byte("/Count 2")) {
+		t.Error("Expected two pages")
+	}
+	if !bytes.Contains(pdf, []byte(`(Thank you \(really\)) Tj`)) {
+		t.Error("Expected footer text with escaped parentheses")
+	}
+
+	// startxref must point at the xref table, and every xref entry at its object
+	match := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdf)
+	if match == nil {
+		t.Fatal("Missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(match[1]))
+	if !bytes.HasPrefix(pdf[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point at the xref table", xref)
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdf[xref:], -1)
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		if want := strconv.Itoa(i+1) + " 0 obj"; !bytes.HasPrefix(pdf[offset:], []byte(want)) {
+			t.Errorf("xref entry %d does not point at %q", i+1, want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package document
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// A4 page size in PDF points
+const (
+	pageWidth  = 595.28
+	pageHeight = 841.89
+)
+
+// helveticaWidths are the Helvetica glyph widths (1/1000 em) for ASCII 32-126,
+// taken from the standard Type 1 font metrics. Used to right-align and
+// truncate text without embedding a font.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+}
+
+// pdfWriter produces a minimal PDF using the built-in Helvetica fonts, so
+// documents can be generated without embedding fonts or external tools.
+// Coordinates are in points from the bottom-left corner of the page.
+type pdfWriter struct {
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+}
+
+func newPDFWriter() *pdfWriter {
+	p := &pdfWriter{}
+	p.addPage()
+	return p
+}
+
+// addPage starts a new page; subsequent drawing goes to it
+func (p *pdfWriter) addPage() {
+	p.page = &bytes.Buffer{}
+	p.pages = append(p.pages, p.page)
+}
+
+// text draws s with its baseline starting at (x, y)
+func (p *pdfWriter) text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(p.page, "BT /%s %.1f Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfEscape(s))
+}
+
+// textRight draws s so that it ends at x
+func (p *pdfWriter) textRight(x, y, size float64, bold bool, s string) {
+	p.text(x-textWidth(s, size, bold), y, size, bold, s)
+}
+
+// line draws a straight line
+func (p *pdfWriter) line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(p.page, "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, y1, x2, y2)
+}
+
+// fillRect draws a filled rectangle in the given gray level (0 black, 1 white)
+func (p *pdfWriter) fillRect(x, y, w, h, gray float64) {
+	fmt.Fprintf(p.page, "%.2f g %.2f %.2f %.2f %.2f re f 0 g\n", gray, x, y, w, h)
+}
+
+// bytes assembles the document: catalog, page tree, fonts, then one page and
+// content stream per page, followed by the cross-reference table
+func (p *pdfWriter) bytes() []byte {
+	var out bytes.Buffer
+	offsets := []int{}
+
+	writeObject := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-4 are fixed; page i uses objects 5+2i (page) and 6+2i (content)
+	kids := []string{}
+	for i := range p.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+2*i))
+	}
+
+	writeObject("<< /Type /Catalog /Pages 2 0 R >>")
+	writeObject(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+	writeObject("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	writeObject("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, page := range p.pages {
+		writeObject(fmt.Sprintf(
+			"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, 6+2*i,
+		))
+		writeObject(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.String()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	return out.Bytes()
+}
+
+// pdfEscape converts s to WinAnsi bytes inside a PDF string literal. Latin-1
+// characters are kept, the naira sign becomes "N" and anything else "?".
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n' || r == '\r' || r == '\t':
+			b.WriteByte(' ')
+		case r >= 32 && r < 127:
+			b.WriteRune(r)
+		case r >= 0xA0 && r <= 0xFF:
+			b.WriteByte(byte(r))
+		case r == '₦':
+			b.WriteByte('N')
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// textWidth estimates the rendered width of s in points. Bold glyphs are
+// slightly wider than regular ones; non-ASCII characters use an average width.
+func textWidth(s string, size float64, bold bool) float64 {
+	units := 0
+	for _, r := range s {
+		if r >= 32 && r < 127 {
+			units += helveticaWidths[r-32]
+		} else {
+			units += 556
+		}
+	}
+	width := float64(units) * size / 1000
+	if bold {
+		width *= 1.05
+	}
+	return width
+}
+
+// truncate shortens s with an ellipsis so it fits within maxWidth
+func truncate(s string, size float64, bold bool, maxWidth float64) string {
+	if textWidth(s, size, bold) <= maxWidth {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && textWidth(string(runes)+"...", size, bold) > maxWidth {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
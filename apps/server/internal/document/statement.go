@@ -0,0 +1,64 @@
+package document
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"time"
+
+	"paystack.mpc.proxy/internal/money"
+)
+
+//go:embed templates/statement.html
+var statementTemplate string
+
+// StatementEntry is one line of a customer statement. Amounts are in kobo.
+type StatementEntry struct {
+	Date        time.Time
+	Type        string
+	Reference   string
+	Description string
+	// Debit increases what the customer owes (invoices); Credit reduces it
+	// (payments, credit notes)
+	Debit   int
+	Credit  int
+	Balance int
+}
+
+// Statement is a customer's account activity over a period. Amounts are in kobo.
+type Statement struct {
+	Business       Business
+	CustomerName   string
+	CustomerEmail  string
+	CustomerCode   string
+	Currency       string
+	From           time.Time
+	To             time.Time
+	OpeningBalance int
+	Entries        []StatementEntry
+	TotalDebits    int
+	TotalCredits   int
+	ClosingBalance int
+	GeneratedAt    time.Time
+}
+
+// StatementHTML renders a customer statement with the built-in template.
+// The invoice template override does not apply to statements.
+func (r *Renderer) StatementHTML(w io.Writer, st *Statement) error {
+	tmpl, err := template.New("statement").Funcs(template.FuncMap{
+		"money": money.Format,
+		"date":  formatDate,
+	}).Parse(statementTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse statement template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, st); err != nil {
+		return fmt.Errorf("failed to render statement template: %w", err)
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
@@ -0,0 +1,69 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"paystack.mpc.proxy/internal/money"
+)
+
+// csvFlushEvery is how many rows are buffered before they are flushed
+const csvFlushEvery = 500
+
+type csvWriter struct {
+	w       *csv.Writer
+	columns []Column
+	rows    int
+}
+
+// NewCSV writes the header row and returns a CSV writer. Money is written as
+// a decimal in major units and times as "2006-01-02 15:04:05" in UTC, so the
+// file opens cleanly in a spreadsheet.
+func NewCSV(w io.Writer, columns []Column) (Writer, error) {
+	cw := &csvWriter{w: csv.NewWriter(w), columns: columns}
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.Name
+	}
+	if err := cw.w.Write(header); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+func (cw *csvWriter) WriteRow(values ...interface{}) error {
+	record := make([]string, len(cw.columns))
+	for i, c := range cw.columns {
+		if i >= len(values) {
+			break
+		}
+		switch c.Kind {
+		case Integer:
+			record[i] = strconv.Itoa(intValue(values[i]))
+		case Money:
+			record[i] = money.Decimal(intValue(values[i]))
+		case Time:
+			if t, ok := timeValue(values[i]); ok {
+				record[i] = t.Format("2006-01-02 15:04:05")
+			}
+		default:
+			record[i] = textValue(values[i])
+		}
+	}
+	if err := cw.w.Write(record); err != nil {
+		return err
+	}
+
+	cw.rows++
+	if cw.rows%csvFlushEvery == 0 {
+		cw.w.Flush()
+		return cw.w.Error()
+	}
+	return nil
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
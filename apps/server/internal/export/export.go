@@ -0,0 +1,115 @@
+// Package export writes accounting exports as CSV, XLSX or OFX.
+//
+// Every writer streams: rows go straight to the underlying io.Writer as they
+// are written, so an export of any size uses constant memory. XLSX is built
+// by hand on archive/zip with inline strings (no shared string table), which
+// is what lets it stream. Callers run the query; this package only formats.
+package export
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Export formats
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+	FormatOFX  = "ofx"
+)
+
+// ContentType returns the HTTP content type for an export format
+func ContentType(format string) string {
+	switch format {
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case FormatOFX:
+		return "application/x-ofx"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+// Kind says how a column's values are formatted
+type Kind int
+
+const (
+	// Text values are strings
+	Text Kind = iota
+	// Integer values are ints
+	Integer
+	// Money values are ints in minor units (kobo), written in major units
+	Money
+	// Time values are time.Time or *time.Time; zero and nil are left empty
+	Time
+)
+
+// Column is one column of a tabular export
+type Column struct {
+	Name string
+	Kind Kind
+}
+
+// Writer streams the rows of a tabular export. Values are passed in column
+// order; Close must be called to finish the file.
+type Writer interface {
+	WriteRow(values ...interface{}) error
+	Close() error
+}
+
+// timeValue unwraps a Time column value; ok is false when it is empty
+func timeValue(v interface{}) (t time.Time, ok bool) {
+	switch tv := v.(type) {
+	case time.Time:
+		t = tv
+	case *time.Time:
+		if tv != nil {
+			t = *tv
+		}
+	}
+	return t.UTC(), !t.IsZero()
+}
+
+// intValue unwraps an Integer or Money column value
+func intValue(v interface{}) int {
+	switch iv := v.(type) {
+	case int:
+		return iv
+	case int64:
+		return int(iv)
+	case *int:
+		if iv != nil {
+			return *iv
+		}
+	}
+	return 0
+}
+
+// formulaPrefixes are the leading characters that make a spreadsheet read a
+// cell as a formula
+const formulaPrefixes = "=+-@\t\r"
+
+// textValue formats any value for a text cell. Text that a spreadsheet would
+// run as a formula (narrations and names come from Paystack and uploaded
+// statements) is prefixed with ' so it is shown as typed.
+func textValue(v interface{}) string {
+	var text string
+	switch tv := v.(type) {
+	case nil:
+		return ""
+	case string:
+		text = tv
+	case int:
+		text = strconv.Itoa(tv)
+	case fmt.Stringer:
+		text = tv.String()
+	default:
+		text = fmt.Sprint(v)
+	}
+	if text != "" && strings.ContainsRune(formulaPrefixes, rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
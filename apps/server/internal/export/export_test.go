@@ -0,0 +1,135 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testColumns = []Column{
+	{Name: "date", Kind: Time},
+	{Name: "name"},
+	{Name: "count", Kind: Integer},
+	{Name: "amount", Kind: Money},
+}
+
+func TestCSV(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewCSV(&buf, testColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	when := time.Date(2025, 3, 4, 10, 30, 0, 0, time.UTC)
+	w.WriteRow(when, "Ada, Ltd", 3, -120050)
+	w.WriteRow((*time.Time)(nil), "", 0, 5)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "date,name,count,amount\n2025-03-04 10:30:00,\"Ada, Ltd\",3,-1200.50\n,,0,0.05\n"
+	if buf.String() != want {
+		t.Errorf("csv = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestFormulaTextIsEscaped(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewCSV(&buf, []Column{{Name: "narration"}, {Name: "amount", Kind: Money}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteRow("=HYPERLINK(\"http://x\")", -500)
+	w.WriteRow("+2348000000000", 0)
+	w.WriteRow("@SUM(A1)", 0)
+	w.WriteRow("-1+1", 0)
+	w.WriteRow("Refund - March", 0)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "narration,amount\n\"'=HYPERLINK(\"\"http://x\"\")\",-5.00\n'+2348000000000,0.00\n'@SUM(A1),0.00\n'-1+1,0.00\nRefund - March,0.00\n"
+	if buf.String() != want {
+		t.Errorf("csv = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewXLSX(&buf, "Expenses: March", testColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteRow(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC), "Tom & <Jerry>", 7, 150000)
+	w.WriteRow(nil, "=1+1", 0, 0)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(body)
+	}
+
+	if !strings.Contains(files["xl/workbook.xml"], `name="Expenses March"`) {
+		t.Errorf("sheet name not sanitised: %s", files["xl/workbook.xml"])
+	}
+	sheet := files["xl/worksheets/sheet1.xml"]
+	for _, want := range []string{
+		`<c r="A2" s="3"><v>45658.5</v></c>`,
+		`Tom &amp; &lt;Jerry&gt;`,
+		`<c r="C2"><v>7</v></c>`,
+		`<c r="D2" s="2"><v>1500.00</v></c>`,
+		`<t xml:space="preserve">&#39;=1+1</t>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("sheet missing %s", want)
+		}
+	}
+}
+
+func TestXLSXColumnName(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 702: "AAA"} {
+		if got := xlsxColumnName(i); got != want {
+			t.Errorf("xlsxColumnName(%d) = %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestOFX(t *testing.T) {
+	var buf bytes.Buffer
+	generated := time.Date(2025, 3, 31, 18, 0, 0, 0, time.UTC)
+	w := NewOFX(&buf, OFXStatement{BankID: "PAYSTACK", AccountID: "ACC", Currency: "NGN", Generated: generated})
+	w.WriteTransaction(OFXTransaction{ID: "charge-T1", Posted: time.Date(2025, 3, 2, 9, 0, 0, 0, time.UTC), Amount: 500000, Name: "b@example.com"})
+	w.WriteTransaction(OFXTransaction{ID: "expense-E1", Posted: time.Date(2025, 3, 5, 9, 0, 0, 0, time.UTC), Amount: -120000, Name: "Smith & Sons Logistics Nigeria Limited", Memo: "line one\nline two"})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"<DTSTART>20250302090000[0:GMT]\n<DTEND>20250331180000[0:GMT]",
+		"<TRNTYPE>CREDIT\n<DTPOSTED>20250302090000[0:GMT]\n<TRNAMT>5000.00\n<FITID>charge-T1",
+		"<TRNTYPE>DEBIT",
+		"<TRNAMT>-1200.00",
+		"<NAME>Smith &amp; Sons Logistics Nigeria L\n",
+		"<MEMO>line one line two\n",
+		"<BALAMT>3800.00",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("ofx missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"paystack.mpc.proxy/internal/money"
+)
+
+// OFXStatement describes the account an OFX export belongs to
+type OFXStatement struct {
+	BankID    string
+	AccountID string
+	Currency  string
+	// From and To bound the statement; a zero From uses the first
+	// transaction's date and a zero To uses Generated
+	From      time.Time
+	To        time.Time
+	Generated time.Time
+}
+
+// OFXTransaction is one statement line. Amount is signed, in minor units:
+// positive is money in, negative is money out.
+type OFXTransaction struct {
+	ID     string
+	Posted time.Time
+	Amount int
+	Name   string
+	Memo   string
+}
+
+// OFXWriter streams an OFX 1.x (SGML) bank statement. Transactions should be
+// written oldest first.
+type OFXWriter struct {
+	w       *bufio.Writer
+	st      OFXStatement
+	started bool
+	net     int
+}
+
+// NewOFX returns a writer for one statement. Nothing is written until the
+// first transaction or Close.
+func NewOFX(w io.Writer, st OFXStatement) *OFXWriter {
+	if st.Generated.IsZero() {
+		st.Generated = time.Now()
+	}
+	return &OFXWriter{w: bufio.NewWriter(w), st: st}
+}
+
+// WriteTransaction writes one STMTTRN
+func (o *OFXWriter) WriteTransaction(t OFXTransaction) error {
+	if !o.started {
+		o.start(t.Posted)
+	}
+	o.net += t.Amount
+
+	trnType := "CREDIT"
+	if t.Amount < 0 {
+		trnType = "DEBIT"
+	}
+	fmt.Fprintf(o.w, "<STMTTRN>\n<TRNTYPE>%s\n<DTPOSTED>%s\n<TRNAMT>%s\n<FITID>%s\n",
+		trnType, ofxTime(t.Posted), money.Decimal(t.Amount), ofxText(t.ID, 255))
+	if t.Name != "" {
+		fmt.Fprintf(o.w, "<NAME>%s\n", ofxText(t.Name, 32))
+	}
+	if t.Memo != "" {
+		fmt.Fprintf(o.w, "<MEMO>%s\n", ofxText(t.Memo, 255))
+	}
+	_, err := o.w.WriteString("</STMTTRN>\n")
+	return err
+}
+
+// Close ends the transaction list and writes the ledger balance, which is the
+// net of the exported transactions rather than an account balance
+func (o *OFXWriter) Close() error {
+	if !o.started {
+		o.start(o.st.Generated)
+	}
+	fmt.Fprintf(o.w, "</BANKTRANLIST>\n<LEDGERBAL>\n<BALAMT>%s\n<DTASOF>%s\n</LEDGERBAL>\n</STMTRS>\n</STMTTRNRS>\n</BANKMSGSRSV1>\n</OFX>\n",
+		money.Decimal(o.net), ofxTime(o.statementEnd()))
+	return o.w.Flush()
+}
+
+// start writes everything up to the first transaction. DTSTART must come
+// before the transactions, so an open-ended statement starts at the first one.
+func (o *OFXWriter) start(first time.Time) {
+	o.started = true
+	from := o.st.From
+	if from.IsZero() {
+		from = first
+	}
+
+	o.w.WriteString("OFXHEADER:100\nDATA:OFXSGML\nVERSION:102\nSECURITY:NONE\nENCODING:UTF-8\nCHARSET:NONE\n" +
+		"COMPRESSION:NONE\nOLDFILEUID:NONE\nNEWFILEUID:NONE\n\n")
+	fmt.Fprintf(o.w, "<OFX>\n<SIGNONMSGSRSV1>\n<SONRS>\n<STATUS>\n<CODE>0\n<SEVERITY>INFO\n</STATUS>\n<DTSERVER>%s\n<LANGUAGE>ENG\n</SONRS>\n</SIGNONMSGSRSV1>\n",
+		ofxTime(o.st.Generated))
+	fmt.Fprintf(o.w, "<BANKMSGSRSV1>\n<STMTTRNRS>\n<TRNUID>1\n<STATUS>\n<CODE>0\n<SEVERITY>INFO\n</STATUS>\n<STMTRS>\n<CURDEF>%s\n", ofxText(o.st.Currency, 3))
+	fmt.Fprintf(o.w, "<BANKACCTFROM>\n<BANKID>%s\n<ACCTID>%s\n<ACCTTYPE>CHECKING\n</BANKACCTFROM>\n",
+		ofxText(o.st.BankID, 9), ofxText(o.st.AccountID, 22))
+	fmt.Fprintf(o.w, "<BANKTRANLIST>\n<DTSTART>%s\n<DTEND>%s\n", ofxTime(from), ofxTime(o.statementEnd()))
+}
+
+func (o *OFXWriter) statementEnd() time.Time {
+	if o.st.To.IsZero() {
+		return o.st.Generated
+	}
+	return o.st.To
+}
+
+// ofxTime formats a time as an OFX datetime in GMT
+func ofxTime(t time.Time) string {
+	return t.UTC().Format("20060102150405") + "[0:GMT]"
+}
+
+// ofxText escapes a value for SGML, folds it onto one line and trims it to the
+// field's maximum length
+func ofxText(s string, maxLen int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if runes := []rune(s); len(runes) > maxLen {
+		s = string(runes[:maxLen])
+	}
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
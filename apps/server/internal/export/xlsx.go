@@ -0,0 +1,213 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"paystack.mpc.proxy/internal/money"
+)
+
+// Cell styles, as indexes into cellXfs in xlsxStyles
+const (
+	xlsxStyleDefault = 0
+	xlsxStyleHeader  = 1
+	xlsxStyleMoney   = 2
+	xlsxStyleTime    = 3
+)
+
+// xlsxEpoch is day zero of Excel's 1900 date system (the 1900 leap year bug
+// makes it 30 Dec 1899 rather than 31 Dec)
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+</Types>`
+
+const xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`
+
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm:ss"/></numFmts>
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="4">
+<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>
+<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>
+<xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+</cellXfs>
+<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>
+</styleSheet>`
+
+const xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>
+<sheetData>`
+
+const xlsxSheetEnd = `</sheetData>
+</worksheet>`
+
+type xlsxWriter struct {
+	zip     *zip.Writer
+	sheet   *bufio.Writer
+	columns []Column
+	refs    []string
+	row     int
+}
+
+// NewXLSX writes the workbook parts and the header row and returns a writer
+// for a single-sheet workbook. The header row is bold and frozen; money is a
+// number in major units and times are Excel dates in UTC.
+func NewXLSX(w io.Writer, sheetName string, columns []Column) (Writer, error) {
+	zw := zip.NewWriter(w)
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", fmt.Sprintf(xlsxWorkbook, xmlEscape(xlsxSheetName(sheetName)))},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, part := range parts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.body); err != nil {
+			return nil, err
+		}
+	}
+
+	// The sheet is the last entry, so it can stay open while rows stream in
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	xw := &xlsxWriter{zip: zw, sheet: bufio.NewWriter(f), columns: columns}
+	xw.refs = make([]string, len(columns))
+	for i := range columns {
+		xw.refs[i] = xlsxColumnName(i)
+	}
+	xw.sheet.WriteString(xlsxSheetStart)
+
+	header := make([]interface{}, len(columns))
+	for i, c := range columns {
+		header[i] = c.Name
+	}
+	if err := xw.writeRow(header, true); err != nil {
+		return nil, err
+	}
+	return xw, nil
+}
+
+func (xw *xlsxWriter) WriteRow(values ...interface{}) error {
+	return xw.writeRow(values, false)
+}
+
+func (xw *xlsxWriter) writeRow(values []interface{}, header bool) error {
+	xw.row++
+	fmt.Fprintf(xw.sheet, `<row r="%d">`, xw.row)
+	for i, c := range xw.columns {
+		if i >= len(values) {
+			break
+		}
+		ref := xw.refs[i] + strconv.Itoa(xw.row)
+
+		kind := c.Kind
+		if header {
+			kind = Text
+		}
+		switch kind {
+		case Integer:
+			fmt.Fprintf(xw.sheet, `<c r="%s"><v>%d</v></c>`, ref, intValue(values[i]))
+		case Money:
+			fmt.Fprintf(xw.sheet, `<c r="%s" s="%d"><v>%s</v></c>`, ref, xlsxStyleMoney, money.Decimal(intValue(values[i])))
+		case Time:
+			if t, ok := timeValue(values[i]); ok {
+				serial := float64(t.Truncate(time.Second).Sub(xlsxEpoch)/time.Second) / 86400
+				fmt.Fprintf(xw.sheet, `<c r="%s" s="%d"><v>%s</v></c>`, ref, xlsxStyleTime, strconv.FormatFloat(serial, 'f', -1, 64))
+			}
+		default:
+			text := textValue(values[i])
+			if text == "" {
+				continue
+			}
+			style := xlsxStyleDefault
+			if header {
+				style = xlsxStyleHeader
+			}
+			fmt.Fprintf(xw.sheet, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, style, xmlEscape(text))
+		}
+	}
+	_, err := xw.sheet.WriteString("</row>\n")
+	return err
+}
+
+func (xw *xlsxWriter) Close() error {
+	xw.sheet.WriteString(xlsxSheetEnd)
+	if err := xw.sheet.Flush(); err != nil {
+		return err
+	}
+	return xw.zip.Close()
+}
+
+// xlsxColumnName returns the spreadsheet column letters for a zero-based index
+// (0 → A, 25 → Z, 26 → AA)
+func xlsxColumnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// xlsxSheetName drops the characters Excel forbids in sheet names and trims
+// the name to 31 characters
+func xlsxSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return -1
+		}
+		return r
+	}, name)
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+	if name == "" {
+		name = "Sheet1"
+	}
+	return name
+}
+
+// xmlEscape escapes text for element content and attribute values
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,437 @@
+// Package handlers implements HTTP handlers for the moniewave financial management system.
+//
+// Account Limits Handler - Spending Controls
+//
+// OBJECTIVES:
+// Put a global guard on how much money can leave the Paystack balance.
+//
+// PURPOSE:
+// - Store a per-transaction maximum and daily, weekly and monthly outflow caps
+// - Schedule limit changes with effective-from dates
+// - Check every money-moving path (transfers, bulk transfers, expenses) against them
+// - Report current utilisation of each limit
+//
+// KEY WORKFLOW:
+// Set Account Limits (effective from a date) → Initiate Transfer / Bulk Transfer / Create Expense →
+// Check Per-Transaction Maximum → Sum Outflow Per Window → Reject Or Allow
+//
+// DESIGN DECISIONS:
+// - Limits are append-only rows; the row with the latest effective_from that has
+//   started is the active one, so history and scheduled changes are kept
+// - An amount of 0 removes that cap from its effective date
+// - Windows are the same calendar windows as beneficiary limits; outflow before a
+//   limit took effect still counts toward the window it falls in
+// - Limits are per currency (default NGN) and only count outflow in that currency
+// - All amounts stored in kobo (Nigerian currency subunit) for precision
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+	"paystack.mpc.proxy/internal/money"
+)
+
+type AccountLimitHandler struct{}
+
+func NewAccountLimitHandler() *AccountLimitHandler {
+	return &AccountLimitHandler{}
+}
+
+// AccountLimitPerTransaction is the limit type for the per-transaction maximum;
+// the other limit types are the LimitWindow names
+const AccountLimitPerTransaction = "per_transaction"
+
+// accountLimitTypes lists the limit types in display order
+var accountLimitTypes = []string{AccountLimitPerTransaction, LimitWindowDaily, LimitWindowWeekly, LimitWindowMonthly}
+
+// AccountLimit is one account limit row
+type AccountLimit struct {
+	ID            int       `json:"id"`
+	LimitType     string    `json:"limit_type"`
+	Amount        int       `json:"amount"`
+	Currency      string    `json:"currency"`
+	EffectiveFrom time.Time `json:"effective_from"`
+	Notes         string    `json:"notes,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type SetAccountLimitsRequest struct {
+	Currency            string `json:"currency,omitempty"`
+	EffectiveFrom       string `json:"effective_from,omitempty"` // YYYY-MM-DD, defaults to now
+	PerTransactionLimit *int   `json:"per_transaction_limit,omitempty"`
+	DailyLimit          *int   `json:"daily_limit,omitempty"`
+	WeeklyLimit         *int   `json:"weekly_limit,omitempty"`
+	MonthlyLimit        *int   `json:"monthly_limit,omitempty"`
+	Notes               string `json:"notes,omitempty"`
+}
+
+type ListAccountLimitsRequest struct {
+	Currency  string `json:"currency,omitempty"`
+	LimitType string `json:"limit_type,omitempty"`
+}
+
+// AccountLimitUsage reports utilisation of one active account limit.
+// Window fields are omitted for the per-transaction maximum.
+type AccountLimitUsage struct {
+	LimitType          string     `json:"limit_type"`
+	Limit              int        `json:"limit"`
+	EffectiveFrom      time.Time  `json:"effective_from"`
+	Spent              *int       `json:"spent,omitempty"`
+	Remaining          *int       `json:"remaining,omitempty"`
+	UtilisationPercent *float64   `json:"utilisation_percent,omitempty"`
+	ResetsAt           *time.Time `json:"resets_at,omitempty"`
+}
+
+// AccountLimitUtilisation is the response of the utilisation endpoint
+type AccountLimitUtilisation struct {
+	Currency  string              `json:"currency"`
+	AsOf      time.Time           `json:"as_of"`
+	Limits    []AccountLimitUsage `json:"limits"`
+	Scheduled []AccountLimit      `json:"scheduled"`
+}
+
+// AccountLimitCheck is the outcome of checking an outflow against the account limits
+type AccountLimitCheck struct {
+	Currency        string              `json:"currency"`
+	RequestedAmount int                 `json:"requested_amount"`
+	Allowed         bool                `json:"allowed"`
+	ExceededLimit   string              `json:"exceeded_limit,omitempty"`
+	ResetsAt        *time.Time          `json:"resets_at,omitempty"`
+	Limits          []AccountLimitUsage `json:"limits"`
+	Reason          string              `json:"reason"`
+}
+
+// activeAccountLimits returns the limit in force at the given time for each
+// limit type. Types without a limit, or whose active limit is 0, are omitted.
+func activeAccountLimits(currency string, at time.Time) (map[string]AccountLimit, error) {
+	rows, err := database.DB.Query(`
+		SELECT id, limit_type, amount, currency, effective_from, COALESCE(notes, ''), created_at
+		FROM account_limits
+		WHERE currency = ? AND effective_from <= ?
+		ORDER BY effective_from DESC, id DESC
+	`, currency, at)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query account limits: %w", err)
+	}
+	defer rows.Close()
+
+	active := map[string]AccountLimit{}
+	seen := map[string]bool{}
+	for rows.Next() {
+		var limit AccountLimit
+		if err := rows.Scan(&limit.ID, &limit.LimitType, &limit.Amount, &limit.Currency, &limit.EffectiveFrom, &limit.Notes, &limit.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan account limit: %w", err)
+		}
+
+		// Rows are newest first, so the first row per type is the one in force
+		if seen[limit.LimitType] {
+			continue
+		}
+		seen[limit.LimitType] = true
+
+		if limit.Amount > 0 {
+			active[limit.LimitType] = limit
+		}
+	}
+
+	return active, rows.Err()
+}
+
+// accountLimitUsage reports utilisation of every active account limit
+func accountLimitUsage(currency string, now time.Time) ([]AccountLimitUsage, error) {
+	active, err := activeAccountLimits(currency, now)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := []AccountLimitUsage{}
+
+	if limit, ok := active[AccountLimitPerTransaction]; ok {
+		usage = append(usage, AccountLimitUsage{
+			LimitType:     AccountLimitPerTransaction,
+			Limit:         limit.Amount,
+			EffectiveFrom: limit.EffectiveFrom,
+		})
+	}
+
+	for _, window := range limitWindows(now) {
+		limit, ok := active[window.Name]
+		if !ok {
+			continue
+		}
+
+		spent, err := sumOutflow(outflowFilter{Currency: currency}, window.Start, window.End)
+		if err != nil {
+			return nil, err
+		}
+
+		resetsAt := window.End
+		remaining := limit.Amount - spent
+		utilisation := (float64(spent) / float64(limit.Amount)) * 100
+		usage = append(usage, AccountLimitUsage{
+			LimitType:          window.Name,
+			Limit:              limit.Amount,
+			EffectiveFrom:      limit.EffectiveFrom,
+			Spent:              &spent,
+			Remaining:          &remaining,
+			UtilisationPercent: &utilisation,
+			ResetsAt:           &resetsAt,
+		})
+	}
+
+	return usage, nil
+}
+
+// CheckAccountLimits checks one or more outflows (a bulk transfer passes one
+// amount per item) against the account limits. Each amount must be within the
+// per-transaction maximum and their total within every window cap.
+func CheckAccountLimits(currency string, amounts ...int) (*AccountLimitCheck, error) {
+	if currency == "" {
+		currency = "NGN"
+	}
+
+	total, largest := 0, 0
+	for _, amount := range amounts {
+		total += amount
+		largest = max(largest, amount)
+	}
+
+	usage, err := accountLimitUsage(currency, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	check := &AccountLimitCheck{
+		Currency:        currency,
+		RequestedAmount: total,
+		Allowed:         true,
+		Limits:          usage,
+		Reason:          "Within account limits",
+	}
+
+	// When several windows are exceeded, report the one that resets last,
+	// since that is when the outflow could go through
+	var exceeded *AccountLimitUsage
+	for i := range usage {
+		limit := &usage[i]
+		if limit.LimitType == AccountLimitPerTransaction {
+			if largest > limit.Limit {
+				check.Allowed = false
+				check.ExceededLimit = AccountLimitPerTransaction
+				check.Reason = fmt.Sprintf("A payment of %s %s exceeds the per-transaction maximum of %s %s",
+					check.Currency, money.Decimal(largest), check.Currency, money.Decimal(limit.Limit))
+				return check, nil
+			}
+			continue
+		}
+
+		if *limit.Spent+total > limit.Limit {
+			if exceeded == nil || limit.ResetsAt.After(*exceeded.ResetsAt) {
+				exceeded = limit
+			}
+		}
+	}
+
+	if exceeded != nil {
+		check.Allowed = false
+		check.ExceededLimit = exceeded.LimitType
+		check.ResetsAt = exceeded.ResetsAt
+		check.Reason = fmt.Sprintf("Paying out %s %s would exceed the account %s outflow limit of %s %s (%s %s already paid out, %s %s remaining); the limit resets at %s",
+			check.Currency, money.Decimal(total), exceeded.LimitType,
+			check.Currency, money.Decimal(exceeded.Limit),
+			check.Currency, money.Decimal(*exceeded.Spent),
+			check.Currency, money.Decimal(max(*exceeded.Remaining, 0)),
+			exceeded.ResetsAt.Format(time.RFC3339))
+	}
+
+	return check, nil
+}
+
+// writeAccountLimitExceeded writes the rejection for an outflow over an account limit
+func writeAccountLimitExceeded(w http.ResponseWriter, check *AccountLimitCheck) {
+	respondWithJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+		"status":  false,
+		"message": fmt.Sprintf("Account %s limit exceeded", check.ExceededLimit),
+		"error":   check.Reason,
+		"data":    check,
+	})
+}
+
+// Set records new account limits, effective from the given date
+func (h *AccountLimitHandler) Set(w http.ResponseWriter, r *http.Request) {
+	var req SetAccountLimitsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.Currency == "" {
+		req.Currency = "NGN"
+	}
+
+	amounts := map[string]*int{
+		AccountLimitPerTransaction: req.PerTransactionLimit,
+		LimitWindowDaily:           req.DailyLimit,
+		LimitWindowWeekly:          req.WeeklyLimit,
+		LimitWindowMonthly:         req.MonthlyLimit,
+	}
+
+	provided := 0
+	for limitType, amount := range amounts {
+		if amount == nil {
+			continue
+		}
+		if *amount < 0 {
+			WriteJSONBadRequest(w, fmt.Sprintf("%s limit cannot be negative", limitType))
+			return
+		}
+		provided++
+	}
+
+	if provided == 0 {
+		WriteJSONBadRequest(w, "at least one of per_transaction_limit, daily_limit, weekly_limit or monthly_limit is required")
+		return
+	}
+
+	now := time.Now()
+	effectiveFrom := now
+	if req.EffectiveFrom != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", req.EffectiveFrom, time.Local)
+		if err != nil {
+			WriteJSONBadRequest(w, "Invalid effective_from format. Use YYYY-MM-DD")
+			return
+		}
+		effectiveFrom = parsed
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to begin transaction: %w", err), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	for _, limitType := range accountLimitTypes {
+		amount := amounts[limitType]
+		if amount == nil {
+			continue
+		}
+
+		_, err := tx.Exec(`
+			INSERT INTO account_limits (limit_type, amount, currency, effective_from, notes, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, limitType, *amount, req.Currency, effectiveFrom, req.Notes, now, now)
+		if err != nil {
+			WriteJSONError(w, fmt.Errorf("failed to set %s limit: %w", limitType, err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to save account limits: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	h.writeUtilisation(w, req.Currency)
+}
+
+// List lists account limit history, newest first
+func (h *AccountLimitHandler) List(w http.ResponseWriter, r *http.Request) {
+	var req ListAccountLimitsRequest
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&req)
+	}
+
+	query := `SELECT id, limit_type, amount, currency, effective_from, COALESCE(notes, ''), created_at FROM account_limits WHERE 1=1`
+	args := []interface{}{}
+
+	if req.Currency != "" {
+		query += " AND currency = ?"
+		args = append(args, req.Currency)
+	}
+
+	if req.LimitType != "" {
+		query += " AND limit_type = ?"
+		args = append(args, req.LimitType)
+	}
+
+	query += " ORDER BY effective_from DESC, id DESC"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to query account limits: %w", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	limits := []AccountLimit{}
+	for rows.Next() {
+		var limit AccountLimit
+		if err := rows.Scan(&limit.ID, &limit.LimitType, &limit.Amount, &limit.Currency, &limit.EffectiveFrom, &limit.Notes, &limit.CreatedAt); err != nil {
+			WriteJSONError(w, fmt.Errorf("failed to scan account limit: %w", err), http.StatusInternalServerError)
+			return
+		}
+		limits = append(limits, limit)
+	}
+
+	if err = rows.Err(); err != nil {
+		WriteJSONError(w, fmt.Errorf("error iterating account limits: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, limits)
+}
+
+// Utilisation shows how much of each active account limit has been used
+func (h *AccountLimitHandler) Utilisation(w http.ResponseWriter, r *http.Request) {
+	currency := r.URL.Query().Get("currency")
+	if currency == "" {
+		currency = "NGN"
+	}
+
+	h.writeUtilisation(w, currency)
+}
+
+// writeUtilisation writes current utilisation and scheduled changes for a currency
+func (h *AccountLimitHandler) writeUtilisation(w http.ResponseWriter, currency string) {
+	now := time.Now()
+
+	usage, err := accountLimitUsage(currency, now)
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT id, limit_type, amount, currency, effective_from, COALESCE(notes, ''), created_at
+		FROM account_limits
+		WHERE currency = ? AND effective_from > ?
+		ORDER BY effective_from, id
+	`, currency, now)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to query scheduled account limits: %w", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	scheduled := []AccountLimit{}
+	for rows.Next() {
+		var limit AccountLimit
+		if err := rows.Scan(&limit.ID, &limit.LimitType, &limit.Amount, &limit.Currency, &limit.EffectiveFrom, &limit.Notes, &limit.CreatedAt); err != nil {
+			WriteJSONError(w, fmt.Errorf("failed to scan account limit: %w", err), http.StatusInternalServerError)
+			return
+		}
+		scheduled = append(scheduled, limit)
+	}
+
+	WriteJSONSuccess(w, AccountLimitUtilisation{
+		Currency:  currency,
+		AsOf:      now,
+		Limits:    usage,
+		Scheduled: scheduled,
+	})
+}
@@ -0,0 +1,581 @@
+// Package handlers implements HTTP handlers for the moniewave financial management system.
+//
+// Account Snapshot - Reporting
+//
+// OBJECTIVES:
+// Give the voice agent a complete picture of the account in one call.
+//
+// PURPOSE:
+// - Combine the Paystack balance with local KPIs: pending outgoing expenses,
+//   outstanding receivables, month-to-date spend against active budgets,
+//   goals at risk and the most recent ledger entries
+// - Include a one-paragraph summary the voice agent can read out as is
+//
+// KEY WORKFLOW:
+// Check Cache → Compute Sections On A Bounded Pool → Summary → Cache Result
+//
+// DESIGN DECISIONS:
+// - Sections run concurrently but at most snapshotConcurrency at a time, so one
+//   snapshot never floods SQLite or Paystack
+// - A failed section is reported under "errors" and the rest of the snapshot is
+//   still returned; snapshots with errors are not cached so the next call retries
+// - Snapshots are cached per currency and recent count for a short TTL
+//   (SNAPSHOT_CACHE_TTL); "refresh": true bypasses the cache
+// - Receivables and month-to-date spend reuse the aging report and outflow
+//   queries, so the numbers match those endpoints
+// - A goal is at risk when it is past or near its end date, or its linked
+//   budget is inactive or no longer has room for the target
+// - One currency per snapshot; amounts are in kobo
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+	"paystack.mpc.proxy/internal/money"
+	"paystack.mpc.proxy/internal/paystack"
+)
+
+// snapshotConcurrency caps how many snapshot sections run at once
+const snapshotConcurrency = 3
+
+// goalDueSoon is how close to its end date a pending goal counts as at risk
+const goalDueSoon = 7 * 24 * time.Hour
+
+// Snapshot section names, as used in the "errors" map
+const (
+	SnapshotSectionBalance     = "balance"
+	SnapshotSectionExpenses    = "pending_expenses"
+	SnapshotSectionReceivables = "receivables"
+	SnapshotSectionSpending    = "spending"
+	SnapshotSectionGoals       = "goals_at_risk"
+	SnapshotSectionRecent      = "recent_transactions"
+)
+
+// SnapshotHandler serves the account snapshot
+type SnapshotHandler struct {
+	client *paystack.Client
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	cache map[string]*AccountSnapshot
+}
+
+// NewSnapshotHandler creates a snapshot handler that caches results for ttl.
+// A zero ttl disables caching.
+func NewSnapshotHandler(client *paystack.Client, ttl time.Duration) *SnapshotHandler {
+	return &SnapshotHandler{client: client, ttl: ttl, cache: map[string]*AccountSnapshot{}}
+}
+
+// SnapshotRequest is the body of POST /account/snapshot
+type SnapshotRequest struct {
+	Currency string `json:"currency,omitempty"`
+	// Recent is how many recent ledger entries to include (default 5, max 50)
+	Recent  int  `json:"recent,omitempty"`
+	Refresh bool `json:"refresh,omitempty"`
+}
+
+// SnapshotBalance is the Paystack balance for the snapshot currency
+type SnapshotBalance struct {
+	Currency string `json:"currency"`
+	Balance  int    `json:"balance"`
+}
+
+// SnapshotExpense is one pending outgoing expense
+type SnapshotExpense struct {
+	ID            int       `json:"id"`
+	RecipientName string    `json:"recipient_name"`
+	Amount        int       `json:"amount"`
+	Category      string    `json:"category,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// SnapshotPendingExpenses totals expenses that have not been paid out yet.
+// Oldest lists the longest-waiting ones.
+type SnapshotPendingExpenses struct {
+	Count  int               `json:"count"`
+	Total  int               `json:"total"`
+	Oldest []SnapshotExpense `json:"oldest"`
+}
+
+// SnapshotReceivables totals what customers owe, from the aging report
+type SnapshotReceivables struct {
+	InvoiceCount int `json:"invoice_count"`
+	Outstanding  int `json:"outstanding"`
+	Overdue      int `json:"overdue"`
+	Over90       int `json:"over_90_days"`
+}
+
+// SnapshotBudget is an active budget and how much of it has been used
+type SnapshotBudget struct {
+	ID             int       `json:"id"`
+	Name           string    `json:"name"`
+	LimitType      string    `json:"limit_type"`
+	Amount         int       `json:"amount"`
+	SpentAmount    int       `json:"spent_amount"`
+	Remaining      int       `json:"remaining"`
+	UsagePercent   float64   `json:"usage_percentage"`
+	AlertThreshold int       `json:"alert_threshold"`
+	OverThreshold  bool      `json:"over_threshold"`
+	PeriodEnd      time.Time `json:"period_end"`
+}
+
+// SnapshotSpending is month-to-date outflow and the active budgets
+type SnapshotSpending struct {
+	MonthStart    string           `json:"month_start"`
+	MonthToDate   int              `json:"month_to_date"`
+	Budgets       []SnapshotBudget `json:"budgets"`
+	OverThreshold int              `json:"budgets_over_threshold"`
+}
+
+// SnapshotGoal is a pending goal that is at risk, with the reasons why
+type SnapshotGoal struct {
+	ID            int        `json:"id"`
+	Title         string     `json:"title"`
+	TargetAmount  int        `json:"target_amount"`
+	Priority      string     `json:"priority"`
+	EndDate       *time.Time `json:"end_date,omitempty"`
+	BudgetLimitID *int       `json:"budget_limit_id,omitempty"`
+	Reasons       []string   `json:"reasons"`
+}
+
+// AccountSnapshot is the combined account view. Sections that failed are
+// empty and their errors are listed under Errors.
+type AccountSnapshot struct {
+	Currency           string                   `json:"currency"`
+	GeneratedAt        time.Time                `json:"generated_at"`
+	Cached             bool                     `json:"cached"`
+	Summary            string                   `json:"summary"`
+	Balance            *SnapshotBalance         `json:"balance,omitempty"`
+	PendingExpenses    *SnapshotPendingExpenses `json:"pending_expenses,omitempty"`
+	Receivables        *SnapshotReceivables     `json:"receivables,omitempty"`
+	Spending           *SnapshotSpending        `json:"spending,omitempty"`
+	GoalsAtRisk        []SnapshotGoal           `json:"goals_at_risk"`
+	RecentTransactions []*LedgerEntry           `json:"recent_transactions"`
+	Errors             map[string]string        `json:"errors,omitempty"`
+}
+
+// Snapshot returns the account snapshot.
+// Body (optional): currency (default NGN), recent (default 5), refresh.
+func (h *SnapshotHandler) Snapshot(w http.ResponseWriter, r *http.Request) {
+	var req SnapshotRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJSONBadRequest(w, "Invalid request body")
+			return
+		}
+	}
+
+	req.Currency = strings.ToUpper(req.Currency)
+	if req.Currency == "" {
+		req.Currency = "NGN"
+	}
+	if req.Recent == 0 {
+		req.Recent = 5
+	}
+	if req.Recent < 0 || req.Recent > 50 {
+		WriteJSONBadRequest(w, "recent must be between 1 and 50")
+		return
+	}
+
+	key := fmt.Sprintf("%s|%d", req.Currency, req.Recent)
+	if !req.Refresh {
+		if cached := h.cached(key); cached != nil {
+			WriteJSONSuccess(w, cached)
+			return
+		}
+	}
+
+	snapshot := h.build(req.Currency, req.Recent, time.Now().UTC())
+	if len(snapshot.Errors) == 0 && h.ttl > 0 {
+		h.mu.Lock()
+		h.cache[key] = snapshot
+		h.mu.Unlock()
+	}
+	WriteJSONSuccess(w, snapshot)
+}
+
+// cached returns a copy of a fresh cached snapshot, or nil
+func (h *SnapshotHandler) cached(key string) *AccountSnapshot {
+	if h.ttl <= 0 {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	snapshot, ok := h.cache[key]
+	if !ok {
+		return nil
+	}
+	if time.Since(snapshot.GeneratedAt) >= h.ttl {
+		delete(h.cache, key)
+		return nil
+	}
+	copied := *snapshot
+	copied.Cached = true
+	return &copied
+}
+
+// build computes every section on a bounded pool and writes the summary
+func (h *SnapshotHandler) build(currency string, recent int, now time.Time) *AccountSnapshot {
+	snapshot := &AccountSnapshot{Currency: currency, GeneratedAt: now}
+
+	// Each section writes only its own field; errors are collected under errMu
+	sections := map[string]func() error{
+		SnapshotSectionBalance: func() (err error) {
+			snapshot.Balance, err = h.snapshotBalance(currency)
+			return err
+		},
+		SnapshotSectionExpenses: func() (err error) {
+			snapshot.PendingExpenses, err = snapshotPendingExpenses(currency)
+			return err
+		},
+		SnapshotSectionReceivables: func() (err error) {
+			snapshot.Receivables, err = snapshotReceivables(currency, now)
+			return err
+		},
+		SnapshotSectionSpending: func() (err error) {
+			snapshot.Spending, err = snapshotSpending(currency, now)
+			return err
+		},
+		SnapshotSectionGoals: func() (err error) {
+			snapshot.GoalsAtRisk, err = goalsAtRisk(now)
+			return err
+		},
+		SnapshotSectionRecent: func() (err error) {
+			snapshot.RecentTransactions, err = recentLedgerEntries(currency, recent)
+			return err
+		},
+	}
+
+	var errMu sync.Mutex
+	sem := make(chan struct{}, snapshotConcurrency)
+	var wg sync.WaitGroup
+	for name, run := range sections {
+		wg.Add(1)
+		go func(name string, run func() error) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if err := run(); err != nil {
+				errMu.Lock()
+				if snapshot.Errors == nil {
+					snapshot.Errors = map[string]string{}
+				}
+				snapshot.Errors[name] = err.Error()
+				errMu.Unlock()
+			}
+		}(name, run)
+	}
+	wg.Wait()
+
+	snapshot.Summary = snapshotSummary(snapshot)
+	return snapshot
+}
+
+// snapshotBalance returns the Paystack balance for currency
+func (h *SnapshotHandler) snapshotBalance(currency string) (*SnapshotBalance, error) {
+	balances, err := h.client.CheckBalances()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check balance: %w", err)
+	}
+	for _, b := range balances {
+		if strings.EqualFold(mapString(b, "currency"), currency) {
+			return &SnapshotBalance{Currency: currency, Balance: mapInt(b, "balance")}, nil
+		}
+	}
+	return nil, fmt.Errorf("no %s balance on the Paystack account", currency)
+}
+
+// snapshotPendingExpenses totals pending expenses and lists the oldest five
+func snapshotPendingExpenses(currency string) (*SnapshotPendingExpenses, error) {
+	pending := &SnapshotPendingExpenses{Oldest: []SnapshotExpense{}}
+
+	where := "status = 'pending' AND COALESCE(currency, 'NGN') = ?"
+	err := database.DB.QueryRow("SELECT COUNT(*), COALESCE(SUM(amount), 0) FROM expenses WHERE "+where, currency).
+		Scan(&pending.Count, &pending.Total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to total pending expenses: %w", err)
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT id, recipient_name, amount, category, created_at
+		FROM expenses WHERE `+where+`
+		ORDER BY created_at ASC, id ASC LIMIT 5`, currency)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending expenses: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e SnapshotExpense
+		var category sql.NullString
+		if err := rows.Scan(&e.ID, &e.RecipientName, &e.Amount, &category, &e.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan pending expense: %w", err)
+		}
+		e.Category = category.String
+		pending.Oldest = append(pending.Oldest, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating pending expenses: %w", err)
+	}
+	return pending, nil
+}
+
+// snapshotReceivables totals outstanding invoices in currency as of now
+func snapshotReceivables(currency string, now time.Time) (*SnapshotReceivables, error) {
+	asOf := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	invoices, err := outstandingInvoices(asOf, currency, "")
+	if err != nil {
+		return nil, err
+	}
+
+	receivables := &SnapshotReceivables{}
+	for _, c := range buildAgingReport(asOf, invoices).Currencies {
+		if c.Currency != currency {
+			continue
+		}
+		receivables.InvoiceCount = c.InvoiceCount
+		receivables.Outstanding = c.Total
+		receivables.Overdue = c.Total - c.Current
+		receivables.Over90 = c.Over90
+	}
+	return receivables, nil
+}
+
+// snapshotSpending sums outflow since the start of the month and loads the
+// budgets active now
+func snapshotSpending(currency string, now time.Time) (*SnapshotSpending, error) {
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	spent, err := sumOutflow(outflowFilter{Currency: currency}, monthStart, now.Add(time.Second))
+	if err != nil {
+		return nil, err
+	}
+
+	spending := &SnapshotSpending{
+		MonthStart:  monthStart.Format("2006-01-02"),
+		MonthToDate: spent,
+		Budgets:     []SnapshotBudget{},
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT id, name, limit_type, amount, COALESCE(spent_amount, 0), COALESCE(alert_threshold, 80), period_end
+		FROM budget_limits
+		WHERE status = 'active' AND period_start <= ? AND period_end >= ?
+		ORDER BY period_end ASC, id ASC`, now, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query active budgets: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b SnapshotBudget
+		if err := rows.Scan(&b.ID, &b.Name, &b.LimitType, &b.Amount, &b.SpentAmount, &b.AlertThreshold, &b.PeriodEnd); err != nil {
+			return nil, fmt.Errorf("failed to scan budget: %w", err)
+		}
+		b.Remaining = b.Amount - b.SpentAmount
+		if b.Amount > 0 {
+			b.UsagePercent = roundPercent(float64(b.SpentAmount) / float64(b.Amount) * 100)
+		}
+		b.OverThreshold = b.UsagePercent >= float64(b.AlertThreshold)
+		if b.OverThreshold {
+			spending.OverThreshold++
+		}
+		spending.Budgets = append(spending.Budgets, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating budgets: %w", err)
+	}
+	return spending, nil
+}
+
+// goalsAtRisk returns pending goals that are past or near their end date, or
+// whose linked budget can no longer fund them. High priority goals come first.
+func goalsAtRisk(now time.Time) ([]SnapshotGoal, error) {
+	rows, err := database.DB.Query(`
+		SELECT g.id, g.title, g.target_amount, COALESCE(g.priority, 'medium'), g.end_date, g.budget_limit_id,
+		       b.amount, b.spent_amount, b.status, b.period_end
+		FROM goals g
+		LEFT JOIN budget_limits b ON b.id = g.budget_limit_id
+		WHERE g.status = 'pending'`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query goals: %w", err)
+	}
+	defer rows.Close()
+
+	goals := []SnapshotGoal{}
+	for rows.Next() {
+		var g SnapshotGoal
+		var endDate, budgetEnd sql.NullTime
+		var budgetID, budgetAmount, budgetSpent sql.NullInt64
+		var budgetStatus sql.NullString
+		err := rows.Scan(&g.ID, &g.Title, &g.TargetAmount, &g.Priority, &endDate, &budgetID,
+			&budgetAmount, &budgetSpent, &budgetStatus, &budgetEnd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan goal: %w", err)
+		}
+		if endDate.Valid {
+			g.EndDate = &endDate.Time
+		}
+		if budgetID.Valid {
+			id := int(budgetID.Int64)
+			g.BudgetLimitID = &id
+		}
+
+		budget := goalBudget{}
+		if budgetAmount.Valid {
+			budget = goalBudget{
+				Linked:    true,
+				Remaining: int(budgetAmount.Int64 - budgetSpent.Int64),
+				Active:    budgetStatus.String == "active" && (!budgetEnd.Valid || !budgetEnd.Time.Before(now)),
+			}
+		}
+		g.Reasons = goalRiskReasons(g, budget, now)
+		if len(g.Reasons) > 0 {
+			goals = append(goals, g)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating goals: %w", err)
+	}
+
+	priorityRank := map[string]int{"high": 0, "medium": 1, "low": 2}
+	sort.SliceStable(goals, func(i, j int) bool {
+		if priorityRank[goals[i].Priority] != priorityRank[goals[j].Priority] {
+			return priorityRank[goals[i].Priority] < priorityRank[goals[j].Priority]
+		}
+		if goals[i].EndDate == nil || goals[j].EndDate == nil {
+			return goals[j].EndDate == nil && goals[i].EndDate != nil
+		}
+		return goals[i].EndDate.Before(*goals[j].EndDate)
+	})
+	return goals, nil
+}
+
+// goalBudget is the state of a goal's linked budget
+type goalBudget struct {
+	Linked    bool
+	Active    bool
+	Remaining int
+}
+
+// goalRiskReasons explains why a pending goal is at risk; empty means on track
+func goalRiskReasons(g SnapshotGoal, budget goalBudget, now time.Time) []string {
+	reasons := []string{}
+	if g.EndDate != nil {
+		if g.EndDate.Before(now) {
+			reasons = append(reasons, "past its end date")
+		} else if g.EndDate.Sub(now) <= goalDueSoon {
+			reasons = append(reasons, "ends within 7 days")
+		}
+	}
+	if budget.Linked {
+		if !budget.Active {
+			reasons = append(reasons, "linked budget is no longer active")
+		} else if budget.Remaining < g.TargetAmount {
+			reasons = append(reasons, "linked budget cannot cover the target")
+		}
+	}
+	return reasons
+}
+
+// recentLedgerEntries returns the latest n ledger entries in currency
+func recentLedgerEntries(currency string, n int) ([]*LedgerEntry, error) {
+	rows, err := database.DB.Query("SELECT "+ledgerEntryColumns+" FROM transactions WHERE currency = ?"+
+		" ORDER BY occurred_at DESC, id DESC LIMIT ?", currency, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ledger: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []*LedgerEntry{}
+	for rows.Next() {
+		entry, err := scanLedgerEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan ledger entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating ledger: %w", err)
+	}
+	return entries, nil
+}
+
+// snapshotSummary writes the plain-text summary read out by the voice agent
+func snapshotSummary(s *AccountSnapshot) string {
+	formatAmount := func(amount int) string { return money.Format(amount, s.Currency) }
+	parts := []string{}
+
+	if s.Balance != nil {
+		parts = append(parts, fmt.Sprintf("Your available balance is %s.", formatAmount(s.Balance.Balance)))
+	}
+
+	if p := s.PendingExpenses; p != nil {
+		if p.Count == 0 {
+			parts = append(parts, "No expenses are waiting to be paid.")
+		} else {
+			parts = append(parts, fmt.Sprintf("%s totalling %s %s waiting to be paid.",
+				countNoun(p.Count, "pending expense", "pending expenses"), formatAmount(p.Total), isAre(p.Count)))
+		}
+	}
+
+	if rc := s.Receivables; rc != nil {
+		if rc.InvoiceCount == 0 {
+			parts = append(parts, "No invoices are outstanding.")
+		} else {
+			line := fmt.Sprintf("Customers owe %s across %s", formatAmount(rc.Outstanding), countNoun(rc.InvoiceCount, "invoice", "invoices"))
+			if rc.Overdue > 0 {
+				line += fmt.Sprintf(", %s of it overdue", formatAmount(rc.Overdue))
+			}
+			parts = append(parts, line+".")
+		}
+	}
+
+	if sp := s.Spending; sp != nil {
+		parts = append(parts, fmt.Sprintf("You have spent %s so far this month.", formatAmount(sp.MonthToDate)))
+		if sp.OverThreshold > 0 {
+			parts = append(parts, fmt.Sprintf("%d of %s %s past the alert threshold.",
+				sp.OverThreshold, countNoun(len(sp.Budgets), "active budget", "active budgets"), isAre(sp.OverThreshold)))
+		}
+	}
+
+	if len(s.GoalsAtRisk) > 0 {
+		parts = append(parts, fmt.Sprintf("%s %s at risk, starting with %q.",
+			countNoun(len(s.GoalsAtRisk), "goal", "goals"), isAre(len(s.GoalsAtRisk)), s.GoalsAtRisk[0].Title))
+	}
+
+	if len(s.Errors) > 0 {
+		failed := []string{}
+		for name := range s.Errors {
+			failed = append(failed, strings.ReplaceAll(name, "_", " "))
+		}
+		sort.Strings(failed)
+		parts = append(parts, fmt.Sprintf("Some figures could not be loaded: %s.", joinStrings(failed, ", ")))
+	}
+
+	return joinStrings(parts, " ")
+}
+
+// countNoun formats n with the singular or plural noun
+func countNoun(n int, singular, plural string) string {
+	if n == 1 {
+		return "1 " + singular
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
+
+// isAre returns the verb agreeing with n
+func isAre(n int) string {
+	if n == 1 {
+		return "is"
+	}
+	return "are"
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGoalRiskReasons(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	date := func(d int) *time.Time { v := time.Date(2025, 3, d, 0, 0, 0, 0, time.UTC); return &v }
+
+	tests := []struct {
+		name   string
+		goal   SnapshotGoal
+		budget goalBudget
+		want   string
+	}{
+		{"on track", SnapshotGoal{TargetAmount: 5000, EndDate: date(30)}, goalBudget{Linked: true, Active: true, Remaining: 8000}, ""},
+		{"no end date or budget", SnapshotGoal{TargetAmount: 5000}, goalBudget{}, ""},
+		{"overdue", SnapshotGoal{EndDate: date(9)}, goalBudget{}, "past its end date"},
+		{"due soon", SnapshotGoal{EndDate: date(15)}, goalBudget{}, "ends within 7 days"},
+		{"budget short", SnapshotGoal{TargetAmount: 5000}, goalBudget{Linked: true, Active: true, Remaining: 4000}, "linked budget cannot cover the target"},
+		{"budget inactive", SnapshotGoal{TargetAmount: 5000, EndDate: date(11)}, goalBudget{Linked: true, Remaining: 9000}, "ends within 7 days,linked budget is no longer active"},
+	}
+	for _, tt := range tests {
+		if got := joinStrings(goalRiskReasons(tt.goal, tt.budget, now), ","); got != tt.want {
+			t.Errorf("%s: reasons = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSnapshotSummary(t *testing.T) {
+	snapshot := &AccountSnapshot{
+		Currency:        "NGN",
+		Balance:         &SnapshotBalance{Currency: "NGN", Balance: 1500000},
+		PendingExpenses: &SnapshotPendingExpenses{Count: 1, Total: 250000},
+		Receivables:     &SnapshotReceivables{InvoiceCount: 3, Outstanding: 900000, Overdue: 400000},
+		Spending:        &SnapshotSpending{MonthToDate: 700000, OverThreshold: 1, Budgets: make([]SnapshotBudget, 2)},
+		GoalsAtRisk:     []SnapshotGoal{{Title: "New laptop"}},
+		Errors:          map[string]string{SnapshotSectionRecent: "boom"},
+	}
+
+	summary := snapshotSummary(snapshot)
+	for _, want := range []string{
+		"1 pending expense totalling",
+		"across 3 invoices,",
+		"of it overdue.",
+		"1 of 2 active budgets is past the alert threshold.",
+		`1 goal is at risk, starting with "New laptop".`,
+		"Some figures could not be loaded: recent transactions.",
+	} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary missing %q: %s", want, summary)
+		}
+	}
+}
@@ -0,0 +1,617 @@
+// Package handlers implements HTTP handlers for the moniewave financial management system.
+//
+// Transaction Aggregates - Reporting
+//
+// OBJECTIVES:
+// Answer "how much, how often and compared to when" questions from local data.
+//
+// PURPOSE:
+// - Group ledger charges, ledger transfers and expenses by day, week, month,
+//   category, recipient, channel or status
+// - Return sums, counts and averages for a filtered date range
+// - Compare against the previous period of the same length
+// - Include a one-paragraph summary the voice agent can read out as is
+//
+// KEY WORKFLOW:
+// Validate Request → Load Entries For Current And Previous Period →
+// Group → Totals, Averages, Shares → Deltas → Summary
+//
+// DESIGN DECISIONS:
+// - Reads only local tables (the synced ledger and expenses), so it works offline
+// - Charges are money in; transfers and expenses are money out. Totals report
+//   inflow, outflow and net as well as the gross sum
+// - An expense paid by a transfer appears in both tables with one reference; it
+//   is counted once, as the expense, because only expenses have a category
+// - By default ledger entries count only once successful, while expenses count
+//   unless failed, cancelled, reversed or rejected (excludedPaymentStatuses), so
+//   pending and OTP expenses are included as committed spend, as in budget and
+//   limit checks; pass filters.status to narrow either
+// - Time groups include empty buckets and compare each bucket with the one
+//   before it; other groups compare with the same group in the previous period
+// - One currency per request; amounts are in kobo
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+	"paystack.mpc.proxy/internal/money"
+)
+
+// Aggregate sources
+const (
+	AggregateSourceTransactions = "transactions"
+	AggregateSourceTransfers    = "transfers"
+	AggregateSourceExpenses     = "expenses"
+)
+
+// Aggregate groupings
+const (
+	AggregateByDay       = "day"
+	AggregateByWeek      = "week"
+	AggregateByMonth     = "month"
+	AggregateByCategory  = "category"
+	AggregateByRecipient = "recipient"
+	AggregateByChannel   = "channel"
+	AggregateByStatus    = "status"
+)
+
+var aggregateGroupings = map[string]bool{
+	AggregateByDay: true, AggregateByWeek: true, AggregateByMonth: true,
+	AggregateByCategory: true, AggregateByRecipient: true, AggregateByChannel: true, AggregateByStatus: true,
+}
+
+// aggregateMaxDays caps the requested range; daily groups are capped separately
+const (
+	aggregateMaxDays      = 3 * 366
+	aggregateMaxDailyDays = 366
+)
+
+type AggregateHandler struct{}
+
+func NewAggregateHandler() *AggregateHandler {
+	return &AggregateHandler{}
+}
+
+type AggregateRequest struct {
+	// Sources defaults to all of transactions, transfers and expenses
+	Sources []string `json:"sources,omitempty"`
+	// GroupBy is day, week, month, category, recipient, channel or status (default month)
+	GroupBy string `json:"group_by,omitempty"`
+	// From and To are inclusive dates (YYYY-MM-DD); default the current month to date
+	From     string           `json:"from,omitempty"`
+	To       string           `json:"to,omitempty"`
+	Currency string           `json:"currency,omitempty"`
+	Filters  AggregateFilters `json:"filters,omitempty"`
+	// Limit caps the number of non-time groups returned, largest first (default 10)
+	Limit int `json:"limit,omitempty"`
+}
+
+type AggregateFilters struct {
+	Status        string `json:"status,omitempty"`
+	Category      string `json:"category,omitempty"`
+	RecipientCode string `json:"recipient_code,omitempty"`
+	Channel       string `json:"channel,omitempty"`
+}
+
+// AggregatePeriod is an inclusive date range
+type AggregatePeriod struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// AggregateTotals summarises a set of entries. Amounts are in kobo.
+type AggregateTotals struct {
+	Count   int `json:"count"`
+	Sum     int `json:"sum"`
+	Average int `json:"average"`
+	Inflow  int `json:"inflow"`
+	Outflow int `json:"outflow"`
+	Net     int `json:"net"`
+}
+
+// AggregateDelta compares a value with the previous period or bucket. Percent
+// is nil when the previous value was zero.
+type AggregateDelta struct {
+	Sum          int      `json:"sum"`
+	SumPercent   *float64 `json:"sum_percent"`
+	Count        int      `json:"count"`
+	CountPercent *float64 `json:"count_percent"`
+}
+
+// AggregateGroup is one row of the grouped result
+type AggregateGroup struct {
+	Key          string           `json:"key"`
+	Label        string           `json:"label"`
+	Count        int              `json:"count"`
+	Sum          int              `json:"sum"`
+	Average      int              `json:"average"`
+	SharePercent float64          `json:"share_percent"`
+	Previous     *AggregateTotals `json:"previous,omitempty"`
+	Delta        *AggregateDelta  `json:"delta,omitempty"`
+}
+
+// AggregateResult is the response of the aggregate endpoint
+type AggregateResult struct {
+	Summary        string           `json:"summary"`
+	Currency       string           `json:"currency"`
+	GroupBy        string           `json:"group_by"`
+	Sources        []string         `json:"sources"`
+	Period         AggregatePeriod  `json:"period"`
+	PreviousPeriod AggregatePeriod  `json:"previous_period"`
+	Totals         AggregateTotals  `json:"totals"`
+	PreviousTotals AggregateTotals  `json:"previous_totals"`
+	Delta          AggregateDelta   `json:"delta"`
+	Groups         []AggregateGroup `json:"groups"`
+	// OtherGroups counts groups left out by the limit
+	OtherGroups int `json:"other_groups,omitempty"`
+}
+
+// aggregateEntry is one charge, transfer or expense, normalised for grouping
+type aggregateEntry struct {
+	Source    string
+	Inflow    bool
+	Amount    int
+	Date      time.Time
+	Category  string
+	Recipient string
+	Label     string
+	Channel   string
+	Status    string
+}
+
+// Aggregate groups locally stored money movement
+func (h *AggregateHandler) Aggregate(w http.ResponseWriter, r *http.Request) {
+	var req AggregateRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJSONBadRequest(w, "Invalid request body")
+			return
+		}
+	}
+
+	if req.GroupBy == "" {
+		req.GroupBy = AggregateByMonth
+	}
+	if !aggregateGroupings[req.GroupBy] {
+		WriteJSONBadRequest(w, "group_by must be day, week, month, category, recipient, channel or status")
+		return
+	}
+	if len(req.Sources) == 0 {
+		req.Sources = []string{AggregateSourceTransactions, AggregateSourceTransfers, AggregateSourceExpenses}
+	}
+	for _, source := range req.Sources {
+		if source != AggregateSourceTransactions && source != AggregateSourceTransfers && source != AggregateSourceExpenses {
+			WriteJSONBadRequest(w, "sources must be transactions, transfers or expenses")
+			return
+		}
+	}
+	req.Currency = strings.ToUpper(req.Currency)
+	if req.Currency == "" {
+		req.Currency = "NGN"
+	}
+	if req.Limit <= 0 {
+		req.Limit = 10
+	}
+
+	now := time.Now().UTC()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if req.To != "" {
+		parsed, err := time.Parse("2006-01-02", req.To)
+		if err != nil {
+			WriteJSONBadRequest(w, "to must be a date (YYYY-MM-DD)")
+			return
+		}
+		to = parsed
+	}
+	from := time.Date(to.Year(), to.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if req.From != "" {
+		parsed, err := time.Parse("2006-01-02", req.From)
+		if err != nil {
+			WriteJSONBadRequest(w, "from must be a date (YYYY-MM-DD)")
+			return
+		}
+		from = parsed
+	}
+	if from.After(to) {
+		WriteJSONBadRequest(w, "from must not be after to")
+		return
+	}
+	days := int(to.Sub(from).Hours()/24) + 1
+	if days > aggregateMaxDays {
+		WriteJSONBadRequest(w, fmt.Sprintf("the date range must be at most %d days", aggregateMaxDays))
+		return
+	}
+	if req.GroupBy == AggregateByDay && days > aggregateMaxDailyDays {
+		WriteJSONBadRequest(w, fmt.Sprintf("daily groups cover at most %d days; group by week or month instead", aggregateMaxDailyDays))
+		return
+	}
+
+	toEnd := to.AddDate(0, 0, 1)
+	previousFrom := from.AddDate(0, 0, -days)
+
+	entries, err := loadAggregateEntries(req, previousFrom, toEnd)
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	result := buildAggregate(req, entries, from, to)
+	WriteJSONSuccess(w, result)
+}
+
+// loadAggregateEntries reads the requested sources in [from, toEnd)
+func loadAggregateEntries(req AggregateRequest, from, toEnd time.Time) ([]aggregateEntry, error) {
+	entries := []aggregateEntry{}
+	includeExpenses := false
+	for _, source := range req.Sources {
+		if source == AggregateSourceExpenses {
+			includeExpenses = true
+		}
+	}
+
+	for _, source := range req.Sources {
+		var query string
+		args := []interface{}{from, toEnd, req.Currency}
+
+		switch source {
+		case AggregateSourceTransactions, AggregateSourceTransfers:
+			entryType := LedgerTypeCharge
+			if source == AggregateSourceTransfers {
+				entryType = LedgerTypeTransfer
+			}
+			query = `
+				SELECT amount, occurred_at, NULL, '',
+				       CASE WHEN type = 'charge' THEN COALESCE(customer_email, '') ELSE COALESCE(recipient_code, '') END,
+				       CASE WHEN type = 'charge' THEN COALESCE(customer_email, '') ELSE COALESCE(recipient_name, '') END,
+				       COALESCE(channel, ''), COALESCE(status, '')
+				FROM transactions
+				WHERE occurred_at >= ? AND occurred_at < ? AND currency = ? AND type = ?`
+			args = append(args, entryType)
+			if req.Filters.Status != "" {
+				query += " AND status = ?"
+				args = append(args, req.Filters.Status)
+			} else {
+				query += " AND status = 'success'"
+			}
+			if req.Filters.Category != "" {
+				// Ledger entries have no category
+				continue
+			}
+			if req.Filters.RecipientCode != "" {
+				query += " AND (recipient_code = ? OR customer_code = ?)"
+				args = append(args, req.Filters.RecipientCode, req.Filters.RecipientCode)
+			}
+			if req.Filters.Channel != "" {
+				query += " AND channel = ?"
+				args = append(args, req.Filters.Channel)
+			}
+			if source == AggregateSourceTransfers && includeExpenses {
+				query += " AND reference NOT IN (SELECT reference FROM expenses WHERE reference IS NOT NULL)"
+			}
+
+		case AggregateSourceExpenses:
+			if req.Filters.Channel != "" {
+				// Expenses have no channel
+				continue
+			}
+			query = `
+				SELECT amount, payment_date, created_at, COALESCE(category, ''),
+				       recipient_code, recipient_name, '', COALESCE(status, '')
+				FROM expenses
+				WHERE COALESCE(payment_date, created_at) >= ? AND COALESCE(payment_date, created_at) < ?
+				  AND COALESCE(currency, 'NGN') = ?`
+			if req.Filters.Status != "" {
+				query += " AND status = ?"
+				args = append(args, req.Filters.Status)
+			} else {
+				query += " AND status NOT IN (" + excludedPaymentStatuses + ")"
+			}
+			if req.Filters.Category != "" {
+				query += " AND LOWER(category) = LOWER(?)"
+				args = append(args, req.Filters.Category)
+			}
+			if req.Filters.RecipientCode != "" {
+				query += " AND recipient_code = ?"
+				args = append(args, req.Filters.RecipientCode)
+			}
+		}
+
+		rows, err := database.DB.Query(query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query %s: %w", source, err)
+		}
+		for rows.Next() {
+			e := aggregateEntry{Source: source, Inflow: source == AggregateSourceTransactions}
+			// COALESCE would lose the column type, so both dates are read
+			var date, fallbackDate sql.NullTime
+			if err := rows.Scan(&e.Amount, &date, &fallbackDate, &e.Category, &e.Recipient, &e.Label, &e.Channel, &e.Status); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to scan %s: %w", source, err)
+			}
+			e.Date = date.Time
+			if !date.Valid {
+				e.Date = fallbackDate.Time
+			}
+			entries = append(entries, e)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error iterating %s: %w", source, err)
+		}
+	}
+
+	return entries, nil
+}
+
+// buildAggregate groups entries for [from, to] and compares them with the
+// period of the same length before from
+func buildAggregate(req AggregateRequest, entries []aggregateEntry, from, to time.Time) *AggregateResult {
+	days := int(to.Sub(from).Hours()/24) + 1
+	previousFrom := from.AddDate(0, 0, -days)
+	toEnd := to.AddDate(0, 0, 1)
+
+	result := &AggregateResult{
+		Currency:       req.Currency,
+		GroupBy:        req.GroupBy,
+		Sources:        req.Sources,
+		Period:         AggregatePeriod{From: from.Format("2006-01-02"), To: to.Format("2006-01-02")},
+		PreviousPeriod: AggregatePeriod{From: previousFrom.Format("2006-01-02"), To: from.AddDate(0, 0, -1).Format("2006-01-02")},
+		Groups:         []AggregateGroup{},
+	}
+
+	current := map[string]*AggregateTotals{}
+	previous := map[string]*AggregateTotals{}
+	labels := map[string]string{}
+	for _, e := range entries {
+		key, label := aggregateKey(req.GroupBy, e)
+		var totals *AggregateTotals
+		var groups map[string]*AggregateTotals
+		switch {
+		case !e.Date.Before(from) && e.Date.Before(toEnd):
+			totals, groups = &result.Totals, current
+		case !e.Date.Before(previousFrom) && e.Date.Before(from):
+			totals, groups = &result.PreviousTotals, previous
+		default:
+			continue
+		}
+		totals.add(e)
+		if groups[key] == nil {
+			groups[key] = &AggregateTotals{}
+		}
+		groups[key].add(e)
+		if _, ok := labels[key]; !ok {
+			labels[key] = label
+		}
+	}
+	result.Totals.finish()
+	result.PreviousTotals.finish()
+	result.Delta = aggregateDelta(result.Totals, result.PreviousTotals)
+
+	if isTimeGrouping(req.GroupBy) {
+		var last *AggregateTotals
+		for _, key := range timeBuckets(req.GroupBy, from, to) {
+			totals := current[key]
+			if totals == nil {
+				totals = &AggregateTotals{}
+			}
+			totals.finish()
+			group := aggregateGroup(key, key, totals, result.Totals.Sum)
+			if last != nil {
+				delta := aggregateDelta(*totals, *last)
+				group.Delta = &delta
+			}
+			result.Groups = append(result.Groups, group)
+			last = totals
+		}
+	} else {
+		for key, totals := range current {
+			totals.finish()
+			group := aggregateGroup(key, labels[key], totals, result.Totals.Sum)
+			prev := previous[key]
+			if prev == nil {
+				prev = &AggregateTotals{}
+			}
+			prev.finish()
+			group.Previous = prev
+			delta := aggregateDelta(*totals, *prev)
+			group.Delta = &delta
+			result.Groups = append(result.Groups, group)
+		}
+		sort.Slice(result.Groups, func(i, j int) bool {
+			if result.Groups[i].Sum != result.Groups[j].Sum {
+				return result.Groups[i].Sum > result.Groups[j].Sum
+			}
+			return result.Groups[i].Key < result.Groups[j].Key
+		})
+		if len(result.Groups) > req.Limit {
+			result.OtherGroups = len(result.Groups) - req.Limit
+			result.Groups = result.Groups[:req.Limit]
+		}
+	}
+
+	result.Summary = aggregateSummary(result)
+	return result
+}
+
+func (t *AggregateTotals) add(e aggregateEntry) {
+	t.Count++
+	t.Sum += e.Amount
+	if e.Inflow {
+		t.Inflow += e.Amount
+	} else {
+		t.Outflow += e.Amount
+	}
+}
+
+func (t *AggregateTotals) finish() {
+	t.Net = t.Inflow - t.Outflow
+	t.Average = 0
+	if t.Count > 0 {
+		t.Average = t.Sum / t.Count
+	}
+}
+
+func aggregateGroup(key, label string, totals *AggregateTotals, total int) AggregateGroup {
+	group := AggregateGroup{Key: key, Label: label, Count: totals.Count, Sum: totals.Sum, Average: totals.Average}
+	if total > 0 {
+		group.SharePercent = roundPercent(float64(totals.Sum) * 100 / float64(total))
+	}
+	return group
+}
+
+func aggregateDelta(current, previous AggregateTotals) AggregateDelta {
+	delta := AggregateDelta{Sum: current.Sum - previous.Sum, Count: current.Count - previous.Count}
+	if previous.Sum != 0 {
+		pct := roundPercent(float64(delta.Sum) * 100 / float64(previous.Sum))
+		delta.SumPercent = &pct
+	}
+	if previous.Count != 0 {
+		pct := roundPercent(float64(delta.Count) * 100 / float64(previous.Count))
+		delta.CountPercent = &pct
+	}
+	return delta
+}
+
+// roundPercent rounds to one decimal place
+func roundPercent(v float64) float64 {
+	if v < 0 {
+		return -float64(int(-v*10+0.5)) / 10
+	}
+	return float64(int(v*10+0.5)) / 10
+}
+
+func isTimeGrouping(groupBy string) bool {
+	return groupBy == AggregateByDay || groupBy == AggregateByWeek || groupBy == AggregateByMonth
+}
+
+// aggregateKey returns the group key and display label of an entry
+func aggregateKey(groupBy string, e aggregateEntry) (string, string) {
+	switch groupBy {
+	case AggregateByDay, AggregateByWeek, AggregateByMonth:
+		key := timeBucket(groupBy, e.Date)
+		return key, key
+	case AggregateByCategory:
+		if e.Category == "" {
+			return "uncategorised", "Uncategorised"
+		}
+		return strings.ToLower(e.Category), e.Category
+	case AggregateByRecipient:
+		if e.Recipient == "" {
+			return "unknown", "Unknown"
+		}
+		label := e.Label
+		if label == "" {
+			label = e.Recipient
+		}
+		return e.Recipient, label
+	case AggregateByChannel:
+		if e.Channel == "" {
+			if e.Source == AggregateSourceExpenses {
+				return "expense", "Expense"
+			}
+			return "unknown", "Unknown"
+		}
+		return e.Channel, strings.ReplaceAll(e.Channel, "_", " ")
+	default:
+		if e.Status == "" {
+			return "unknown", "Unknown"
+		}
+		return e.Status, e.Status
+	}
+}
+
+// timeBucket names the day (2025-03-04), ISO week (2025-W10) or month (2025-03) of t
+func timeBucket(groupBy string, t time.Time) string {
+	t = t.UTC()
+	switch groupBy {
+	case AggregateByDay:
+		return t.Format("2006-01-02")
+	case AggregateByWeek:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	default:
+		return t.Format("2006-01")
+	}
+}
+
+// timeBuckets lists every bucket touching [from, to], in order
+func timeBuckets(groupBy string, from, to time.Time) []string {
+	buckets := []string{}
+	seen := map[string]bool{}
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if key := timeBucket(groupBy, d); !seen[key] {
+			seen[key] = true
+			buckets = append(buckets, key)
+		}
+	}
+	return buckets
+}
+
+// aggregateSummary describes the result in plain sentences
+func aggregateSummary(result *AggregateResult) string {
+	from, _ := time.Parse("2006-01-02", result.Period.From)
+	to, _ := time.Parse("2006-01-02", result.Period.To)
+	formatAmount := func(amount int) string { return money.Format(amount, result.Currency) }
+
+	if result.Totals.Count == 0 {
+		return fmt.Sprintf("No %s found between %s and %s.", joinStrings(result.Sources, ", "), from.Format("2 Jan 2006"), to.Format("2 Jan 2006"))
+	}
+
+	counted := fmt.Sprintf("there were %d entries", result.Totals.Count)
+	if result.Totals.Count == 1 {
+		counted = "there was 1 entry"
+	}
+	parts := []string{fmt.Sprintf("Between %s and %s %s totalling %s, averaging %s each.",
+		from.Format("2 Jan 2006"), to.Format("2 Jan 2006"), counted, formatAmount(result.Totals.Sum), formatAmount(result.Totals.Average))}
+
+	if result.Totals.Inflow > 0 && result.Totals.Outflow > 0 {
+		parts = append(parts, fmt.Sprintf("Money in was %s and money out %s, a net of %s.",
+			formatAmount(result.Totals.Inflow), formatAmount(result.Totals.Outflow), formatAmount(result.Totals.Net)))
+	}
+
+	if pct := result.Delta.SumPercent; pct != nil {
+		direction := "up"
+		if *pct < 0 {
+			direction = "down"
+		}
+		if *pct == 0 {
+			parts = append(parts, "That is unchanged from the previous period.")
+		} else {
+			parts = append(parts, fmt.Sprintf("That is %s %.1f%% from %s in the previous period.", direction, absPercent(*pct), formatAmount(result.PreviousTotals.Sum)))
+		}
+	} else {
+		parts = append(parts, "There was nothing in the previous period to compare with.")
+	}
+
+	if !isTimeGrouping(result.GroupBy) && len(result.Groups) > 0 {
+		top := result.Groups[0]
+		parts = append(parts, fmt.Sprintf("The largest %s was %s with %s (%.1f%%).", result.GroupBy, top.Label, formatAmount(top.Sum), top.SharePercent))
+	} else if isTimeGrouping(result.GroupBy) {
+		busiest := -1
+		for i, g := range result.Groups {
+			if busiest < 0 || g.Sum > result.Groups[busiest].Sum {
+				busiest = i
+			}
+		}
+		if busiest >= 0 && len(result.Groups) > 1 {
+			parts = append(parts, fmt.Sprintf("The busiest %s was %s with %s.", result.GroupBy, result.Groups[busiest].Label, formatAmount(result.Groups[busiest].Sum)))
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func absPercent(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildAggregateByCategory(t *testing.T) {
+	day := func(m time.Month, d int) time.Time { return time.Date(2025, m, d, 12, 0, 0, 0, time.UTC) }
+	entries := []aggregateEntry{
+		{Source: AggregateSourceExpenses, Amount: 6000, Date: day(3, 2), Category: "Rent"},
+		{Source: AggregateSourceExpenses, Amount: 2000, Date: day(3, 9), Category: "rent"},
+		{Source: AggregateSourceExpenses, Amount: 2000, Date: day(3, 15), Category: "Food"},
+		{Source: AggregateSourceTransactions, Inflow: true, Amount: 5000, Date: day(3, 20)},
+		{Source: AggregateSourceExpenses, Amount: 4000, Date: day(2, 10), Category: "Rent"},
+		{Source: AggregateSourceExpenses, Amount: 9999, Date: day(1, 5), Category: "Rent"},
+	}
+	req := AggregateRequest{GroupBy: AggregateByCategory, Currency: "NGN", Limit: 2, Sources: []string{"expenses", "transactions"}}
+
+	result := buildAggregate(req, entries, day(3, 1).Truncate(24*time.Hour), day(3, 31).Truncate(24*time.Hour))
+
+	if result.Totals.Count != 4 || result.Totals.Sum != 15000 || result.Totals.Net != -5000 {
+		t.Errorf("totals = %+v", result.Totals)
+	}
+	// The previous period is 29 Jan - 28 Feb; the 5 Jan entry falls outside it
+	if result.PreviousTotals.Sum != 4000 {
+		t.Errorf("previous sum = %d, want 4000", result.PreviousTotals.Sum)
+	}
+	if len(result.Groups) != 2 || result.OtherGroups != 1 {
+		t.Fatalf("got %d groups (+%d), want 2 (+1)", len(result.Groups), result.OtherGroups)
+	}
+	rent := result.Groups[0]
+	if rent.Key != "rent" || rent.Sum != 8000 || rent.Previous.Sum != 4000 || *rent.Delta.SumPercent != 100 {
+		t.Errorf("rent group = %+v, delta %+v", rent, rent.Delta)
+	}
+	if !strings.Contains(result.Summary, "up 275.0%") || !strings.Contains(result.Summary, "largest category was Rent") {
+		t.Errorf("summary = %q", result.Summary)
+	}
+}
+
+func TestTimeBuckets(t *testing.T) {
+	from := time.Date(2025, 1, 30, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	if got := joinStrings(timeBuckets(AggregateByMonth, from, to), ","); got != "2025-01,2025-02,2025-03" {
+		t.Errorf("month buckets = %s", got)
+	}
+	weeks := timeBuckets(AggregateByWeek, from, to)
+	if weeks[0] != "2025-W05" || weeks[len(weeks)-1] != "2025-W09" {
+		t.Errorf("week buckets = %v", weeks)
+	}
+}
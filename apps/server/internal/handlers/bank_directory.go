@@ -0,0 +1,391 @@
+// Package handlers implements HTTP handlers for the moniewave financial management system.
+//
+// Bank Directory - Payment Infrastructure
+//
+// OBJECTIVES:
+// Answer "which bank is this?" without calling Paystack every time.
+//
+// PURPOSE:
+// - Cache the Paystack bank list in SQLite with a TTL
+// - Search banks by name, slug or code with country, currency and type filters
+// - Look up bank codes for recipient creation without a network call
+// - Backfill missing bank names on cached recipients
+//
+// KEY WORKFLOW:
+// Lookup / Search → Serve From SQLite
+// Background Job / Manual Refresh → Stale? → Page Through Paystack Banks → Replace Cache
+//
+// DESIGN DECISIONS:
+// - A refresh replaces the whole cache in one transaction, so banks Paystack
+//   removes disappear and readers never see a half-written list
+// - Lookups and searches only read the table; refreshes run from the background
+//   job or POST /banks/refresh, so a slow Paystack never blocks recipient creation
+// - Refreshes page with Paystack's cursor (meta.next) and stop at
+//   bankDirectoryMaxPages, so a cursor that never ends cannot hold the lock forever
+// - If a refresh fails, the stale cache keeps being served and the error is logged
+// - An empty cache never rejects a bank code; callers fall back to Paystack
+// - Refreshes are serialised so concurrent triggers run at most one fetch
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+	"paystack.mpc.proxy/internal/paystack"
+)
+
+const (
+	// bankDirectoryPageSize is the Paystack page size used while refreshing
+	bankDirectoryPageSize = 100
+	// bankDirectoryMaxPages caps the pages fetched per country in one refresh
+	bankDirectoryMaxPages = 20
+)
+
+var (
+	// ErrUnknownBank is returned when a bank code is not in the cached directory
+	ErrUnknownBank = errors.New("unknown bank code")
+	// ErrBankDirectoryEmpty is returned when the directory has never been loaded
+	ErrBankDirectoryEmpty = errors.New("bank directory is empty")
+)
+
+// BankDirectory is the SQLite-backed cache of the Paystack bank list
+type BankDirectory struct {
+	client    *paystack.Client
+	ttl       time.Duration
+	countries []string
+	mu        sync.Mutex
+}
+
+// NewBankDirectory creates a bank directory for the given Paystack countries
+func NewBankDirectory(client *paystack.Client, ttl time.Duration, countries []string) *BankDirectory {
+	return &BankDirectory{client: client, ttl: ttl, countries: countries}
+}
+
+// CachedBank is a bank from the cached directory
+type CachedBank struct {
+	PaystackID       int       `json:"id"`
+	Name             string    `json:"name"`
+	Slug             string    `json:"slug"`
+	Code             string    `json:"code"`
+	LongCode         string    `json:"long_code,omitempty"`
+	Gateway          string    `json:"gateway,omitempty"`
+	Country          string    `json:"country"`
+	Currency         string    `json:"currency"`
+	Type             string    `json:"type"`
+	PayWithBank      bool      `json:"pay_with_bank"`
+	SupportsTransfer bool      `json:"supports_transfer"`
+	Active           bool      `json:"active"`
+	FetchedAt        time.Time `json:"fetched_at"`
+}
+
+// BankSearch filters a directory search; empty fields match everything
+type BankSearch struct {
+	Query      string `json:"search,omitempty"`
+	Country    string `json:"country,omitempty"`
+	Currency   string `json:"currency,omitempty"`
+	Type       string `json:"type,omitempty"`
+	ActiveOnly bool   `json:"active_only,omitempty"`
+}
+
+// BankRefreshReport summarises a directory refresh
+type BankRefreshReport struct {
+	StartedAt          time.Time      `json:"started_at"`
+	FinishedAt         time.Time      `json:"finished_at"`
+	Countries          map[string]int `json:"countries"`
+	Total              int            `json:"total"`
+	RecipientsBackfill int64          `json:"recipients_backfilled"`
+}
+
+const cachedBankColumns = `paystack_id, name, COALESCE(slug, ''), code, COALESCE(long_code, ''), COALESCE(gateway, ''),
+	COALESCE(country, ''), COALESCE(currency, ''), COALESCE(type, ''), pay_with_bank, supports_transfer, active, fetched_at`
+
+// scanCachedBank scans a row selected with cachedBankColumns
+func scanCachedBank(row rowScanner) (*CachedBank, error) {
+	var bank CachedBank
+	err := row.Scan(
+		&bank.PaystackID,
+		&bank.Name,
+		&bank.Slug,
+		&bank.Code,
+		&bank.LongCode,
+		&bank.Gateway,
+		&bank.Country,
+		&bank.Currency,
+		&bank.Type,
+		&bank.PayWithBank,
+		&bank.SupportsTransfer,
+		&bank.Active,
+		&bank.FetchedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &bank, nil
+}
+
+// lastFetched returns when the cache was last refreshed; ok is false when it is empty
+func (d *BankDirectory) lastFetched() (last time.Time, ok bool, err error) {
+	err = database.DB.QueryRow("SELECT fetched_at FROM banks ORDER BY fetched_at DESC LIMIT 1").Scan(&last)
+	if err == sql.ErrNoRows {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to read bank directory: %w", err)
+	}
+	return last, true, nil
+}
+
+// EnsureFresh refreshes the cache when it is empty or older than the TTL.
+// A failed refresh of a non-empty cache is logged and the stale data is kept.
+func (d *BankDirectory) EnsureFresh() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Checked under the lock so callers that waited on a refresh reuse its result
+	last, cached, err := d.lastFetched()
+	if err != nil {
+		return err
+	}
+	if cached && time.Since(last) < d.ttl {
+		return nil
+	}
+
+	if _, err := d.refreshLocked(); err != nil {
+		if cached {
+			log.Printf("Warning: bank directory refresh failed, serving cached banks from %s: %v", last.Format(time.RFC3339), err)
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// Refresh reloads the bank list from Paystack and replaces the cache
+func (d *BankDirectory) Refresh() (*BankRefreshReport, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.refreshLocked()
+}
+
+// refreshLocked runs a refresh and records it in sync_runs; d.mu must be held
+func (d *BankDirectory) refreshLocked() (*BankRefreshReport, error) {
+	report := &BankRefreshReport{
+		StartedAt: time.Now(),
+		Countries: map[string]int{},
+	}
+
+	err := d.refresh(report)
+	report.FinishedAt = time.Now()
+	recordSyncRun("banks", report.StartedAt, report, err)
+
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (d *BankDirectory) refresh(report *BankRefreshReport) error {
+	banks := []CachedBank{}
+	for _, country := range d.countries {
+		fetched, err := d.fetchCountry(country)
+		if err != nil {
+			return err
+		}
+		report.Countries[country] = len(fetched)
+		banks = append(banks, fetched...)
+	}
+
+	if len(banks) == 0 {
+		return fmt.Errorf("Paystack returned no banks for %s", strings.Join(d.countries, ", "))
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM banks"); err != nil {
+		return fmt.Errorf("failed to clear bank directory: %w", err)
+	}
+
+	now := time.Now()
+	for _, bank := range banks {
+		_, err := tx.Exec(`
+			INSERT INTO banks (
+				paystack_id, name, slug, code, long_code, gateway, country, currency, type,
+				pay_with_bank, supports_transfer, active, fetched_at
+			)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(paystack_id) DO NOTHING
+		`, bank.PaystackID, bank.Name, bank.Slug, bank.Code, bank.LongCode, bank.Gateway, bank.Country,
+			bank.Currency, bank.Type, bank.PayWithBank, bank.SupportsTransfer, bank.Active, now)
+		if err != nil {
+			return fmt.Errorf("failed to cache bank %s: %w", bank.Code, err)
+		}
+	}
+
+	// Fill in bank names that recipients were cached without
+	result, err := tx.Exec(`
+		UPDATE recipients
+		SET bank_name = (
+			SELECT b.name FROM banks b
+			WHERE b.code = recipients.bank_code AND (recipients.currency IS NULL OR b.currency = recipients.currency)
+			LIMIT 1
+		)
+		WHERE COALESCE(bank_name, '') = ''
+		AND EXISTS (
+			SELECT 1 FROM banks b
+			WHERE b.code = recipients.bank_code AND (recipients.currency IS NULL OR b.currency = recipients.currency)
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to backfill recipient bank names: %w", err)
+	}
+	report.RecipientsBackfill, _ = result.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit bank directory: %w", err)
+	}
+
+	report.Total = len(banks)
+	return nil
+}
+
+// fetchCountry follows the Paystack cursor through every bank for one country
+func (d *BankDirectory) fetchCountry(country string) ([]CachedBank, error) {
+	banks := []CachedBank{}
+	next := ""
+
+	for page := 1; ; page++ {
+		if page > bankDirectoryMaxPages {
+			return nil, fmt.Errorf("%s bank list exceeded %d pages", country, bankDirectoryMaxPages)
+		}
+
+		resp, err := d.client.ListBanks(country, bankDirectoryPageSize, next)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s banks (page %d): %w", country, page, err)
+		}
+
+		data, _ := resp["data"].([]interface{})
+		for _, item := range data {
+			m, ok := item.(map[string]interface{})
+			if !ok || mapBool(m, "is_deleted") {
+				continue
+			}
+
+			// supports_transfer is missing on older responses; treat as supported
+			supportsTransfer := true
+			if v, ok := m["supports_transfer"].(bool); ok {
+				supportsTransfer = v
+			}
+
+			banks = append(banks, CachedBank{
+				PaystackID:       mapInt(m, "id"),
+				Name:             mapString(m, "name"),
+				Slug:             mapString(m, "slug"),
+				Code:             mapString(m, "code"),
+				LongCode:         mapString(m, "longcode"),
+				Gateway:          mapString(m, "gateway"),
+				Country:          mapString(m, "country"),
+				Currency:         mapString(m, "currency"),
+				Type:             mapString(m, "type"),
+				PayWithBank:      mapBool(m, "pay_with_bank"),
+				SupportsTransfer: supportsTransfer,
+				Active:           mapBool(m, "active"),
+			})
+		}
+
+		next = mapString(mapObject(resp, "meta"), "next")
+		if next == "" || len(data) == 0 {
+			break
+		}
+	}
+
+	return banks, nil
+}
+
+// Search returns cached banks matching the filter, ordered by name. It never
+// calls Paystack; an empty cache returns no banks.
+func (d *BankDirectory) Search(filter BankSearch) ([]CachedBank, error) {
+	query := "SELECT " + cachedBankColumns + " FROM banks WHERE 1=1"
+	args := []interface{}{}
+
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		like := "%" + escapeLike(q) + "%"
+		query += ` AND (name LIKE ? ESCAPE '\' OR slug LIKE ? ESCAPE '\' OR code = ?)`
+		args = append(args, like, like, q)
+	}
+
+	if filter.Country != "" {
+		query += " AND LOWER(country) = LOWER(?)"
+		args = append(args, filter.Country)
+	}
+
+	if filter.Currency != "" {
+		query += " AND UPPER(currency) = UPPER(?)"
+		args = append(args, filter.Currency)
+	}
+
+	if filter.Type != "" {
+		query += " AND type = ?"
+		args = append(args, filter.Type)
+	}
+
+	if filter.ActiveOnly {
+		query += " AND active = 1"
+	}
+
+	query += " ORDER BY name"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search banks: %w", err)
+	}
+	defer rows.Close()
+
+	banks := []CachedBank{}
+	for rows.Next() {
+		bank, err := scanCachedBank(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan bank: %w", err)
+		}
+		banks = append(banks, *bank)
+	}
+
+	return banks, rows.Err()
+}
+
+// LookupCode finds a bank by code (and currency, when given) in the cache.
+// It returns ErrUnknownBank when the directory is loaded but has no such bank,
+// and ErrBankDirectoryEmpty when the directory has not been loaded yet.
+// It never calls Paystack.
+func (d *BankDirectory) LookupCode(code, currency string) (*CachedBank, error) {
+	query := "SELECT " + cachedBankColumns + " FROM banks WHERE code = ?"
+	args := []interface{}{code}
+	if currency != "" {
+		query += " AND UPPER(currency) = UPPER(?)"
+		args = append(args, currency)
+	}
+	query += " ORDER BY active DESC LIMIT 1"
+
+	bank, err := scanCachedBank(database.DB.QueryRow(query, args...))
+	if err == nil {
+		return bank, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to look up bank %s: %w", code, err)
+	}
+
+	if _, cached, err := d.lastFetched(); err == nil && !cached {
+		return nil, ErrBankDirectoryEmpty
+	}
+	return nil, ErrUnknownBank
+}
@@ -0,0 +1,790 @@
+// Package handlers implements HTTP handlers for the moniewave financial management system.
+//
+// Bank Statement Import Handler - Financial Management Core
+//
+// OBJECTIVES:
+// Not all spending goes through Paystack; bank statements should become expenses without retyping them.
+//
+// PURPOSE:
+// - Upload bank statements as CSV (with a column mapping) or OFX
+// - Preview every line with the category and budget the import rules assign
+// - Flag lines already imported from an earlier or overlapping statement
+// - Let users adjust or skip lines before committing them as expenses
+// - Record where each imported expense came from
+//
+// KEY WORKFLOW:
+// Upload → Parse → Hash Lines → Store Preview → Review (Rules, Duplicates, Overrides) →
+// Commit → Insert Expenses → Update Budget Spent → Mark Import Committed
+//
+// DESIGN DECISIONS:
+// - A line's hash is built from the account, currency and either the bank's
+//   transaction ID or date, amount, narration and reference; an expense keeps
+//   the hash (unique) so a line is never imported twice
+// - The preview is computed on every read rather than stored, so new rules and
+//   newly committed statements are reflected until the import is committed
+// - Only money out becomes an expense; credits are shown but not imported
+// - A budget is assigned only when the line falls within its period and the
+//   budget is active; lines without a rule get no budget rather than the
+//   default budget, since they describe spending that already happened
+// - Imported expenses are already paid, so budgets are not checked for
+//   affordability; the commit reports budgets that went over their limit
+// - Commit is a single database transaction
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"paystack.mpc.proxy/internal/bankimport"
+	"paystack.mpc.proxy/internal/database"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// bankImportMaxBytes caps the size of an uploaded statement
+const bankImportMaxBytes = 10 << 20
+
+// Bank import statuses
+const (
+	BankImportStatusPreview   = "preview"
+	BankImportStatusCommitted = "committed"
+	BankImportStatusDiscarded = "discarded"
+)
+
+// Preview line actions: what committing the import does with a line
+const (
+	ImportActionImport    = "import"
+	ImportActionDuplicate = "duplicate"
+	ImportActionCredit    = "credit"
+	ImportActionSkip      = "skip"
+	ImportActionImported  = "imported"
+)
+
+type BankImportHandler struct{}
+
+func NewBankImportHandler() *BankImportHandler {
+	return &BankImportHandler{}
+}
+
+// BankImport is one uploaded statement
+type BankImport struct {
+	ID             int        `json:"id"`
+	Filename       string     `json:"filename"`
+	Format         string     `json:"format"`
+	Account        string     `json:"account"`
+	Currency       string     `json:"currency"`
+	Status         string     `json:"status"`
+	LineCount      int        `json:"line_count"`
+	ImportedCount  int        `json:"imported_count"`
+	ImportedAmount int        `json:"imported_amount"`
+	CreatedAt      time.Time  `json:"created_at"`
+	CommittedAt    *time.Time `json:"committed_at,omitempty"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// ImportPreviewLine is a statement line with what committing will do with it
+type ImportPreviewLine struct {
+	bankimport.Line
+	Action        string `json:"action"`
+	Category      string `json:"category,omitempty"`
+	BudgetLimitID *int   `json:"budget_limit_id,omitempty"`
+	RuleID        *int   `json:"rule_id,omitempty"`
+	RuleName      string `json:"rule_name,omitempty"`
+	Overridden    bool   `json:"overridden"`
+	// DuplicateOf is the expense an earlier import created from this line
+	DuplicateOf *int   `json:"duplicate_of,omitempty"`
+	ExpenseID   *int   `json:"expense_id,omitempty"`
+	Note        string `json:"note,omitempty"`
+}
+
+type ImportPreviewSummary struct {
+	ImportCount    int            `json:"import_count"`
+	ImportAmount   int            `json:"import_amount"`
+	DuplicateCount int            `json:"duplicate_count"`
+	CreditCount    int            `json:"credit_count"`
+	SkipCount      int            `json:"skip_count"`
+	ImportedCount  int            `json:"imported_count"`
+	Uncategorised  int            `json:"uncategorised"`
+	ByCategory     map[string]int `json:"by_category"`
+}
+
+type ImportPreview struct {
+	Import  *BankImport          `json:"import"`
+	Summary ImportPreviewSummary `json:"summary"`
+	Lines   []*ImportPreviewLine `json:"lines"`
+}
+
+type UpdateImportLineRequest struct {
+	Category      *string `json:"category,omitempty"`
+	BudgetLimitID *int    `json:"budget_limit_id,omitempty"`
+	Skip          *bool   `json:"skip,omitempty"`
+	// Reset drops the category and budget overrides so rules apply again
+	Reset bool `json:"reset,omitempty"`
+}
+
+const bankImportColumns = "id, COALESCE(filename, ''), format, account, COALESCE(currency, 'NGN'), COALESCE(status, 'preview'), COALESCE(line_count, 0), COALESCE(imported_count, 0), COALESCE(imported_amount, 0), created_at, committed_at, updated_at"
+
+func scanBankImport(row rowScanner) (*BankImport, error) {
+	var imp BankImport
+	var committedAt sql.NullTime
+	err := row.Scan(&imp.ID, &imp.Filename, &imp.Format, &imp.Account, &imp.Currency, &imp.Status,
+		&imp.LineCount, &imp.ImportedCount, &imp.ImportedAmount, &imp.CreatedAt, &committedAt, &imp.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if committedAt.Valid {
+		imp.CommittedAt = &committedAt.Time
+	}
+	return &imp, nil
+}
+
+func getBankImport(id int) (*BankImport, error) {
+	return scanBankImport(database.DB.QueryRow("SELECT "+bankImportColumns+" FROM bank_imports WHERE id = ?", id))
+}
+
+// importBudget is what the preview needs to know about a budget
+type importBudget struct {
+	Name        string
+	PeriodStart string
+	PeriodEnd   string
+	Status      string
+}
+
+func loadImportBudgets() (map[int]importBudget, error) {
+	rows, err := database.DB.Query("SELECT id, name, period_start, period_end, COALESCE(status, '') FROM budget_limits")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query budgets: %w", err)
+	}
+	defer rows.Close()
+
+	budgets := map[int]importBudget{}
+	for rows.Next() {
+		var id int
+		var start, end time.Time
+		var b importBudget
+		if err := rows.Scan(&id, &b.Name, &start, &end, &b.Status); err != nil {
+			return nil, fmt.Errorf("failed to scan budget: %w", err)
+		}
+		b.PeriodStart = start.Format("2006-01-02")
+		b.PeriodEnd = end.Format("2006-01-02")
+		budgets[id] = b
+	}
+	return budgets, rows.Err()
+}
+
+// checkImportBudget returns why a line cannot count against a budget, or ""
+func checkImportBudget(budgets map[int]importBudget, budgetID int, posted time.Time) string {
+	b, ok := budgets[budgetID]
+	if !ok {
+		return fmt.Sprintf("budget %d not found", budgetID)
+	}
+	if b.Status != "active" {
+		return fmt.Sprintf("budget %q is %s", b.Name, b.Status)
+	}
+	day := posted.Format("2006-01-02")
+	if day < b.PeriodStart || day > b.PeriodEnd {
+		return fmt.Sprintf("line is outside the period of budget %q", b.Name)
+	}
+	return ""
+}
+
+// buildImportPreview works out what committing the import would do with each
+// of its lines. It only reads.
+func buildImportPreview(imp *BankImport) (*ImportPreview, error) {
+	rules, err := activeImportRules()
+	if err != nil {
+		return nil, err
+	}
+	budgets, err := loadImportBudgets()
+	if err != nil {
+		return nil, err
+	}
+
+	existing := map[string]int{}
+	hashRows, err := database.DB.Query(`
+		SELECT e.import_hash, e.id FROM expenses e
+		WHERE e.import_hash IN (SELECT hash FROM bank_import_lines WHERE import_id = ?)
+	`, imp.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for duplicates: %w", err)
+	}
+	for hashRows.Next() {
+		var hash string
+		var id int
+		if err := hashRows.Scan(&hash, &id); err != nil {
+			hashRows.Close()
+			return nil, fmt.Errorf("failed to scan duplicate: %w", err)
+		}
+		existing[hash] = id
+	}
+	hashRows.Close()
+	if err := hashRows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT line_no, hash, posted_at, amount, COALESCE(narration, ''), COALESCE(counterparty, ''),
+		       COALESCE(reference, ''), COALESCE(external_id, ''), category, budget_limit_id,
+		       COALESCE(overridden, 0), COALESCE(skip, 0), expense_id
+		FROM bank_import_lines
+		WHERE import_id = ?
+		ORDER BY line_no
+	`, imp.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query import lines: %w", err)
+	}
+	defer rows.Close()
+
+	preview := &ImportPreview{
+		Import:  imp,
+		Summary: ImportPreviewSummary{ByCategory: map[string]int{}},
+		Lines:   []*ImportPreviewLine{},
+	}
+	for rows.Next() {
+		var line ImportPreviewLine
+		var category sql.NullString
+		var budgetLimitID, expenseID sql.NullInt64
+		var skip bool
+		err := rows.Scan(&line.LineNo, &line.Hash, &line.PostedAt, &line.Amount, &line.Narration, &line.Counterparty,
+			&line.Reference, &line.ExternalID, &category, &budgetLimitID, &line.Overridden, &skip, &expenseID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan import line: %w", err)
+		}
+
+		if line.Overridden {
+			line.Category = category.String
+			if budgetLimitID.Valid {
+				id := int(budgetLimitID.Int64)
+				line.BudgetLimitID = &id
+			}
+		} else if rule := bankimport.FirstMatch(rules, line.Line); rule != nil {
+			id := rule.ID
+			line.RuleID = &id
+			line.RuleName = rule.Name
+			line.Category = rule.Category
+			line.BudgetLimitID = rule.BudgetLimitID
+		}
+		if line.BudgetLimitID != nil {
+			if reason := checkImportBudget(budgets, *line.BudgetLimitID, line.PostedAt); reason != "" {
+				line.Note = reason + "; no budget will be assigned"
+				line.BudgetLimitID = nil
+			}
+		}
+
+		switch {
+		case expenseID.Valid:
+			id := int(expenseID.Int64)
+			line.ExpenseID = &id
+			line.Action = ImportActionImported
+			preview.Summary.ImportedCount++
+		case existing[line.Hash] != 0:
+			id := existing[line.Hash]
+			line.DuplicateOf = &id
+			line.Action = ImportActionDuplicate
+			preview.Summary.DuplicateCount++
+		case !line.Debit():
+			line.Action = ImportActionCredit
+			preview.Summary.CreditCount++
+		case skip:
+			line.Action = ImportActionSkip
+			preview.Summary.SkipCount++
+		default:
+			line.Action = ImportActionImport
+			preview.Summary.ImportCount++
+			preview.Summary.ImportAmount += -line.Amount
+			if line.Category == "" {
+				preview.Summary.Uncategorised++
+			} else {
+				preview.Summary.ByCategory[line.Category] += -line.Amount
+			}
+		}
+		preview.Lines = append(preview.Lines, &line)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating import lines: %w", err)
+	}
+	return preview, nil
+}
+
+// Upload parses a statement and stores it as a preview. Multipart form
+// fields: file, format (csv|ofx, default from the file extension), account,
+// currency and, for CSV, mapping (JSON, see bankimport.CSVMapping).
+func (h *BankImportHandler) Upload(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, bankImportMaxBytes)
+	if err := r.ParseMultipartForm(bankImportMaxBytes); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			WriteJSONError(w, fmt.Errorf("statement is larger than %d MB", bankImportMaxBytes>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+		WriteJSONBadRequest(w, "Request must be a multipart form with a file field")
+		return
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		WriteJSONBadRequest(w, "file is required")
+		return
+	}
+	defer file.Close()
+
+	format := strings.ToLower(strings.TrimSpace(r.FormValue("format")))
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(header.Filename)) {
+		case ".ofx", ".qfx":
+			format = bankimport.FormatOFX
+		default:
+			format = bankimport.FormatCSV
+		}
+	}
+	account := strings.TrimSpace(r.FormValue("account"))
+	currency := strings.ToUpper(strings.TrimSpace(r.FormValue("currency")))
+
+	var lines []bankimport.Line
+	switch format {
+	case bankimport.FormatCSV:
+		var mapping bankimport.CSVMapping
+		if err := json.Unmarshal([]byte(r.FormValue("mapping")), &mapping); err != nil {
+			WriteJSONBadRequest(w, "mapping must be a JSON column mapping")
+			return
+		}
+		lines, err = bankimport.ParseCSV(file, mapping)
+	case bankimport.FormatOFX:
+		var st *bankimport.OFXStatement
+		st, err = bankimport.ParseOFX(file)
+		if st != nil {
+			lines = st.Lines
+			if account == "" {
+				account = st.AccountID
+			}
+			if currency == "" {
+				currency = st.Currency
+			}
+		}
+	default:
+		WriteJSONBadRequest(w, "format must be csv or ofx")
+		return
+	}
+	if err != nil {
+		WriteJSONBadRequest(w, fmt.Sprintf("Failed to read statement: %v", err))
+		return
+	}
+	if account == "" {
+		WriteJSONBadRequest(w, "account is required to tell statements of different accounts apart")
+		return
+	}
+	if currency == "" {
+		currency = "NGN"
+	}
+	bankimport.AssignHashes(lines, account, currency)
+
+	imp, err := saveBankImport(header.Filename, format, account, currency, lines)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to save statement: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	preview, err := buildImportPreview(imp)
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+	WriteJSONSuccess(w, preview)
+}
+
+// saveBankImport stores a parsed statement and its lines
+func saveBankImport(filename, format, account, currency string, lines []bankimport.Line) (*BankImport, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec(`
+		INSERT INTO bank_imports (filename, format, account, currency, status, line_count, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, filename, format, account, currency, BankImportStatusPreview, len(lines), now, now)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := result.LastInsertId()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO bank_import_lines (import_id, line_no, hash, posted_at, amount, narration, counterparty, reference, external_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	for _, l := range lines {
+		if _, err := stmt.Exec(id, l.LineNo, l.Hash, l.PostedAt.UTC(), l.Amount, l.Narration, l.Counterparty, l.Reference, l.ExternalID); err != nil {
+			return nil, fmt.Errorf("line %d: %w", l.LineNo, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &BankImport{
+		ID:        int(id),
+		Filename:  filename,
+		Format:    format,
+		Account:   account,
+		Currency:  currency,
+		Status:    BankImportStatusPreview,
+		LineCount: len(lines),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, nil
+}
+
+// List lists uploaded statements, newest first. Query params: status.
+func (h *BankImportHandler) List(w http.ResponseWriter, r *http.Request) {
+	query := "SELECT " + bankImportColumns + " FROM bank_imports"
+	args := []interface{}{}
+	if status := r.URL.Query().Get("status"); status != "" {
+		query += " WHERE status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to query bank imports: %w", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	imports := []*BankImport{}
+	for rows.Next() {
+		imp, err := scanBankImport(rows)
+		if err != nil {
+			WriteJSONError(w, fmt.Errorf("failed to scan bank import: %w", err), http.StatusInternalServerError)
+			return
+		}
+		imports = append(imports, imp)
+	}
+
+	if err := rows.Err(); err != nil {
+		WriteJSONError(w, fmt.Errorf("error iterating bank imports: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, imports)
+}
+
+// loadBankImport reads the {id} URL param and its import, writing the error
+// response itself when either fails
+func loadBankImport(w http.ResponseWriter, r *http.Request) (*BankImport, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		WriteJSONBadRequest(w, "id must be a number")
+		return nil, false
+	}
+
+	imp, err := getBankImport(id)
+	if err == sql.ErrNoRows {
+		WriteJSONError(w, fmt.Errorf("bank import %d not found", id), http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return nil, false
+	}
+	return imp, true
+}
+
+// Preview returns an import with what committing it would do with each line
+func (h *BankImportHandler) Preview(w http.ResponseWriter, r *http.Request) {
+	imp, ok := loadBankImport(w, r)
+	if !ok {
+		return
+	}
+
+	preview, err := buildImportPreview(imp)
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+	WriteJSONSuccess(w, preview)
+}
+
+// UpdateLine overrides the category or budget of one line, or skips it
+func (h *BankImportHandler) UpdateLine(w http.ResponseWriter, r *http.Request) {
+	imp, ok := loadBankImport(w, r)
+	if !ok {
+		return
+	}
+	lineNo, err := strconv.Atoi(chi.URLParam(r, "line_no"))
+	if err != nil {
+		WriteJSONBadRequest(w, "line_no must be a number")
+		return
+	}
+
+	var req UpdateImportLineRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+
+	if imp.Status != BankImportStatusPreview {
+		WriteJSONError(w, fmt.Errorf("bank import %d is %s", imp.ID, imp.Status), http.StatusConflict)
+		return
+	}
+
+	preview, err := buildImportPreview(imp)
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+	var line *ImportPreviewLine
+	for _, l := range preview.Lines {
+		if l.LineNo == lineNo {
+			line = l
+			break
+		}
+	}
+	if line == nil {
+		WriteJSONError(w, fmt.Errorf("line %d not found in bank import %d", lineNo, imp.ID), http.StatusNotFound)
+		return
+	}
+
+	updates := []string{}
+	args := []interface{}{}
+	if req.Reset {
+		updates = append(updates, "overridden = 0", "category = NULL", "budget_limit_id = NULL")
+	} else if req.Category != nil || req.BudgetLimitID != nil {
+		// Overriding one field keeps what the line currently resolves to for the other
+		category, budgetID := line.Category, line.BudgetLimitID
+		if req.Category != nil {
+			category = strings.TrimSpace(*req.Category)
+		}
+		if req.BudgetLimitID != nil {
+			budgetID = nil
+			if *req.BudgetLimitID > 0 {
+				budgets, err := loadImportBudgets()
+				if err != nil {
+					WriteJSONError(w, err, http.StatusInternalServerError)
+					return
+				}
+				if reason := checkImportBudget(budgets, *req.BudgetLimitID, line.PostedAt); reason != "" {
+					WriteJSONBadRequest(w, reason)
+					return
+				}
+				budgetID = req.BudgetLimitID
+			}
+		}
+		updates = append(updates, "overridden = 1", "category = ?", "budget_limit_id = ?")
+		args = append(args, category, budgetID)
+	}
+	if req.Skip != nil {
+		updates = append(updates, "skip = ?")
+		args = append(args, *req.Skip)
+	}
+	if len(updates) == 0 {
+		WriteJSONBadRequest(w, "Nothing to update")
+		return
+	}
+
+	args = append(args, imp.ID, lineNo)
+	query := fmt.Sprintf("UPDATE bank_import_lines SET %s WHERE import_id = ? AND line_no = ?", joinStrings(updates, ", "))
+	if _, err := database.DB.Exec(query, args...); err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to update import line: %w", err), http.StatusInternalServerError)
+		return
+	}
+	database.DB.Exec("UPDATE bank_imports SET updated_at = ? WHERE id = ?", time.Now(), imp.ID)
+
+	preview, err = buildImportPreview(imp)
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+	for _, l := range preview.Lines {
+		if l.LineNo == lineNo {
+			WriteJSONSuccess(w, l)
+			return
+		}
+	}
+}
+
+// Commit turns the import's debit lines into expenses
+func (h *BankImportHandler) Commit(w http.ResponseWriter, r *http.Request) {
+	imp, ok := loadBankImport(w, r)
+	if !ok {
+		return
+	}
+	if imp.Status != BankImportStatusPreview {
+		WriteJSONError(w, fmt.Errorf("bank import %d is %s", imp.ID, imp.Status), http.StatusConflict)
+		return
+	}
+
+	preview, err := buildImportPreview(imp)
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// Claim the import first so two commits of the same statement cannot both run
+	now := time.Now()
+	result, err := tx.Exec("UPDATE bank_imports SET status = ?, committed_at = ?, updated_at = ? WHERE id = ? AND status = ?",
+		BankImportStatusCommitted, now, now, imp.ID, BankImportStatusPreview)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to commit bank import: %w", err), http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		WriteJSONError(w, fmt.Errorf("bank import %d is no longer a preview", imp.ID), http.StatusConflict)
+		return
+	}
+
+	budgetSpend := map[int]int{}
+	imported, importedAmount, duplicates := 0, 0, 0
+	for _, line := range preview.Lines {
+		if line.Action != ImportActionImport {
+			continue
+		}
+
+		amount := -line.Amount
+		recipientName := line.Counterparty
+		if recipientName == "" {
+			recipientName = line.Narration
+		}
+		// The unique import_hash index catches a line another statement
+		// committed since the preview was built
+		result, err := tx.Exec(`
+			INSERT OR IGNORE INTO expenses (
+				recipient_code, recipient_name, amount, currency, category,
+				narration, reference, status, payment_date, notes, budget_limit_id,
+				source, import_id, import_hash, created_at, updated_at
+			)
+			VALUES ('', ?, ?, ?, ?, ?, ?, 'paid', ?, ?, ?, ?, ?, ?, ?, ?)
+		`, recipientName, amount, imp.Currency, line.Category, line.Narration, "BNK_"+line.Hash[:16],
+			line.PostedAt, line.Reference, line.BudgetLimitID, ExpenseSourceBankImport, imp.ID, line.Hash, now, now)
+		if err != nil {
+			WriteJSONError(w, fmt.Errorf("failed to import line %d: %w", line.LineNo, err), http.StatusInternalServerError)
+			return
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			duplicates++
+			continue
+		}
+		expenseID, _ := result.LastInsertId()
+
+		if _, err := tx.Exec("UPDATE bank_import_lines SET expense_id = ? WHERE import_id = ? AND line_no = ?",
+			expenseID, imp.ID, line.LineNo); err != nil {
+			WriteJSONError(w, fmt.Errorf("failed to update import line %d: %w", line.LineNo, err), http.StatusInternalServerError)
+			return
+		}
+		if line.BudgetLimitID != nil {
+			budgetSpend[*line.BudgetLimitID] += amount
+		}
+		imported++
+		importedAmount += amount
+	}
+
+	for budgetID, amount := range budgetSpend {
+		if _, err := tx.Exec("UPDATE budget_limits SET spent_amount = spent_amount + ?, updated_at = ? WHERE id = ?",
+			amount, now, budgetID); err != nil {
+			WriteJSONError(w, fmt.Errorf("failed to update budget %d: %w", budgetID, err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if _, err := tx.Exec("UPDATE bank_imports SET imported_count = ?, imported_amount = ? WHERE id = ?",
+		imported, importedAmount, imp.ID); err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to commit bank import: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to commit bank import: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	overBudget := []map[string]interface{}{}
+	for budgetID := range budgetSpend {
+		var name string
+		var limit, spent int
+		err := database.DB.QueryRow("SELECT name, amount, spent_amount FROM budget_limits WHERE id = ?", budgetID).Scan(&name, &limit, &spent)
+		if err == nil && spent > limit {
+			overBudget = append(overBudget, map[string]interface{}{
+				"budget_id":    budgetID,
+				"name":         name,
+				"amount":       limit,
+				"spent_amount": spent,
+				"over_by":      spent - limit,
+			})
+		}
+	}
+
+	imp, err = getBankImport(imp.ID)
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+	WriteJSONSuccess(w, map[string]interface{}{
+		"import":             imp,
+		"imported_count":     imported,
+		"imported_amount":    importedAmount,
+		"skipped_duplicates": duplicates,
+		"budget_spend":       budgetSpend,
+		"over_budget":        overBudget,
+	})
+}
+
+// Discard drops a preview that should not be committed
+func (h *BankImportHandler) Discard(w http.ResponseWriter, r *http.Request) {
+	imp, ok := loadBankImport(w, r)
+	if !ok {
+		return
+	}
+	if imp.Status != BankImportStatusPreview {
+		WriteJSONError(w, fmt.Errorf("bank import %d is %s", imp.ID, imp.Status), http.StatusConflict)
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("UPDATE bank_imports SET status = ?, updated_at = ? WHERE id = ? AND status = ?",
+		BankImportStatusDiscarded, time.Now(), imp.ID, BankImportStatusPreview)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to discard bank import: %w", err), http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		WriteJSONError(w, fmt.Errorf("bank import %d is no longer a preview", imp.ID), http.StatusConflict)
+		return
+	}
+	if _, err := tx.Exec("DELETE FROM bank_import_lines WHERE import_id = ?", imp.ID); err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to discard bank import: %w", err), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to discard bank import: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, map[string]interface{}{"id": imp.ID, "status": BankImportStatusDiscarded})
+}
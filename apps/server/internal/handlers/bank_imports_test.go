@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckImportBudget(t *testing.T) {
+	budgets := map[int]importBudget{
+		1: {Name: "Ops", PeriodStart: "2025-03-01", PeriodEnd: "2025-03-31", Status: "active"},
+		2: {Name: "Old", PeriodStart: "2025-03-01", PeriodEnd: "2025-03-31", Status: "expired"},
+	}
+
+	cases := []struct {
+		budgetID int
+		posted   time.Time
+		want     string
+	}{
+		{1, time.Date(2025, 3, 31, 18, 0, 0, 0, time.UTC), ""},
+		{1, time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), ""},
+		{1, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), "outside the period"},
+		{2, time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), "is expired"},
+		{3, time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), "not found"},
+	}
+	for _, c := range cases {
+		got := checkImportBudget(budgets, c.budgetID, c.posted)
+		if (c.want == "") != (got == "") || !strings.Contains(got, c.want) {
+			t.Errorf("checkImportBudget(%d, %s) = %q, want %q", c.budgetID, c.posted.Format("2006-01-02"), got, c.want)
+		}
+	}
+}
@@ -6,7 +6,7 @@
 // Provide bank information for Nigerian banking operations.
 //
 // PURPOSE:
-// - List Nigerian banks and their codes
+// - List and search banks and their codes from a local cache
 // - Resolve account numbers to verify account ownership
 // - Enable accurate recipient creation for transfers
 //
@@ -14,25 +14,31 @@
 // List Banks → User Selects Bank → Resolve Account Number → Verify Account Details
 //
 // DESIGN DECISIONS:
-// - Bank list fetched from Paystack for up-to-date information
+// - Bank list cached in SQLite and refreshed in the background when older than
+//   the TTL (see BankDirectory); a manual refresh is available
+// - /banks/list keeps the Paystack BankList shape existing clients parse; it
+//   falls back to a live Paystack call until the cache has been loaded
 // - Account resolution validates account ownership before transfers
-// - No local caching (banks list is small and changes infrequently)
 package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"paystack.mpc.proxy/internal/paystack"
+
+	paystackSDK "github.com/borderlesshq/paystack-go"
 )
 
 type BankHandler struct {
-	client *paystack.Client
+	client    *paystack.Client
+	directory *BankDirectory
 }
 
-func NewBankHandler(client *paystack.Client) *BankHandler {
-	return &BankHandler{client: client}
+func NewBankHandler(client *paystack.Client, directory *BankDirectory) *BankHandler {
+	return &BankHandler{client: client, directory: directory}
 }
 
 type ResolveAccountRequest struct {
@@ -40,15 +46,96 @@ type ResolveAccountRequest struct {
 	BankCode      string `json:"bank_code"`
 }
 
+// List lists banks in the Paystack BankList shape. The optional body filters
+// cached banks by search (name, slug or code), country, currency, type and active_only.
 func (h *BankHandler) List(w http.ResponseWriter, r *http.Request) {
-	result, err := h.client.Bank.List()
+	var req BankSearch
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&req)
+	}
+
+	banks, err := h.directory.Search(req)
 	if err != nil {
 		WriteJSONError(w, fmt.Errorf("failed to list banks: %w", err), http.StatusInternalServerError)
 		return
 	}
+
+	if len(banks) == 0 {
+		if _, cached, err := h.directory.lastFetched(); err == nil && !cached {
+			result, err := h.client.Bank.List()
+			if err != nil {
+				WriteJSONError(w, fmt.Errorf("failed to list banks: %w", err), http.StatusInternalServerError)
+				return
+			}
+			WriteJSONSuccess(w, result)
+			return
+		}
+	}
+
+	result := paystackSDK.BankList{
+		Meta:   paystackSDK.ListMeta{Total: len(banks), PerPage: len(banks), Page: 1, PageCount: 1},
+		Values: make([]paystackSDK.Bank, 0, len(banks)),
+	}
+	for _, bank := range banks {
+		result.Values = append(result.Values, paystackSDK.Bank{
+			ID:       bank.PaystackID,
+			Name:     bank.Name,
+			Slug:     bank.Slug,
+			Code:     bank.Code,
+			LongCode: bank.LongCode,
+			Gateway:  bank.Gateway,
+			Active:   bank.Active,
+		})
+	}
 	WriteJSONSuccess(w, result)
 }
 
+// Search returns cached banks with country, currency and type. It takes the
+// same filters as List.
+func (h *BankHandler) Search(w http.ResponseWriter, r *http.Request) {
+	var req BankSearch
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&req)
+	}
+
+	banks, err := h.directory.Search(req)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to search banks: %w", err), http.StatusInternalServerError)
+		return
+	}
+	WriteJSONSuccess(w, banks)
+}
+
+// Lookup finds a single bank by code in the cache
+func (h *BankHandler) Lookup(w http.ResponseWriter, r *http.Request) {
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		WriteJSONBadRequest(w, "code query parameter is required")
+		return
+	}
+
+	bank, err := h.directory.LookupCode(code, r.URL.Query().Get("currency"))
+	if errors.Is(err, ErrUnknownBank) {
+		WriteJSONError(w, fmt.Errorf("%w: %s", ErrUnknownBank, code), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to look up bank: %w", err), http.StatusServiceUnavailable)
+		return
+	}
+	WriteJSONSuccess(w, bank)
+}
+
+// Refresh reloads the bank directory from Paystack regardless of the TTL
+func (h *BankHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	report, err := h.directory.Refresh()
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("bank directory refresh failed: %w", err), http.StatusBadGateway)
+		return
+	}
+	WriteJSONSuccess(w, report)
+}
+
 func (h *BankHandler) ResolveAccount(w http.ResponseWriter, r *http.Request) {
 	var req ResolveAccountRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
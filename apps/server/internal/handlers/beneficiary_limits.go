@@ -0,0 +1,423 @@
+// Package handlers implements HTTP handlers for the moniewave financial management system.
+//
+// Beneficiary Limits Handler - Spending Controls
+//
+// OBJECTIVES:
+// Cap how much can be paid to any single recipient over time.
+//
+// PURPOSE:
+// - Store daily, weekly and monthly caps per recipient_code
+// - Enforce caps on expense creation and transfer initiation
+// - Report current usage and when each window resets
+//
+// KEY WORKFLOW:
+// Set Beneficiary Limit → Create Expense / Initiate Transfer →
+// Sum Prior Spend Per Window → Reject With Window And Reset Time, Or Allow
+//
+// DESIGN DECISIONS:
+// - Windows are calendar based: the day starts at midnight, the week on Monday,
+//   the month on the 1st (server local time)
+// - Prior spend is expenses plus transfers initiated through this server; a
+//   transfer that shares a reference with an expense is only counted once
+// - Failed, cancelled and reversed payments do not count toward a limit
+// - A missing or zero cap means that window is unlimited
+// - All amounts stored in kobo (Nigerian currency subunit) for precision
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+	"paystack.mpc.proxy/internal/money"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type BeneficiaryLimitHandler struct{}
+
+func NewBeneficiaryLimitHandler() *BeneficiaryLimitHandler {
+	return &BeneficiaryLimitHandler{}
+}
+
+// Limit window names
+const (
+	LimitWindowDaily   = "daily"
+	LimitWindowWeekly  = "weekly"
+	LimitWindowMonthly = "monthly"
+)
+
+// BeneficiaryLimit represents the caps configured for a recipient
+type BeneficiaryLimit struct {
+	ID            int       `json:"id"`
+	RecipientCode string    `json:"recipient_code"`
+	DailyLimit    *int      `json:"daily_limit,omitempty"`
+	WeeklyLimit   *int      `json:"weekly_limit,omitempty"`
+	MonthlyLimit  *int      `json:"monthly_limit,omitempty"`
+	Notes         string    `json:"notes,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+type SetBeneficiaryLimitRequest struct {
+	RecipientCode string `json:"recipient_code"`
+	DailyLimit    *int   `json:"daily_limit,omitempty"`
+	WeeklyLimit   *int   `json:"weekly_limit,omitempty"`
+	MonthlyLimit  *int   `json:"monthly_limit,omitempty"`
+	Notes         string `json:"notes,omitempty"`
+}
+
+// LimitWindow is a calendar window [Start, End) used for limit checks
+type LimitWindow struct {
+	Name  string    `json:"name"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// WindowUsage reports spend against the cap for one window
+type WindowUsage struct {
+	Window    string    `json:"window"`
+	Limit     int       `json:"limit"`
+	Spent     int       `json:"spent"`
+	Remaining int       `json:"remaining"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// BeneficiaryLimitCheck is the outcome of checking a payment against a recipient's caps
+type BeneficiaryLimitCheck struct {
+	RecipientCode   string        `json:"recipient_code"`
+	Currency        string        `json:"currency"`
+	RequestedAmount int           `json:"requested_amount"`
+	Allowed         bool          `json:"allowed"`
+	ExceededWindow  string        `json:"exceeded_window,omitempty"`
+	ResetsAt        *time.Time    `json:"resets_at,omitempty"`
+	Windows         []WindowUsage `json:"windows"`
+	Reason          string        `json:"reason"`
+}
+
+// BeneficiaryLimitStatus is a recipient's caps together with current usage
+type BeneficiaryLimitStatus struct {
+	BeneficiaryLimit
+	Usage []WindowUsage `json:"usage"`
+}
+
+// limitWindows returns the daily, weekly and monthly windows containing now
+func limitWindows(now time.Time) []LimitWindow {
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	// Weeks start on Monday
+	offset := (int(dayStart.Weekday()) + 6) % 7
+	weekStart := dayStart.AddDate(0, 0, -offset)
+
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	return []LimitWindow{
+		{Name: LimitWindowDaily, Start: dayStart, End: dayStart.AddDate(0, 0, 1)},
+		{Name: LimitWindowWeekly, Start: weekStart, End: weekStart.AddDate(0, 0, 7)},
+		{Name: LimitWindowMonthly, Start: monthStart, End: monthStart.AddDate(0, 1, 0)},
+	}
+}
+
+// limitFor returns the configured cap for a window, or 0 when unlimited
+func (l *BeneficiaryLimit) limitFor(window string) int {
+	var limit *int
+	switch window {
+	case LimitWindowDaily:
+		limit = l.DailyLimit
+	case LimitWindowWeekly:
+		limit = l.WeeklyLimit
+	case LimitWindowMonthly:
+		limit = l.MonthlyLimit
+	}
+	if limit == nil {
+		return 0
+	}
+	return *limit
+}
+
+// getBeneficiaryLimit loads the caps for a recipient; sql.ErrNoRows means none are set
+func getBeneficiaryLimit(recipientCode string) (*BeneficiaryLimit, error) {
+	query := `
+		SELECT id, recipient_code, daily_limit, weekly_limit, monthly_limit, notes, created_at, updated_at
+		FROM beneficiary_limits
+		WHERE recipient_code = ?
+	`
+
+	var limit BeneficiaryLimit
+	var daily, weekly, monthly sql.NullInt64
+	var notes sql.NullString
+
+	err := database.DB.QueryRow(query, recipientCode).Scan(
+		&limit.ID,
+		&limit.RecipientCode,
+		&daily,
+		&weekly,
+		&monthly,
+		&notes,
+		&limit.CreatedAt,
+		&limit.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	limit.DailyLimit = nullIntPtr(daily)
+	limit.WeeklyLimit = nullIntPtr(weekly)
+	limit.MonthlyLimit = nullIntPtr(monthly)
+	limit.Notes = notes.String
+
+	return &limit, nil
+}
+
+// nullIntPtr converts a nullable integer column to *int
+func nullIntPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	n := int(v.Int64)
+	return &n
+}
+
+// RecipientSpend sums what has been paid to a recipient in [from, to)
+func RecipientSpend(recipientCode string, from, to time.Time) (int, error) {
+	return sumOutflow(outflowFilter{RecipientCode: recipientCode}, from, to)
+}
+
+// beneficiaryUsage reports spend against every configured window
+func beneficiaryUsage(limit *BeneficiaryLimit, now time.Time) ([]WindowUsage, error) {
+	usage := []WindowUsage{}
+
+	for _, window := range limitWindows(now) {
+		windowLimit := limit.limitFor(window.Name)
+		if windowLimit <= 0 {
+			continue
+		}
+
+		spent, err := RecipientSpend(limit.RecipientCode, window.Start, window.End)
+		if err != nil {
+			return nil, err
+		}
+
+		usage = append(usage, WindowUsage{
+			Window:    window.Name,
+			Limit:     windowLimit,
+			Spent:     spent,
+			Remaining: windowLimit - spent,
+			ResetsAt:  window.End,
+		})
+	}
+
+	return usage, nil
+}
+
+// CheckBeneficiaryLimit checks whether paying amount to a recipient would
+// exceed any of its caps. Recipients without caps are always allowed. The
+// currency is the payment's and is only used to describe the amounts.
+func CheckBeneficiaryLimit(recipientCode, currency string, amount int) (*BeneficiaryLimitCheck, error) {
+	check := &BeneficiaryLimitCheck{
+		RecipientCode:   recipientCode,
+		Currency:        currency,
+		RequestedAmount: amount,
+		Allowed:         true,
+		Windows:         []WindowUsage{},
+		Reason:          "No beneficiary limit set",
+	}
+
+	limit, err := getBeneficiaryLimit(recipientCode)
+	if err == sql.ErrNoRows {
+		return check, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load beneficiary limit: %w", err)
+	}
+
+	usage, err := beneficiaryUsage(limit, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	check.Windows = usage
+	check.Reason = "Within beneficiary limits"
+
+	// When several windows are exceeded, report the one that resets last,
+	// since that is when the payment could go through
+	var exceeded *WindowUsage
+	for i := range usage {
+		if usage[i].Spent+amount > usage[i].Limit {
+			if exceeded == nil || usage[i].ResetsAt.After(exceeded.ResetsAt) {
+				exceeded = &usage[i]
+			}
+		}
+	}
+
+	if exceeded != nil {
+		check.Allowed = false
+		check.ExceededWindow = exceeded.Window
+		check.ResetsAt = &exceeded.ResetsAt
+		check.Reason = fmt.Sprintf("Paying %s %s to %s would exceed the %s limit of %s %s (%s %s already paid, %s %s remaining); the limit resets at %s",
+			currency, money.Decimal(amount), recipientCode, exceeded.Window,
+			currency, money.Decimal(exceeded.Limit),
+			currency, money.Decimal(exceeded.Spent),
+			currency, money.Decimal(max(exceeded.Remaining, 0)),
+			exceeded.ResetsAt.Format(time.RFC3339))
+	}
+
+	return check, nil
+}
+
+// writeBeneficiaryLimitExceeded writes the rejection for a payment over a beneficiary limit
+func writeBeneficiaryLimitExceeded(w http.ResponseWriter, check *BeneficiaryLimitCheck) {
+	respondWithJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+		"status":  false,
+		"message": fmt.Sprintf("Beneficiary %s limit exceeded", check.ExceededWindow),
+		"error":   check.Reason,
+		"data":    check,
+	})
+}
+
+// Set creates or replaces the caps for a recipient
+func (h *BeneficiaryLimitHandler) Set(w http.ResponseWriter, r *http.Request) {
+	var req SetBeneficiaryLimitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.RecipientCode == "" {
+		WriteJSONBadRequest(w, "recipient_code is required")
+		return
+	}
+
+	if req.DailyLimit == nil && req.WeeklyLimit == nil && req.MonthlyLimit == nil {
+		WriteJSONBadRequest(w, "at least one of daily_limit, weekly_limit or monthly_limit is required")
+		return
+	}
+
+	for name, limit := range map[string]*int{
+		"daily_limit":   req.DailyLimit,
+		"weekly_limit":  req.WeeklyLimit,
+		"monthly_limit": req.MonthlyLimit,
+	} {
+		if limit != nil && *limit < 0 {
+			WriteJSONBadRequest(w, fmt.Sprintf("%s cannot be negative", name))
+			return
+		}
+	}
+
+	if _, err := getRecipientByCode(req.RecipientCode); err != nil {
+		WriteJSONError(w, fmt.Errorf("recipient not found: %s", req.RecipientCode), http.StatusNotFound)
+		return
+	}
+
+	query := `
+		INSERT INTO beneficiary_limits (recipient_code, daily_limit, weekly_limit, monthly_limit, notes, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(recipient_code) DO UPDATE SET
+			daily_limit = excluded.daily_limit,
+			weekly_limit = excluded.weekly_limit,
+			monthly_limit = excluded.monthly_limit,
+			notes = excluded.notes,
+			updated_at = excluded.updated_at
+	`
+
+	now := time.Now()
+	_, err := database.DB.Exec(query, req.RecipientCode, req.DailyLimit, req.WeeklyLimit, req.MonthlyLimit, req.Notes, now, now)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to set beneficiary limit: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	h.writeStatus(w, req.RecipientCode)
+}
+
+// Get returns a recipient's caps and current usage per window
+func (h *BeneficiaryLimitHandler) Get(w http.ResponseWriter, r *http.Request) {
+	h.writeStatus(w, chi.URLParam(r, "recipient_code"))
+}
+
+// List lists all configured beneficiary limits
+func (h *BeneficiaryLimitHandler) List(w http.ResponseWriter, r *http.Request) {
+	rows, err := database.DB.Query("SELECT recipient_code FROM beneficiary_limits ORDER BY recipient_code")
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to query beneficiary limits: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	codes := []string{}
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			rows.Close()
+			WriteJSONError(w, fmt.Errorf("failed to scan beneficiary limit: %w", err), http.StatusInternalServerError)
+			return
+		}
+		codes = append(codes, code)
+	}
+	rows.Close()
+
+	limits := []BeneficiaryLimitStatus{}
+	for _, code := range codes {
+		status, err := beneficiaryLimitStatus(code)
+		if err != nil {
+			WriteJSONError(w, err, http.StatusInternalServerError)
+			return
+		}
+		limits = append(limits, *status)
+	}
+
+	WriteJSONSuccess(w, limits)
+}
+
+// Delete removes the caps for a recipient
+func (h *BeneficiaryLimitHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	recipientCode := chi.URLParam(r, "recipient_code")
+
+	result, err := database.DB.Exec("DELETE FROM beneficiary_limits WHERE recipient_code = ?", recipientCode)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to delete beneficiary limit: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		WriteJSONError(w, fmt.Errorf("no beneficiary limit set for %s", recipientCode), http.StatusNotFound)
+		return
+	}
+
+	WriteJSONSuccess(w, map[string]interface{}{
+		"recipient_code": recipientCode,
+		"deleted":        true,
+	})
+}
+
+// writeStatus writes a recipient's caps and usage, or 404 when none are set
+func (h *BeneficiaryLimitHandler) writeStatus(w http.ResponseWriter, recipientCode string) {
+	status, err := beneficiaryLimitStatus(recipientCode)
+	if err == sql.ErrNoRows {
+		WriteJSONError(w, fmt.Errorf("no beneficiary limit set for %s", recipientCode), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, status)
+}
+
+// beneficiaryLimitStatus loads a recipient's caps with current usage
+func beneficiaryLimitStatus(recipientCode string) (*BeneficiaryLimitStatus, error) {
+	limit, err := getBeneficiaryLimit(recipientCode)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := beneficiaryUsage(limit, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	return &BeneficiaryLimitStatus{BeneficiaryLimit: *limit, Usage: usage}, nil
+}
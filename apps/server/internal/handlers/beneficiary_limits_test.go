@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimitWindows(t *testing.T) {
+	// Wednesday afternoon
+	now := time.Date(2025, time.January, 29, 15, 30, 0, 0, time.UTC)
+
+	want := map[string][2]time.Time{
+		LimitWindowDaily: {
+			time.Date(2025, time.January, 29, 0, 0, 0, 0, time.UTC),
+			time.Date(2025, time.January, 30, 0, 0, 0, 0, time.UTC),
+		},
+		LimitWindowWeekly: {
+			time.Date(2025, time.January, 27, 0, 0, 0, 0, time.UTC),
+			time.Date(2025, time.February, 3, 0, 0, 0, 0, time.UTC),
+		},
+		LimitWindowMonthly: {
+			time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2025, time.February, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	windows := limitWindows(now)
+	if len(windows) != len(want) {
+		t.Fatalf("Expected %d windows, got %d", len(want), len(windows))
+	}
+
+	for _, window := range windows {
+		bounds := want[window.Name]
+		if !window.Start.Equal(bounds[0]) || !window.End.Equal(bounds[1]) {
+			t.Errorf("%s window = [%s, %s), want [%s, %s)", window.Name, window.Start, window.End, bounds[0], bounds[1])
+		}
+	}
+
+	// A Sunday still belongs to the week that started on Monday
+	sunday := time.Date(2025, time.February, 2, 23, 0, 0, 0, time.UTC)
+	weekly := limitWindows(sunday)[1]
+	if !weekly.Start.Equal(time.Date(2025, time.January, 27, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Sunday weekly window starts %s, want 2025-01-27", weekly.Start)
+	}
+}
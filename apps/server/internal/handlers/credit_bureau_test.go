@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"paystack.mpc.proxy/internal/bureau"
+)
+
+func TestRefreshThenFetchMixedCaseEmail(t *testing.T) {
+	openTestDB(t)
+	h := NewVerdictHandler(bureau.NewFileBureau("../bureau/testdata/reports.json"), time.Hour)
+
+	rec := httptest.NewRecorder()
+	h.RefreshCreditProfile(rec, httptest.NewRequest("POST", "/verdict/refresh",
+		strings.NewReader(`{"email":"Ada.Obi@Example.com"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("refresh returned %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	h.GetFinancialProfile(rec, httptest.NewRequest("GET", "/verdict/profile?email=ADA.OBI@example.com", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("profile returned %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp struct {
+		Data CreditProfile `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data.Email != "ada.obi@example.com" || resp.Data.CreditScore != 720 {
+		t.Errorf("profile = %s, score %d", resp.Data.Email, resp.Data.CreditScore)
+	}
+}
@@ -0,0 +1,302 @@
+// Package handlers implements HTTP handlers for the moniewave financial management system.
+//
+// Credit Notes Handler - Income Management
+//
+// OBJECTIVES:
+// Users need to reduce what a customer owes on an invoice (discounts, returns,
+// billing mistakes) without deleting or re-issuing the invoice.
+//
+// PURPOSE:
+// - Issue credit notes against an invoice for part or all of its balance
+// - Number credit notes from their own sequence (CN-000001, CN-000002, ...)
+// - Reduce the invoice's outstanding balance in invoice, dunning and receivables views
+// - Archive the Paystack payment request once credit notes settle an invoice
+//
+// KEY WORKFLOW:
+// Validate Amount Against Balance → Take Next Credit Note Number → Insert Credit Note →
+// Recompute Invoice Balance And Status → Archive Payment Request If Fully Credited
+//
+// DESIGN DECISIONS:
+// - Credit notes are immutable; a mistake is corrected with a new invoice, not an edit
+// - The number is taken inside the insert transaction, so the sequence has no gaps
+// - A credit can never exceed the outstanding balance (amount - paid - credited)
+// - An invoice settled only by credit notes is "credited"; settled by a mix that
+//   includes payments it is "paid"
+// - Archiving upstream is best effort; a failure is reported and can be retried
+//   with POST /invoices/archive/{code}
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+	"paystack.mpc.proxy/internal/money"
+	"paystack.mpc.proxy/internal/paystack"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// creditNoteSequence is the number_sequences row used for credit note numbers
+const creditNoteSequence = "credit_note"
+
+type CreditNoteHandler struct {
+	client *paystack.Client
+}
+
+func NewCreditNoteHandler(client *paystack.Client) *CreditNoteHandler {
+	return &CreditNoteHandler{client: client}
+}
+
+// CreditNote reduces the balance of one invoice
+type CreditNote struct {
+	ID               int       `json:"id"`
+	CreditNoteNumber string    `json:"credit_note_number"`
+	InvoiceCode      string    `json:"invoice_code"`
+	Amount           int       `json:"amount"`
+	Currency         string    `json:"currency"`
+	Reason           string    `json:"reason"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+type CreateCreditNoteRequest struct {
+	InvoiceCode string `json:"invoice_code"`
+	// Amount in kobo; omit to credit the full outstanding balance
+	Amount int    `json:"amount,omitempty"`
+	Reason string `json:"reason"`
+}
+
+// CreateCreditNoteResponse is the new credit note and the invoice's balance after it
+type CreateCreditNoteResponse struct {
+	CreditNote             CreditNote      `json:"credit_note"`
+	Invoice                *InvoiceBalance `json:"invoice"`
+	PaymentRequestArchived bool            `json:"payment_request_archived"`
+	ArchiveError           string          `json:"archive_error,omitempty"`
+}
+
+// scanCreditNote reads a credit note row selected with creditNoteColumns
+func scanCreditNote(row rowScanner) (CreditNote, error) {
+	var note CreditNote
+	err := row.Scan(&note.ID, &note.CreditNoteNumber, &note.InvoiceCode, &note.Amount, &note.Currency, &note.Reason, &note.CreatedAt)
+	return note, err
+}
+
+const creditNoteColumns = "id, credit_note_number, invoice_code, amount, COALESCE(currency, 'NGN'), reason, created_at"
+
+// listCreditNotes returns credit notes oldest first, for one invoice or all
+// when invoiceCode is empty
+func listCreditNotes(invoiceCode string) ([]CreditNote, error) {
+	query := "SELECT " + creditNoteColumns + " FROM credit_notes"
+	args := []interface{}{}
+	if invoiceCode != "" {
+		query += " WHERE invoice_code = ?"
+		args = append(args, invoiceCode)
+	}
+	query += " ORDER BY id"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query credit notes: %w", err)
+	}
+	defer rows.Close()
+
+	notes := []CreditNote{}
+	for rows.Next() {
+		note, err := scanCreditNote(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan credit note: %w", err)
+		}
+		notes = append(notes, note)
+	}
+
+	return notes, rows.Err()
+}
+
+// Create issues a credit note against an invoice
+func (h *CreditNoteHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req CreateCreditNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+
+	req.InvoiceCode = strings.TrimSpace(req.InvoiceCode)
+	if req.InvoiceCode == "" {
+		WriteJSONBadRequest(w, "invoice_code is required")
+		return
+	}
+	if req.Amount < 0 {
+		WriteJSONBadRequest(w, "amount must be greater than 0")
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		WriteJSONBadRequest(w, "reason is required")
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to begin transaction: %w", err), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var amount, paid, credited int
+	var currency string
+	var status sql.NullString
+	err = tx.QueryRow(`
+		SELECT amount, COALESCE(currency, 'NGN'), COALESCE(paid_amount, 0), COALESCE(credited_amount, 0), status
+		FROM invoices WHERE invoice_code = ?
+	`, req.InvoiceCode).Scan(&amount, &currency, &paid, &credited, &status)
+	if err == sql.ErrNoRows {
+		WriteJSONError(w, fmt.Errorf("invoice %s not found", req.InvoiceCode), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to load invoice: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	outstanding := amount - paid - credited
+	if upstreamInvoiceStatuses[status.String] || outstanding <= 0 {
+		respondWithJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"status":  false,
+			"message": "Invoice cannot be credited",
+			"error":   fmt.Sprintf("invoice %s is %s with no outstanding balance to credit", req.InvoiceCode, status.String),
+		})
+		return
+	}
+	if req.Amount == 0 {
+		req.Amount = outstanding
+	}
+	if req.Amount > outstanding {
+		respondWithJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"status":  false,
+			"message": "Credit exceeds outstanding balance",
+			"error":   fmt.Sprintf("credit of %s %s exceeds the outstanding balance of %s %s",
+				currency, money.Decimal(req.Amount), currency, money.Decimal(outstanding)),
+			"data": map[string]interface{}{
+				"outstanding": outstanding,
+			},
+		})
+		return
+	}
+
+	number, err := nextSequenceNumber(tx, creditNoteSequence)
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	note := CreditNote{
+		CreditNoteNumber: number,
+		InvoiceCode:      req.InvoiceCode,
+		Amount:           req.Amount,
+		Currency:         currency,
+		Reason:           req.Reason,
+		CreatedAt:        time.Now(),
+	}
+	result, err := tx.Exec(`
+		INSERT INTO credit_notes (credit_note_number, invoice_code, amount, currency, reason, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, note.CreditNoteNumber, note.InvoiceCode, note.Amount, note.Currency, note.Reason, note.CreatedAt)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to save credit note: %w", err), http.StatusInternalServerError)
+		return
+	}
+	id, _ := result.LastInsertId()
+	note.ID = int(id)
+
+	detail := fmt.Sprintf("credit note %s of %s %s: %s", note.CreditNoteNumber, note.Currency, money.Decimal(note.Amount), note.Reason)
+	if err := recordInvoiceEvent(tx, note.InvoiceCode, "credit_note_issued", "", "", nil, InvoiceEventSourceCreditNote, detail); err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if _, err := refreshInvoiceBalance(tx, note.InvoiceCode, "", InvoiceEventSourceCreditNote); err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to commit credit note: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	response := CreateCreditNoteResponse{CreditNote: note}
+
+	// A settled invoice must not be payable on Paystack any more
+	if req.Amount == outstanding {
+		if err := archiveInvoice(h.client, note.InvoiceCode, InvoiceEventSourceCreditNote); err != nil {
+			log.Printf("Warning: failed to archive credited payment request %s: %v", note.InvoiceCode, err)
+			response.ArchiveError = err.Error()
+		} else {
+			response.PaymentRequestArchived = true
+		}
+	}
+
+	response.Invoice, err = getInvoiceBalance(note.InvoiceCode)
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, response)
+}
+
+// List returns credit notes, optionally for one invoice (?invoice_code=)
+func (h *CreditNoteHandler) List(w http.ResponseWriter, r *http.Request) {
+	notes, err := listCreditNotes(r.URL.Query().Get("invoice_code"))
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, notes)
+}
+
+// Get returns a credit note by number
+func (h *CreditNoteHandler) Get(w http.ResponseWriter, r *http.Request) {
+	number := chi.URLParam(r, "number")
+
+	row := database.DB.QueryRow("SELECT "+creditNoteColumns+" FROM credit_notes WHERE credit_note_number = ?", number)
+	note, err := scanCreditNote(row)
+	if err == sql.ErrNoRows {
+		WriteJSONError(w, fmt.Errorf("credit note %s not found", number), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to load credit note: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, note)
+}
+
+// archiveInvoice archives an invoice's Paystack payment request and records it
+// locally. A settled invoice keeps its paid or credited status.
+func archiveInvoice(client *paystack.Client, code, source string) error {
+	if _, err := client.ArchivePaymentRequest(code); err != nil {
+		return err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := recordInvoiceEvent(tx, code, "payment_request_archived", "", "", nil, source, ""); err != nil {
+		return err
+	}
+	if _, err := refreshInvoiceBalance(tx, code, "archived", source); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,649 @@
+// Package handlers implements HTTP handlers for the moniewave financial management system.
+//
+// Customer Directory - Paystack Integration Layer
+//
+// OBJECTIVES:
+// Look customers up without a Paystack round trip, and keep our own notes on them.
+//
+// PURPOSE:
+// - Mirror Paystack customers into a local customers table
+// - Sync incrementally (new customers since the last run) or in full
+// - Search by name, email, phone or customer code
+// - Keep local tags and notes that are never sent to Paystack
+// - Resolve customer names and emails for invoices from the cache
+//
+// KEY WORKFLOW:
+// Read Cursor → Page Through Paystack Customers Created Since Cursor →
+// Upsert Into Cache → Advance Cursor → Record Sync Run
+//
+// DESIGN DECISIONS:
+// - Paystack filters customer lists by creation date only, so an incremental
+//   sync picks up new customers; a full sync (or a cache miss) refreshes
+//   customers edited upstream
+// - The cursor moves in the same transaction as the upserts, and each run
+//   re-reads a small overlap so customers created mid-page are not missed
+// - Search matches every word of the query as a word prefix against name,
+//   email, phone and code through the customers_fts FTS4 index (go-sqlite3
+//   builds FTS3/FTS4, not FTS5); triggers keep the index in step with the table
+// - A cache miss during invoicing fetches the customer from Paystack and
+//   caches it, so the cache fills itself even before the first sync
+// - Tags and notes are local only and survive syncs
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"paystack.mpc.proxy/internal/database"
+	"paystack.mpc.proxy/internal/paystack"
+
+	paystackSDK "github.com/borderlesshq/paystack-go"
+	"github.com/go-chi/chi/v5"
+)
+
+// customerSyncPageSize is the Paystack page size used while syncing
+const customerSyncPageSize = 100
+
+// customerSyncOverlap is how far before the cursor each incremental sync starts
+const customerSyncOverlap = 10 * time.Minute
+
+// customerSearchLimit caps search results when no limit is given
+const customerSearchLimit = 50
+
+var customerSyncMu sync.Mutex
+
+// LocalCustomer is a cached Paystack customer with local tags and notes
+type LocalCustomer struct {
+	CustomerCode      string     `json:"customer_code"`
+	PaystackID        int        `json:"paystack_id,omitempty"`
+	Email             string     `json:"email"`
+	FirstName         string     `json:"first_name,omitempty"`
+	LastName          string     `json:"last_name,omitempty"`
+	Phone             string     `json:"phone,omitempty"`
+	RiskAction        string     `json:"risk_action,omitempty"`
+	Tags              []string   `json:"tags"`
+	Notes             string     `json:"notes,omitempty"`
+	UpstreamCreatedAt *time.Time `json:"upstream_created_at,omitempty"`
+	LastSyncedAt      *time.Time `json:"last_synced_at,omitempty"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+}
+
+// DisplayName is the customer's full name, or their email when Paystack has no name
+func (c *LocalCustomer) DisplayName() string {
+	name := strings.TrimSpace(c.FirstName + " " + c.LastName)
+	if name == "" {
+		name = c.Email
+	}
+	return name
+}
+
+// CustomerSyncReport summarises one customer sync
+type CustomerSyncReport struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Full       bool      `json:"full"`
+	From       string    `json:"from,omitempty"`
+	Pages      int       `json:"pages"`
+	Fetched    int       `json:"fetched"`
+	Added      []string  `json:"added"`
+	Updated    []string  `json:"updated"`
+	Unchanged  int       `json:"unchanged"`
+	Cursor     string    `json:"cursor,omitempty"`
+}
+
+type SyncCustomersRequest struct {
+	// Full re-reads every customer instead of only those created since the last sync
+	Full bool `json:"full,omitempty"`
+}
+
+type UpdateCustomerProfileRequest struct {
+	// Tags replaces the customer's tags when set
+	Tags  []string `json:"tags,omitempty"`
+	Notes *string  `json:"notes,omitempty"`
+}
+
+// customerFromSDK converts a Paystack customer into a cache row
+func customerFromSDK(c *paystackSDK.Customer) *LocalCustomer {
+	customer := &LocalCustomer{
+		CustomerCode: c.CustomerCode,
+		PaystackID:   c.ID,
+		Email:        c.Email,
+		FirstName:    c.FirstName,
+		LastName:     c.LastName,
+		Phone:        c.Phone,
+		RiskAction:   c.RiskAction,
+	}
+	if t, err := time.Parse(time.RFC3339, c.CreatedAt); err == nil {
+		customer.UpstreamCreatedAt = &t
+	}
+	return customer
+}
+
+// customerFromMap converts a customer from a raw list response
+func customerFromMap(m map[string]interface{}) *LocalCustomer {
+	customer := &LocalCustomer{
+		CustomerCode: mapString(m, "customer_code"),
+		PaystackID:   mapInt(m, "id"),
+		Email:        mapString(m, "email"),
+		FirstName:    mapString(m, "first_name"),
+		LastName:     mapString(m, "last_name"),
+		Phone:        mapString(m, "phone"),
+		RiskAction:   mapString(m, "risk_action"),
+	}
+	createdAt := mapString(m, "createdAt")
+	if createdAt == "" {
+		createdAt = mapString(m, "created_at")
+	}
+	if t, err := time.Parse(time.RFC3339, createdAt); err == nil {
+		customer.UpstreamCreatedAt = &t
+	}
+	return customer
+}
+
+// upsertCustomer writes Paystack fields into the cache, keeping local tags
+// and notes. It returns "added", "updated" or "unchanged".
+func upsertCustomer(tx *sql.Tx, c *LocalCustomer) (string, error) {
+	now := time.Now()
+
+	var email, firstName, lastName, phone, riskAction sql.NullString
+	err := tx.QueryRow(
+		"SELECT email, first_name, last_name, phone, risk_action FROM customers WHERE customer_code = ?",
+		c.CustomerCode,
+	).Scan(&email, &firstName, &lastName, &phone, &riskAction)
+	if err == sql.ErrNoRows {
+		_, err := tx.Exec(`
+			INSERT INTO customers (customer_code, paystack_id, email, first_name, last_name, phone, risk_action,
+			                       upstream_created_at, last_synced_at, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, c.CustomerCode, c.PaystackID, c.Email, c.FirstName, c.LastName, c.Phone, c.RiskAction,
+			c.UpstreamCreatedAt, now, now, now)
+		if err != nil {
+			return "", fmt.Errorf("failed to insert customer %s: %w", c.CustomerCode, err)
+		}
+		return "added", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load customer %s: %w", c.CustomerCode, err)
+	}
+
+	if email.String == c.Email && firstName.String == c.FirstName && lastName.String == c.LastName &&
+		phone.String == c.Phone && riskAction.String == c.RiskAction {
+		if _, err := tx.Exec("UPDATE customers SET last_synced_at = ? WHERE customer_code = ?", now, c.CustomerCode); err != nil {
+			return "", fmt.Errorf("failed to update customer %s: %w", c.CustomerCode, err)
+		}
+		return "unchanged", nil
+	}
+
+	_, err = tx.Exec(`
+		UPDATE customers
+		SET paystack_id = ?, email = ?, first_name = ?, last_name = ?, phone = ?, risk_action = ?,
+		    upstream_created_at = COALESCE(?, upstream_created_at), last_synced_at = ?, updated_at = ?
+		WHERE customer_code = ?
+	`, c.PaystackID, c.Email, c.FirstName, c.LastName, c.Phone, c.RiskAction, c.UpstreamCreatedAt, now, now, c.CustomerCode)
+	if err != nil {
+		return "", fmt.Errorf("failed to update customer %s: %w", c.CustomerCode, err)
+	}
+	return "updated", nil
+}
+
+// cacheCustomer upserts one customer in its own transaction
+func cacheCustomer(c *LocalCustomer) error {
+	if c.CustomerCode == "" {
+		return fmt.Errorf("customer has no customer_code")
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := upsertCustomer(tx, c); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const localCustomerColumns = `customer_code, COALESCE(paystack_id, 0), COALESCE(email, ''), COALESCE(first_name, ''),
+	COALESCE(last_name, ''), COALESCE(phone, ''), COALESCE(risk_action, ''), COALESCE(notes, ''),
+	upstream_created_at, last_synced_at, updated_at`
+
+func scanLocalCustomer(row rowScanner) (*LocalCustomer, error) {
+	var c LocalCustomer
+	var createdAt, syncedAt sql.NullTime
+	err := row.Scan(&c.CustomerCode, &c.PaystackID, &c.Email, &c.FirstName, &c.LastName, &c.Phone,
+		&c.RiskAction, &c.Notes, &createdAt, &syncedAt, &c.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if createdAt.Valid {
+		c.UpstreamCreatedAt = &createdAt.Time
+	}
+	if syncedAt.Valid {
+		c.LastSyncedAt = &syncedAt.Time
+	}
+	c.Tags = []string{}
+	return &c, nil
+}
+
+// lookupCachedCustomer finds a customer by code or email in the cache. It
+// returns sql.ErrNoRows on a cache miss.
+func lookupCachedCustomer(codeOrEmail string) (*LocalCustomer, error) {
+	row := database.DB.QueryRow(
+		"SELECT "+localCustomerColumns+" FROM customers WHERE customer_code = ? OR email = ? ORDER BY customer_code = ? DESC LIMIT 1",
+		codeOrEmail, codeOrEmail, codeOrEmail,
+	)
+	customer, err := scanLocalCustomer(row)
+	if err != nil {
+		return nil, err
+	}
+	if err := loadCustomerTags([]*LocalCustomer{customer}); err != nil {
+		return nil, err
+	}
+	return customer, nil
+}
+
+// fetchCustomer reads a customer from Paystack without touching the cache
+func fetchCustomer(client *paystack.Client, codeOrEmail string) (*LocalCustomer, error) {
+	customer, err := client.Customer.Get(codeOrEmail)
+	if err != nil {
+		return nil, err
+	}
+	return customerFromSDK(customer), nil
+}
+
+// resolveCustomer returns a customer from the cache, fetching and caching it
+// from Paystack on a miss
+func resolveCustomer(client *paystack.Client, codeOrEmail string) (*LocalCustomer, error) {
+	customer, err := lookupCachedCustomer(codeOrEmail)
+	if err == nil {
+		return customer, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	customer, err = fetchCustomer(client, codeOrEmail)
+	if err != nil {
+		return nil, err
+	}
+	if err := cacheCustomer(customer); err != nil {
+		// The invoice can still be created; the next sync will cache the customer
+		return customer, nil
+	}
+	return customer, nil
+}
+
+// loadCustomerTags fills Tags for the given customers
+func loadCustomerTags(customers []*LocalCustomer) error {
+	if len(customers) == 0 {
+		return nil
+	}
+
+	byCode := map[string]*LocalCustomer{}
+	placeholders := []string{}
+	args := []interface{}{}
+	for _, c := range customers {
+		byCode[c.CustomerCode] = c
+		placeholders = append(placeholders, "?")
+		args = append(args, c.CustomerCode)
+	}
+
+	rows, err := database.DB.Query(
+		"SELECT customer_code, tag FROM customer_tags WHERE customer_code IN ("+joinStrings(placeholders, ", ")+") ORDER BY tag",
+		args...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query customer tags: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var code, tag string
+		if err := rows.Scan(&code, &tag); err != nil {
+			return fmt.Errorf("failed to scan customer tag: %w", err)
+		}
+		if c := byCode[code]; c != nil {
+			c.Tags = append(c.Tags, tag)
+		}
+	}
+	return rows.Err()
+}
+
+// normalizeTags trims, lowercases and de-duplicates tags
+func normalizeTags(tags []string) ([]string, error) {
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		if len(tag) > 40 {
+			return nil, fmt.Errorf("tag %q is longer than 40 characters", tag)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// Sync pulls Paystack customers into the local cache
+func (h *CustomerHandler) Sync(w http.ResponseWriter, r *http.Request) {
+	var req SyncCustomersRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJSONBadRequest(w, "Invalid request body")
+			return
+		}
+	}
+
+	report, err := SyncCustomers(h.client, req.Full)
+	if err != nil {
+		if errors.Is(err, ErrSyncInProgress) {
+			WriteJSONError(w, err, http.StatusConflict)
+			return
+		}
+		WriteJSONError(w, fmt.Errorf("customer sync failed: %w", err), http.StatusBadGateway)
+		return
+	}
+
+	WriteJSONSuccess(w, report)
+}
+
+// SyncCustomers runs a customer sync and records the run in sync_runs. An
+// incremental sync starts from the saved cursor; the first sync is always full.
+func SyncCustomers(client *paystack.Client, full bool) (*CustomerSyncReport, error) {
+	if !customerSyncMu.TryLock() {
+		return nil, ErrSyncInProgress
+	}
+	defer customerSyncMu.Unlock()
+
+	report := &CustomerSyncReport{
+		StartedAt: time.Now(),
+		Full:      full,
+		Added:     []string{},
+		Updated:   []string{},
+	}
+
+	err := syncCustomers(client, report)
+	report.FinishedAt = time.Now()
+	recordSyncRun("customers", report.StartedAt, report, err)
+
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func syncCustomers(client *paystack.Client, report *CustomerSyncReport) error {
+	cursor, err := getSyncCursor("customers")
+	if err != nil {
+		return err
+	}
+	if cursor == "" {
+		report.Full = true
+	}
+	if !report.Full {
+		if t, err := time.Parse(time.RFC3339, cursor); err == nil {
+			report.From = t.Add(-customerSyncOverlap).UTC().Format(time.RFC3339)
+		} else {
+			report.Full = true
+		}
+	}
+
+	// Fetch everything before writing so the transaction stays short
+	upstream := []*LocalCustomer{}
+	for page := 1; ; page++ {
+		resp, err := client.ListCustomers(customerSyncPageSize, page, report.From)
+		if err != nil {
+			return fmt.Errorf("failed to list customers (page %d): %w", page, err)
+		}
+		report.Pages++
+
+		data, _ := resp["data"].([]interface{})
+		for _, item := range data {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if customer := customerFromMap(m); customer.CustomerCode != "" {
+				upstream = append(upstream, customer)
+			}
+		}
+
+		meta := mapObject(resp, "meta")
+		pageCount := mapInt(meta, "pageCount")
+		if len(data) < customerSyncPageSize || (pageCount > 0 && page >= pageCount) {
+			break
+		}
+	}
+	report.Fetched = len(upstream)
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	latest, _ := time.Parse(time.RFC3339, cursor)
+	for _, customer := range upstream {
+		outcome, err := upsertCustomer(tx, customer)
+		if err != nil {
+			return err
+		}
+		switch outcome {
+		case "added":
+			report.Added = append(report.Added, customer.CustomerCode)
+		case "updated":
+			report.Updated = append(report.Updated, customer.CustomerCode)
+		default:
+			report.Unchanged++
+		}
+		if customer.UpstreamCreatedAt != nil && customer.UpstreamCreatedAt.After(latest) {
+			latest = *customer.UpstreamCreatedAt
+		}
+	}
+
+	if !latest.IsZero() {
+		report.Cursor = latest.UTC().Format(time.RFC3339)
+		if err := setSyncCursor(tx, "customers", report.Cursor); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit customer sync: %w", err)
+	}
+	return nil
+}
+
+// Search finds cached customers.
+// Query params: q (words matched against name, email, phone and code), tag,
+// limit (default 50), offset.
+func (h *CustomerHandler) Search(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := customerSearchLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 500 {
+			WriteJSONBadRequest(w, "limit must be between 1 and 500")
+			return
+		}
+		limit = n
+	}
+	offset := 0
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			WriteJSONBadRequest(w, "offset must be a non-negative number")
+			return
+		}
+		offset = n
+	}
+
+	sqlQuery := "SELECT " + localCustomerColumns + " FROM customers WHERE 1=1"
+	args := []interface{}{}
+
+	q := strings.TrimSpace(query.Get("q"))
+	if match := customerMatchQuery(q); match != "" {
+		sqlQuery += " AND id IN (SELECT rowid FROM customers_fts WHERE customers_fts MATCH ?)"
+		args = append(args, match)
+	}
+	if tag := strings.ToLower(strings.TrimSpace(query.Get("tag"))); tag != "" {
+		sqlQuery += " AND customer_code IN (SELECT customer_code FROM customer_tags WHERE tag = ?)"
+		args = append(args, tag)
+	}
+
+	// Exact email or code matches first, then names starting with the query
+	sqlQuery += ` ORDER BY CASE
+			WHEN LOWER(COALESCE(email, '')) = ? OR customer_code = ? THEN 0
+			WHEN LOWER(COALESCE(first_name, '') || ' ' || COALESCE(last_name, '')) LIKE ? ESCAPE '\' THEN 1
+			ELSE 2
+		END, LOWER(COALESCE(first_name, '')), LOWER(COALESCE(last_name, '')), customer_code
+		LIMIT ? OFFSET ?`
+	args = append(args, strings.ToLower(q), q, escapeLike(strings.ToLower(q))+"%", limit, offset)
+
+	rows, err := database.DB.Query(sqlQuery, args...)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to search customers: %w", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	customers := []*LocalCustomer{}
+	for rows.Next() {
+		customer, err := scanLocalCustomer(rows)
+		if err != nil {
+			WriteJSONError(w, fmt.Errorf("failed to scan customer: %w", err), http.StatusInternalServerError)
+			return
+		}
+		customers = append(customers, customer)
+	}
+	if err := rows.Err(); err != nil {
+		WriteJSONError(w, fmt.Errorf("error iterating customers: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	if err := loadCustomerTags(customers); err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, customers)
+}
+
+// customerMatchQuery turns a search into an FTS4 MATCH expression: each word
+// becomes a quoted prefix phrase, so punctuation in emails and codes is
+// tokenised like the index and cannot be read as query syntax. It returns ""
+// when the search has no letters or digits.
+func customerMatchQuery(q string) string {
+	terms := []string{}
+	for _, word := range strings.Fields(q) {
+		if !strings.ContainsFunc(word, func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) }) {
+			continue
+		}
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, " ")+`*"`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// GetLocal returns a cached customer by code or email
+func (h *CustomerHandler) GetLocal(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+
+	customer, err := lookupCachedCustomer(code)
+	if err == sql.ErrNoRows {
+		WriteJSONError(w, fmt.Errorf("customer %s is not in the local directory", code), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to load customer: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, customer)
+}
+
+// UpdateProfile sets a cached customer's local tags and notes
+func (h *CustomerHandler) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+
+	var req UpdateCustomerProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+	if req.Tags == nil && req.Notes == nil {
+		WriteJSONBadRequest(w, "at least one of tags or notes is required")
+		return
+	}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		WriteJSONBadRequest(w, err.Error())
+		return
+	}
+
+	customer, err := lookupCachedCustomer(code)
+	if err == sql.ErrNoRows {
+		WriteJSONError(w, fmt.Errorf("customer %s is not in the local directory", code), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to load customer: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to begin transaction: %w", err), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if req.Notes != nil {
+		if _, err := tx.Exec("UPDATE customers SET notes = ?, updated_at = ? WHERE customer_code = ?", *req.Notes, now, customer.CustomerCode); err != nil {
+			WriteJSONError(w, fmt.Errorf("failed to update notes: %w", err), http.StatusInternalServerError)
+			return
+		}
+	}
+	if req.Tags != nil {
+		if _, err := tx.Exec("DELETE FROM customer_tags WHERE customer_code = ?", customer.CustomerCode); err != nil {
+			WriteJSONError(w, fmt.Errorf("failed to update tags: %w", err), http.StatusInternalServerError)
+			return
+		}
+		for _, tag := range tags {
+			if _, err := tx.Exec("INSERT INTO customer_tags (customer_code, tag, created_at) VALUES (?, ?, ?)", customer.CustomerCode, tag, now); err != nil {
+				WriteJSONError(w, fmt.Errorf("failed to update tags: %w", err), http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to commit customer profile: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	customer, err = lookupCachedCustomer(customer.CustomerCode)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to load customer: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, customer)
+}
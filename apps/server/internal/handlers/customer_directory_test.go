@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	got, err := normalizeTags([]string{" VIP", "retail", "vip", "", "Retail "})
+	if err != nil {
+		t.Fatalf("normalizeTags returned error: %v", err)
+	}
+	if want := []string{"retail", "vip"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeTags = %v, want %v", got, want)
+	}
+
+	if _, err := normalizeTags([]string{"this-tag-is-far-too-long-to-be-useful-as-a-label"}); err == nil {
+		t.Error("expected an error for a tag over 40 characters")
+	}
+}
+
+func TestCustomerFromMap(t *testing.T) {
+	customer := customerFromMap(map[string]interface{}{
+		"id":            float64(12),
+		"customer_code": "CUS_abc",
+		"email":         "ada@example.com",
+		"first_name":    "",
+		"last_name":     "",
+		"createdAt":     "2025-03-01T09:30:00.000Z",
+	})
+
+	if customer.CustomerCode != "CUS_abc" || customer.PaystackID != 12 {
+		t.Errorf("unexpected customer: %+v", customer)
+	}
+	if customer.UpstreamCreatedAt == nil || customer.UpstreamCreatedAt.Day() != 1 {
+		t.Errorf("UpstreamCreatedAt = %v, want 2025-03-01", customer.UpstreamCreatedAt)
+	}
+	if got := customer.DisplayName(); got != "ada@example.com" {
+		t.Errorf("DisplayName = %q, want the email when there is no name", got)
+	}
+}
+
+func TestSearchCustomers(t *testing.T) {
+	openTestDB(t)
+	for _, c := range []*LocalCustomer{
+		{CustomerCode: "CUS_ada1", Email: "ada.obi@example.com", FirstName: "Ada", LastName: "Obi", Phone: "+2348012345678"},
+		{CustomerCode: "CUS_bola", Email: "bola_50@example.com", FirstName: "Bola", LastName: "Adeyemi"},
+		{CustomerCode: "CUS_chi9", Email: "chi@sample.org", FirstName: "Chinedu", LastName: "Eze"},
+	} {
+		if err := cacheCustomer(c); err != nil {
+			t.Fatalf("cacheCustomer: %v", err)
+		}
+	}
+	// Updates must reach the index too
+	if err := cacheCustomer(&LocalCustomer{CustomerCode: "CUS_chi9", Email: "chinedu@sample.org", FirstName: "Chinedu", LastName: "Eze"}); err != nil {
+		t.Fatalf("cacheCustomer: %v", err)
+	}
+
+	search := func(q string) []string {
+		w := httptest.NewRecorder()
+		NewCustomerHandler(nil).Search(w, httptest.NewRequest("GET", "/?q="+url.QueryEscape(q), nil))
+		var resp struct {
+			Data []LocalCustomer `json:"data"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatalf("search %q: %v (status %d)", q, err, w.Code)
+		}
+		codes := []string{}
+		for _, c := range resp.Data {
+			codes = append(codes, c.CustomerCode)
+		}
+		return codes
+	}
+
+	tests := map[string][]string{
+		"ad":                  {"CUS_ada1", "CUS_bola"},
+		"ada obi":             {"CUS_ada1"},
+		"ada.obi@example.com": {"CUS_ada1"},
+		"bola_50":             {"CUS_bola"},
+		"50%":                 {"CUS_bola"},
+		"cus_chi":             {"CUS_chi9"},
+		"chinedu@sample":      {"CUS_chi9"},
+		"chi@sample":          {},
+		`"eze`:                {"CUS_chi9"},
+	}
+	for q, want := range tests {
+		if got := search(q); !reflect.DeepEqual(got, want) {
+			t.Errorf("search %q = %v, want %v", q, got, want)
+		}
+	}
+}
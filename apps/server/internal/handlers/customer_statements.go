@@ -0,0 +1,522 @@
+// Package handlers implements HTTP handlers for the moniewave financial management system.
+//
+// Customer Statements - Income Management
+//
+// OBJECTIVES:
+// Answer "what does this customer owe and what have they paid" in one call.
+//
+// PURPOSE:
+// - Produce a statement of account for one customer over a date range
+// - Combine invoices, invoice payments, credit notes and Paystack transactions
+// - Show opening balance, a running balance per entry and the closing balance
+// - Render as JSON, CSV or HTML
+//
+// KEY WORKFLOW:
+// Resolve Customer → Load Their Invoices, Payments And Credit Notes →
+// Fetch Their Successful Paystack Transactions For The Period →
+// Drop Transactions Already In The Payments Ledger → Sort → Running Balance
+//
+// DESIGN DECISIONS:
+// - Invoices are debits; payments and credit notes are credits. The balance is
+//   what the customer owes, so the closing balance matches receivables
+// - Cancelled, void, archived, failed and draft invoices are left out, with
+//   their payments and credit notes, as in the aging report
+// - Paystack transactions that paid an invoice are already in the payments
+//   ledger; a transaction is matched to a Paystack ledger payment of the same
+//   amount within two days and not listed twice
+// - Remaining transactions were paid at checkout with no invoice; they appear
+//   as both debit and credit so they show on the statement without moving
+//   the balance
+// - One statement covers one currency; amounts are never added across currencies
+// - JSON amounts are in kobo; CSV amounts are decimals in major units
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+	"paystack.mpc.proxy/internal/document"
+	"paystack.mpc.proxy/internal/money"
+	"paystack.mpc.proxy/internal/paystack"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Statement entry types, in the order they are listed on the same day
+const (
+	StatementEntryInvoice     = "invoice"
+	StatementEntryTransaction = "transaction"
+	StatementEntryPayment     = "payment"
+	StatementEntryCreditNote  = "credit_note"
+)
+
+var statementEntryOrder = map[string]int{
+	StatementEntryInvoice:     0,
+	StatementEntryTransaction: 1,
+	StatementEntryPayment:     2,
+	StatementEntryCreditNote:  3,
+}
+
+// statementMatchWindow is how far apart a Paystack transaction and the ledger
+// payment it produced may be
+const statementMatchWindow = 48 * time.Hour
+
+// statementTransactionPages caps the Paystack pages read for one statement
+const statementTransactionPages = 20
+
+type StatementHandler struct {
+	client   *paystack.Client
+	renderer *document.Renderer
+}
+
+func NewStatementHandler(client *paystack.Client, renderer *document.Renderer) *StatementHandler {
+	return &StatementHandler{client: client, renderer: renderer}
+}
+
+// StatementEntry is one line of a customer statement. Amounts are in kobo.
+type StatementEntry struct {
+	Date        time.Time `json:"date"`
+	Type        string    `json:"type"`
+	Reference   string    `json:"reference"`
+	InvoiceCode string    `json:"invoice_code,omitempty"`
+	Description string    `json:"description"`
+	Debit       int       `json:"debit"`
+	Credit      int       `json:"credit"`
+	Balance     int       `json:"balance"`
+
+	// source is the ledger source of a payment, used for transaction matching
+	source string
+}
+
+// CustomerStatement is a customer's account activity over a period
+type CustomerStatement struct {
+	CustomerCode   string           `json:"customer_code"`
+	CustomerName   string           `json:"customer_name"`
+	CustomerEmail  string           `json:"customer_email"`
+	Currency       string           `json:"currency"`
+	From           string           `json:"from"`
+	To             string           `json:"to"`
+	OpeningBalance int              `json:"opening_balance"`
+	Entries        []StatementEntry `json:"entries"`
+	TotalDebits    int              `json:"total_debits"`
+	TotalCredits   int              `json:"total_credits"`
+	ClosingBalance int              `json:"closing_balance"`
+	GeneratedAt    time.Time        `json:"generated_at"`
+}
+
+// Get returns a customer's statement.
+// Query params: from and to (YYYY-MM-DD, default the current month to date),
+// currency (default NGN), format=json|csv|html.
+func (h *StatementHandler) Get(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+	query := r.URL.Query()
+
+	now := time.Now().UTC()
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if value := query.Get("to"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			WriteJSONBadRequest(w, "to must be a date (YYYY-MM-DD)")
+			return
+		}
+		to = parsed
+	}
+	from := time.Date(to.Year(), to.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if value := query.Get("from"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			WriteJSONBadRequest(w, "from must be a date (YYYY-MM-DD)")
+			return
+		}
+		from = parsed
+	}
+	if from.After(to) {
+		WriteJSONBadRequest(w, "from must not be after to")
+		return
+	}
+
+	currency := strings.ToUpper(query.Get("currency"))
+	if currency == "" {
+		currency = "NGN"
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" && format != "html" {
+		WriteJSONBadRequest(w, "format must be json, csv or html")
+		return
+	}
+
+	customer, err := resolveCustomer(h.client, code)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("customer not found: %w", err), http.StatusNotFound)
+		return
+	}
+
+	transactions, err := h.customerTransactions(customer, currency, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		WriteJSONError(w, err, http.StatusBadGateway)
+		return
+	}
+
+	statement, err := buildCustomerStatement(customer, currency, from, to, transactions)
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	switch format {
+	case "csv":
+		header := []string{"date", "type", "reference", "invoice_code", "description", "debit", "credit", "balance"}
+		rows := [][]string{{statement.From, "opening_balance", "", "", "Opening balance", "", "", money.Decimal(statement.OpeningBalance)}}
+		for _, e := range statement.Entries {
+			rows = append(rows, []string{
+				e.Date.Format("2006-01-02"), e.Type, e.Reference, e.InvoiceCode, e.Description,
+				money.Decimal(e.Debit), money.Decimal(e.Credit), money.Decimal(e.Balance),
+			})
+		}
+		rows = append(rows, []string{
+			statement.To, "closing_balance", "", "", "Closing balance",
+			money.Decimal(statement.TotalDebits), money.Decimal(statement.TotalCredits), money.Decimal(statement.ClosingBalance),
+		})
+		WriteCSV(w, fmt.Sprintf("statement-%s-%s-%s.csv", customer.CustomerCode, statement.From, statement.To), header, rows)
+
+	case "html":
+		var page bytes.Buffer
+		if err := h.renderer.StatementHTML(&page, statementDocument(statement, h.renderer.Business(), from, to)); err != nil {
+			WriteJSONError(w, fmt.Errorf("failed to render statement: %w", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page.Bytes())
+
+	default:
+		WriteJSONSuccess(w, statement)
+	}
+}
+
+// buildCustomerStatement loads everything the customer did up to the end of
+// to, adds the period's Paystack transactions and turns it into a statement
+// for [from, to]
+func buildCustomerStatement(customer *LocalCustomer, currency string, from, to time.Time, transactions []StatementEntry) (*CustomerStatement, error) {
+	toEnd := to.AddDate(0, 0, 1)
+
+	entries, err := customerLedgerEntries(customer, currency, toEnd)
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, unmatchedTransactions(transactions, entries)...)
+
+	statement := &CustomerStatement{
+		CustomerCode:  customer.CustomerCode,
+		CustomerName:  customer.DisplayName(),
+		CustomerEmail: customer.Email,
+		Currency:      currency,
+		From:          from.Format("2006-01-02"),
+		To:            to.Format("2006-01-02"),
+		GeneratedAt:   time.Now(),
+	}
+	statement.OpeningBalance, statement.Entries = runningBalance(entries, from, toEnd)
+	statement.ClosingBalance = statement.OpeningBalance
+	for _, e := range statement.Entries {
+		statement.TotalDebits += e.Debit
+		statement.TotalCredits += e.Credit
+	}
+	statement.ClosingBalance += statement.TotalDebits - statement.TotalCredits
+
+	return statement, nil
+}
+
+// runningBalance sorts entries, folds everything before from into the opening
+// balance and returns the entries in [from, toEnd) with their running balance
+func runningBalance(entries []StatementEntry, from, toEnd time.Time) (int, []StatementEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].Date.Equal(entries[j].Date) {
+			return entries[i].Date.Before(entries[j].Date)
+		}
+		if statementEntryOrder[entries[i].Type] != statementEntryOrder[entries[j].Type] {
+			return statementEntryOrder[entries[i].Type] < statementEntryOrder[entries[j].Type]
+		}
+		return entries[i].Reference < entries[j].Reference
+	})
+
+	opening := 0
+	listed := []StatementEntry{}
+	balance := 0
+	for _, e := range entries {
+		if e.Date.Before(from) {
+			opening += e.Debit - e.Credit
+			balance = opening
+			continue
+		}
+		if !e.Date.Before(toEnd) {
+			continue
+		}
+		balance += e.Debit - e.Credit
+		e.Balance = balance
+		listed = append(listed, e)
+	}
+	return opening, listed
+}
+
+// customerLedgerEntries returns the customer's invoices, payments and credit
+// notes in the currency, up to toEnd
+func customerLedgerEntries(customer *LocalCustomer, currency string, toEnd time.Time) ([]StatementEntry, error) {
+	// Invoices are stored under whatever identifier they were created with.
+	// Email is only matched when the customer has one, so customers without an
+	// email never pick up invoices stored with an empty customer_email.
+	customerMatch := "customer_id = ?"
+	filterArgs := []interface{}{customer.CustomerCode}
+	if customer.Email != "" {
+		customerMatch += " OR customer_id = ? OR customer_email = ?"
+		filterArgs = append(filterArgs, customer.Email, customer.Email)
+	}
+	invoiceFilter := `
+		FROM invoices
+		WHERE (` + customerMatch + `)
+		  AND COALESCE(currency, 'NGN') = ?
+		  AND COALESCE(status, '') NOT IN ('cancelled', 'void', 'archived', 'failed', 'draft')
+		  AND created_at < ?`
+	filterArgs = append(filterArgs, currency, toEnd)
+
+	entries := []StatementEntry{}
+
+	rows, err := database.DB.Query(`
+		SELECT invoice_code, COALESCE(invoice_number, ''), COALESCE(description, ''), amount, created_at`+invoiceFilter,
+		filterArgs...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invoices: %w", err)
+	}
+	defer rows.Close()
+
+	numbers := map[string]string{}
+	for rows.Next() {
+		var code, number, description string
+		var e StatementEntry
+		if err := rows.Scan(&code, &number, &description, &e.Debit, &e.Date); err != nil {
+			return nil, fmt.Errorf("failed to scan invoice: %w", err)
+		}
+		if number == "" {
+			number = code
+		}
+		numbers[code] = number
+		e.Type = StatementEntryInvoice
+		e.Reference = number
+		e.InvoiceCode = code
+		e.Description = "Invoice " + number
+		if description != "" {
+			e.Description += " - " + description
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating invoices: %w", err)
+	}
+	rows.Close()
+
+	payments, err := database.DB.Query(`
+		SELECT invoice_code, source, amount, reference, COALESCE(method, ''), paid_at
+		FROM invoice_payments
+		WHERE paid_at < ? AND invoice_code IN (SELECT invoice_code`+invoiceFilter+`)`,
+		append([]interface{}{toEnd}, filterArgs...)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invoice payments: %w", err)
+	}
+	defer payments.Close()
+
+	for payments.Next() {
+		var method string
+		var e StatementEntry
+		if err := payments.Scan(&e.InvoiceCode, &e.source, &e.Credit, &e.Reference, &method, &e.Date); err != nil {
+			return nil, fmt.Errorf("failed to scan invoice payment: %w", err)
+		}
+		e.Type = StatementEntryPayment
+		e.Description = "Payment for " + numbers[e.InvoiceCode]
+		if e.source == PaymentSourcePaystack {
+			e.Description += " via Paystack"
+		} else if method != "" {
+			e.Description += " (" + strings.ReplaceAll(method, "_", " ") + ")"
+		}
+		entries = append(entries, e)
+	}
+	if err := payments.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating invoice payments: %w", err)
+	}
+	payments.Close()
+
+	notes, err := database.DB.Query(`
+		SELECT credit_note_number, invoice_code, amount, COALESCE(reason, ''), created_at
+		FROM credit_notes
+		WHERE created_at < ? AND invoice_code IN (SELECT invoice_code`+invoiceFilter+`)`,
+		append([]interface{}{toEnd}, filterArgs...)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query credit notes: %w", err)
+	}
+	defer notes.Close()
+
+	for notes.Next() {
+		var reason string
+		var e StatementEntry
+		if err := notes.Scan(&e.Reference, &e.InvoiceCode, &e.Credit, &reason, &e.Date); err != nil {
+			return nil, fmt.Errorf("failed to scan credit note: %w", err)
+		}
+		e.Type = StatementEntryCreditNote
+		e.Description = "Credit note against " + numbers[e.InvoiceCode]
+		if reason != "" {
+			e.Description += " - " + reason
+		}
+		entries = append(entries, e)
+	}
+	if err := notes.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating credit notes: %w", err)
+	}
+
+	return entries, nil
+}
+
+// customerTransactions fetches the customer's successful Paystack transactions
+// in the currency between from and toEnd
+func (h *StatementHandler) customerTransactions(customer *LocalCustomer, currency string, from, toEnd time.Time) ([]StatementEntry, error) {
+	filter := paystack.TransactionFilter{
+		CustomerID: customer.PaystackID,
+		Status:     "success",
+		From:       from.Format(time.RFC3339),
+		To:         toEnd.Format(time.RFC3339),
+	}
+	if filter.CustomerID == 0 {
+		// Cached before the Paystack ID was known; ask Paystack again
+		fetched, err := fetchCustomer(h.client, customer.CustomerCode)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up customer %s: %w", customer.CustomerCode, err)
+		}
+		filter.CustomerID = fetched.PaystackID
+	}
+
+	transactions := []StatementEntry{}
+	for page := 1; page <= statementTransactionPages; page++ {
+		resp, err := h.client.ListTransactions(100, page, filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list transactions (page %d): %w", page, err)
+		}
+
+		data, _ := resp["data"].([]interface{})
+		for _, item := range data {
+			m, ok := item.(map[string]interface{})
+			if !ok || !strings.EqualFold(mapString(m, "currency"), currency) {
+				continue
+			}
+			if customerEmail := mapString(mapObject(m, "customer"), "email"); customerEmail != "" && !strings.EqualFold(customerEmail, customer.Email) {
+				continue
+			}
+
+			paidAt := firstTime(mapString(m, "paid_at"), mapString(m, "paidAt"), mapString(m, "created_at"), mapString(m, "createdAt"))
+			if paidAt.IsZero() {
+				continue
+			}
+			reference := mapString(m, "reference")
+			description := "Paystack payment"
+			if channel := mapString(m, "channel"); channel != "" {
+				description += " (" + strings.ReplaceAll(channel, "_", " ") + ")"
+			}
+			transactions = append(transactions, StatementEntry{
+				Date:        paidAt,
+				Type:        StatementEntryTransaction,
+				Reference:   reference,
+				Description: description,
+				Debit:       mapInt(m, "amount"),
+				Credit:      mapInt(m, "amount"),
+			})
+		}
+
+		meta := mapObject(resp, "meta")
+		pageCount := mapInt(meta, "pageCount")
+		if len(data) < 100 || (pageCount > 0 && page >= pageCount) {
+			break
+		}
+	}
+
+	return transactions, nil
+}
+
+// unmatchedTransactions returns the transactions that have no Paystack ledger
+// payment of the same amount within statementMatchWindow. Each ledger payment
+// matches at most one transaction.
+func unmatchedTransactions(transactions, ledger []StatementEntry) []StatementEntry {
+	used := make([]bool, len(ledger))
+	unmatched := []StatementEntry{}
+
+	for _, txn := range transactions {
+		matched := false
+		for i, e := range ledger {
+			if used[i] || e.Type != StatementEntryPayment || e.source != PaymentSourcePaystack || e.Credit != txn.Credit {
+				continue
+			}
+			gap := e.Date.Sub(txn.Date)
+			if gap < 0 {
+				gap = -gap
+			}
+			if gap <= statementMatchWindow {
+				used[i] = true
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			unmatched = append(unmatched, txn)
+		}
+	}
+	return unmatched
+}
+
+// firstTime parses the first non-empty RFC3339 timestamp
+func firstTime(values ...string) time.Time {
+	for _, v := range values {
+		if v == "" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// statementDocument converts a statement for the HTML renderer
+func statementDocument(st *CustomerStatement, business document.Business, from, to time.Time) *document.Statement {
+	doc := &document.Statement{
+		Business:       business,
+		CustomerName:   st.CustomerName,
+		CustomerEmail:  st.CustomerEmail,
+		CustomerCode:   st.CustomerCode,
+		Currency:       st.Currency,
+		From:           from,
+		To:             to,
+		OpeningBalance: st.OpeningBalance,
+		TotalDebits:    st.TotalDebits,
+		TotalCredits:   st.TotalCredits,
+		ClosingBalance: st.ClosingBalance,
+		GeneratedAt:    st.GeneratedAt,
+	}
+	for _, e := range st.Entries {
+		doc.Entries = append(doc.Entries, document.StatementEntry{
+			Date:        e.Date,
+			Type:        e.Type,
+			Reference:   e.Reference,
+			Description: e.Description,
+			Debit:       e.Debit,
+			Credit:      e.Credit,
+			Balance:     e.Balance,
+		})
+	}
+	return doc
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRunningBalance(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2025, 3, d, 0, 0, 0, 0, time.UTC) }
+
+	entries := []StatementEntry{
+		{Date: day(20), Type: StatementEntryCreditNote, Reference: "CN-1", Credit: 1000},
+		{Date: day(5), Type: StatementEntryPayment, Reference: "P2", Credit: 3000},
+		{Date: day(1), Type: StatementEntryInvoice, Reference: "INV-1", Debit: 10000},
+		{Date: day(5), Type: StatementEntryInvoice, Reference: "INV-2", Debit: 5000},
+		{Date: day(2), Type: StatementEntryPayment, Reference: "P1", Credit: 4000},
+		{Date: day(31), Type: StatementEntryInvoice, Reference: "INV-3", Debit: 900},
+	}
+
+	opening, listed := runningBalance(entries, day(3), day(31))
+
+	if opening != 6000 {
+		t.Errorf("opening = %d, want 6000", opening)
+	}
+	want := []struct {
+		reference string
+		balance   int
+	}{
+		{"INV-2", 11000},
+		{"P2", 8000},
+		{"CN-1", 7000},
+	}
+	if len(listed) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(listed), len(want), listed)
+	}
+	for i, w := range want {
+		if listed[i].Reference != w.reference || listed[i].Balance != w.balance {
+			t.Errorf("entry %d = %s/%d, want %s/%d", i, listed[i].Reference, listed[i].Balance, w.reference, w.balance)
+		}
+	}
+}
+
+func TestUnmatchedTransactions(t *testing.T) {
+	paid := time.Date(2025, 3, 5, 10, 0, 0, 0, time.UTC)
+
+	ledger := []StatementEntry{
+		{Date: paid.Add(20 * time.Hour), Type: StatementEntryPayment, Credit: 3000, source: PaymentSourcePaystack},
+		{Date: paid, Type: StatementEntryPayment, Credit: 1500, source: PaymentSourceOffline},
+	}
+	transactions := []StatementEntry{
+		{Date: paid, Reference: "T1", Credit: 3000},
+		{Date: paid, Reference: "T2", Credit: 3000},
+		{Date: paid, Reference: "T3", Credit: 1500},
+		{Date: paid.Add(-72 * time.Hour), Reference: "T4", Credit: 3000},
+	}
+
+	unmatched := unmatchedTransactions(transactions, ledger)
+
+	got := []string{}
+	for _, e := range unmatched {
+		got = append(got, e.Reference)
+	}
+	// T1 takes the only Paystack payment; offline payments never match
+	if joinStrings(got, ",") != "T2,T3,T4" {
+		t.Errorf("unmatched = %v, want [T2 T3 T4]", got)
+	}
+}
@@ -9,12 +9,13 @@
 // - Create customer records in Paystack
 // - List and retrieve customer information
 // - Enable transaction association with customers
+// - Keep new customers in the local directory (see customer_directory.go)
 //
 // KEY WORKFLOW:
 // Create Customer → Store in Paystack → Use in Transactions → Track Payment History
 //
 // DESIGN DECISIONS:
-// - All customer data stored in Paystack (no local cache)
+// - Paystack is the source of truth; the local directory is a searchable cache
 // - Email is the primary identifier for customers
 // - Optional fields (first_name, last_name, phone) for flexible customer profiles
 // - Direct passthrough to Paystack SDK for consistency
@@ -23,6 +24,7 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 
 	"paystack.mpc.proxy/internal/paystack"
@@ -74,6 +76,11 @@ func (h *CustomerHandler) Create(w http.ResponseWriter, r *http.Request) {
 		WriteJSONError(w, err, http.StatusInternalServerError)
 		return
 	}
+
+	if err := cacheCustomer(customerFromSDK(result)); err != nil {
+		log.Printf("Warning: failed to cache customer %s: %v", result.CustomerCode, err)
+	}
+
 	WriteJSONSuccess(w, result)
 }
 
@@ -0,0 +1,703 @@
+// Package handlers implements HTTP handlers for the moniewave financial management system.
+//
+// Dunning Handler - Income Management
+//
+// OBJECTIVES:
+// Unpaid invoices should chase themselves instead of sitting in the invoices table.
+//
+// PURPOSE:
+// - Define reminder schedules (dunning policies) relative to invoice due dates
+// - Evaluate open invoices on a schedule and send the reminder that is due
+// - Record every reminder attempted, sent or failed
+// - Let users pick a policy per invoice or pause reminders for one invoice
+//
+// KEY WORKFLOW:
+// Tick → Select Open Invoices With A Due Date → Resolve Policy →
+// Find Current Step → Skip If Already Sent → Notify → Record Reminder
+//
+// DESIGN DECISIONS:
+// - A policy is a list of day offsets from the due date (negative = before),
+//   optionally repeating every N days after the last offset
+// - Only the most recent step that is due is sent; steps missed while the
+//   server was down are skipped rather than sent in a burst
+// - A step is sent at most once per invoice (unique index on sent reminders);
+//   failed attempts are retried on later ticks up to dunningMaxAttempts
+// - Delivery is pluggable (notify.Notifier): Paystack's notify endpoint, SMTP,
+//   or a log-only stand-in for offline use
+// - Reminders handled by the log-only stand-in are recorded as logged, not
+//   sent, so the step is still delivered once a real notifier is configured
+// - Invoices without a policy use the default policy
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+	"paystack.mpc.proxy/internal/notify"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// dunningMaxAttempts is how many times a failed reminder step is retried
+const dunningMaxAttempts = 3
+
+var dunningMu sync.Mutex
+
+// Reminder statuses. Logged reminders were handled by a notifier that does
+// not deliver them (notify.ErrNotDelivered) and do not count as sent.
+const (
+	ReminderStatusSent   = "sent"
+	ReminderStatusFailed = "failed"
+	ReminderStatusLogged = "logged"
+)
+
+type DunningHandler struct {
+	notifier notify.Notifier
+}
+
+func NewDunningHandler(notifier notify.Notifier) *DunningHandler {
+	return &DunningHandler{notifier: notifier}
+}
+
+// DunningPolicy is a reminder schedule relative to an invoice's due date
+type DunningPolicy struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Offsets are days relative to the due date: -3 is three days before,
+	// 0 the due date, 7 a week after
+	Offsets []int `json:"offsets"`
+	// RepeatEveryDays repeats reminders after the last offset; 0 disables
+	RepeatEveryDays int `json:"repeat_every_days"`
+	// MaxRepeats caps repeated reminders; 0 means no cap
+	MaxRepeats int       `json:"max_repeats"`
+	IsDefault  bool      `json:"is_default"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type CreateDunningPolicyRequest struct {
+	Name            string `json:"name"`
+	Offsets         []int  `json:"offsets"`
+	RepeatEveryDays int    `json:"repeat_every_days,omitempty"`
+	MaxRepeats      int    `json:"max_repeats,omitempty"`
+	IsDefault       bool   `json:"is_default,omitempty"`
+}
+
+type UpdateDunningPolicyRequest struct {
+	Name            *string `json:"name,omitempty"`
+	Offsets         []int   `json:"offsets,omitempty"`
+	RepeatEveryDays *int    `json:"repeat_every_days,omitempty"`
+	MaxRepeats      *int    `json:"max_repeats,omitempty"`
+	IsDefault       *bool   `json:"is_default,omitempty"`
+	Active          *bool   `json:"active,omitempty"`
+}
+
+type SetInvoiceDunningRequest struct {
+	// PolicyID selects a policy; 0 reverts to the default policy
+	PolicyID *int  `json:"policy_id,omitempty"`
+	Paused   *bool `json:"paused,omitempty"`
+}
+
+// InvoiceReminder is one reminder attempt in the history
+type InvoiceReminder struct {
+	ID           int       `json:"id"`
+	InvoiceCode  string    `json:"invoice_code"`
+	PolicyID     *int      `json:"policy_id,omitempty"`
+	OffsetDays   int       `json:"offset_days"`
+	Stage        string    `json:"stage"`
+	Channel      string    `json:"channel"`
+	Status       string    `json:"status"`
+	Error        string    `json:"error,omitempty"`
+	ScheduledFor time.Time `json:"scheduled_for"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// DunningReport summarises one scheduler run
+type DunningReport struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Channel    string    `json:"channel"`
+	Evaluated  int       `json:"evaluated"`
+	Sent       []string  `json:"sent"`
+	Failed     []string  `json:"failed"`
+	Logged     []string  `json:"logged"`
+}
+
+// validate checks offsets and repeat settings
+func (p *DunningPolicy) validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(p.Offsets) == 0 {
+		return fmt.Errorf("offsets must contain at least one day offset")
+	}
+	seen := map[int]bool{}
+	for _, offset := range p.Offsets {
+		if offset < -365 || offset > 365 {
+			return fmt.Errorf("offsets must be between -365 and 365 days")
+		}
+		if seen[offset] {
+			return fmt.Errorf("offsets must not repeat (%d)", offset)
+		}
+		seen[offset] = true
+	}
+	if p.RepeatEveryDays < 0 || p.MaxRepeats < 0 {
+		return fmt.Errorf("repeat_every_days and max_repeats cannot be negative")
+	}
+	sort.Ints(p.Offsets)
+	return nil
+}
+
+// currentStep returns the most recent reminder offset that is due at now, or
+// false when no reminder is due yet. Offsets count whole days from the due date.
+func (p *DunningPolicy) currentStep(dueDate, now time.Time) (int, bool) {
+	elapsed := int(math.Floor(now.Sub(dueDate).Hours() / 24))
+
+	step, found := 0, false
+	for _, offset := range p.Offsets {
+		if offset <= elapsed {
+			step, found = offset, true
+		}
+	}
+
+	last := p.Offsets[len(p.Offsets)-1]
+	if p.RepeatEveryDays > 0 && elapsed > last {
+		repeats := (elapsed - last) / p.RepeatEveryDays
+		if p.MaxRepeats > 0 {
+			repeats = min(repeats, p.MaxRepeats)
+		}
+		if repeats > 0 {
+			step, found = last+repeats*p.RepeatEveryDays, true
+		}
+	}
+
+	return step, found
+}
+
+// reminderStage names a step by its position relative to the due date
+func reminderStage(offset int) string {
+	switch {
+	case offset < 0:
+		return notify.StageBeforeDue
+	case offset == 0:
+		return notify.StageDue
+	default:
+		return notify.StageOverdue
+	}
+}
+
+const dunningPolicyColumns = `id, name, offsets, COALESCE(repeat_every_days, 0), COALESCE(max_repeats, 0),
+	COALESCE(is_default, 0), COALESCE(active, 1), created_at, updated_at`
+
+func scanDunningPolicy(row rowScanner) (*DunningPolicy, error) {
+	var policy DunningPolicy
+	var offsets string
+	err := row.Scan(
+		&policy.ID,
+		&policy.Name,
+		&offsets,
+		&policy.RepeatEveryDays,
+		&policy.MaxRepeats,
+		&policy.IsDefault,
+		&policy.Active,
+		&policy.CreatedAt,
+		&policy.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(offsets), &policy.Offsets); err != nil {
+		return nil, fmt.Errorf("invalid offsets for dunning policy %d: %w", policy.ID, err)
+	}
+	sort.Ints(policy.Offsets)
+	return &policy, nil
+}
+
+func getDunningPolicy(id int) (*DunningPolicy, error) {
+	row := database.DB.QueryRow("SELECT "+dunningPolicyColumns+" FROM dunning_policies WHERE id = ?", id)
+	return scanDunningPolicy(row)
+}
+
+func listDunningPolicies() ([]*DunningPolicy, error) {
+	rows, err := database.DB.Query("SELECT " + dunningPolicyColumns + " FROM dunning_policies ORDER BY is_default DESC, name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query dunning policies: %w", err)
+	}
+	defer rows.Close()
+
+	policies := []*DunningPolicy{}
+	for rows.Next() {
+		policy, err := scanDunningPolicy(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dunning policy: %w", err)
+		}
+		policies = append(policies, policy)
+	}
+	return policies, rows.Err()
+}
+
+// saveDunningPolicy inserts or updates a policy; making it the default clears the
+// flag on every other policy in the same transaction
+func saveDunningPolicy(policy *DunningPolicy) error {
+	offsets, err := json.Marshal(policy.Offsets)
+	if err != nil {
+		return fmt.Errorf("failed to encode offsets: %w", err)
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if policy.ID == 0 {
+		result, err := tx.Exec(`
+			INSERT INTO dunning_policies (name, offsets, repeat_every_days, max_repeats, is_default, active, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, policy.Name, string(offsets), policy.RepeatEveryDays, policy.MaxRepeats, policy.IsDefault, policy.Active, now, now)
+		if err != nil {
+			return err
+		}
+		id, _ := result.LastInsertId()
+		policy.ID = int(id)
+		policy.CreatedAt = now
+	} else {
+		_, err := tx.Exec(`
+			UPDATE dunning_policies
+			SET name = ?, offsets = ?, repeat_every_days = ?, max_repeats = ?, is_default = ?, active = ?, updated_at = ?
+			WHERE id = ?
+		`, policy.Name, string(offsets), policy.RepeatEveryDays, policy.MaxRepeats, policy.IsDefault, policy.Active, now, policy.ID)
+		if err != nil {
+			return err
+		}
+	}
+	policy.UpdatedAt = now
+
+	if policy.IsDefault {
+		if _, err := tx.Exec("UPDATE dunning_policies SET is_default = 0 WHERE id != ?", policy.ID); err != nil {
+			return fmt.Errorf("failed to clear previous default policy: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// CreatePolicy creates a dunning policy
+func (h *DunningHandler) CreatePolicy(w http.ResponseWriter, r *http.Request) {
+	var req CreateDunningPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+
+	policy := &DunningPolicy{
+		Name:            req.Name,
+		Offsets:         req.Offsets,
+		RepeatEveryDays: req.RepeatEveryDays,
+		MaxRepeats:      req.MaxRepeats,
+		IsDefault:       req.IsDefault,
+		Active:          true,
+	}
+	if err := policy.validate(); err != nil {
+		WriteJSONBadRequest(w, err.Error())
+		return
+	}
+
+	if err := saveDunningPolicy(policy); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			WriteJSONError(w, fmt.Errorf("a dunning policy named %q already exists", policy.Name), http.StatusConflict)
+			return
+		}
+		WriteJSONError(w, fmt.Errorf("failed to create dunning policy: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, policy)
+}
+
+// ListPolicies lists all dunning policies, default first
+func (h *DunningHandler) ListPolicies(w http.ResponseWriter, r *http.Request) {
+	policies, err := listDunningPolicies()
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, policies)
+}
+
+// UpdatePolicy changes a dunning policy. Steps already sent are not resent.
+func (h *DunningHandler) UpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		WriteJSONBadRequest(w, "id must be a number")
+		return
+	}
+
+	var req UpdateDunningPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+
+	policy, err := getDunningPolicy(id)
+	if err == sql.ErrNoRows {
+		WriteJSONError(w, fmt.Errorf("dunning policy %d not found", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if req.Name != nil {
+		policy.Name = *req.Name
+	}
+	if req.Offsets != nil {
+		policy.Offsets = req.Offsets
+	}
+	if req.RepeatEveryDays != nil {
+		policy.RepeatEveryDays = *req.RepeatEveryDays
+	}
+	if req.MaxRepeats != nil {
+		policy.MaxRepeats = *req.MaxRepeats
+	}
+	if req.IsDefault != nil {
+		policy.IsDefault = *req.IsDefault
+	}
+	if req.Active != nil {
+		policy.Active = *req.Active
+	}
+	if policy.IsDefault && !policy.Active {
+		WriteJSONBadRequest(w, "the default policy cannot be inactive")
+		return
+	}
+	if err := policy.validate(); err != nil {
+		WriteJSONBadRequest(w, err.Error())
+		return
+	}
+
+	if err := saveDunningPolicy(policy); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			WriteJSONError(w, fmt.Errorf("a dunning policy named %q already exists", policy.Name), http.StatusConflict)
+			return
+		}
+		WriteJSONError(w, fmt.Errorf("failed to update dunning policy: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, policy)
+}
+
+// SetInvoice assigns a dunning policy to an invoice or pauses its reminders
+func (h *DunningHandler) SetInvoice(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "invoice_code")
+
+	var req SetInvoiceDunningRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+	if req.PolicyID == nil && req.Paused == nil {
+		WriteJSONBadRequest(w, "at least one of policy_id or paused is required")
+		return
+	}
+
+	updates := []string{}
+	args := []interface{}{}
+
+	if req.PolicyID != nil {
+		if *req.PolicyID == 0 {
+			updates = append(updates, "dunning_policy_id = NULL")
+		} else {
+			if _, err := getDunningPolicy(*req.PolicyID); err != nil {
+				WriteJSONBadRequest(w, fmt.Sprintf("dunning policy %d not found", *req.PolicyID))
+				return
+			}
+			updates = append(updates, "dunning_policy_id = ?")
+			args = append(args, *req.PolicyID)
+		}
+	}
+	if req.Paused != nil {
+		updates = append(updates, "dunning_paused = ?")
+		args = append(args, *req.Paused)
+	}
+
+	updates = append(updates, "updated_at = ?")
+	args = append(args, time.Now(), code)
+
+	result, err := database.DB.Exec("UPDATE invoices SET "+joinStrings(updates, ", ")+" WHERE invoice_code = ?", args...)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to update invoice: %w", err), http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		WriteJSONError(w, fmt.Errorf("invoice %s not found", code), http.StatusNotFound)
+		return
+	}
+
+	WriteJSONSuccess(w, map[string]interface{}{
+		"invoice_code": code,
+		"policy_id":    req.PolicyID,
+		"paused":       req.Paused,
+	})
+}
+
+// Run evaluates all open invoices now instead of waiting for the scheduler
+func (h *DunningHandler) Run(w http.ResponseWriter, r *http.Request) {
+	report, err := RunDunning(h.notifier, time.Now())
+	if err == ErrSyncInProgress {
+		WriteJSONError(w, err, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("dunning run failed: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, report)
+}
+
+// Reminders lists the reminder history, newest first.
+// Query params: invoice_code, status (sent|failed|logged), limit (default 100).
+func (h *DunningHandler) Reminders(w http.ResponseWriter, r *http.Request) {
+	query := `
+		SELECT id, invoice_code, policy_id, offset_days, stage, channel, status, COALESCE(error, ''), scheduled_for, created_at
+		FROM invoice_reminders
+		WHERE 1=1`
+	args := []interface{}{}
+
+	if code := r.URL.Query().Get("invoice_code"); code != "" {
+		query += " AND invoice_code = ?"
+		args = append(args, code)
+	}
+	if status := r.URL.Query().Get("status"); status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+
+	limit := 100
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			WriteJSONBadRequest(w, "limit must be a positive number")
+			return
+		}
+		limit = parsed
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to query reminders: %w", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	reminders := []InvoiceReminder{}
+	for rows.Next() {
+		var reminder InvoiceReminder
+		var policyID sql.NullInt64
+		err := rows.Scan(
+			&reminder.ID,
+			&reminder.InvoiceCode,
+			&policyID,
+			&reminder.OffsetDays,
+			&reminder.Stage,
+			&reminder.Channel,
+			&reminder.Status,
+			&reminder.Error,
+			&reminder.ScheduledFor,
+			&reminder.CreatedAt,
+		)
+		if err != nil {
+			WriteJSONError(w, fmt.Errorf("failed to scan reminder: %w", err), http.StatusInternalServerError)
+			return
+		}
+		reminder.PolicyID = nullIntPtr(policyID)
+		reminders = append(reminders, reminder)
+	}
+
+	if err = rows.Err(); err != nil {
+		WriteJSONError(w, fmt.Errorf("error iterating reminders: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, reminders)
+}
+
+// RunDunning sends the reminders that are due at now and records the run in
+// sync_runs when anything was attempted
+func RunDunning(notifier notify.Notifier, now time.Time) (*DunningReport, error) {
+	if !dunningMu.TryLock() {
+		return nil, ErrSyncInProgress
+	}
+	defer dunningMu.Unlock()
+
+	report := &DunningReport{
+		StartedAt: time.Now(),
+		Channel:   notifier.Name(),
+		Sent:      []string{},
+		Failed:    []string{},
+		Logged:    []string{},
+	}
+
+	err := runDunning(notifier, now, report)
+	report.FinishedAt = time.Now()
+
+	if len(report.Sent) > 0 || len(report.Failed) > 0 || len(report.Logged) > 0 || err != nil {
+		recordSyncRun("dunning", report.StartedAt, report, err)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func runDunning(notifier notify.Notifier, now time.Time, report *DunningReport) error {
+	policies, err := listDunningPolicies()
+	if err != nil {
+		return err
+	}
+	byID := map[int]*DunningPolicy{}
+	var defaultPolicy *DunningPolicy
+	for _, policy := range policies {
+		byID[policy.ID] = policy
+		if policy.IsDefault {
+			defaultPolicy = policy
+		}
+	}
+
+	placeholders := []string{}
+	args := []interface{}{}
+	for _, status := range finalInvoiceStatuses {
+		placeholders = append(placeholders, "?")
+		args = append(args, status)
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT invoice_code, customer_id, customer_name, COALESCE(customer_email, ''), amount,
+		       COALESCE(paid_amount, 0) + COALESCE(credited_amount, 0), COALESCE(currency, 'NGN'), due_date, dunning_policy_id
+		FROM invoices
+		WHERE due_date IS NOT NULL
+		AND COALESCE(dunning_paused, 0) = 0
+		AND amount > COALESCE(paid_amount, 0) + COALESCE(credited_amount, 0)
+		AND COALESCE(status, '') NOT IN (`+joinStrings(placeholders, ", ")+`)
+		ORDER BY due_date
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to select invoices for dunning: %w", err)
+	}
+
+	type openInvoice struct {
+		reminder notify.Reminder
+		policyID sql.NullInt64
+	}
+	open := []openInvoice{}
+	for rows.Next() {
+		var inv openInvoice
+		var settled int
+		err := rows.Scan(
+			&inv.reminder.InvoiceCode,
+			&inv.reminder.CustomerID,
+			&inv.reminder.CustomerName,
+			&inv.reminder.CustomerEmail,
+			&inv.reminder.Amount,
+			&settled,
+			&inv.reminder.Currency,
+			&inv.reminder.DueDate,
+			&inv.policyID,
+		)
+		if err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan invoice: %w", err)
+		}
+		inv.reminder.BalanceDue = inv.reminder.Amount - settled
+		open = append(open, inv)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating invoices: %w", err)
+	}
+
+	for _, inv := range open {
+		policy := defaultPolicy
+		if inv.policyID.Valid {
+			policy = byID[int(inv.policyID.Int64)]
+		}
+		if policy == nil || !policy.Active {
+			continue
+		}
+		report.Evaluated++
+
+		step, due := policy.currentStep(inv.reminder.DueDate, now)
+		if !due {
+			continue
+		}
+
+		// A step logged on this channel is not logged again every tick
+		var sent, failed, logged int
+		err := database.DB.QueryRow(`
+			SELECT COUNT(CASE WHEN status = ? THEN 1 END), COUNT(CASE WHEN status = ? THEN 1 END),
+			       COUNT(CASE WHEN status = ? AND channel = ? THEN 1 END)
+			FROM invoice_reminders
+			WHERE invoice_code = ? AND offset_days = ?
+		`, ReminderStatusSent, ReminderStatusFailed, ReminderStatusLogged, notifier.Name(),
+			inv.reminder.InvoiceCode, step).Scan(&sent, &failed, &logged)
+		if err != nil {
+			return fmt.Errorf("failed to check reminders for %s: %w", inv.reminder.InvoiceCode, err)
+		}
+		if sent > 0 || logged > 0 || failed >= dunningMaxAttempts {
+			continue
+		}
+
+		reminder := inv.reminder
+		reminder.Stage = reminderStage(step)
+		reminder.OffsetDays = step
+
+		status, errMessage := ReminderStatusSent, ""
+		if err := notifier.SendInvoiceReminder(reminder); errors.Is(err, notify.ErrNotDelivered) {
+			status = ReminderStatusLogged
+			report.Logged = append(report.Logged, reminder.InvoiceCode)
+		} else if err != nil {
+			log.Printf("Warning: reminder for invoice %s failed: %v", reminder.InvoiceCode, err)
+			status, errMessage = ReminderStatusFailed, err.Error()
+			report.Failed = append(report.Failed, reminder.InvoiceCode)
+		} else {
+			report.Sent = append(report.Sent, reminder.InvoiceCode)
+		}
+
+		_, err = database.DB.Exec(`
+			INSERT INTO invoice_reminders (invoice_code, policy_id, offset_days, stage, channel, status, error, scheduled_for, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, reminder.InvoiceCode, policy.ID, step, reminder.Stage, notifier.Name(), status, errMessage,
+			reminder.DueDate.AddDate(0, 0, step), time.Now())
+		if err != nil {
+			return fmt.Errorf("failed to record reminder for %s: %w", reminder.InvoiceCode, err)
+		}
+
+		if status == ReminderStatusSent {
+			detail := fmt.Sprintf("%s reminder sent via %s (day %+d)", reminder.Stage, notifier.Name(), step)
+			if err := recordInvoiceEvent(database.DB, reminder.InvoiceCode, "reminder_sent", "", "", nil, InvoiceEventSourceDunning, detail); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+	}
+
+	return nil
+}
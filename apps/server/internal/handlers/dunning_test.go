@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+	"paystack.mpc.proxy/internal/notify"
+)
+
+func TestDunningCurrentStep(t *testing.T) {
+	// 3 days before due, on the due date, then every 7 days (at most twice)
+	policy := &DunningPolicy{Offsets: []int{-3, 0}, RepeatEveryDays: 7, MaxRepeats: 2}
+	due := time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		now      time.Time
+		wantStep int
+		wantDue  bool
+	}{
+		{time.Date(2025, time.March, 6, 9, 0, 0, 0, time.UTC), 0, false},
+		{time.Date(2025, time.March, 7, 9, 0, 0, 0, time.UTC), -3, true},
+		{time.Date(2025, time.March, 9, 23, 0, 0, 0, time.UTC), -3, true},
+		{time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC), 0, true},
+		{time.Date(2025, time.March, 16, 9, 0, 0, 0, time.UTC), 0, true},
+		{time.Date(2025, time.March, 17, 9, 0, 0, 0, time.UTC), 7, true},
+		{time.Date(2025, time.March, 25, 9, 0, 0, 0, time.UTC), 14, true},
+		// Capped at two repeats
+		{time.Date(2025, time.April, 30, 9, 0, 0, 0, time.UTC), 14, true},
+	}
+
+	for _, tt := range tests {
+		step, ok := policy.currentStep(due, tt.now)
+		if ok != tt.wantDue || (ok && step != tt.wantStep) {
+			t.Errorf("currentStep at %s = (%d, %v), want (%d, %v)", tt.now.Format(time.RFC3339), step, ok, tt.wantStep, tt.wantDue)
+		}
+	}
+}
+
+// recordingNotifier delivers every reminder it is given
+type recordingNotifier struct {
+	sent []string
+}
+
+func (n *recordingNotifier) Name() string { return "test" }
+
+func (n *recordingNotifier) SendInvoiceReminder(reminder notify.Reminder) error {
+	n.sent = append(n.sent, reminder.InvoiceCode)
+	return nil
+}
+
+func TestLoggedRemindersAreSentByALaterNotifier(t *testing.T) {
+	openTestDB(t)
+	due := time.Date(2025, time.March, 10, 0, 0, 0, 0, time.UTC)
+	_, err := database.DB.Exec(`
+		INSERT INTO invoices (invoice_code, customer_id, customer_name, amount, currency, status, due_date, created_at, updated_at)
+		VALUES ('PRQ_due', 'CUS_a', 'Ada', 50000, 'NGN', 'pending', ?, ?, ?)
+	`, due, due, due)
+	if err != nil {
+		t.Fatalf("Failed to insert invoice: %v", err)
+	}
+	now := due.Add(12 * time.Hour)
+
+	// The log notifier records the step without counting it as sent, and
+	// does not log it again on the next tick
+	for range 2 {
+		report, err := RunDunning(notify.NewLogNotifier(), now)
+		if err != nil {
+			t.Fatalf("RunDunning(log) error: %v", err)
+		}
+		if len(report.Sent) != 0 {
+			t.Errorf("log notifier reported sent = %v", report.Sent)
+		}
+	}
+	var logged int
+	database.DB.QueryRow("SELECT COUNT(*) FROM invoice_reminders WHERE status = ?", ReminderStatusLogged).Scan(&logged)
+	if logged != 1 {
+		t.Errorf("logged reminders = %d, want 1", logged)
+	}
+
+	notifier := &recordingNotifier{}
+	report, err := RunDunning(notifier, now)
+	if err != nil {
+		t.Fatalf("RunDunning(test) error: %v", err)
+	}
+	if len(notifier.sent) != 1 || len(report.Sent) != 1 {
+		t.Errorf("after switching notifier sent = %v, report = %v; want PRQ_due delivered", notifier.sent, report.Sent)
+	}
+}
@@ -12,7 +12,7 @@
 // - Maintain payment history for analysis and reporting
 //
 // KEY WORKFLOW:
-// Create Expense → Validate Recipient → Check Budget Limit → Update Budget Spent →
+// Create Expense → Validate Recipient → Check Account And Beneficiary Limits → Check Budget Limit → Update Budget Spent →
 // Link to Goal (if applicable) → Return Budget Status
 //
 // DESIGN DECISIONS:
@@ -28,6 +28,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"paystack.mpc.proxy/internal/database"
@@ -56,6 +57,8 @@ type Expense struct {
 	Notes         string     `json:"notes"`
 	GoalID        *int       `json:"goal_id,omitempty"`
 	BudgetLimitID *int       `json:"budget_limit_id,omitempty"`
+	Source        string     `json:"source"`
+	ImportID      *int       `json:"import_id,omitempty"`
 	CreatedAt     time.Time  `json:"created_at"`
 	UpdatedAt     time.Time  `json:"updated_at"`
 }
@@ -84,12 +87,123 @@ type ListExpensesRequest struct {
 	RecipientCode string `json:"recipient_code,omitempty"`
 	Category      string `json:"category,omitempty"`
 	Status        string `json:"status,omitempty"`
+	Source        string `json:"source,omitempty"`
+	ImportID      int    `json:"import_id,omitempty"`
 	From          string `json:"from,omitempty"`
 	To            string `json:"to,omitempty"`
 	Count         int    `json:"count,omitempty"`
 	Offset        int    `json:"offset,omitempty"`
 }
 
+// Expense sources: where an expense was recorded from
+const (
+	ExpenseSourceManual     = "manual"
+	ExpenseSourceBankImport = "bank_import"
+)
+
+const expenseColumns = "id, recipient_code, recipient_name, amount, currency, category, narration, reference, status, payment_date, notes, goal_id, budget_limit_id, source, import_id, created_at, updated_at"
+
+func scanExpense(row rowScanner) (*Expense, error) {
+	var expense Expense
+	var category, notes, source sql.NullString
+	var paymentDate sql.NullTime
+	var goalID, budgetLimitID, importID sql.NullInt64
+
+	err := row.Scan(
+		&expense.ID,
+		&expense.RecipientCode,
+		&expense.RecipientName,
+		&expense.Amount,
+		&expense.Currency,
+		&category,
+		&expense.Narration,
+		&expense.Reference,
+		&expense.Status,
+		&paymentDate,
+		&notes,
+		&goalID,
+		&budgetLimitID,
+		&source,
+		&importID,
+		&expense.CreatedAt,
+		&expense.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if category.Valid {
+		expense.Category = category.String
+	}
+	if notes.Valid {
+		expense.Notes = notes.String
+	}
+	if paymentDate.Valid {
+		expense.PaymentDate = &paymentDate.Time
+	}
+	if goalID.Valid {
+		gid := int(goalID.Int64)
+		expense.GoalID = &gid
+	}
+	if budgetLimitID.Valid {
+		bid := int(budgetLimitID.Int64)
+		expense.BudgetLimitID = &bid
+	}
+	expense.Source = ExpenseSourceManual
+	if source.Valid && source.String != "" {
+		expense.Source = source.String
+	}
+	if importID.Valid {
+		iid := int(importID.Int64)
+		expense.ImportID = &iid
+	}
+	return &expense, nil
+}
+
+// expenseFilters turns list filters into " AND ..." conditions; shared by
+// List and the expense export
+func expenseFilters(req ListExpensesRequest) (string, []interface{}) {
+	where := ""
+	args := []interface{}{}
+
+	if req.RecipientCode != "" {
+		where += " AND recipient_code = ?"
+		args = append(args, req.RecipientCode)
+	}
+
+	if req.Category != "" {
+		where += " AND category = ?"
+		args = append(args, req.Category)
+	}
+
+	if req.Status != "" {
+		where += " AND status = ?"
+		args = append(args, req.Status)
+	}
+
+	if req.Source != "" {
+		where += " AND COALESCE(source, 'manual') = ?"
+		args = append(args, req.Source)
+	}
+
+	if req.ImportID > 0 {
+		where += " AND import_id = ?"
+		args = append(args, req.ImportID)
+	}
+
+	if req.From != "" {
+		where += " AND created_at >= ?"
+		args = append(args, req.From)
+	}
+
+	if req.To != "" {
+		where += " AND created_at <= ?"
+		args = append(args, req.To)
+	}
+
+	return where, args
+}
+
 // Create creates a new expense with budget validation
 func (h *ExpenseHandler) Create(w http.ResponseWriter, r *http.Request) {
 	var req CreateExpenseRequest
@@ -119,14 +233,50 @@ func (h *ExpenseHandler) Create(w http.ResponseWriter, r *http.Request) {
 		req.Currency = "NGN"
 	}
 
-	// Verify recipient exists
+	// Verify recipient exists and can still be paid
 	var recipientName string
-	err := database.DB.QueryRow("SELECT name FROM recipients WHERE recipient_code = ?", req.RecipientCode).Scan(&recipientName)
+	var recipientActive, recipientDeleted bool
+	err := database.DB.QueryRow(
+		"SELECT name, COALESCE(active, 1), COALESCE(deleted_upstream, 0) FROM recipients WHERE recipient_code = ?",
+		req.RecipientCode,
+	).Scan(&recipientName, &recipientActive, &recipientDeleted)
 	if err != nil {
 		WriteJSONError(w, fmt.Errorf("recipient not found: %s", req.RecipientCode), http.StatusNotFound)
 		return
 	}
 
+	if !recipientActive || recipientDeleted {
+		WriteJSONError(w, fmt.Errorf("recipient %s is deactivated and cannot receive new expenses", req.RecipientCode), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// The expense row is the reservation; the lock is released once it is written
+	outflowMu.Lock()
+	unlock := sync.OnceFunc(outflowMu.Unlock)
+	defer unlock()
+
+	// Enforce account-wide outflow limits
+	accountCheck, err := CheckAccountLimits(req.Currency, req.Amount)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("error checking account limits: %w", err), http.StatusInternalServerError)
+		return
+	}
+	if !accountCheck.Allowed {
+		writeAccountLimitExceeded(w, accountCheck)
+		return
+	}
+
+	// Enforce per-recipient daily/weekly/monthly caps
+	limitCheck, err := CheckBeneficiaryLimit(req.RecipientCode, req.Currency, req.Amount)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("error checking beneficiary limit: %w", err), http.StatusInternalServerError)
+		return
+	}
+	if !limitCheck.Allowed {
+		writeBeneficiaryLimitExceeded(w, limitCheck)
+		return
+	}
+
 	// BUDGET RESOLUTION LOGIC
 	// Step 1: Determine which budget to use
 	var budgetID int
@@ -267,6 +417,7 @@ func (h *ExpenseHandler) Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	expenseID, _ := result.LastInsertId()
+	unlock()
 
 	// Step 4: Update budget spent amount
 	err = UpdateBudgetSpending(budgetID, req.Amount)
@@ -308,6 +459,7 @@ func (h *ExpenseHandler) Create(w http.ResponseWriter, r *http.Request) {
 		Notes:         req.Notes,
 		GoalID:        goalID,
 		BudgetLimitID: &budgetID,
+		Source:        ExpenseSourceManual,
 		CreatedAt:     now,
 		UpdatedAt:     now,
 	}
@@ -342,34 +494,8 @@ func (h *ExpenseHandler) List(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Build query with filters
-	query := `SELECT id, recipient_code, recipient_name, amount, currency, category, narration, reference, status, payment_date, notes, goal_id, budget_limit_id, created_at, updated_at FROM expenses WHERE 1=1`
-	args := []interface{}{}
-
-	// Add filters
-	if req.RecipientCode != "" {
-		query += " AND recipient_code = ?"
-		args = append(args, req.RecipientCode)
-	}
-
-	if req.Category != "" {
-		query += " AND category = ?"
-		args = append(args, req.Category)
-	}
-
-	if req.Status != "" {
-		query += " AND status = ?"
-		args = append(args, req.Status)
-	}
-
-	if req.From != "" {
-		query += " AND created_at >= ?"
-		args = append(args, req.From)
-	}
-
-	if req.To != "" {
-		query += " AND created_at <= ?"
-		args = append(args, req.To)
-	}
+	where, args := expenseFilters(req)
+	query := "SELECT " + expenseColumns + " FROM expenses WHERE 1=1" + where
 
 	// Add ordering
 	query += " ORDER BY created_at DESC"
@@ -389,52 +515,12 @@ func (h *ExpenseHandler) List(w http.ResponseWriter, r *http.Request) {
 
 	expenses := []Expense{}
 	for rows.Next() {
-		var expense Expense
-		var category, notes sql.NullString
-		var paymentDate sql.NullTime
-		var goalID, budgetLimitID sql.NullInt64
-
-		err := rows.Scan(
-			&expense.ID,
-			&expense.RecipientCode,
-			&expense.RecipientName,
-			&expense.Amount,
-			&expense.Currency,
-			&category,
-			&expense.Narration,
-			&expense.Reference,
-			&expense.Status,
-			&paymentDate,
-			&notes,
-			&goalID,
-			&budgetLimitID,
-			&expense.CreatedAt,
-			&expense.UpdatedAt,
-		)
+		expense, err := scanExpense(rows)
 		if err != nil {
 			WriteJSONError(w, fmt.Errorf("failed to scan expense: %w", err), http.StatusInternalServerError)
 			return
 		}
-
-		if category.Valid {
-			expense.Category = category.String
-		}
-		if notes.Valid {
-			expense.Notes = notes.String
-		}
-		if paymentDate.Valid {
-			expense.PaymentDate = &paymentDate.Time
-		}
-		if goalID.Valid {
-			gid := int(goalID.Int64)
-			expense.GoalID = &gid
-		}
-		if budgetLimitID.Valid {
-			bid := int(budgetLimitID.Int64)
-			expense.BudgetLimitID = &bid
-		}
-
-		expenses = append(expenses, expense)
+		expenses = append(expenses, *expense)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -453,58 +539,12 @@ func (h *ExpenseHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `
-		SELECT id, recipient_code, recipient_name, amount, currency, category, narration, reference, status, payment_date, notes, goal_id, budget_limit_id, created_at, updated_at
-		FROM expenses
-		WHERE id = ?
-	`
-
-	var expense Expense
-	var category, notes sql.NullString
-	var paymentDate sql.NullTime
-	var goalID, budgetLimitID sql.NullInt64
-
-	err := database.DB.QueryRow(query, id).Scan(
-		&expense.ID,
-		&expense.RecipientCode,
-		&expense.RecipientName,
-		&expense.Amount,
-		&expense.Currency,
-		&category,
-		&expense.Narration,
-		&expense.Reference,
-		&expense.Status,
-		&paymentDate,
-		&notes,
-		&goalID,
-		&budgetLimitID,
-		&expense.CreatedAt,
-		&expense.UpdatedAt,
-	)
-
+	expense, err := scanExpense(database.DB.QueryRow("SELECT "+expenseColumns+" FROM expenses WHERE id = ?", id))
 	if err != nil {
 		WriteJSONError(w, fmt.Errorf("expense not found: %s", id), http.StatusNotFound)
 		return
 	}
 
-	if category.Valid {
-		expense.Category = category.String
-	}
-	if notes.Valid {
-		expense.Notes = notes.String
-	}
-	if paymentDate.Valid {
-		expense.PaymentDate = &paymentDate.Time
-	}
-	if goalID.Valid {
-		gid := int(goalID.Int64)
-		expense.GoalID = &gid
-	}
-	if budgetLimitID.Valid {
-		bid := int(budgetLimitID.Int64)
-		expense.BudgetLimitID = &bid
-	}
-
 	WriteJSONSuccess(w, expense)
 }
 
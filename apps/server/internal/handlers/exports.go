@@ -0,0 +1,466 @@
+// Package handlers implements HTTP handlers for the moniewave financial management system.
+//
+// Exports Handler - Reporting
+//
+// OBJECTIVES:
+// Hand the accountant files they can open or import, instead of JSON.
+//
+// PURPOSE:
+// - Export expenses, invoices, recipients and ledger transactions as CSV or XLSX
+// - Export expenses and ledger transactions as an OFX bank statement
+// - Accept exactly the filters of the matching list endpoint
+//
+// KEY WORKFLOW:
+// Parse List Filters → Run Query → Stream Each Row Into The Format Writer
+//
+// DESIGN DECISIONS:
+// - Filters are built by the same functions the list endpoints use, so an
+//   export always matches what the list shows (pagination is ignored)
+// - Rows stream from SQLite straight into the response; nothing is collected
+//   in memory, so exports of any size are safe
+// - The query runs before any output, so bad filters and query errors are
+//   still JSON errors. A failure mid-stream aborts the connection so a
+//   truncated file is never mistaken for a complete one
+// - Rows are oldest first, the order accounting tools expect
+// - OFX is a bank statement format: it is offered only for money that moved
+//   (expenses and ledger transactions), one currency per file. Without a status
+//   filter it leaves out failed, cancelled, reversed and rejected expenses and
+//   includes only successful ledger entries
+// - CSV and XLSX amounts are decimals in major units; OFX amounts are signed
+//   (money out is negative)
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+	"paystack.mpc.proxy/internal/export"
+)
+
+// OFX account identifiers; Paystack has no bank or account number of its own
+const (
+	ofxBankID    = "PAYSTACK"
+	ofxAccountID = "MONIEWAVE"
+)
+
+var expenseExportColumns = []export.Column{
+	{Name: "id", Kind: export.Integer},
+	{Name: "reference"},
+	{Name: "created_at", Kind: export.Time},
+	{Name: "payment_date", Kind: export.Time},
+	{Name: "recipient_code"},
+	{Name: "recipient_name"},
+	{Name: "category"},
+	{Name: "narration"},
+	{Name: "status"},
+	{Name: "currency"},
+	{Name: "amount", Kind: export.Money},
+	{Name: "notes"},
+	{Name: "source"},
+}
+
+var invoiceExportColumns = []export.Column{
+	{Name: "invoice_code"},
+	{Name: "number"},
+	{Name: "customer_id"},
+	{Name: "customer_name"},
+	{Name: "created_at", Kind: export.Time},
+	{Name: "due_date", Kind: export.Time},
+	{Name: "status"},
+	{Name: "currency"},
+	{Name: "amount", Kind: export.Money},
+	{Name: "paid_amount", Kind: export.Money},
+	{Name: "credited_amount", Kind: export.Money},
+	{Name: "balance", Kind: export.Money},
+	{Name: "paid_at", Kind: export.Time},
+	{Name: "quote_number"},
+	{Name: "batch_id"},
+	{Name: "description"},
+}
+
+var recipientExportColumns = []export.Column{
+	{Name: "recipient_code"},
+	{Name: "name"},
+	{Name: "type"},
+	{Name: "bank_name"},
+	{Name: "bank_code"},
+	{Name: "account_number"},
+	{Name: "currency"},
+	{Name: "resolved_account_name"},
+	{Name: "verification_status"},
+	{Name: "name_match_score"},
+	{Name: "active"},
+	{Name: "origin"},
+	{Name: "email"},
+	{Name: "description"},
+	{Name: "created_at", Kind: export.Time},
+	{Name: "last_synced_at", Kind: export.Time},
+}
+
+var ledgerExportColumns = []export.Column{
+	{Name: "occurred_at", Kind: export.Time},
+	{Name: "type"},
+	{Name: "reference"},
+	{Name: "paystack_id", Kind: export.Integer},
+	{Name: "status"},
+	{Name: "channel"},
+	{Name: "currency"},
+	{Name: "amount", Kind: export.Money},
+	{Name: "fees", Kind: export.Money},
+	{Name: "customer_code"},
+	{Name: "customer_email"},
+	{Name: "recipient_code"},
+	{Name: "recipient_name"},
+	{Name: "description"},
+}
+
+type ExportHandler struct{}
+
+func NewExportHandler() *ExportHandler {
+	return &ExportHandler{}
+}
+
+// Expenses exports expenses. The body is the same as /expenses/list.
+// Query params: format (csv|xlsx|ofx, default csv); currency (OFX only, default NGN).
+func (h *ExportHandler) Expenses(w http.ResponseWriter, r *http.Request) {
+	format, ok := exportFormat(w, r, true)
+	if !ok {
+		return
+	}
+
+	var req ListExpensesRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJSONBadRequest(w, "Invalid request body")
+			return
+		}
+	}
+
+	where, args := expenseFilters(req)
+	currency := ""
+	if format == export.FormatOFX {
+		currency = exportCurrency(r)
+		where += " AND COALESCE(currency, 'NGN') = ?"
+		args = append(args, currency)
+		if req.Status == "" {
+			where += " AND status NOT IN (" + excludedPaymentStatuses + ")"
+		}
+	}
+
+	rows, err := database.DB.QueryContext(r.Context(),
+		"SELECT "+expenseColumns+" FROM expenses WHERE 1=1"+where+" ORDER BY created_at ASC, id ASC", args...)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to query expenses: %w", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	if format == export.FormatOFX {
+		from, to := exportDateRange(req.From, req.To)
+		streamOFX(w, "expenses", rows, export.OFXStatement{Currency: currency, From: from, To: to}, func(row rowScanner) (*export.OFXTransaction, error) {
+			e, err := scanExpense(row)
+			if err != nil {
+				return nil, err
+			}
+			posted := e.CreatedAt
+			if e.PaymentDate != nil {
+				posted = *e.PaymentDate
+			}
+			memo := e.Narration
+			if e.Category != "" {
+				memo = strings.TrimSpace(e.Category + ": " + memo)
+			}
+			return &export.OFXTransaction{
+				ID:     exportFITID("expense", e.Reference, e.ID),
+				Posted: posted,
+				Amount: -e.Amount,
+				Name:   e.RecipientName,
+				Memo:   memo,
+			}, nil
+		})
+		return
+	}
+
+	streamTable(w, format, "expenses", expenseExportColumns, rows, func(row rowScanner) ([]interface{}, error) {
+		e, err := scanExpense(row)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{e.ID, e.Reference, e.CreatedAt, e.PaymentDate, e.RecipientCode, e.RecipientName,
+			e.Category, e.Narration, e.Status, e.Currency, e.Amount, e.Notes, e.Source}, nil
+	})
+}
+
+// Invoices exports invoices. The body is the same as /invoices/list, except
+// that customer_id is optional. Query params: format (csv|xlsx, default csv).
+func (h *ExportHandler) Invoices(w http.ResponseWriter, r *http.Request) {
+	format, ok := exportFormat(w, r, false)
+	if !ok {
+		return
+	}
+
+	var req ListInvoicesRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJSONBadRequest(w, "Invalid request body")
+			return
+		}
+	}
+
+	where, args := invoiceFilters(req)
+	rows, err := database.DB.QueryContext(r.Context(),
+		"SELECT "+invoiceColumns+" FROM invoices WHERE 1=1"+where+" ORDER BY created_at ASC, id ASC", args...)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to query invoices: %w", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	streamTable(w, format, "invoices", invoiceExportColumns, rows, func(row rowScanner) ([]interface{}, error) {
+		inv, err := scanInvoice(row)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{inv.InvoiceCode, inv.Number, inv.CustomerID, inv.CustomerName, inv.CreatedAt, inv.DueDate,
+			inv.Status, inv.Currency, inv.Amount, inv.PaidAmount, inv.CreditedAmount, inv.Balance, inv.PaidAt,
+			inv.QuoteNumber, inv.BatchID, inv.Description}, nil
+	})
+}
+
+// Recipients exports cached recipients. Query params are the same as
+// /recipients/list (page and per_page are ignored), plus format (csv|xlsx).
+func (h *ExportHandler) Recipients(w http.ResponseWriter, r *http.Request) {
+	format, ok := exportFormat(w, r, false)
+	if !ok {
+		return
+	}
+
+	where, args := recipientFilters(r.URL.Query())
+	rows, err := database.DB.QueryContext(r.Context(),
+		"SELECT "+recipientColumns+" FROM recipients"+where+" ORDER BY created_at ASC, id ASC", args...)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to query recipients: %w", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	streamTable(w, format, "recipients", recipientExportColumns, rows, func(row rowScanner) ([]interface{}, error) {
+		rc, err := scanRecipient(row)
+		if err != nil {
+			return nil, err
+		}
+		score := ""
+		if rc.NameMatchScore != nil {
+			score = fmt.Sprintf("%.2f", *rc.NameMatchScore)
+		}
+		active := "false"
+		if rc.Active {
+			active = "true"
+		}
+		return []interface{}{rc.RecipientCode, rc.Name, rc.Type, rc.BankName, rc.BankCode, rc.AccountNumber, rc.Currency,
+			rc.ResolvedAccountName, rc.VerificationStatus, score, active, rc.Origin, rc.Email, rc.Description,
+			rc.CreatedAt, rc.LastSyncedAt}, nil
+	})
+}
+
+// Transactions exports the local ledger. Query params are the same as
+// /transactions/ledger (limit and offset are ignored), plus format
+// (csv|xlsx|ofx, default csv). OFX uses the currency filter, default NGN.
+func (h *ExportHandler) Transactions(w http.ResponseWriter, r *http.Request) {
+	format, ok := exportFormat(w, r, true)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	where, args, err := ledgerFilters(query)
+	if err != nil {
+		WriteJSONBadRequest(w, err.Error())
+		return
+	}
+
+	currency := ""
+	if format == export.FormatOFX {
+		currency = exportCurrency(r)
+		if query.Get("currency") == "" {
+			where += " AND currency = ?"
+			args = append(args, currency)
+		}
+		if query.Get("status") == "" {
+			where += " AND status = 'success'"
+		}
+	}
+
+	rows, err := database.DB.QueryContext(r.Context(),
+		"SELECT "+ledgerEntryColumns+" FROM transactions WHERE 1=1"+where+" ORDER BY occurred_at ASC, id ASC", args...)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to query ledger: %w", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	if format == export.FormatOFX {
+		from, to := exportDateRange(query.Get("from"), query.Get("to"))
+		streamOFX(w, "transactions", rows, export.OFXStatement{Currency: currency, From: from, To: to}, func(row rowScanner) (*export.OFXTransaction, error) {
+			e, err := scanLedgerEntry(row)
+			if err != nil {
+				return nil, err
+			}
+			t := &export.OFXTransaction{
+				ID:     exportFITID(e.Type, e.Reference, e.ID),
+				Posted: e.OccurredAt,
+				Amount: e.Amount,
+				Name:   e.CustomerEmail,
+				Memo:   e.Description,
+			}
+			if e.Type == LedgerTypeTransfer {
+				t.Amount = -e.Amount
+				t.Name = e.RecipientName
+			}
+			return t, nil
+		})
+		return
+	}
+
+	streamTable(w, format, "transactions", ledgerExportColumns, rows, func(row rowScanner) ([]interface{}, error) {
+		e, err := scanLedgerEntry(row)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{e.OccurredAt, e.Type, e.Reference, e.PaystackID, e.Status, e.Channel, e.Currency, e.Amount,
+			e.Fees, e.CustomerCode, e.CustomerEmail, e.RecipientCode, e.RecipientName, e.Description}, nil
+	})
+}
+
+// exportFormat reads and validates the format query parameter, writing a 400
+// when it is not supported
+func exportFormat(w http.ResponseWriter, r *http.Request, allowOFX bool) (string, bool) {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	switch format {
+	case "":
+		return export.FormatCSV, true
+	case export.FormatCSV, export.FormatXLSX:
+		return format, true
+	case export.FormatOFX:
+		if allowOFX {
+			return format, true
+		}
+		WriteJSONBadRequest(w, "ofx is only available for expenses and transactions")
+		return "", false
+	}
+	if allowOFX {
+		WriteJSONBadRequest(w, "format must be csv, xlsx or ofx")
+	} else {
+		WriteJSONBadRequest(w, "format must be csv or xlsx")
+	}
+	return "", false
+}
+
+// exportCurrency returns the currency query parameter, default NGN
+func exportCurrency(r *http.Request) string {
+	if v := strings.ToUpper(r.URL.Query().Get("currency")); v != "" {
+		return v
+	}
+	return "NGN"
+}
+
+// exportDateRange parses from/to filters for the OFX statement range. Values
+// that are not plain dates leave that end open.
+func exportDateRange(fromValue, toValue string) (from, to time.Time) {
+	if len(fromValue) >= 10 {
+		from, _ = time.Parse("2006-01-02", fromValue[:10])
+	}
+	if len(toValue) >= 10 {
+		if parsed, err := time.Parse("2006-01-02", toValue[:10]); err == nil {
+			to = parsed.AddDate(0, 0, 1).Add(-time.Second)
+		}
+	}
+	return from, to
+}
+
+// exportFITID builds a unique OFX transaction ID from a reference, falling
+// back to the local row ID
+func exportFITID(kind, reference string, id int) string {
+	if reference == "" {
+		reference = fmt.Sprintf("id%d", id)
+	}
+	return kind + "-" + reference
+}
+
+// startExport writes the download headers
+func startExport(w http.ResponseWriter, format, name string) {
+	filename := fmt.Sprintf("%s-%s.%s", name, time.Now().UTC().Format("20060102"), format)
+	w.Header().Set("Content-Type", export.ContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	w.WriteHeader(http.StatusOK)
+}
+
+// abortExport logs a mid-stream failure and drops the connection. The status
+// line is already sent, so this is the only way to tell the client the file
+// is incomplete.
+func abortExport(name string, err error) {
+	log.Printf("Export of %s failed mid-stream: %v", name, err)
+	panic(http.ErrAbortHandler)
+}
+
+// streamTable writes rows as CSV or XLSX as they are scanned
+func streamTable(w http.ResponseWriter, format, name string, columns []export.Column, rows *sql.Rows, values func(rowScanner) ([]interface{}, error)) {
+	startExport(w, format, name)
+
+	var out export.Writer
+	var err error
+	if format == export.FormatXLSX {
+		out, err = export.NewXLSX(w, strings.ToUpper(name[:1])+name[1:], columns)
+	} else {
+		out, err = export.NewCSV(w, columns)
+	}
+	if err != nil {
+		abortExport(name, err)
+	}
+
+	for rows.Next() {
+		row, err := values(rows)
+		if err != nil {
+			abortExport(name, err)
+		}
+		if err := out.WriteRow(row...); err != nil {
+			abortExport(name, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		abortExport(name, err)
+	}
+	if err := out.Close(); err != nil {
+		abortExport(name, err)
+	}
+}
+
+// streamOFX writes rows as an OFX statement as they are scanned
+func streamOFX(w http.ResponseWriter, name string, rows *sql.Rows, st export.OFXStatement, transaction func(rowScanner) (*export.OFXTransaction, error)) {
+	startExport(w, export.FormatOFX, name)
+
+	st.BankID = ofxBankID
+	st.AccountID = ofxAccountID
+	out := export.NewOFX(w, st)
+	for rows.Next() {
+		t, err := transaction(rows)
+		if err != nil {
+			abortExport(name, err)
+		}
+		if err := out.WriteTransaction(*t); err != nil {
+			abortExport(name, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		abortExport(name, err)
+	}
+	if err := out.Close(); err != nil {
+		abortExport(name, err)
+	}
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"path/filepath"
+	"testing"
+
+	"paystack.mpc.proxy/internal/database"
+)
+
+// openTestDB points database.DB at a fresh, fully migrated database for the
+// duration of the test and returns its path
+func openTestDB(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.db")
+	if err := database.Initialize(path); err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return path
+}
@@ -0,0 +1,340 @@
+// Package handlers implements HTTP handlers for the moniewave financial management system.
+//
+// Import Rules Handler - Financial Management Core
+//
+// OBJECTIVES:
+// Imported bank lines should arrive already categorised, without retyping the same category every month.
+//
+// PURPOSE:
+// - Store rules that match statement lines by narration, counterparty and amount
+// - Assign a category and/or budget to every line a rule matches
+// - Order rules by priority so specific rules win over broad ones
+//
+// KEY WORKFLOW:
+// Create Rule → Validate Conditions And Regex → Save →
+// Statement Preview Applies Active Rules In Priority Order
+//
+// DESIGN DECISIONS:
+// - The first matching rule wins (lowest priority number, then oldest); rules
+//   are not combined, so a line never gets a category from one rule and a
+//   budget from another
+// - Rules are applied when a statement is previewed, not when it is uploaded,
+//   so editing a rule changes every statement that has not been committed yet
+// - Amount bounds are in kobo and compare against the absolute line amount
+// - Rules can be deactivated instead of deleted to keep them for later
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"paystack.mpc.proxy/internal/bankimport"
+	"paystack.mpc.proxy/internal/database"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// defaultImportRulePriority is used when a rule is created without a priority
+const defaultImportRulePriority = 100
+
+type ImportRuleHandler struct{}
+
+func NewImportRuleHandler() *ImportRuleHandler {
+	return &ImportRuleHandler{}
+}
+
+// ImportRule is a stored bank import rule
+type ImportRule struct {
+	bankimport.Rule
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ImportRuleRequest struct {
+	Name                 *string `json:"name,omitempty"`
+	Priority             *int    `json:"priority,omitempty"`
+	NarrationContains    *string `json:"narration_contains,omitempty"`
+	NarrationRegex       *string `json:"narration_regex,omitempty"`
+	CounterpartyContains *string `json:"counterparty_contains,omitempty"`
+	MinAmount            *int    `json:"min_amount,omitempty"`
+	MaxAmount            *int    `json:"max_amount,omitempty"`
+	Category             *string `json:"category,omitempty"`
+	BudgetLimitID        *int    `json:"budget_limit_id,omitempty"`
+	Active               *bool   `json:"active,omitempty"`
+}
+
+// apply copies the fields set in the request onto the rule. A zero
+// budget_limit_id or negative amount bound clears the field.
+func (req ImportRuleRequest) apply(rule *ImportRule) {
+	if req.Name != nil {
+		rule.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Priority != nil {
+		rule.Priority = *req.Priority
+	}
+	if req.NarrationContains != nil {
+		rule.NarrationContains = strings.TrimSpace(*req.NarrationContains)
+	}
+	if req.NarrationRegex != nil {
+		rule.NarrationRegex = strings.TrimSpace(*req.NarrationRegex)
+	}
+	if req.CounterpartyContains != nil {
+		rule.CounterpartyContains = strings.TrimSpace(*req.CounterpartyContains)
+	}
+	if req.MinAmount != nil {
+		rule.MinAmount = nonNegative(*req.MinAmount)
+	}
+	if req.MaxAmount != nil {
+		rule.MaxAmount = nonNegative(*req.MaxAmount)
+	}
+	if req.Category != nil {
+		rule.Category = strings.TrimSpace(*req.Category)
+	}
+	if req.BudgetLimitID != nil {
+		rule.BudgetLimitID = nil
+		if *req.BudgetLimitID > 0 {
+			id := *req.BudgetLimitID
+			rule.BudgetLimitID = &id
+		}
+	}
+	if req.Active != nil {
+		rule.Active = *req.Active
+	}
+}
+
+func nonNegative(n int) *int {
+	if n < 0 {
+		return nil
+	}
+	return &n
+}
+
+func (rule *ImportRule) validate() error {
+	if rule.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if err := rule.Compile(); err != nil {
+		return err
+	}
+	if rule.BudgetLimitID != nil {
+		var count int
+		if err := database.DB.QueryRow("SELECT COUNT(*) FROM budget_limits WHERE id = ?", *rule.BudgetLimitID).Scan(&count); err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("budget %d not found", *rule.BudgetLimitID)
+		}
+	}
+	return nil
+}
+
+const importRuleColumns = "id, name, COALESCE(priority, 100), narration_contains, narration_regex, counterparty_contains, min_amount, max_amount, category, budget_limit_id, COALESCE(active, 1), created_at, updated_at"
+
+func scanImportRule(row rowScanner) (*ImportRule, error) {
+	var rule ImportRule
+	var narrationContains, narrationRegex, counterpartyContains, category sql.NullString
+	var minAmount, maxAmount, budgetLimitID sql.NullInt64
+
+	err := row.Scan(&rule.ID, &rule.Name, &rule.Priority, &narrationContains, &narrationRegex, &counterpartyContains,
+		&minAmount, &maxAmount, &category, &budgetLimitID, &rule.Active, &rule.CreatedAt, &rule.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rule.NarrationContains = narrationContains.String
+	rule.NarrationRegex = narrationRegex.String
+	rule.CounterpartyContains = counterpartyContains.String
+	rule.Category = category.String
+	if minAmount.Valid {
+		v := int(minAmount.Int64)
+		rule.MinAmount = &v
+	}
+	if maxAmount.Valid {
+		v := int(maxAmount.Int64)
+		rule.MaxAmount = &v
+	}
+	if budgetLimitID.Valid {
+		v := int(budgetLimitID.Int64)
+		rule.BudgetLimitID = &v
+	}
+	return &rule, nil
+}
+
+func getImportRule(id int) (*ImportRule, error) {
+	return scanImportRule(database.DB.QueryRow("SELECT "+importRuleColumns+" FROM import_rules WHERE id = ?", id))
+}
+
+// activeImportRules loads the active rules in the order they are applied.
+// A stored rule whose regex no longer compiles is skipped rather than
+// failing every preview.
+func activeImportRules() ([]*bankimport.Rule, error) {
+	rows, err := database.DB.Query("SELECT " + importRuleColumns + " FROM import_rules WHERE COALESCE(active, 1) = 1 ORDER BY COALESCE(priority, 100), id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query import rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []*bankimport.Rule{}
+	for rows.Next() {
+		rule, err := scanImportRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan import rule: %w", err)
+		}
+		if err := rule.Compile(); err != nil {
+			continue
+		}
+		rules = append(rules, &rule.Rule)
+	}
+	return rules, rows.Err()
+}
+
+func saveImportRule(rule *ImportRule) error {
+	now := time.Now()
+	if rule.ID == 0 {
+		result, err := database.DB.Exec(`
+			INSERT INTO import_rules (
+				name, priority, narration_contains, narration_regex, counterparty_contains,
+				min_amount, max_amount, category, budget_limit_id, active, created_at, updated_at
+			)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, rule.Name, rule.Priority, rule.NarrationContains, rule.NarrationRegex,
+			rule.CounterpartyContains, rule.MinAmount, rule.MaxAmount, rule.Category,
+			rule.BudgetLimitID, rule.Active, now, now)
+		if err != nil {
+			return err
+		}
+		id, _ := result.LastInsertId()
+		rule.ID = int(id)
+		rule.CreatedAt = now
+	} else {
+		_, err := database.DB.Exec(`
+			UPDATE import_rules
+			SET name = ?, priority = ?, narration_contains = ?, narration_regex = ?, counterparty_contains = ?,
+			    min_amount = ?, max_amount = ?, category = ?, budget_limit_id = ?, active = ?, updated_at = ?
+			WHERE id = ?
+		`, rule.Name, rule.Priority, rule.NarrationContains, rule.NarrationRegex,
+			rule.CounterpartyContains, rule.MinAmount, rule.MaxAmount, rule.Category,
+			rule.BudgetLimitID, rule.Active, now, rule.ID)
+		if err != nil {
+			return err
+		}
+	}
+	rule.UpdatedAt = now
+	return nil
+}
+
+// Create creates an import rule
+func (h *ImportRuleHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req ImportRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+
+	rule := &ImportRule{Active: true}
+	rule.Priority = defaultImportRulePriority
+	req.apply(rule)
+	if err := rule.validate(); err != nil {
+		WriteJSONBadRequest(w, err.Error())
+		return
+	}
+
+	if err := saveImportRule(rule); err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to create import rule: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, rule)
+}
+
+// List lists import rules in the order they are applied
+func (h *ImportRuleHandler) List(w http.ResponseWriter, r *http.Request) {
+	rows, err := database.DB.Query("SELECT " + importRuleColumns + " FROM import_rules ORDER BY COALESCE(priority, 100), id")
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to query import rules: %w", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	rules := []*ImportRule{}
+	for rows.Next() {
+		rule, err := scanImportRule(rows)
+		if err != nil {
+			WriteJSONError(w, fmt.Errorf("failed to scan import rule: %w", err), http.StatusInternalServerError)
+			return
+		}
+		rules = append(rules, rule)
+	}
+
+	if err := rows.Err(); err != nil {
+		WriteJSONError(w, fmt.Errorf("error iterating import rules: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, rules)
+}
+
+// Update changes an import rule. Only the fields present in the body change.
+func (h *ImportRuleHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		WriteJSONBadRequest(w, "id must be a number")
+		return
+	}
+
+	var req ImportRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+
+	rule, err := getImportRule(id)
+	if err == sql.ErrNoRows {
+		WriteJSONError(w, fmt.Errorf("import rule %d not found", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	req.apply(rule)
+	if err := rule.validate(); err != nil {
+		WriteJSONBadRequest(w, err.Error())
+		return
+	}
+
+	if err := saveImportRule(rule); err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to update import rule: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, rule)
+}
+
+// Delete deletes an import rule. Committed expenses keep their category.
+func (h *ImportRuleHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		WriteJSONBadRequest(w, "id must be a number")
+		return
+	}
+
+	result, err := database.DB.Exec("DELETE FROM import_rules WHERE id = ?", id)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to delete import rule: %w", err), http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		WriteJSONError(w, fmt.Errorf("import rule %d not found", id), http.StatusNotFound)
+		return
+	}
+
+	WriteJSONSuccess(w, map[string]interface{}{"id": id, "deleted": true})
+}
@@ -0,0 +1,704 @@
+// Package handlers implements HTTP handlers for the moniewave financial management system.
+//
+// Invoice Batches Handler - Income Management
+//
+// OBJECTIVES:
+// Bill a group of customers in one call: split an event cost among members,
+// or run a monthly membership charge.
+//
+// PURPOSE:
+// - Create one payment request per customer under a batch ID
+// - Take explicit amounts, or a total split equally, by weight, or as fixed
+//   amounts for some customers plus an equal share of the remainder for the rest
+// - Report the result for every customer (invoice code and number, or the error)
+//
+// KEY WORKFLOW:
+// Validate Customers → Split Amounts → Save Batch And Items → Return Batch ID →
+// (background) Create Payment Requests (bounded concurrency) → Cache Invoices →
+// Record Per-Customer Results
+//
+// DESIGN DECISIONS:
+// - Splits are computed in kobo and always add up to the total exactly; the
+//   leftover kobo go to the customers with the largest rounded-off fractions
+//   (ties to the earlier customer)
+// - Batches of up to invoiceBatchMaxCustomers take longer than a request
+//   timeout, so invoices are created in the background and callers poll the batch
+// - At most invoiceBatchConcurrency Paystack calls run at once; all database
+//   writes happen on the batch goroutine so SQLite never sees concurrent writers
+// - Batches cut off by a restart are finished at startup with their pending
+//   items failed, never retried, so no customer is invoiced twice
+// - A failed customer does not stop the batch; the batch is completed,
+//   partial or failed depending on how many invoices were created
+// - Batch IDs come from their own number sequence (BATCH-000001, ...)
+// - Invoice numbers are reserved for every customer when the batch is saved and
+//   sent to Paystack; a failed customer keeps its number, leaving a visible gap
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+	"paystack.mpc.proxy/internal/paystack"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// invoiceBatchSequence is the number_sequences row used for batch IDs
+const invoiceBatchSequence = "invoice_batch"
+
+// invoiceBatchConcurrency caps concurrent Paystack calls for one batch
+const invoiceBatchConcurrency = 5
+
+// invoiceBatchMaxCustomers caps the size of one batch
+const invoiceBatchMaxCustomers = 200
+
+// Batch split methods
+const (
+	SplitExplicit           = "explicit"
+	SplitEqual              = "equal"
+	SplitWeighted           = "weighted"
+	SplitFixedPlusRemainder = "fixed_plus_remainder"
+)
+
+// Batch and batch item statuses
+const (
+	BatchStatusProcessing = "processing"
+	BatchStatusCompleted  = "completed"
+	BatchStatusPartial    = "partial"
+	BatchStatusFailed     = "failed"
+
+	BatchItemStatusPending = "pending"
+	BatchItemStatusCreated = "created"
+	BatchItemStatusFailed  = "failed"
+)
+
+type InvoiceBatchHandler struct {
+	client *paystack.Client
+}
+
+func NewInvoiceBatchHandler(client *paystack.Client) *InvoiceBatchHandler {
+	return &InvoiceBatchHandler{client: client}
+}
+
+// BatchCustomer is one customer in a batch request
+type BatchCustomer struct {
+	Customer string `json:"customer"`
+	// Amount in kobo: required for explicit splits, optional (fixed) for
+	// fixed_plus_remainder, ignored otherwise
+	Amount int `json:"amount,omitempty"`
+	// Weight is the customer's share for weighted splits
+	Weight float64 `json:"weight,omitempty"`
+}
+
+type CreateInvoiceBatchRequest struct {
+	// Split is explicit (default), equal, weighted or fixed_plus_remainder
+	Split string `json:"split,omitempty"`
+	// TotalAmount in kobo is split across customers; not used for explicit splits
+	TotalAmount      int             `json:"total_amount,omitempty"`
+	Customers        []BatchCustomer `json:"customers"`
+	Description      string          `json:"description,omitempty"`
+	Currency         string          `json:"currency,omitempty"`
+	DueDate          string          `json:"due_date,omitempty"`
+	SendNotification bool            `json:"send_notification,omitempty"`
+	DunningPolicyID  *int            `json:"dunning_policy_id,omitempty"`
+}
+
+type ListInvoiceBatchesRequest struct {
+	Status string `json:"status,omitempty"`
+	Count  int    `json:"count,omitempty"`
+	Offset int    `json:"offset,omitempty"`
+}
+
+// InvoiceBatchItem is the result for one customer in a batch
+type InvoiceBatchItem struct {
+	Position      int       `json:"position"`
+	CustomerID    string    `json:"customer_id"`
+	Amount        int       `json:"amount"`
+	Status        string    `json:"status"`
+	InvoiceCode   string    `json:"invoice_code,omitempty"`
+	InvoiceNumber string    `json:"invoice_number,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// InvoiceBatch is a group of invoices created together
+type InvoiceBatch struct {
+	BatchID     string             `json:"batch_id"`
+	Description string             `json:"description,omitempty"`
+	Currency    string             `json:"currency"`
+	SplitMethod string             `json:"split_method"`
+	TotalAmount int                `json:"total_amount"`
+	Status      string             `json:"status"`
+	Requested   int                `json:"requested"`
+	Succeeded   int                `json:"succeeded"`
+	Failed      int                `json:"failed"`
+	Items       []InvoiceBatchItem `json:"items,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+	CompletedAt *time.Time         `json:"completed_at,omitempty"`
+}
+
+// batchInvoiceResult is what a worker reports back for one customer
+type batchInvoiceResult struct {
+	index         int
+	customerName  string
+	customerEmail string
+	// fetched is set when the customer was not in the local directory
+	fetched     *LocalCustomer
+	requestCode string
+	status      string
+	err         error
+}
+
+// splitBatchAmounts works out each customer's amount in kobo
+func splitBatchAmounts(method string, total int, customers []BatchCustomer) ([]int, error) {
+	amounts := make([]int, len(customers))
+
+	switch method {
+	case SplitExplicit:
+		for i, c := range customers {
+			if c.Amount <= 0 {
+				return nil, fmt.Errorf("customers[%d].amount must be greater than 0", i)
+			}
+			amounts[i] = c.Amount
+		}
+		return amounts, nil
+
+	case SplitEqual:
+		if total <= 0 {
+			return nil, fmt.Errorf("total_amount must be greater than 0")
+		}
+		weights := make([]float64, len(customers))
+		for i := range weights {
+			weights[i] = 1
+		}
+		return apportion(total, weights)
+
+	case SplitWeighted:
+		if total <= 0 {
+			return nil, fmt.Errorf("total_amount must be greater than 0")
+		}
+		weights := make([]float64, len(customers))
+		for i, c := range customers {
+			if c.Weight <= 0 {
+				return nil, fmt.Errorf("customers[%d].weight must be greater than 0", i)
+			}
+			weights[i] = c.Weight
+		}
+		return apportion(total, weights)
+
+	case SplitFixedPlusRemainder:
+		if total <= 0 {
+			return nil, fmt.Errorf("total_amount must be greater than 0")
+		}
+		fixed := 0
+		rest := []int{}
+		for i, c := range customers {
+			if c.Amount < 0 {
+				return nil, fmt.Errorf("customers[%d].amount cannot be negative", i)
+			}
+			if c.Amount > 0 {
+				amounts[i] = c.Amount
+				fixed += c.Amount
+			} else {
+				rest = append(rest, i)
+			}
+		}
+		remainder := total - fixed
+		if len(rest) == 0 {
+			if remainder != 0 {
+				return nil, fmt.Errorf("fixed amounts add up to %d but total_amount is %d", fixed, total)
+			}
+			return amounts, nil
+		}
+		if remainder < len(rest) {
+			return nil, fmt.Errorf("fixed amounts add up to %d, leaving too little of total_amount %d for %d other customers", fixed, total, len(rest))
+		}
+		weights := make([]float64, len(rest))
+		for i := range weights {
+			weights[i] = 1
+		}
+		shares, err := apportion(remainder, weights)
+		if err != nil {
+			return nil, err
+		}
+		for i, idx := range rest {
+			amounts[idx] = shares[i]
+		}
+		return amounts, nil
+	}
+
+	return nil, fmt.Errorf("split must be one of explicit, equal, weighted, fixed_plus_remainder")
+}
+
+// apportion splits total by weight so the parts add up to total exactly,
+// giving leftover units to the largest fractional parts (earlier wins ties)
+func apportion(total int, weights []float64) ([]int, error) {
+	sum := 0.0
+	for _, w := range weights {
+		sum += w
+	}
+
+	parts := make([]int, len(weights))
+	fractions := make([]float64, len(weights))
+	allocated := 0
+	for i, w := range weights {
+		exact := float64(total) * w / sum
+		parts[i] = int(math.Floor(exact))
+		fractions[i] = exact - float64(parts[i])
+		allocated += parts[i]
+	}
+
+	order := make([]int, len(weights))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return fractions[order[a]] > fractions[order[b]]
+	})
+	for i := 0; allocated < total; i++ {
+		parts[order[i%len(order)]]++
+		allocated++
+	}
+
+	for i, part := range parts {
+		if part <= 0 {
+			return nil, fmt.Errorf("customers[%d] would be invoiced nothing; total_amount is too small for this split", i)
+		}
+	}
+	return parts, nil
+}
+
+// Create validates and saves a batch, then creates one invoice per customer in
+// the background. It returns the batch straight away with every item pending;
+// GET /invoices/batches/{batch_id} reports the results as they come in.
+func (h *InvoiceBatchHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req CreateInvoiceBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+
+	if len(req.Customers) == 0 {
+		WriteJSONBadRequest(w, "customers is required")
+		return
+	}
+	if len(req.Customers) > invoiceBatchMaxCustomers {
+		WriteJSONBadRequest(w, fmt.Sprintf("a batch can have at most %d customers", invoiceBatchMaxCustomers))
+		return
+	}
+	seen := map[string]bool{}
+	for i, c := range req.Customers {
+		if strings.TrimSpace(c.Customer) == "" {
+			WriteJSONBadRequest(w, fmt.Sprintf("customers[%d].customer is required", i))
+			return
+		}
+		if seen[c.Customer] {
+			WriteJSONBadRequest(w, fmt.Sprintf("customer %s appears more than once", c.Customer))
+			return
+		}
+		seen[c.Customer] = true
+	}
+
+	if req.Split == "" {
+		req.Split = SplitExplicit
+	}
+	amounts, err := splitBatchAmounts(req.Split, req.TotalAmount, req.Customers)
+	if err != nil {
+		WriteJSONBadRequest(w, err.Error())
+		return
+	}
+	total := 0
+	for _, amount := range amounts {
+		total += amount
+	}
+
+	var dueDate *time.Time
+	if req.DueDate != "" {
+		parsed, err := parseDueDate(req.DueDate)
+		if err != nil {
+			WriteJSONBadRequest(w, "due_date must be a date (YYYY-MM-DD) or RFC3339 timestamp")
+			return
+		}
+		dueDate = &parsed
+	}
+	if req.DunningPolicyID != nil {
+		if _, err := getDunningPolicy(*req.DunningPolicyID); err != nil {
+			WriteJSONBadRequest(w, fmt.Sprintf("dunning policy %d not found", *req.DunningPolicyID))
+			return
+		}
+	}
+	currency := req.Currency
+	if currency == "" {
+		currency = "NGN"
+	}
+
+	batchID, numbers, err := saveInvoiceBatch(&req, currency, total, amounts)
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	invoiceReqs := make([]CreateInvoiceRequest, len(req.Customers))
+	for i, c := range req.Customers {
+		invoiceReqs[i] = CreateInvoiceRequest{
+			Customer:         c.Customer,
+			Amount:           amounts[i],
+			Description:      req.Description,
+			DueDate:          req.DueDate,
+			SendNotification: req.SendNotification,
+			InvoiceNumber:    numbers[i].Value,
+			Currency:         req.Currency,
+			DunningPolicyID:  req.DunningPolicyID,
+		}
+	}
+
+	batch, err := getInvoiceBatch(batchID)
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	// Invoices are created in the background; callers poll the batch for results
+	go h.processInvoiceBatch(batchID, invoiceReqs, numbers, currency, dueDate)
+
+	WriteJSONSuccess(w, batch)
+}
+
+// processInvoiceBatch creates the payment requests for a saved batch and
+// records a result for every customer. Paystack calls run on a bounded pool;
+// all database writes happen on this goroutine, one at a time.
+func (h *InvoiceBatchHandler) processInvoiceBatch(batchID string, invoiceReqs []CreateInvoiceRequest, numbers []sequenceNumber, currency string, dueDate *time.Time) {
+	results := make(chan batchInvoiceResult)
+	sem := make(chan struct{}, invoiceBatchConcurrency)
+	var wg sync.WaitGroup
+	for i := range invoiceReqs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results <- h.createBatchInvoice(i, invoiceReqs[i])
+		}(i)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for result := range results {
+		invoiceReq := invoiceReqs[result.index]
+		if result.fetched != nil {
+			if err := cacheCustomer(result.fetched); err != nil {
+				log.Printf("Warning: failed to cache customer %s: %v", invoiceReq.Customer, err)
+			}
+		}
+		number := numbers[result.index].Formatted
+		if result.err != nil {
+			// The item keeps its number so the gap in the invoice sequence is explained
+			updateBatchItem(batchID, result.index+1, BatchItemStatusFailed, "", number, result.err.Error())
+			continue
+		}
+
+		err := cacheInvoice(result.requestCode, number, invoiceReq, result.customerName, result.customerEmail, result.status, currency, dueDate)
+		if err != nil {
+			log.Printf("Warning: Failed to cache batch invoice %s: %v", result.requestCode, err)
+		} else if _, err := database.DB.Exec("UPDATE invoices SET batch_id = ? WHERE invoice_code = ?", batchID, result.requestCode); err != nil {
+			log.Printf("Warning: failed to link invoice %s to batch %s: %v", result.requestCode, batchID, err)
+		}
+		updateBatchItem(batchID, result.index+1, BatchItemStatusCreated, result.requestCode, number, "")
+	}
+
+	if err := finishInvoiceBatch(batchID); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// FailInterruptedInvoiceBatches finishes batches that were still processing
+// when the server stopped. Their pending items are marked failed rather than
+// retried, since Paystack may already have created those payment requests.
+func FailInterruptedInvoiceBatches() error {
+	rows, err := database.DB.Query("SELECT batch_id FROM invoice_batches WHERE status = ?", BatchStatusProcessing)
+	if err != nil {
+		return fmt.Errorf("failed to query interrupted batches: %w", err)
+	}
+	batchIDs := []string{}
+	for rows.Next() {
+		var batchID string
+		if err := rows.Scan(&batchID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan batch: %w", err)
+		}
+		batchIDs = append(batchIDs, batchID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, batchID := range batchIDs {
+		_, err := database.DB.Exec(`
+			UPDATE invoice_batch_items SET status = ?, error = ?, updated_at = ?
+			WHERE batch_id = ? AND status = ?
+		`, BatchItemStatusFailed, "interrupted by a server restart; check Paystack before retrying", time.Now(), batchID, BatchItemStatusPending)
+		if err != nil {
+			return fmt.Errorf("failed to fail interrupted batch %s: %w", batchID, err)
+		}
+		if err := finishInvoiceBatch(batchID); err != nil {
+			return err
+		}
+		log.Printf("Invoice batch %s was interrupted; pending items marked failed", batchID)
+	}
+	return nil
+}
+
+// createBatchInvoice looks up one customer and creates their payment request
+// under the invoice number reserved for them. It only reads the database; the
+// caller stores the result and caches any customer that had to be fetched
+// from Paystack.
+func (h *InvoiceBatchHandler) createBatchInvoice(index int, invoiceReq CreateInvoiceRequest) batchInvoiceResult {
+	result := batchInvoiceResult{index: index}
+
+	customer, err := lookupCachedCustomer(invoiceReq.Customer)
+	if err != nil {
+		customer, err = fetchCustomer(h.client, invoiceReq.Customer)
+		if err != nil {
+			result.err = fmt.Errorf("customer not found: %w", err)
+			return result
+		}
+		result.fetched = customer
+	}
+	result.customerName = customer.DisplayName()
+	result.customerEmail = customer.Email
+
+	response, err := h.client.CreatePaymentRequest(newPaymentRequest(invoiceReq))
+	if err != nil {
+		result.err = fmt.Errorf("failed to create payment request: %w", err)
+		return result
+	}
+
+	result.requestCode = mapString(response, "request_code")
+	result.status = mapString(response, "status")
+	if result.requestCode == "" {
+		result.err = fmt.Errorf("payment request response has no request_code")
+	}
+	return result
+}
+
+// saveInvoiceBatch stores the batch and a pending item per customer, each
+// with an invoice number reserved for it, and returns the new batch ID and
+// the numbers in customer order
+func saveInvoiceBatch(req *CreateInvoiceBatchRequest, currency string, total int, amounts []int) (string, []sequenceNumber, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	batchID, err := nextSequenceNumber(tx, invoiceBatchSequence)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		INSERT INTO invoice_batches (batch_id, description, currency, split_method, total_amount, status, requested, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, batchID, req.Description, currency, req.Split, total, BatchStatusProcessing, len(req.Customers), now)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to save invoice batch: %w", err)
+	}
+
+	numbers := make([]sequenceNumber, len(req.Customers))
+	for i, c := range req.Customers {
+		numbers[i], err = advanceSequence(tx, invoiceSequence)
+		if err != nil {
+			return "", nil, err
+		}
+		_, err := tx.Exec(`
+			INSERT INTO invoice_batch_items (batch_id, position, customer_id, amount, status, invoice_number, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, batchID, i+1, c.Customer, amounts[i], BatchItemStatusPending, numbers[i].Formatted, now, now)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to save batch item %d: %w", i+1, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", nil, fmt.Errorf("failed to commit invoice batch: %w", err)
+	}
+	return batchID, numbers, nil
+}
+
+// updateBatchItem records the outcome for one customer
+func updateBatchItem(batchID string, position int, status, invoiceCode, invoiceNumber, errMsg string) {
+	_, err := database.DB.Exec(`
+		UPDATE invoice_batch_items
+		SET status = ?, invoice_code = ?, invoice_number = ?, error = ?, updated_at = ?
+		WHERE batch_id = ? AND position = ?
+	`, status, invoiceCode, invoiceNumber, errMsg, time.Now(), batchID, position)
+	if err != nil {
+		log.Printf("Warning: failed to update batch %s item %d: %v", batchID, position, err)
+	}
+}
+
+// finishInvoiceBatch counts item results and sets the batch's final status
+func finishInvoiceBatch(batchID string) error {
+	var succeeded, failed int
+	err := database.DB.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0)
+		FROM invoice_batch_items WHERE batch_id = ?
+	`, BatchItemStatusCreated, BatchItemStatusFailed, batchID).Scan(&succeeded, &failed)
+	if err != nil {
+		return fmt.Errorf("failed to count batch %s results: %w", batchID, err)
+	}
+
+	status := BatchStatusCompleted
+	switch {
+	case succeeded == 0:
+		status = BatchStatusFailed
+	case failed > 0:
+		status = BatchStatusPartial
+	}
+
+	_, err = database.DB.Exec(`
+		UPDATE invoice_batches SET status = ?, succeeded = ?, failed = ?, completed_at = ?
+		WHERE batch_id = ?
+	`, status, succeeded, failed, time.Now(), batchID)
+	if err != nil {
+		return fmt.Errorf("failed to finish batch %s: %w", batchID, err)
+	}
+	return nil
+}
+
+const invoiceBatchColumns = "batch_id, COALESCE(description, ''), COALESCE(currency, 'NGN'), split_method, total_amount, status, requested, succeeded, failed, created_at, completed_at"
+
+func scanInvoiceBatch(row rowScanner) (*InvoiceBatch, error) {
+	var batch InvoiceBatch
+	var completedAt sql.NullTime
+	err := row.Scan(
+		&batch.BatchID,
+		&batch.Description,
+		&batch.Currency,
+		&batch.SplitMethod,
+		&batch.TotalAmount,
+		&batch.Status,
+		&batch.Requested,
+		&batch.Succeeded,
+		&batch.Failed,
+		&batch.CreatedAt,
+		&completedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if completedAt.Valid {
+		batch.CompletedAt = &completedAt.Time
+	}
+	return &batch, nil
+}
+
+// getInvoiceBatch loads a batch with its per-customer results. It returns
+// sql.ErrNoRows when the batch does not exist.
+func getInvoiceBatch(batchID string) (*InvoiceBatch, error) {
+	row := database.DB.QueryRow("SELECT "+invoiceBatchColumns+" FROM invoice_batches WHERE batch_id = ?", batchID)
+	batch, err := scanInvoiceBatch(row)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT position, customer_id, amount, status, COALESCE(invoice_code, ''), COALESCE(invoice_number, ''), COALESCE(error, ''), updated_at
+		FROM invoice_batch_items
+		WHERE batch_id = ?
+		ORDER BY position
+	`, batchID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query batch items: %w", err)
+	}
+	defer rows.Close()
+
+	batch.Items = []InvoiceBatchItem{}
+	for rows.Next() {
+		var item InvoiceBatchItem
+		err := rows.Scan(&item.Position, &item.CustomerID, &item.Amount, &item.Status, &item.InvoiceCode, &item.InvoiceNumber, &item.Error, &item.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan batch item: %w", err)
+		}
+		batch.Items = append(batch.Items, item)
+	}
+
+	return batch, rows.Err()
+}
+
+// Get returns a batch with the result for every customer
+func (h *InvoiceBatchHandler) Get(w http.ResponseWriter, r *http.Request) {
+	batchID := chi.URLParam(r, "batch_id")
+
+	batch, err := getInvoiceBatch(batchID)
+	if err == sql.ErrNoRows {
+		WriteJSONError(w, fmt.Errorf("invoice batch %s not found", batchID), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, batch)
+}
+
+// List lists batches newest first, without their items
+func (h *InvoiceBatchHandler) List(w http.ResponseWriter, r *http.Request) {
+	var req ListInvoiceBatchesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+
+	query := "SELECT " + invoiceBatchColumns + " FROM invoice_batches"
+	args := []interface{}{}
+	if req.Status != "" {
+		query += " WHERE status = ?"
+		args = append(args, req.Status)
+	}
+	query += " ORDER BY id DESC"
+	if req.Count > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, req.Count, req.Offset)
+	}
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to query invoice batches: %w", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	batches := []*InvoiceBatch{}
+	for rows.Next() {
+		batch, err := scanInvoiceBatch(rows)
+		if err != nil {
+			WriteJSONError(w, fmt.Errorf("failed to scan invoice batch: %w", err), http.StatusInternalServerError)
+			return
+		}
+		batches = append(batches, batch)
+	}
+
+	if err := rows.Err(); err != nil {
+		WriteJSONError(w, fmt.Errorf("error iterating invoice batches: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, batches)
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitBatchAmounts(t *testing.T) {
+	three := []BatchCustomer{{Customer: "A"}, {Customer: "B"}, {Customer: "C"}}
+
+	tests := []struct {
+		name      string
+		method    string
+		total     int
+		customers []BatchCustomer
+		want      []int
+	}{
+		{"equal with leftover kobo", SplitEqual, 100000, three, []int{33334, 33333, 33333}},
+		{"weighted", SplitWeighted, 100000, []BatchCustomer{{Customer: "A", Weight: 2}, {Customer: "B", Weight: 1}, {Customer: "C", Weight: 1}}, []int{50000, 25000, 25000}},
+		{"weighted rounding", SplitWeighted, 1000, []BatchCustomer{{Customer: "A", Weight: 1}, {Customer: "B", Weight: 1}, {Customer: "C", Weight: 1}}, []int{334, 333, 333}},
+		{"fixed plus remainder", SplitFixedPlusRemainder, 100000, []BatchCustomer{{Customer: "A", Amount: 40000}, {Customer: "B"}, {Customer: "C"}}, []int{40000, 30000, 30000}},
+		{"explicit", SplitExplicit, 0, []BatchCustomer{{Customer: "A", Amount: 500}, {Customer: "B", Amount: 700}}, []int{500, 700}},
+	}
+
+	for _, tt := range tests {
+		got, err := splitBatchAmounts(tt.method, tt.total, tt.customers)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// Invalid splits
+	if _, err := splitBatchAmounts(SplitExplicit, 0, []BatchCustomer{{Customer: "A"}}); err == nil {
+		t.Error("Expected error for explicit split without amount")
+	}
+	if _, err := splitBatchAmounts(SplitEqual, 2, three); err == nil {
+		t.Error("Expected error when the total is too small to give everyone something")
+	}
+	if _, err := splitBatchAmounts(SplitFixedPlusRemainder, 50000, []BatchCustomer{{Customer: "A", Amount: 60000}, {Customer: "B"}}); err == nil {
+		t.Error("Expected error when fixed amounts exceed the total")
+	}
+	if _, err := splitBatchAmounts("random", 100, three); err == nil {
+		t.Error("Expected error for unknown split")
+	}
+}
@@ -0,0 +1,420 @@
+// Package handlers implements HTTP handlers for the moniewave financial management system.
+//
+// Invoice Payments - Income Management
+//
+// OBJECTIVES:
+// Invoices can be paid in parts, through Paystack or offline, and the balance must stay right.
+//
+// PURPOSE:
+// - Keep a ledger of every payment against an invoice (source, amount, reference, date)
+// - Record manual offline payments (cash, bank transfer, cheque) with a reference
+// - Derive paid amount, balance and status (pending → partially_paid → paid) from the ledger
+//
+// KEY WORKFLOW:
+// Payment Seen (Paystack verify/poll) or Recorded (offline) → Insert Ledger Row →
+// Sum Ledger → Update paid_amount And Status → Record Invoice Event
+//
+// DESIGN DECISIONS:
+// - The ledger is the source of truth; invoices.paid_amount is a cached sum
+// - Invoices marked success/paid before the ledger existed are backfilled as
+//   paid in full by the migration, so every report reads settlement from one place
+// - Paystack only reports a cumulative paid amount, so the difference from what
+//   the ledger already holds for Paystack becomes a new row; its reference
+//   encodes the cumulative amount so the same observation is never counted twice
+// - Overpayments are rejected; an invoice is paid once the ledger covers its amount
+// - Offline payments are rejected on settled or final invoices (see finalInvoiceStatuses)
+// - Upstream cancelled/void/archived/failed/draft statuses are kept unless the
+//   ledger shows the invoice fully paid
+// - When an offline payment settles an invoice, the Paystack payment request is
+//   archived so the customer cannot pay it again
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+	"paystack.mpc.proxy/internal/money"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Invoice payment sources
+const (
+	PaymentSourcePaystack = "paystack"
+	PaymentSourceOffline  = "offline"
+)
+
+// Local invoice statuses derived from the payments ledger
+const (
+	InvoiceStatusPending       = "pending"
+	InvoiceStatusPartiallyPaid = "partially_paid"
+	InvoiceStatusPaid          = "paid"
+	InvoiceStatusCredited      = "credited"
+)
+
+// upstreamInvoiceStatuses are Paystack statuses kept as-is unless the ledger
+// and credit notes show the invoice settled
+var upstreamInvoiceStatuses = map[string]bool{
+	"cancelled": true,
+	"void":      true,
+	"archived":  true,
+	"failed":    true,
+	"draft":     true,
+}
+
+// InvoicePayment is one payment in an invoice's ledger
+type InvoicePayment struct {
+	ID          int       `json:"id"`
+	InvoiceCode string    `json:"invoice_code"`
+	Source      string    `json:"source"`
+	Amount      int       `json:"amount"`
+	Reference   string    `json:"reference"`
+	Method      string    `json:"method,omitempty"`
+	Notes       string    `json:"notes,omitempty"`
+	PaidAt      time.Time `json:"paid_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// InvoiceBalance is an invoice's ledger summary
+type InvoiceBalance struct {
+	InvoiceCode    string           `json:"invoice_code"`
+	Currency       string           `json:"currency"`
+	Amount         int              `json:"amount"`
+	PaidAmount     int              `json:"paid_amount"`
+	CreditedAmount int              `json:"credited_amount"`
+	Balance        int              `json:"balance"`
+	Status         string           `json:"status"`
+	Payments       []InvoicePayment `json:"payments"`
+	CreditNotes    []CreditNote     `json:"credit_notes"`
+}
+
+type RecordInvoicePaymentRequest struct {
+	Amount    int    `json:"amount"`
+	Reference string `json:"reference"`
+	// Method describes how the money arrived (cash, bank_transfer, cheque, ...)
+	Method string `json:"method,omitempty"`
+	Notes  string `json:"notes,omitempty"`
+	// PaidAt is a date (YYYY-MM-DD) or RFC3339 timestamp; defaults to now
+	PaidAt string `json:"paid_at,omitempty"`
+}
+
+// invoiceStatusFor derives an invoice's status from its ledger total and the
+// amount written off by credit notes. An invoice settled only by credit notes
+// is credited; one settled by any mix that includes full payment is paid.
+func invoiceStatusFor(amount, paid, credited int, upstream string) string {
+	switch {
+	case amount > 0 && credited >= amount:
+		return InvoiceStatusCredited
+	case amount > 0 && paid+credited >= amount:
+		return InvoiceStatusPaid
+	case upstreamInvoiceStatuses[upstream]:
+		return upstream
+	case paid > 0:
+		return InvoiceStatusPartiallyPaid
+	default:
+		return InvoiceStatusPending
+	}
+}
+
+// insertInvoicePayment adds a row to the payments ledger
+func insertInvoicePayment(tx *sql.Tx, payment *InvoicePayment) error {
+	now := time.Now()
+	result, err := tx.Exec(`
+		INSERT INTO invoice_payments (invoice_code, source, amount, reference, method, notes, paid_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, payment.InvoiceCode, payment.Source, payment.Amount, payment.Reference, payment.Method, payment.Notes, payment.PaidAt, now)
+	if err != nil {
+		return fmt.Errorf("failed to record payment for %s: %w", payment.InvoiceCode, err)
+	}
+	id, _ := result.LastInsertId()
+	payment.ID = int(id)
+	payment.CreatedAt = now
+	return nil
+}
+
+// refreshInvoiceBalance recomputes paid_amount, credited_amount and status from
+// the ledger and credit notes, and records an event when any of them changed.
+// upstream is the Paystack status, or empty to keep the cached one. It returns
+// whether anything changed.
+func refreshInvoiceBalance(tx *sql.Tx, code, upstream, source string) (bool, error) {
+	var amount int
+	var currentStatus sql.NullString
+	var currentPaid, currentCredited sql.NullInt64
+	err := tx.QueryRow("SELECT amount, status, paid_amount, credited_amount FROM invoices WHERE invoice_code = ?", code).Scan(&amount, &currentStatus, &currentPaid, &currentCredited)
+	if err != nil {
+		return false, err
+	}
+
+	var paid int
+	var lastPaidAt sql.NullString
+	err = tx.QueryRow("SELECT COALESCE(SUM(amount), 0), MAX(paid_at) FROM invoice_payments WHERE invoice_code = ?", code).Scan(&paid, &lastPaidAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to sum payments for %s: %w", code, err)
+	}
+
+	var credited int
+	err = tx.QueryRow("SELECT COALESCE(SUM(amount), 0) FROM credit_notes WHERE invoice_code = ?", code).Scan(&credited)
+	if err != nil {
+		return false, fmt.Errorf("failed to sum credit notes for %s: %w", code, err)
+	}
+
+	if upstream == "" {
+		upstream = currentStatus.String
+	}
+	status := invoiceStatusFor(amount, paid, credited, upstream)
+
+	statusChanged := status != currentStatus.String
+	paidChanged := int64(paid) != currentPaid.Int64
+	creditedChanged := int64(credited) != currentCredited.Int64
+	if !statusChanged && !paidChanged && !creditedChanged {
+		return false, nil
+	}
+
+	// paid_at is the date of the payment that settled the invoice
+	var paidAt interface{}
+	if status == InvoiceStatusPaid && lastPaidAt.Valid {
+		paidAt = lastPaidAt.String
+	}
+
+	_, err = tx.Exec(`
+		UPDATE invoices
+		SET status = ?, paid_amount = ?, credited_amount = ?, paid_at = COALESCE(?, paid_at), updated_at = ?
+		WHERE invoice_code = ?
+	`, status, paid, credited, paidAt, time.Now(), code)
+	if err != nil {
+		return false, fmt.Errorf("failed to update invoice %s: %w", code, err)
+	}
+
+	eventType := "status_changed"
+	if !statusChanged {
+		eventType = "payment_updated"
+		if !paidChanged {
+			eventType = "credit_updated"
+		}
+	}
+	if err := recordInvoiceEvent(tx, code, eventType, currentStatus.String, status, &paid, source, ""); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// getInvoiceBalance returns an invoice's amount, ledger, credit notes and balance
+func getInvoiceBalance(code string) (*InvoiceBalance, error) {
+	balance := &InvoiceBalance{InvoiceCode: code, Payments: []InvoicePayment{}}
+
+	var status sql.NullString
+	err := database.DB.QueryRow(`
+		SELECT amount, COALESCE(currency, 'NGN'), COALESCE(paid_amount, 0), COALESCE(credited_amount, 0), status
+		FROM invoices WHERE invoice_code = ?
+	`, code).Scan(&balance.Amount, &balance.Currency, &balance.PaidAmount, &balance.CreditedAmount, &status)
+	if err != nil {
+		return nil, err
+	}
+	balance.Status = status.String
+	balance.Balance = max(balance.Amount-balance.PaidAmount-balance.CreditedAmount, 0)
+
+	balance.CreditNotes, err = listCreditNotes(code)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT id, invoice_code, source, amount, reference, COALESCE(method, ''), COALESCE(notes, ''), paid_at, created_at
+		FROM invoice_payments
+		WHERE invoice_code = ?
+		ORDER BY paid_at, id
+	`, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invoice payments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p InvoicePayment
+		err := rows.Scan(&p.ID, &p.InvoiceCode, &p.Source, &p.Amount, &p.Reference, &p.Method, &p.Notes, &p.PaidAt, &p.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invoice payment: %w", err)
+		}
+		balance.Payments = append(balance.Payments, p)
+	}
+
+	return balance, rows.Err()
+}
+
+// Payments returns an invoice's payments ledger and outstanding balance
+func (h *InvoiceHandler) Payments(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+
+	balance, err := getInvoiceBalance(code)
+	if err == sql.ErrNoRows {
+		WriteJSONError(w, fmt.Errorf("invoice %s not found", code), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, balance)
+}
+
+// RecordPayment records a manual offline payment against an invoice
+func (h *InvoiceHandler) RecordPayment(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+
+	var req RecordInvoicePaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.Amount <= 0 {
+		WriteJSONBadRequest(w, "amount must be greater than 0")
+		return
+	}
+	req.Reference = strings.TrimSpace(req.Reference)
+	if req.Reference == "" {
+		WriteJSONBadRequest(w, "reference is required")
+		return
+	}
+
+	paidAt := time.Now()
+	if req.PaidAt != "" {
+		parsed, err := parseDueDate(req.PaidAt)
+		if err != nil {
+			WriteJSONBadRequest(w, "paid_at must be a date (YYYY-MM-DD) or RFC3339 timestamp")
+			return
+		}
+		if parsed.After(time.Now()) {
+			WriteJSONBadRequest(w, "paid_at cannot be in the future")
+			return
+		}
+		paidAt = parsed
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to begin transaction: %w", err), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	var amount, paid, credited int
+	var currency string
+	var status sql.NullString
+	err = tx.QueryRow("SELECT amount, COALESCE(currency, 'NGN'), COALESCE(paid_amount, 0), COALESCE(credited_amount, 0), status FROM invoices WHERE invoice_code = ?", code).Scan(&amount, &currency, &paid, &credited, &status)
+	if err == sql.ErrNoRows {
+		WriteJSONError(w, fmt.Errorf("invoice %s not found", code), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to load invoice: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	if upstreamInvoiceStatuses[status.String] || isFinalInvoiceStatus(status.String) {
+		respondWithJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"status":  false,
+			"message": "Invoice cannot accept payments",
+			"error":   fmt.Sprintf("invoice %s is %s", code, status.String),
+		})
+		return
+	}
+	if outstanding := amount - paid - credited; req.Amount > outstanding {
+		respondWithJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"status":  false,
+			"message": "Payment exceeds outstanding balance",
+			"error":   fmt.Sprintf("payment of %s %s exceeds the outstanding balance of %s %s",
+				currency, money.Decimal(req.Amount), currency, money.Decimal(outstanding)),
+			"data": map[string]interface{}{
+				"outstanding": outstanding,
+			},
+		})
+		return
+	}
+
+	payment := &InvoicePayment{
+		InvoiceCode: code,
+		Source:      PaymentSourceOffline,
+		Amount:      req.Amount,
+		Reference:   req.Reference,
+		Method:      req.Method,
+		Notes:       req.Notes,
+		PaidAt:      paidAt,
+	}
+	if err := insertInvoicePayment(tx, payment); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			WriteJSONError(w, fmt.Errorf("a payment with reference %s is already recorded", req.Reference), http.StatusConflict)
+			return
+		}
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	detail := fmt.Sprintf("offline payment %s of %s %s", req.Reference, currency, money.Decimal(req.Amount))
+	if err := recordInvoiceEvent(tx, code, "payment_recorded", "", "", nil, PaymentSourceOffline, detail); err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if _, err := refreshInvoiceBalance(tx, code, "", PaymentSourceOffline); err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to commit payment: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	balance, err := getInvoiceBalance(code)
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	// Close the Paystack payment request so the customer cannot pay twice
+	if balance.Status == InvoiceStatusPaid {
+		if err := archiveInvoice(h.client, code, PaymentSourceOffline); err != nil {
+			log.Printf("Warning: failed to archive settled payment request %s: %v", code, err)
+		}
+	}
+
+	WriteJSONSuccess(w, balance)
+}
+
+// Archive archives an invoice's Paystack payment request so it can no longer
+// be paid, e.g. to retry after a failed archive when the invoice was settled
+func (h *InvoiceHandler) Archive(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+
+	var exists int
+	err := database.DB.QueryRow("SELECT 1 FROM invoices WHERE invoice_code = ?", code).Scan(&exists)
+	if err == sql.ErrNoRows {
+		WriteJSONError(w, fmt.Errorf("invoice %s not found", code), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to load invoice: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	if err := archiveInvoice(h.client, code, InvoiceEventSourceArchive); err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to archive payment request: %w", err), http.StatusBadGateway)
+		return
+	}
+
+	balance, err := getInvoiceBalance(code)
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, balance)
+}
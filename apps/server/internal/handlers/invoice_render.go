@@ -0,0 +1,200 @@
+// Package handlers implements HTTP handlers for the moniewave financial management system.
+//
+// Invoice Rendering - Income Management
+//
+// OBJECTIVES:
+// Produce an invoice document for our own records, without depending on Paystack.
+//
+// PURPOSE:
+// - Render a cached invoice as HTML or PDF
+// - Show business details, line items, totals, due date and payment status
+//
+// KEY WORKFLOW:
+// Load Cached Invoice + Line Items → Compute Totals → Render HTML Template / Draw PDF
+//
+// DESIGN DECISIONS:
+// - Rendering reads only the local cache, so it works offline; the cached status
+//   is kept current by the invoice poller
+// - Invoices created before line items were stored render as a single line
+//   for the full amount
+// - The HTML template is configurable (INVOICE_TEMPLATE_FILE); the PDF layout is
+//   built in and uses the same business details and footer
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+	"paystack.mpc.proxy/internal/document"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// InvoiceLineItem is a line item stored for a cached invoice
+type InvoiceLineItem struct {
+	Position   int    `json:"position"`
+	Name       string `json:"name"`
+	Quantity   int    `json:"quantity"`
+	UnitAmount int    `json:"unit_amount"`
+	Amount     int    `json:"amount"`
+}
+
+// Render renders a cached invoice as HTML (default) or PDF.
+// Query params: format=html|pdf, download=true to send as an attachment.
+func (h *InvoiceHandler) Render(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+	if code == "" {
+		WriteJSONBadRequest(w, "code is required")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "html"
+	}
+	if format != "html" && format != "pdf" {
+		WriteJSONBadRequest(w, "format must be html or pdf")
+		return
+	}
+
+	inv, err := loadInvoiceDocument(code, h.renderer.Business())
+	if err == sql.ErrNoRows {
+		WriteJSONError(w, fmt.Errorf("invoice %s not found", code), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	disposition := "inline"
+	if r.URL.Query().Get("download") == "true" {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("%s; filename=\"invoice-%s.%s\"", disposition, code, format))
+
+	if format == "pdf" {
+		w.Header().Set("Content-Type", "application/pdf")
+		if err := h.renderer.PDF(w, inv); err != nil {
+			WriteJSONError(w, fmt.Errorf("failed to render invoice: %w", err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Render HTML to a buffer so template errors can still be reported as JSON
+	var page bytes.Buffer
+	if err := h.renderer.HTML(&page, inv); err != nil {
+		w.Header().Del("Content-Disposition")
+		WriteJSONError(w, fmt.Errorf("failed to render invoice: %w", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page.Bytes())
+}
+
+// loadInvoiceDocument builds a renderable invoice from the local cache. It
+// returns sql.ErrNoRows when the invoice is not cached.
+func loadInvoiceDocument(code string, business document.Business) (*document.Invoice, error) {
+	inv := &document.Invoice{
+		Number:      code,
+		Business:    business,
+		GeneratedAt: time.Now(),
+	}
+
+	var number string
+	var status sql.NullString
+	var dueDate, paidAt sql.NullTime
+	err := database.DB.QueryRow(`
+		SELECT COALESCE(invoice_number, ''), customer_id, customer_name, amount, COALESCE(currency, 'NGN'), COALESCE(description, ''), COALESCE(notes, ''),
+		       due_date, status, COALESCE(paid_amount, 0), COALESCE(credited_amount, 0), paid_at, created_at
+		FROM invoices
+		WHERE invoice_code = ?
+	`, code).Scan(
+		&number,
+		&inv.CustomerID,
+		&inv.CustomerName,
+		&inv.Total,
+		&inv.Currency,
+		&inv.Description,
+		&inv.Notes,
+		&dueDate,
+		&status,
+		&inv.PaidAmount,
+		&inv.CreditedAmount,
+		&paidAt,
+		&inv.IssuedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load invoice %s: %w", code, err)
+	}
+
+	// Invoices cached before numbering was added fall back to their code
+	if number != "" {
+		inv.Number = number
+	}
+	inv.Status = status.String
+	if dueDate.Valid {
+		inv.DueDate = &dueDate.Time
+	}
+	if paidAt.Valid {
+		inv.PaidAt = &paidAt.Time
+	}
+
+	items, err := getInvoiceLineItems(code)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		inv.LineItems = append(inv.LineItems, document.LineItem{
+			Name:       item.Name,
+			Quantity:   item.Quantity,
+			UnitAmount: item.UnitAmount,
+			Amount:     item.Amount,
+		})
+		inv.Subtotal += item.Amount
+	}
+
+	if len(inv.LineItems) == 0 {
+		name := inv.Description
+		if name == "" {
+			name = "Invoice " + code
+		}
+		inv.LineItems = []document.LineItem{{Name: name, Quantity: 1, UnitAmount: inv.Total, Amount: inv.Total}}
+		inv.Subtotal = inv.Total
+	}
+
+	inv.BalanceDue = max(inv.Total-inv.PaidAmount-inv.CreditedAmount, 0)
+	return inv, nil
+}
+
+// getInvoiceLineItems returns an invoice's stored line items in order
+func getInvoiceLineItems(code string) ([]InvoiceLineItem, error) {
+	rows, err := database.DB.Query(`
+		SELECT position, name, quantity, unit_amount, amount
+		FROM invoice_line_items
+		WHERE invoice_code = ?
+		ORDER BY position
+	`, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invoice line items: %w", err)
+	}
+	defer rows.Close()
+
+	items := []InvoiceLineItem{}
+	for rows.Next() {
+		var item InvoiceLineItem
+		if err := rows.Scan(&item.Position, &item.Name, &item.Quantity, &item.UnitAmount, &item.Amount); err != nil {
+			return nil, fmt.Errorf("failed to scan invoice line item: %w", err)
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
@@ -0,0 +1,356 @@
+// Package handlers implements HTTP handlers for the moniewave financial management system.
+//
+// Invoice Sync - Income Management
+//
+// OBJECTIVES:
+// Keep local invoice status in step with Paystack without anyone calling Verify.
+//
+// PURPOSE:
+// - Periodically verify invoices that are not in a final state
+// - Update status, paid amount and paid-at time in the invoices cache
+// - Record every status change in an invoice event history
+//
+// KEY WORKFLOW:
+// Tick → Select Non-Final Invoices Due A Check → Verify With Paystack →
+// Apply Status (record event on change) → Schedule Next Check With Backoff
+//
+// DESIGN DECISIONS:
+// - Each invoice carries its own next_check_at; an unchanged invoice waits twice
+//   as long each time (capped), a changed invoice goes back to the base interval
+// - API errors back off the same way, so one broken invoice cannot hog the poller
+// - Verify, Get and the poller share applyPaymentRequest, so history is the same
+//   whichever path noticed the change; payments go through the ledger in
+//   invoice_payments.go
+// - Only a bounded batch is checked per tick to stay well inside Paystack rate limits
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+	"paystack.mpc.proxy/internal/paystack"
+)
+
+// invoicePollBatchSize is the maximum number of invoices verified per tick
+const invoicePollBatchSize = 50
+
+// finalInvoiceStatuses are statuses the poller no longer checks
+var finalInvoiceStatuses = []string{"success", InvoiceStatusPaid, InvoiceStatusCredited, "cancelled", "failed", "archived", "void"}
+
+// isFinalInvoiceStatus reports whether status is one of finalInvoiceStatuses
+func isFinalInvoiceStatus(status string) bool {
+	for _, final := range finalInvoiceStatuses {
+		if status == final {
+			return true
+		}
+	}
+	return false
+}
+
+var invoicePollMu sync.Mutex
+
+// Invoice event sources
+const (
+	InvoiceEventSourceCreate     = "create"
+	InvoiceEventSourceVerify     = "verify"
+	InvoiceEventSourceGet        = "get"
+	InvoiceEventSourcePoller     = "poller"
+	InvoiceEventSourceDunning    = "dunning"
+	InvoiceEventSourceCreditNote = "credit_note"
+	InvoiceEventSourceArchive    = "archive"
+)
+
+// InvoicePollPolicy controls the background invoice poller
+type InvoicePollPolicy struct {
+	// Interval is the base delay between checks of one invoice
+	Interval time.Duration
+	// MaxBackoff caps the delay between checks of an unchanged invoice
+	MaxBackoff time.Duration
+}
+
+// InvoiceEvent is one entry in an invoice's history
+type InvoiceEvent struct {
+	ID          int       `json:"id"`
+	InvoiceCode string    `json:"invoice_code"`
+	EventType   string    `json:"event_type"`
+	FromStatus  string    `json:"from_status,omitempty"`
+	ToStatus    string    `json:"to_status,omitempty"`
+	PaidAmount  *int      `json:"paid_amount,omitempty"`
+	Source      string    `json:"source"`
+	Details     string    `json:"details,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// InvoicePollReport summarises one poller tick
+type InvoicePollReport struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Checked    int       `json:"checked"`
+	Changed    []string  `json:"changed"`
+	Failed     []string  `json:"failed"`
+}
+
+// invoiceBackoff returns the delay before the next check after the given
+// number of consecutive checks without a change
+func invoiceBackoff(policy InvoicePollPolicy, attempts int) time.Duration {
+	delay := policy.Interval
+	for i := 0; i < attempts && delay < policy.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > policy.MaxBackoff {
+		delay = policy.MaxBackoff
+	}
+	return delay
+}
+
+// paymentRequestState is the part of a Paystack payment request the cache tracks
+type paymentRequestState struct {
+	Status     string
+	PaidAmount int
+	PaidAt     *time.Time
+}
+
+// parsePaymentRequestState reads status, paid amount and paid-at from a
+// Paystack payment request (fetch or verify response)
+func parsePaymentRequestState(result map[string]interface{}) paymentRequestState {
+	state := paymentRequestState{Status: mapString(result, "status")}
+
+	if mapBool(result, "archived") {
+		state.Status = "archived"
+	}
+
+	amount := mapInt(result, "amount")
+	if mapBool(result, "paid") {
+		state.PaidAmount = amount
+	} else if _, ok := result["pending_amount"]; ok {
+		state.PaidAmount = max(amount-mapInt(result, "pending_amount"), 0)
+	}
+
+	if paidAt := mapString(result, "paid_at"); paidAt != "" {
+		if t, err := time.Parse(time.RFC3339, paidAt); err == nil {
+			state.PaidAt = &t
+		}
+	}
+
+	return state
+}
+
+// applyPaymentRequest updates the cached invoice from a Paystack payment
+// request: any newly paid amount goes into the payments ledger, then paid
+// amount and status are recomputed and an event recorded when they changed.
+// It returns whether anything changed; invoices that are not cached are ignored.
+func applyPaymentRequest(code string, result map[string]interface{}, source string) (bool, error) {
+	state := parsePaymentRequestState(result)
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var exists int
+	err = tx.QueryRow("SELECT 1 FROM invoices WHERE invoice_code = ?", code).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to load invoice %s: %w", code, err)
+	}
+
+	// Paystack reports a cumulative amount; record what the ledger is missing
+	var recorded int
+	err = tx.QueryRow(
+		"SELECT COALESCE(SUM(amount), 0) FROM invoice_payments WHERE invoice_code = ? AND source = ?",
+		code, PaymentSourcePaystack,
+	).Scan(&recorded)
+	if err != nil {
+		return false, fmt.Errorf("failed to sum payments for %s: %w", code, err)
+	}
+	if delta := state.PaidAmount - recorded; delta > 0 {
+		paidAt := time.Now()
+		if state.PaidAt != nil {
+			paidAt = *state.PaidAt
+		}
+		err := insertInvoicePayment(tx, &InvoicePayment{
+			InvoiceCode: code,
+			Source:      PaymentSourcePaystack,
+			Amount:      delta,
+			Reference:   fmt.Sprintf("%s-%d", code, state.PaidAmount),
+			PaidAt:      paidAt,
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+
+	changed, err := refreshInvoiceBalance(tx, code, state.Status, source)
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit invoice %s: %w", code, err)
+	}
+	return changed, nil
+}
+
+// sqlExecer is satisfied by *sql.DB and *sql.Tx
+type sqlExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// recordInvoiceEvent appends an entry to an invoice's history
+func recordInvoiceEvent(db sqlExecer, code, eventType, fromStatus, toStatus string, paidAmount *int, source, details string) error {
+	_, err := db.Exec(`
+		INSERT INTO invoice_events (invoice_code, event_type, from_status, to_status, paid_amount, source, details, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, code, eventType, fromStatus, toStatus, paidAmount, source, details, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to record invoice event for %s: %w", code, err)
+	}
+	return nil
+}
+
+// getInvoiceEvents returns an invoice's history, oldest first
+func getInvoiceEvents(code string) ([]InvoiceEvent, error) {
+	rows, err := database.DB.Query(`
+		SELECT id, invoice_code, event_type, COALESCE(from_status, ''), COALESCE(to_status, ''), paid_amount,
+		       source, COALESCE(details, ''), created_at
+		FROM invoice_events
+		WHERE invoice_code = ?
+		ORDER BY created_at, id
+	`, code)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invoice events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []InvoiceEvent{}
+	for rows.Next() {
+		var event InvoiceEvent
+		var paidAmount sql.NullInt64
+		err := rows.Scan(
+			&event.ID,
+			&event.InvoiceCode,
+			&event.EventType,
+			&event.FromStatus,
+			&event.ToStatus,
+			&paidAmount,
+			&event.Source,
+			&event.Details,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invoice event: %w", err)
+		}
+		event.PaidAmount = nullIntPtr(paidAmount)
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// PollInvoices verifies the non-final invoices that are due a check and
+// records the tick in sync_runs
+func PollInvoices(client *paystack.Client, policy InvoicePollPolicy) (*InvoicePollReport, error) {
+	if !invoicePollMu.TryLock() {
+		return nil, ErrSyncInProgress
+	}
+	defer invoicePollMu.Unlock()
+
+	report := &InvoicePollReport{
+		StartedAt: time.Now(),
+		Changed:   []string{},
+		Failed:    []string{},
+	}
+
+	err := pollInvoices(client, policy, report)
+	report.FinishedAt = time.Now()
+
+	// Idle ticks are not worth a sync_runs row
+	if report.Checked > 0 || err != nil {
+		recordSyncRun("invoices", report.StartedAt, report, err)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func pollInvoices(client *paystack.Client, policy InvoicePollPolicy, report *InvoicePollReport) error {
+	placeholders := []string{}
+	args := []interface{}{}
+	for _, status := range finalInvoiceStatuses {
+		placeholders = append(placeholders, "?")
+		args = append(args, status)
+	}
+	args = append(args, time.Now(), invoicePollBatchSize)
+
+	rows, err := database.DB.Query(`
+		SELECT invoice_code, COALESCE(poll_attempts, 0)
+		FROM invoices
+		WHERE COALESCE(status, '') NOT IN (`+joinStrings(placeholders, ", ")+`)
+		AND (next_check_at IS NULL OR next_check_at <= ?)
+		ORDER BY COALESCE(next_check_at, created_at)
+		LIMIT ?
+	`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to select invoices to poll: %w", err)
+	}
+
+	type dueInvoice struct {
+		code     string
+		attempts int
+	}
+	due := []dueInvoice{}
+	for rows.Next() {
+		var inv dueInvoice
+		if err := rows.Scan(&inv.code, &inv.attempts); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan invoice: %w", err)
+		}
+		due = append(due, inv)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating invoices: %w", err)
+	}
+
+	for _, inv := range due {
+		report.Checked++
+
+		changed := false
+		result, err := client.VerifyPaymentRequest(inv.code)
+		if err == nil {
+			changed, err = applyPaymentRequest(inv.code, result, InvoiceEventSourcePoller)
+		}
+		if err != nil {
+			log.Printf("Warning: invoice poll for %s failed: %v", inv.code, err)
+			report.Failed = append(report.Failed, inv.code)
+		}
+		if changed {
+			report.Changed = append(report.Changed, inv.code)
+		}
+
+		attempts := inv.attempts + 1
+		if changed {
+			attempts = 0
+		}
+
+		checkedAt := time.Now()
+		_, err = database.DB.Exec(
+			"UPDATE invoices SET last_checked_at = ?, next_check_at = ?, poll_attempts = ? WHERE invoice_code = ?",
+			checkedAt, checkedAt.Add(invoiceBackoff(policy, attempts)), attempts, inv.code,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to schedule next check for %s: %w", inv.code, err)
+		}
+	}
+
+	return nil
+}
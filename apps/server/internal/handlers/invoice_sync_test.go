@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInvoiceBackoff(t *testing.T) {
+	policy := InvoicePollPolicy{Interval: 5 * time.Minute, MaxBackoff: time.Hour}
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 5 * time.Minute},
+		{1, 10 * time.Minute},
+		{3, 40 * time.Minute},
+		{4, time.Hour},
+		{50, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := invoiceBackoff(policy, tt.attempts); got != tt.want {
+			t.Errorf("invoiceBackoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestParsePaymentRequestState(t *testing.T) {
+	paid := parsePaymentRequestState(map[string]interface{}{
+		"status":  "success",
+		"amount":  float64(50000),
+		"paid":    true,
+		"paid_at": "2025-01-15T10:30:00.000Z",
+	})
+	if paid.Status != "success" || paid.PaidAmount != 50000 || paid.PaidAt == nil {
+		t.Errorf("Unexpected paid state: %+v", paid)
+	}
+
+	partial := parsePaymentRequestState(map[string]interface{}{
+		"status":         "pending",
+		"amount":         float64(50000),
+		"pending_amount": float64(20000),
+	})
+	if partial.PaidAmount != 30000 || partial.PaidAt != nil {
+		t.Errorf("Unexpected partial state: %+v", partial)
+	}
+
+	archived := parsePaymentRequestState(map[string]interface{}{
+		"status":   "pending",
+		"archived": true,
+	})
+	if archived.Status != "archived" {
+		t.Errorf("Expected archived status, got %s", archived.Status)
+	}
+}
+
+func TestInvoiceStatusFor(t *testing.T) {
+	tests := []struct {
+		amount, paid, credited int
+		upstream               string
+		want                   string
+	}{
+		{50000, 0, 0, "pending", InvoiceStatusPending},
+		{50000, 0, 0, "success", InvoiceStatusPending},
+		{50000, 20000, 0, "pending", InvoiceStatusPartiallyPaid},
+		{50000, 50000, 0, "pending", InvoiceStatusPaid},
+		{50000, 60000, 0, "", InvoiceStatusPaid},
+		{50000, 20000, 0, "cancelled", "cancelled"},
+		{50000, 50000, 0, "archived", InvoiceStatusPaid},
+		{50000, 0, 0, "draft", "draft"},
+		// Credit notes
+		{50000, 0, 10000, "pending", InvoiceStatusPending},
+		{50000, 20000, 10000, "pending", InvoiceStatusPartiallyPaid},
+		{50000, 20000, 30000, "pending", InvoiceStatusPaid},
+		{50000, 0, 50000, "pending", InvoiceStatusCredited},
+		{50000, 0, 50000, "archived", InvoiceStatusCredited},
+	}
+
+	for _, tt := range tests {
+		if got := invoiceStatusFor(tt.amount, tt.paid, tt.credited, tt.upstream); got != tt.want {
+			t.Errorf("invoiceStatusFor(%d, %d, %d, %q) = %s, want %s", tt.amount, tt.paid, tt.credited, tt.upstream, got, tt.want)
+		}
+	}
+}
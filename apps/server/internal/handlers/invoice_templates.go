@@ -0,0 +1,335 @@
+// Package handlers implements HTTP handlers for the moniewave financial management system.
+//
+// Invoice Templates Handler - Income Management
+//
+// OBJECTIVES:
+// Recurring kinds of invoice (a monthly retainer, a standard service package)
+// should not be typed out from scratch every time.
+//
+// PURPOSE:
+// - Save reusable invoice defaults: line items, currency, due-in-days and notes
+// - Apply a template when creating an invoice (template_id on /invoices/create)
+// - List, update and delete templates
+//
+// KEY WORKFLOW:
+// Create Template → Create Invoice With template_id → Fill Missing Fields From
+// Template → Create Payment Request → Cache Invoice With Template Reference
+//
+// DESIGN DECISIONS:
+// - Fields sent on the invoice request always win over the template
+// - When the invoice has no amount, it is the sum of the (template) line items
+// - due_in_days is counted from the day the invoice is created; 0 means no due date
+// - Line items are stored as JSON on the template row, like dunning offsets
+// - Deleting a template does not change invoices already created from it
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+	"paystack.mpc.proxy/internal/paystack"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type InvoiceTemplateHandler struct{}
+
+func NewInvoiceTemplateHandler() *InvoiceTemplateHandler {
+	return &InvoiceTemplateHandler{}
+}
+
+// InvoiceTemplate holds defaults for new invoices
+type InvoiceTemplate struct {
+	ID          int                 `json:"id"`
+	Name        string              `json:"name"`
+	Description string              `json:"description,omitempty"`
+	Currency    string              `json:"currency"`
+	DueInDays   int                 `json:"due_in_days"`
+	Notes       string              `json:"notes,omitempty"`
+	LineItems   []paystack.LineItem `json:"line_items"`
+	CreatedAt   time.Time           `json:"created_at"`
+	UpdatedAt   time.Time           `json:"updated_at"`
+}
+
+type CreateInvoiceTemplateRequest struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description,omitempty"`
+	Currency    string              `json:"currency,omitempty"`
+	DueInDays   int                 `json:"due_in_days,omitempty"`
+	Notes       string              `json:"notes,omitempty"`
+	LineItems   []paystack.LineItem `json:"line_items,omitempty"`
+}
+
+type UpdateInvoiceTemplateRequest struct {
+	Name        *string             `json:"name,omitempty"`
+	Description *string             `json:"description,omitempty"`
+	Currency    *string             `json:"currency,omitempty"`
+	DueInDays   *int                `json:"due_in_days,omitempty"`
+	Notes       *string             `json:"notes,omitempty"`
+	LineItems   []paystack.LineItem `json:"line_items,omitempty"`
+}
+
+func (t *InvoiceTemplate) validate() error {
+	if strings.TrimSpace(t.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if t.DueInDays < 0 || t.DueInDays > 365 {
+		return fmt.Errorf("due_in_days must be between 0 and 365")
+	}
+	if msg := validateLineItems(t.LineItems); msg != "" {
+		return fmt.Errorf("%s", msg)
+	}
+	return nil
+}
+
+// apply fills the fields of an invoice request that the caller left empty
+func (t *InvoiceTemplate) apply(req *CreateInvoiceRequest, now time.Time) {
+	if len(req.LineItems) == 0 {
+		req.LineItems = append([]paystack.LineItem(nil), t.LineItems...)
+	}
+	if req.Description == "" {
+		req.Description = t.Description
+	}
+	if req.Currency == "" {
+		req.Currency = t.Currency
+	}
+	if req.Notes == "" {
+		req.Notes = t.Notes
+	}
+	if req.DueDate == "" && t.DueInDays > 0 {
+		req.DueDate = now.AddDate(0, 0, t.DueInDays).Format("2006-01-02")
+	}
+	if req.Amount == 0 {
+		for _, item := range req.LineItems {
+			req.Amount += item.Amount * max(item.Quantity, 1)
+		}
+	}
+}
+
+const invoiceTemplateColumns = "id, name, COALESCE(description, ''), COALESCE(currency, 'NGN'), COALESCE(due_in_days, 0), COALESCE(notes, ''), line_items, created_at, updated_at"
+
+func scanInvoiceTemplate(row rowScanner) (*InvoiceTemplate, error) {
+	var t InvoiceTemplate
+	var lineItems string
+	err := row.Scan(&t.ID, &t.Name, &t.Description, &t.Currency, &t.DueInDays, &t.Notes, &lineItems, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(lineItems), &t.LineItems); err != nil {
+		return nil, fmt.Errorf("invalid line items for invoice template %d: %w", t.ID, err)
+	}
+	if t.LineItems == nil {
+		t.LineItems = []paystack.LineItem{}
+	}
+	return &t, nil
+}
+
+func getInvoiceTemplate(id int) (*InvoiceTemplate, error) {
+	row := database.DB.QueryRow("SELECT "+invoiceTemplateColumns+" FROM invoice_templates WHERE id = ?", id)
+	return scanInvoiceTemplate(row)
+}
+
+func saveInvoiceTemplate(t *InvoiceTemplate) error {
+	if t.LineItems == nil {
+		t.LineItems = []paystack.LineItem{}
+	}
+	lineItems, err := json.Marshal(t.LineItems)
+	if err != nil {
+		return fmt.Errorf("failed to encode line items: %w", err)
+	}
+
+	now := time.Now()
+	if t.ID == 0 {
+		result, err := database.DB.Exec(`
+			INSERT INTO invoice_templates (name, description, currency, due_in_days, notes, line_items, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, t.Name, t.Description, t.Currency, t.DueInDays, t.Notes, string(lineItems), now, now)
+		if err != nil {
+			return err
+		}
+		id, _ := result.LastInsertId()
+		t.ID = int(id)
+		t.CreatedAt = now
+	} else {
+		_, err := database.DB.Exec(`
+			UPDATE invoice_templates
+			SET name = ?, description = ?, currency = ?, due_in_days = ?, notes = ?, line_items = ?, updated_at = ?
+			WHERE id = ?
+		`, t.Name, t.Description, t.Currency, t.DueInDays, t.Notes, string(lineItems), now, t.ID)
+		if err != nil {
+			return err
+		}
+	}
+	t.UpdatedAt = now
+	return nil
+}
+
+// Create creates an invoice template
+func (h *InvoiceTemplateHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req CreateInvoiceTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+
+	template := &InvoiceTemplate{
+		Name:        strings.TrimSpace(req.Name),
+		Description: req.Description,
+		Currency:    req.Currency,
+		DueInDays:   req.DueInDays,
+		Notes:       req.Notes,
+		LineItems:   req.LineItems,
+	}
+	if template.Currency == "" {
+		template.Currency = "NGN"
+	}
+	if err := template.validate(); err != nil {
+		WriteJSONBadRequest(w, err.Error())
+		return
+	}
+
+	if err := saveInvoiceTemplate(template); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			WriteJSONError(w, fmt.Errorf("an invoice template named %q already exists", template.Name), http.StatusConflict)
+			return
+		}
+		WriteJSONError(w, fmt.Errorf("failed to create invoice template: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, template)
+}
+
+// List lists invoice templates by name
+func (h *InvoiceTemplateHandler) List(w http.ResponseWriter, r *http.Request) {
+	rows, err := database.DB.Query("SELECT " + invoiceTemplateColumns + " FROM invoice_templates ORDER BY name")
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to query invoice templates: %w", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	templates := []*InvoiceTemplate{}
+	for rows.Next() {
+		template, err := scanInvoiceTemplate(rows)
+		if err != nil {
+			WriteJSONError(w, fmt.Errorf("failed to scan invoice template: %w", err), http.StatusInternalServerError)
+			return
+		}
+		templates = append(templates, template)
+	}
+
+	if err := rows.Err(); err != nil {
+		WriteJSONError(w, fmt.Errorf("error iterating invoice templates: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, templates)
+}
+
+// Get returns one invoice template
+func (h *InvoiceTemplateHandler) Get(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		WriteJSONBadRequest(w, "id must be a number")
+		return
+	}
+
+	template, err := getInvoiceTemplate(id)
+	if err == sql.ErrNoRows {
+		WriteJSONError(w, fmt.Errorf("invoice template %d not found", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, template)
+}
+
+// Update changes an invoice template. Invoices already created are not affected.
+func (h *InvoiceTemplateHandler) Update(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		WriteJSONBadRequest(w, "id must be a number")
+		return
+	}
+
+	var req UpdateInvoiceTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+
+	template, err := getInvoiceTemplate(id)
+	if err == sql.ErrNoRows {
+		WriteJSONError(w, fmt.Errorf("invoice template %d not found", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	if req.Name != nil {
+		template.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		template.Description = *req.Description
+	}
+	if req.Currency != nil {
+		template.Currency = *req.Currency
+	}
+	if req.DueInDays != nil {
+		template.DueInDays = *req.DueInDays
+	}
+	if req.Notes != nil {
+		template.Notes = *req.Notes
+	}
+	if req.LineItems != nil {
+		template.LineItems = req.LineItems
+	}
+	if err := template.validate(); err != nil {
+		WriteJSONBadRequest(w, err.Error())
+		return
+	}
+
+	if err := saveInvoiceTemplate(template); err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			WriteJSONError(w, fmt.Errorf("an invoice template named %q already exists", template.Name), http.StatusConflict)
+			return
+		}
+		WriteJSONError(w, fmt.Errorf("failed to update invoice template: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, template)
+}
+
+// Delete deletes an invoice template
+func (h *InvoiceTemplateHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		WriteJSONBadRequest(w, "id must be a number")
+		return
+	}
+
+	result, err := database.DB.Exec("DELETE FROM invoice_templates WHERE id = ?", id)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to delete invoice template: %w", err), http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		WriteJSONError(w, fmt.Errorf("invoice template %d not found", id), http.StatusNotFound)
+		return
+	}
+
+	WriteJSONSuccess(w, map[string]interface{}{"id": id, "deleted": true})
+}
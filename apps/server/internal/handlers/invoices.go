@@ -19,41 +19,67 @@
 // - Invoices store both local metadata and Paystack references
 // - Status tracking enables invoice lifecycle management
 // - Verification endpoint confirms payment completion
-// - Line items support for detailed invoice breakdown
+// - Line items support for detailed invoice breakdown; they are stored locally
+//   so invoices can be rendered as HTML/PDF offline (see invoice_render.go)
 // - Local database cache for quick invoice lookups
+// - A background poller keeps cached status current (see invoice_sync.go);
+//   every status change is kept in invoice_events
+// - Paystack and offline payments are kept in a payments ledger that drives
+//   paid amount, balance and status (see invoice_payments.go)
+// - Invoices converted from an accepted quote keep its quote_number (see quotes.go)
+// - Invoice numbers come from a server-managed sequence (see sequences.go) and
+//   are reserved before the Paystack call so Paystack gets the same number;
+//   templates can prefill new invoices (see invoice_templates.go)
+// - Groups of customers are invoiced together under a batch ID (see invoice_batches.go)
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"time"
 
 	"paystack.mpc.proxy/internal/database"
+	"paystack.mpc.proxy/internal/document"
 	"paystack.mpc.proxy/internal/paystack"
 
 	"github.com/go-chi/chi/v5"
 )
 
+// invoiceSequence is the number_sequences row used for invoice numbers
+const invoiceSequence = "invoice"
+
 type InvoiceHandler struct {
-	client *paystack.Client
+	client   *paystack.Client
+	renderer *document.Renderer
 }
 
-func NewInvoiceHandler(client *paystack.Client) *InvoiceHandler {
-	return &InvoiceHandler{client: client}
+func NewInvoiceHandler(client *paystack.Client, renderer *document.Renderer) *InvoiceHandler {
+	return &InvoiceHandler{client: client, renderer: renderer}
 }
 
 type CreateInvoiceRequest struct {
-	Customer         string                `json:"customer"`
-	Amount           int                   `json:"amount"`
-	Description      string                `json:"description,omitempty"`
-	LineItems        []paystack.LineItem   `json:"line_items,omitempty"`
-	DueDate          string                `json:"due_date,omitempty"`
-	SendNotification bool                  `json:"send_notification,omitempty"`
-	Draft            bool                  `json:"draft,omitempty"`
-	HasInvoice       bool                  `json:"has_invoice,omitempty"`
-	InvoiceNumber    int                   `json:"invoice_number,omitempty"`
-	Currency         string                `json:"currency,omitempty"`
+	Customer         string              `json:"customer"`
+	Amount           int                 `json:"amount"`
+	Description      string              `json:"description,omitempty"`
+	LineItems        []paystack.LineItem `json:"line_items,omitempty"`
+	DueDate          string              `json:"due_date,omitempty"`
+	SendNotification bool                `json:"send_notification,omitempty"`
+	Draft            bool                `json:"draft,omitempty"`
+	HasInvoice       bool                `json:"has_invoice,omitempty"`
+	// InvoiceNumber is set by the server from the invoice sequence (the 42 in
+	// INV-2025-00042) and sent to Paystack; requests that set it are rejected
+	InvoiceNumber int    `json:"invoice_number,omitempty"`
+	Currency      string `json:"currency,omitempty"`
+	// Notes are printed on the rendered invoice
+	Notes string `json:"notes,omitempty"`
+	// DunningPolicyID selects the reminder schedule; the default policy is used when unset
+	DunningPolicyID *int `json:"dunning_policy_id,omitempty"`
+	// TemplateID fills line items, currency, due date, description and notes
+	// the request leaves empty from an invoice template
+	TemplateID *int `json:"template_id,omitempty"`
 }
 
 type ListInvoicesRequest struct {
@@ -66,14 +92,25 @@ type ListInvoicesRequest struct {
 }
 
 type Invoice struct {
-	ID           int       `json:"id"`
-	InvoiceCode  string    `json:"invoice_code"`
-	CustomerID   string    `json:"customer_id"`
-	CustomerName string    `json:"customer_name"`
-	Amount       int       `json:"amount"`
-	Status       string    `json:"status"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	ID             int        `json:"id"`
+	InvoiceCode    string     `json:"invoice_code"`
+	Number         string     `json:"number,omitempty"`
+	CustomerID     string     `json:"customer_id"`
+	CustomerName   string     `json:"customer_name"`
+	Amount         int        `json:"amount"`
+	Currency       string     `json:"currency"`
+	Description    string     `json:"description,omitempty"`
+	DueDate        *time.Time `json:"due_date,omitempty"`
+	Status         string     `json:"status"`
+	PaidAmount     int        `json:"paid_amount"`
+	CreditedAmount int        `json:"credited_amount"`
+	Balance        int        `json:"balance"`
+	QuoteNumber    string     `json:"quote_number,omitempty"`
+	BatchID        string     `json:"batch_id,omitempty"`
+	PaidAt         *time.Time `json:"paid_at,omitempty"`
+	LastCheckedAt  *time.Time `json:"last_checked_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
 }
 
 // Create creates a new invoice
@@ -90,105 +127,175 @@ func (h *InvoiceHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.InvoiceNumber != 0 {
+		WriteJSONBadRequest(w, "invoice_number is allocated by the server")
+		return
+	}
+
+	if req.TemplateID != nil {
+		template, err := getInvoiceTemplate(*req.TemplateID)
+		if err != nil {
+			WriteJSONBadRequest(w, fmt.Sprintf("invoice template %d not found", *req.TemplateID))
+			return
+		}
+		template.apply(&req, time.Now())
+	}
+
 	if req.Amount <= 0 {
 		WriteJSONBadRequest(w, "amount must be greater than 0")
 		return
 	}
 
-	// Verify customer exists in Paystack
-	customer, err := h.client.Customer.Get(req.Customer)
-	if err != nil {
-		WriteJSONError(w, fmt.Errorf("customer not found: %w", err), http.StatusBadRequest)
+	if msg := validateLineItems(req.LineItems); msg != "" {
+		WriteJSONBadRequest(w, msg)
 		return
 	}
 
-	// Extract customer name
-	customerName := customer.FirstName
-	if customer.LastName != "" {
-		if customerName != "" {
-			customerName += " " + customer.LastName
-		} else {
-			customerName = customer.LastName
+	var dueDate *time.Time
+	if req.DueDate != "" {
+		parsed, err := parseDueDate(req.DueDate)
+		if err != nil {
+			WriteJSONBadRequest(w, "due_date must be a date (YYYY-MM-DD) or RFC3339 timestamp")
+			return
+		}
+		dueDate = &parsed
+	}
+
+	if req.DunningPolicyID != nil {
+		if _, err := getDunningPolicy(*req.DunningPolicyID); err != nil {
+			WriteJSONBadRequest(w, fmt.Sprintf("dunning policy %d not found", *req.DunningPolicyID))
+			return
 		}
 	}
-	if customerName == "" {
-		customerName = customer.Email
+
+	currency := req.Currency
+	if currency == "" {
+		currency = "NGN"
 	}
 
-	// Create payment request in Paystack
-	paymentReq := &paystack.PaymentRequest{
-		Customer:         req.Customer,
-		Amount:           req.Amount,
-		Description:      req.Description,
-		LineItems:        req.LineItems,
-		DueDate:          req.DueDate,
-		SendNotification: req.SendNotification,
-		Draft:            req.Draft,
-		HasInvoice:       req.HasInvoice,
-		InvoiceNumber:    req.InvoiceNumber,
-		Currency:         req.Currency,
+	// Verify customer exists (local directory first, then Paystack)
+	customer, err := resolveCustomer(h.client, req.Customer)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("customer not found: %w", err), http.StatusBadRequest)
+		return
 	}
 
-	result, err := h.client.CreatePaymentRequest(paymentReq)
+	customerName := customer.DisplayName()
+
+	// Create payment request in Paystack under the next invoice number
+	result, number, err := createPaymentRequest(h.client, req)
 	if err != nil {
-		WriteJSONError(w, fmt.Errorf("failed to create payment request: %w", err), http.StatusInternalServerError)
+		WriteJSONError(w, err, http.StatusInternalServerError)
 		return
 	}
+	result["number"] = number
 
 	// Extract response fields
 	requestCode, _ := result["request_code"].(string)
 	status, _ := result["status"].(string)
 
-	// Insert into SQLite
-	query := `
-		INSERT INTO invoices (invoice_code, customer_id, customer_name, amount, status, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`
-	now := time.Now()
-	_, err = database.DB.Exec(query, requestCode, req.Customer, customerName, req.Amount, status, now, now)
-	if err != nil {
+	// Insert into SQLite together with the line items
+	if err := cacheInvoice(requestCode, number, req, customerName, customer.Email, status, currency, dueDate); err != nil {
 		// Log the error but still return the Paystack response
-		fmt.Printf("Warning: Failed to cache invoice in database: %v\n", err)
+		log.Printf("Warning: Failed to cache invoice in database: %v", err)
 	}
 
 	// Return full Paystack response
 	WriteJSONSuccess(w, result)
 }
 
-// List lists invoices from SQLite cache
-func (h *InvoiceHandler) List(w http.ResponseWriter, r *http.Request) {
-	var req ListInvoicesRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		WriteJSONBadRequest(w, "Invalid request body")
-		return
+const invoiceColumns = `id, invoice_code, COALESCE(invoice_number, ''), customer_id, customer_name, amount, COALESCE(currency, 'NGN'),
+	COALESCE(description, ''), due_date, status, COALESCE(paid_amount, 0), COALESCE(credited_amount, 0), COALESCE(quote_number, ''),
+	COALESCE(batch_id, ''), paid_at, last_checked_at, created_at, updated_at`
+
+func scanInvoice(row rowScanner) (*Invoice, error) {
+	var invoice Invoice
+	var status sql.NullString
+	var dueDate, paidAt, lastCheckedAt sql.NullTime
+	err := row.Scan(
+		&invoice.ID,
+		&invoice.InvoiceCode,
+		&invoice.Number,
+		&invoice.CustomerID,
+		&invoice.CustomerName,
+		&invoice.Amount,
+		&invoice.Currency,
+		&invoice.Description,
+		&dueDate,
+		&status,
+		&invoice.PaidAmount,
+		&invoice.CreditedAmount,
+		&invoice.QuoteNumber,
+		&invoice.BatchID,
+		&paidAt,
+		&lastCheckedAt,
+		&invoice.CreatedAt,
+		&invoice.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
 	}
-
-	// Validate required field
-	if req.CustomerID == "" {
-		WriteJSONBadRequest(w, "customer_id is required")
-		return
+	invoice.Status = status.String
+	invoice.Balance = max(invoice.Amount-invoice.PaidAmount-invoice.CreditedAmount, 0)
+	if dueDate.Valid {
+		invoice.DueDate = &dueDate.Time
+	}
+	if paidAt.Valid {
+		invoice.PaidAt = &paidAt.Time
+	}
+	if lastCheckedAt.Valid {
+		invoice.LastCheckedAt = &lastCheckedAt.Time
 	}
+	return &invoice, nil
+}
 
-	// Build query with filters
-	query := `SELECT id, invoice_code, customer_id, customer_name, amount, status, created_at, updated_at FROM invoices WHERE customer_id = ?`
-	args := []interface{}{req.CustomerID}
+// invoiceFilters turns list filters into " AND ..." conditions; shared by
+// List and the invoice export. An empty customer_id matches every customer.
+func invoiceFilters(req ListInvoicesRequest) (string, []interface{}) {
+	where := ""
+	args := []interface{}{}
+
+	if req.CustomerID != "" {
+		where += " AND customer_id = ?"
+		args = append(args, req.CustomerID)
+	}
 
-	// Add optional filters
 	if req.Status != "" {
-		query += " AND status = ?"
+		where += " AND status = ?"
 		args = append(args, req.Status)
 	}
 
 	if req.From != "" {
-		query += " AND created_at >= ?"
+		where += " AND created_at >= ?"
 		args = append(args, req.From)
 	}
 
 	if req.To != "" {
-		query += " AND created_at <= ?"
+		where += " AND created_at <= ?"
 		args = append(args, req.To)
 	}
 
+	return where, args
+}
+
+// List lists invoices from SQLite cache
+func (h *InvoiceHandler) List(w http.ResponseWriter, r *http.Request) {
+	var req ListInvoicesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+
+	// Validate required field
+	if req.CustomerID == "" {
+		WriteJSONBadRequest(w, "customer_id is required")
+		return
+	}
+
+	// Build query with filters
+	where, args := invoiceFilters(req)
+	query := "SELECT " + invoiceColumns + " FROM invoices WHERE 1=1" + where
+
 	// Add ordering
 	query += " ORDER BY created_at DESC"
 
@@ -207,22 +314,12 @@ func (h *InvoiceHandler) List(w http.ResponseWriter, r *http.Request) {
 
 	invoices := []Invoice{}
 	for rows.Next() {
-		var invoice Invoice
-		err := rows.Scan(
-			&invoice.ID,
-			&invoice.InvoiceCode,
-			&invoice.CustomerID,
-			&invoice.CustomerName,
-			&invoice.Amount,
-			&invoice.Status,
-			&invoice.CreatedAt,
-			&invoice.UpdatedAt,
-		)
+		invoice, err := scanInvoice(rows)
 		if err != nil {
 			WriteJSONError(w, fmt.Errorf("failed to scan invoice: %w", err), http.StatusInternalServerError)
 			return
 		}
-		invoices = append(invoices, invoice)
+		invoices = append(invoices, *invoice)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -233,7 +330,9 @@ func (h *InvoiceHandler) List(w http.ResponseWriter, r *http.Request) {
 	WriteJSONSuccess(w, invoices)
 }
 
-// Get fetches a single invoice from Paystack
+// Get fetches a single invoice from Paystack, refreshes the local cache and
+// adds the invoice's event history under "events" and its credit notes under
+// "credit_notes"
 func (h *InvoiceHandler) Get(w http.ResponseWriter, r *http.Request) {
 	idOrCode := chi.URLParam(r, "id_or_code")
 	if idOrCode == "" {
@@ -247,6 +346,26 @@ func (h *InvoiceHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if code := mapString(result, "request_code"); code != "" {
+		if _, err := applyPaymentRequest(code, result, InvoiceEventSourceGet); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+
+		events, err := getInvoiceEvents(code)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			events = []InvoiceEvent{}
+		}
+		result["events"] = events
+
+		creditNotes, err := listCreditNotes(code)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			creditNotes = []CreditNote{}
+		}
+		result["credit_notes"] = creditNotes
+	}
+
 	WriteJSONSuccess(w, result)
 }
 
@@ -264,16 +383,112 @@ func (h *InvoiceHandler) Verify(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract status from response
-	status, _ := result["status"].(string)
-
-	// Update local cache
-	query := `UPDATE invoices SET status = ?, updated_at = ? WHERE invoice_code = ?`
-	_, err = database.DB.Exec(query, status, time.Now(), code)
-	if err != nil {
+	// Update local cache and record the change in the invoice history
+	if _, err := applyPaymentRequest(code, result, InvoiceEventSourceVerify); err != nil {
 		// Log the error but still return the Paystack response
-		fmt.Printf("Warning: Failed to update invoice status in database: %v\n", err)
+		log.Printf("Warning: Failed to update invoice status in database: %v", err)
 	}
 
 	WriteJSONSuccess(w, result)
 }
+
+// newPaymentRequest builds the Paystack payment request for an invoice. Every
+// path that creates invoices (create, quote accept, batches) goes through it so
+// they send Paystack the same fields.
+func newPaymentRequest(req CreateInvoiceRequest) *paystack.PaymentRequest {
+	return &paystack.PaymentRequest{
+		Customer:         req.Customer,
+		Amount:           req.Amount,
+		Description:      req.Description,
+		LineItems:        req.LineItems,
+		DueDate:          req.DueDate,
+		SendNotification: req.SendNotification,
+		Draft:            req.Draft,
+		HasInvoice:       req.HasInvoice,
+		InvoiceNumber:    req.InvoiceNumber,
+		Currency:         req.Currency,
+	}
+}
+
+// createPaymentRequest reserves the next invoice number, sends it to Paystack
+// with the payment request and returns the formatted number. The number is
+// given back if Paystack rejects the request.
+func createPaymentRequest(client *paystack.Client, req CreateInvoiceRequest) (map[string]interface{}, string, error) {
+	number, err := reserveSequenceNumber(invoiceSequence)
+	if err != nil {
+		return nil, "", err
+	}
+
+	req.InvoiceNumber = number.Value
+	result, err := client.CreatePaymentRequest(newPaymentRequest(req))
+	if err != nil {
+		releaseSequenceNumber(invoiceSequence, number)
+		return nil, "", fmt.Errorf("failed to create payment request: %w", err)
+	}
+	return result, number.Formatted, nil
+}
+
+// cacheInvoice stores a newly created invoice under its reserved number,
+// with its line items and its "created" event, in one transaction
+func cacheInvoice(code, number string, req CreateInvoiceRequest, customerName, customerEmail, status, currency string, dueDate *time.Time) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	_, err = tx.Exec(`
+		INSERT INTO invoices (invoice_code, invoice_number, customer_id, customer_name, customer_email, amount, currency, description, notes, due_date, dunning_policy_id, template_id, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, code, number, req.Customer, customerName, customerEmail, req.Amount, currency, req.Description, req.Notes, dueDate, req.DunningPolicyID, req.TemplateID, status, now, now)
+	if err != nil {
+		return fmt.Errorf("failed to insert invoice: %w", err)
+	}
+
+	for i, item := range req.LineItems {
+		quantity := item.Quantity
+		if quantity == 0 {
+			quantity = 1
+		}
+		_, err := tx.Exec(`
+			INSERT INTO invoice_line_items (invoice_code, position, name, quantity, unit_amount, amount, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, code, i+1, item.Name, quantity, item.Amount, item.Amount*quantity, now)
+		if err != nil {
+			return fmt.Errorf("failed to insert line item %d: %w", i+1, err)
+		}
+	}
+
+	if err := recordInvoiceEvent(tx, code, "created", "", status, nil, InvoiceEventSourceCreate, number); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// validateLineItems checks invoice or quote line items and returns a message
+// describing the first problem, or "" when they are valid
+func validateLineItems(items []paystack.LineItem) string {
+	for i, item := range items {
+		if item.Name == "" {
+			return fmt.Sprintf("line_items[%d].name is required", i)
+		}
+		if item.Amount <= 0 {
+			return fmt.Sprintf("line_items[%d].amount must be greater than 0", i)
+		}
+		if item.Quantity < 0 {
+			return fmt.Sprintf("line_items[%d].quantity cannot be negative", i)
+		}
+	}
+	return ""
+}
+
+// parseDueDate accepts the due date formats Paystack accepts: a plain date or
+// an RFC3339 timestamp
+func parseDueDate(value string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
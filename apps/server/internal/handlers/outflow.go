@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+)
+
+// outflowMu serialises limit checks with the local write that reserves the
+// payment, so two concurrent payments cannot both spend the same remaining
+// allowance. It is never held across a Paystack call.
+var outflowMu sync.Mutex
+
+// TransferStatusReserved marks a transfer recorded before Paystack is called.
+// It counts toward limits like any other outflow until the Paystack response
+// confirms or releases it.
+const TransferStatusReserved = "reserved"
+
+// excludedPaymentStatuses are payment statuses that do not count toward limits
+const excludedPaymentStatuses = "'failed', 'cancelled', 'reversed', 'rejected'"
+
+// outflowFilter narrows an outflow sum; empty fields match everything
+type outflowFilter struct {
+	RecipientCode string
+	Currency      string
+}
+
+// sumOutflow sums money paid out in [from, to): expenses plus transfers
+// initiated through this server. A transfer that shares a reference with an
+// expense is the same payment and is only counted once. Expenses imported
+// from bank statements never left through Paystack and are not counted.
+func sumOutflow(filter outflowFilter, from, to time.Time) (int, error) {
+	expenseWhere := "COALESCE(payment_date, created_at) >= ? AND COALESCE(payment_date, created_at) < ?" +
+		" AND status NOT IN (" + excludedPaymentStatuses + ")" +
+		" AND COALESCE(source, 'manual') != ?"
+	transferWhere := "created_at >= ? AND created_at < ?" +
+		" AND COALESCE(status, '') NOT IN (" + excludedPaymentStatuses + ")" +
+		" AND (reference IS NULL OR reference NOT IN (SELECT reference FROM expenses WHERE reference IS NOT NULL))"
+	expenseArgs := []interface{}{from, to, ExpenseSourceBankImport}
+	transferArgs := []interface{}{from, to}
+
+	if filter.RecipientCode != "" {
+		expenseWhere += " AND recipient_code = ?"
+		transferWhere += " AND recipient_code = ?"
+		expenseArgs = append(expenseArgs, filter.RecipientCode)
+		transferArgs = append(transferArgs, filter.RecipientCode)
+	}
+
+	if filter.Currency != "" {
+		expenseWhere += " AND currency = ?"
+		transferWhere += " AND currency = ?"
+		expenseArgs = append(expenseArgs, filter.Currency)
+		transferArgs = append(transferArgs, filter.Currency)
+	}
+
+	query := "SELECT COALESCE((SELECT SUM(amount) FROM expenses WHERE " + expenseWhere + "), 0)" +
+		" + COALESCE((SELECT SUM(amount) FROM transfers WHERE " + transferWhere + "), 0)"
+
+	var spent int
+	if err := database.DB.QueryRow(query, append(expenseArgs, transferArgs...)...).Scan(&spent); err != nil {
+		return 0, fmt.Errorf("failed to sum outflow: %w", err)
+	}
+	return spent, nil
+}
+
+// localTransfer is a transfer initiated through this server
+type localTransfer struct {
+	Reference     string
+	RecipientCode string
+	Amount        int
+	Source        string
+	Reason        string
+}
+
+// reserveTransfers checks the transfers against account-wide and
+// per-recipient limits and, if they pass, records them as reserved so
+// concurrent payments count them. It returns the reserved row IDs in order.
+// A rejected batch returns the failing check and reserves nothing.
+func reserveTransfers(currency string, transfers []localTransfer) ([]int64, *AccountLimitCheck, *BeneficiaryLimitCheck, error) {
+	amounts := make([]int, len(transfers))
+	perRecipient := map[string]int{}
+	for i, t := range transfers {
+		amounts[i] = t.Amount
+		perRecipient[t.RecipientCode] += t.Amount
+	}
+
+	outflowMu.Lock()
+	defer outflowMu.Unlock()
+
+	// Enforce account-wide outflow limits on the total
+	accountCheck, err := CheckAccountLimits(currency, amounts...)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error checking account limits: %w", err)
+	}
+	if !accountCheck.Allowed {
+		return nil, accountCheck, nil, nil
+	}
+
+	// Enforce per-recipient caps on each recipient's share
+	for recipientCode, amount := range perRecipient {
+		limitCheck, err := CheckBeneficiaryLimit(recipientCode, currency, amount)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("error checking beneficiary limit: %w", err)
+		}
+		if !limitCheck.Allowed {
+			return nil, nil, limitCheck, nil
+		}
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	ids := make([]int64, len(transfers))
+	for i, t := range transfers {
+		var reference interface{}
+		if t.Reference != "" {
+			reference = t.Reference
+		}
+		result, err := tx.Exec(`
+			INSERT INTO transfers (reference, recipient_code, amount, currency, source, reason, status, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, reference, t.RecipientCode, t.Amount, currency, t.Source, t.Reason, TransferStatusReserved, now, now)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("failed to reserve transfer to %s: %w", t.RecipientCode, err)
+		}
+		ids[i], _ = result.LastInsertId()
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to commit transfer reservation: %w", err)
+	}
+	return ids, nil, nil, nil
+}
+
+// confirmTransfer replaces a reservation with what Paystack returned
+func confirmTransfer(id int64, transferCode, status string) {
+	var code interface{}
+	if transferCode != "" {
+		code = transferCode
+	}
+	_, err := database.DB.Exec(
+		"UPDATE transfers SET transfer_code = ?, status = ?, updated_at = ? WHERE id = ?",
+		code, status, time.Now(), id,
+	)
+	if err != nil {
+		log.Printf("Warning: Failed to confirm transfer %d (%s): %v", id, transferCode, err)
+	}
+}
+
+// releaseTransfers drops reservations for transfers Paystack did not accept
+func releaseTransfers(ids []int64) {
+	for _, id := range ids {
+		if _, err := database.DB.Exec("DELETE FROM transfers WHERE id = ? AND status = ?", id, TransferStatusReserved); err != nil {
+			log.Printf("Warning: Failed to release transfer reservation %d: %v", id, err)
+		}
+	}
+}
+
+// updateTransferStatus sets the status of a locally recorded transfer, found
+// by transfer code or reference. A missing transfer code is filled in, since
+// bulk transfers can be recorded before Paystack assigns one. Transfers not
+// initiated through this server are ignored.
+func updateTransferStatus(transferCode, reference, status string) error {
+	if status == "" || (transferCode == "" && reference == "") {
+		return nil
+	}
+
+	_, err := database.DB.Exec(`
+		UPDATE transfers
+		SET status = ?, transfer_code = COALESCE(transfer_code, NULLIF(?, '')), updated_at = ?
+		WHERE (transfer_code = ? AND ? != '') OR (reference = ? AND ? != '')
+	`, status, transferCode, time.Now(), transferCode, transferCode, reference, reference)
+	return err
+}
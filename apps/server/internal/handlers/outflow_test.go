@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+)
+
+func TestTransferReservationCountsUntilReleased(t *testing.T) {
+	openTestDB(t)
+	from, to := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	filter := outflowFilter{RecipientCode: "RCP_a"}
+
+	ids, accountCheck, limitCheck, err := reserveTransfers("NGN", []localTransfer{
+		{RecipientCode: "RCP_a", Amount: 5000, Reference: "REF_1"},
+		{RecipientCode: "RCP_a", Amount: 2500},
+	})
+	if err != nil || accountCheck != nil || limitCheck != nil {
+		t.Fatalf("reserve = %v, %v, %v", err, accountCheck, limitCheck)
+	}
+	if spent, _ := sumOutflow(filter, from, to); spent != 7500 {
+		t.Errorf("reserved outflow = %d, want 7500", spent)
+	}
+
+	confirmTransfer(ids[0], "TRF_1", "otp")
+	releaseTransfers(ids)
+
+	if spent, _ := sumOutflow(filter, from, to); spent != 5000 {
+		t.Errorf("outflow after release = %d, want only the confirmed 5000", spent)
+	}
+	var code, status string
+	database.DB.QueryRow("SELECT transfer_code, status FROM transfers WHERE id = ?", ids[0]).Scan(&code, &status)
+	if code != "TRF_1" || status != "otp" {
+		t.Errorf("confirmed transfer = %s/%s", code, status)
+	}
+}
+
+func TestImportedExpensesDoNotCountAsOutflow(t *testing.T) {
+	openTestDB(t)
+	from, to := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+
+	for _, source := range []string{ExpenseSourceBankImport, "manual"} {
+		_, err := database.DB.Exec(`
+			INSERT INTO expenses (recipient_code, recipient_name, amount, currency, status, payment_date, source, created_at, updated_at)
+			VALUES ('', 'Vendor', 4000, 'NGN', 'paid', ?, ?, ?, ?)
+		`, time.Now(), source, time.Now(), time.Now())
+		if err != nil {
+			t.Fatalf("insert %s expense: %v", source, err)
+		}
+	}
+
+	if spent, _ := sumOutflow(outflowFilter{Currency: "NGN"}, from, to); spent != 4000 {
+		t.Errorf("outflow = %d, want only the manual 4000", spent)
+	}
+}
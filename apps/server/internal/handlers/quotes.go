@@ -0,0 +1,583 @@
+// Package handlers implements HTTP handlers for the moniewave financial management system.
+//
+// Quotes Handler - Income Management
+//
+// OBJECTIVES:
+// Sales sends estimates before invoicing; an accepted estimate should become
+// an invoice without re-typing it.
+//
+// PURPOSE:
+// - Create quotes with line items and a validity date
+// - Track quote status (draft → sent → accepted / declined / expired)
+// - Convert an accepted quote into a Paystack payment request, carrying the
+//   line items over, and link the quote to the resulting invoice
+//
+// KEY WORKFLOW:
+// Create Quote (draft) → Send → Customer Accepts → Create Payment Request →
+// Cache Invoice + Line Items → Link Quote And Invoice
+//
+// DESIGN DECISIONS:
+// - Quotes are numbered from their own sequence (QT-000001, ...)
+// - The quote amount is always the sum of its line items
+// - A quote is valid through the end of its valid_until date; draft and sent
+//   quotes past that date read as expired (quoteStatusColumn), so reads never write
+// - Accepting claims the quote before calling Paystack so it cannot be
+//   converted twice; the claim is released if Paystack rejects the request
+// - Accepted, declined and expired quotes are final
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+	"paystack.mpc.proxy/internal/paystack"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// quoteSequence is the number_sequences row used for quote numbers
+const quoteSequence = "quote"
+
+// Quote statuses
+const (
+	QuoteStatusDraft    = "draft"
+	QuoteStatusSent     = "sent"
+	QuoteStatusAccepted = "accepted"
+	QuoteStatusDeclined = "declined"
+	QuoteStatusExpired  = "expired"
+)
+
+type QuoteHandler struct {
+	client *paystack.Client
+}
+
+func NewQuoteHandler(client *paystack.Client) *QuoteHandler {
+	return &QuoteHandler{client: client}
+}
+
+// Quote is an estimate that can be converted into an invoice
+type Quote struct {
+	ID            int               `json:"id"`
+	QuoteNumber   string            `json:"quote_number"`
+	CustomerID    string            `json:"customer_id"`
+	CustomerName  string            `json:"customer_name"`
+	CustomerEmail string            `json:"customer_email,omitempty"`
+	Amount        int               `json:"amount"`
+	Currency      string            `json:"currency"`
+	Description   string            `json:"description,omitempty"`
+	Notes         string            `json:"notes,omitempty"`
+	ValidUntil    time.Time         `json:"valid_until"`
+	Status        string            `json:"status"`
+	InvoiceCode   string            `json:"invoice_code,omitempty"`
+	DeclineReason string            `json:"decline_reason,omitempty"`
+	LineItems     []InvoiceLineItem `json:"line_items,omitempty"`
+	SentAt        *time.Time        `json:"sent_at,omitempty"`
+	AcceptedAt    *time.Time        `json:"accepted_at,omitempty"`
+	DeclinedAt    *time.Time        `json:"declined_at,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+type CreateQuoteRequest struct {
+	Customer    string              `json:"customer"`
+	Description string              `json:"description,omitempty"`
+	Notes       string              `json:"notes,omitempty"`
+	Currency    string              `json:"currency,omitempty"`
+	LineItems   []paystack.LineItem `json:"line_items"`
+	// ValidUntil is a date (YYYY-MM-DD) or RFC3339 timestamp
+	ValidUntil string `json:"valid_until"`
+}
+
+type ListQuotesRequest struct {
+	CustomerID string `json:"customer_id,omitempty"`
+	Status     string `json:"status,omitempty"`
+	Count      int    `json:"count,omitempty"`
+	Offset     int    `json:"offset,omitempty"`
+}
+
+// AcceptQuoteRequest sets the invoice options for the converted quote
+type AcceptQuoteRequest struct {
+	DueDate          string `json:"due_date,omitempty"`
+	SendNotification bool   `json:"send_notification,omitempty"`
+	DunningPolicyID  *int   `json:"dunning_policy_id,omitempty"`
+}
+
+type DeclineQuoteRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// AcceptQuoteResponse is the accepted quote and the Paystack payment request
+type AcceptQuoteResponse struct {
+	Quote          *Quote                 `json:"quote"`
+	PaymentRequest map[string]interface{} `json:"payment_request"`
+}
+
+// quoteStatusColumn is a quote's status with expiry applied: draft and sent
+// quotes whose valid_until date has passed are expired
+const quoteStatusColumn = `CASE WHEN status IN ('` + QuoteStatusDraft + `', '` + QuoteStatusSent + `') AND valid_until < date('now')
+	THEN '` + QuoteStatusExpired + `' ELSE status END`
+
+const quoteColumns = `id, quote_number, customer_id, customer_name, COALESCE(customer_email, ''), amount,
+	COALESCE(currency, 'NGN'), COALESCE(description, ''), COALESCE(notes, ''), valid_until, ` + quoteStatusColumn + `,
+	COALESCE(invoice_code, ''), COALESCE(decline_reason, ''), sent_at, accepted_at, declined_at, created_at, updated_at`
+
+// scanQuote reads a quote row selected with quoteColumns
+func scanQuote(row rowScanner) (*Quote, error) {
+	q := &Quote{}
+	var sentAt, acceptedAt, declinedAt sql.NullTime
+	err := row.Scan(
+		&q.ID,
+		&q.QuoteNumber,
+		&q.CustomerID,
+		&q.CustomerName,
+		&q.CustomerEmail,
+		&q.Amount,
+		&q.Currency,
+		&q.Description,
+		&q.Notes,
+		&q.ValidUntil,
+		&q.Status,
+		&q.InvoiceCode,
+		&q.DeclineReason,
+		&sentAt,
+		&acceptedAt,
+		&declinedAt,
+		&q.CreatedAt,
+		&q.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if sentAt.Valid {
+		q.SentAt = &sentAt.Time
+	}
+	if acceptedAt.Valid {
+		q.AcceptedAt = &acceptedAt.Time
+	}
+	if declinedAt.Valid {
+		q.DeclinedAt = &declinedAt.Time
+	}
+	return q, nil
+}
+
+// getQuote loads a quote and its line items by number. It returns
+// sql.ErrNoRows when the quote does not exist.
+func getQuote(number string) (*Quote, error) {
+	row := database.DB.QueryRow("SELECT "+quoteColumns+" FROM quotes WHERE quote_number = ?", number)
+	q, err := scanQuote(row)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := database.DB.Query(`
+		SELECT position, name, quantity, unit_amount, amount
+		FROM quote_line_items
+		WHERE quote_id = ?
+		ORDER BY position
+	`, q.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query quote line items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item InvoiceLineItem
+		if err := rows.Scan(&item.Position, &item.Name, &item.Quantity, &item.UnitAmount, &item.Amount); err != nil {
+			return nil, fmt.Errorf("failed to scan quote line item: %w", err)
+		}
+		q.LineItems = append(q.LineItems, item)
+	}
+
+	return q, rows.Err()
+}
+
+// writeQuoteLookupError writes the response for a failed getQuote
+func writeQuoteLookupError(w http.ResponseWriter, number string, err error) {
+	if err == sql.ErrNoRows {
+		WriteJSONError(w, fmt.Errorf("quote %s not found", number), http.StatusNotFound)
+		return
+	}
+	WriteJSONError(w, fmt.Errorf("failed to load quote: %w", err), http.StatusInternalServerError)
+}
+
+// writeQuoteStatusError rejects a transition from a final status
+func writeQuoteStatusError(w http.ResponseWriter, q *Quote, action string) {
+	respondWithJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+		"status":  false,
+		"message": fmt.Sprintf("Quote cannot be %s", action),
+		"error":   fmt.Sprintf("quote %s is %s", q.QuoteNumber, q.Status),
+	})
+}
+
+// Create creates a draft quote
+func (h *QuoteHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req CreateQuoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.Customer == "" {
+		WriteJSONBadRequest(w, "customer is required")
+		return
+	}
+	if len(req.LineItems) == 0 {
+		WriteJSONBadRequest(w, "line_items is required")
+		return
+	}
+	if msg := validateLineItems(req.LineItems); msg != "" {
+		WriteJSONBadRequest(w, msg)
+		return
+	}
+	if req.ValidUntil == "" {
+		WriteJSONBadRequest(w, "valid_until is required")
+		return
+	}
+	validUntil, err := parseDueDate(req.ValidUntil)
+	if err != nil {
+		WriteJSONBadRequest(w, "valid_until must be a date (YYYY-MM-DD) or RFC3339 timestamp")
+		return
+	}
+	validUntil = time.Date(validUntil.Year(), validUntil.Month(), validUntil.Day(), 0, 0, 0, 0, time.UTC)
+	now := time.Now()
+	if validUntil.Before(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)) {
+		WriteJSONBadRequest(w, "valid_until cannot be in the past")
+		return
+	}
+
+	currency := req.Currency
+	if currency == "" {
+		currency = "NGN"
+	}
+
+	// Verify customer exists (local directory first, then Paystack)
+	customer, err := resolveCustomer(h.client, req.Customer)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("customer not found: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to begin transaction: %w", err), http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	number, err := nextSequenceNumber(tx, quoteSequence)
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	amount := 0
+	for _, item := range req.LineItems {
+		amount += item.Amount * max(item.Quantity, 1)
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO quotes (quote_number, customer_id, customer_name, customer_email, amount, currency, description, notes, valid_until, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, number, req.Customer, customer.DisplayName(), customer.Email, amount, currency, req.Description, req.Notes, validUntil, QuoteStatusDraft, now, now)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to save quote: %w", err), http.StatusInternalServerError)
+		return
+	}
+	quoteID, _ := result.LastInsertId()
+
+	for i, item := range req.LineItems {
+		quantity := max(item.Quantity, 1)
+		_, err := tx.Exec(`
+			INSERT INTO quote_line_items (quote_id, position, name, quantity, unit_amount, amount)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, quoteID, i+1, item.Name, quantity, item.Amount, item.Amount*quantity)
+		if err != nil {
+			WriteJSONError(w, fmt.Errorf("failed to save line item %d: %w", i+1, err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to commit quote: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	q, err := getQuote(number)
+	if err != nil {
+		writeQuoteLookupError(w, number, err)
+		return
+	}
+
+	WriteJSONSuccess(w, q)
+}
+
+// List lists quotes (without line items), newest first, optionally filtered by
+// customer and status
+func (h *QuoteHandler) List(w http.ResponseWriter, r *http.Request) {
+	var req ListQuotesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+
+	query := "SELECT " + quoteColumns + " FROM quotes WHERE 1=1"
+	args := []interface{}{}
+	if req.CustomerID != "" {
+		query += " AND customer_id = ?"
+		args = append(args, req.CustomerID)
+	}
+	if req.Status != "" {
+		query += " AND " + quoteStatusColumn + " = ?"
+		args = append(args, req.Status)
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+	if req.Count > 0 {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, req.Count, req.Offset)
+	}
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to query quotes: %w", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	quotes := []*Quote{}
+	for rows.Next() {
+		q, err := scanQuote(rows)
+		if err != nil {
+			WriteJSONError(w, fmt.Errorf("failed to scan quote: %w", err), http.StatusInternalServerError)
+			return
+		}
+		quotes = append(quotes, q)
+	}
+
+	if err := rows.Err(); err != nil {
+		WriteJSONError(w, fmt.Errorf("error iterating quotes: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, quotes)
+}
+
+// Get returns a quote with its line items
+func (h *QuoteHandler) Get(w http.ResponseWriter, r *http.Request) {
+	number := chi.URLParam(r, "number")
+
+	q, err := getQuote(number)
+	if err != nil {
+		writeQuoteLookupError(w, number, err)
+		return
+	}
+
+	WriteJSONSuccess(w, q)
+}
+
+// Send marks a draft quote as sent to the customer
+func (h *QuoteHandler) Send(w http.ResponseWriter, r *http.Request) {
+	number := chi.URLParam(r, "number")
+
+	q, err := getQuote(number)
+	if err != nil {
+		writeQuoteLookupError(w, number, err)
+		return
+	}
+	if q.Status != QuoteStatusDraft {
+		writeQuoteStatusError(w, q, "sent")
+		return
+	}
+
+	now := time.Now()
+	_, err = database.DB.Exec(
+		"UPDATE quotes SET status = ?, sent_at = ?, updated_at = ? WHERE id = ? AND status = ?",
+		QuoteStatusSent, now, now, q.ID, QuoteStatusDraft,
+	)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to update quote: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	q, err = getQuote(number)
+	if err != nil {
+		writeQuoteLookupError(w, number, err)
+		return
+	}
+
+	WriteJSONSuccess(w, q)
+}
+
+// Decline marks a draft or sent quote as declined
+func (h *QuoteHandler) Decline(w http.ResponseWriter, r *http.Request) {
+	number := chi.URLParam(r, "number")
+
+	var req DeclineQuoteRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJSONBadRequest(w, "Invalid request body")
+			return
+		}
+	}
+
+	q, err := getQuote(number)
+	if err != nil {
+		writeQuoteLookupError(w, number, err)
+		return
+	}
+	if q.Status != QuoteStatusDraft && q.Status != QuoteStatusSent {
+		writeQuoteStatusError(w, q, "declined")
+		return
+	}
+
+	now := time.Now()
+	_, err = database.DB.Exec(`
+		UPDATE quotes SET status = ?, decline_reason = ?, declined_at = ?, updated_at = ?
+		WHERE id = ? AND status IN (?, ?)
+	`, QuoteStatusDeclined, strings.TrimSpace(req.Reason), now, now, q.ID, QuoteStatusDraft, QuoteStatusSent)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to update quote: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	q, err = getQuote(number)
+	if err != nil {
+		writeQuoteLookupError(w, number, err)
+		return
+	}
+
+	WriteJSONSuccess(w, q)
+}
+
+// Accept converts a draft or sent quote into a Paystack payment request and
+// links the quote to the cached invoice
+func (h *QuoteHandler) Accept(w http.ResponseWriter, r *http.Request) {
+	number := chi.URLParam(r, "number")
+
+	var req AcceptQuoteRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJSONBadRequest(w, "Invalid request body")
+			return
+		}
+	}
+
+	var dueDate *time.Time
+	if req.DueDate != "" {
+		parsed, err := parseDueDate(req.DueDate)
+		if err != nil {
+			WriteJSONBadRequest(w, "due_date must be a date (YYYY-MM-DD) or RFC3339 timestamp")
+			return
+		}
+		dueDate = &parsed
+	}
+	if req.DunningPolicyID != nil {
+		if _, err := getDunningPolicy(*req.DunningPolicyID); err != nil {
+			WriteJSONBadRequest(w, fmt.Sprintf("dunning policy %d not found", *req.DunningPolicyID))
+			return
+		}
+	}
+
+	q, err := getQuote(number)
+	if err != nil {
+		writeQuoteLookupError(w, number, err)
+		return
+	}
+	if q.Status != QuoteStatusDraft && q.Status != QuoteStatusSent {
+		writeQuoteStatusError(w, q, "accepted")
+		return
+	}
+
+	// Claim the quote so a concurrent accept cannot convert it twice
+	now := time.Now()
+	result, err := database.DB.Exec(
+		"UPDATE quotes SET status = ?, accepted_at = ?, updated_at = ? WHERE id = ? AND status = ?",
+		QuoteStatusAccepted, now, now, q.ID, q.Status,
+	)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to update quote: %w", err), http.StatusInternalServerError)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		WriteJSONError(w, fmt.Errorf("quote %s changed while being accepted", number), http.StatusConflict)
+		return
+	}
+
+	invoiceReq := CreateInvoiceRequest{
+		Customer:         q.CustomerID,
+		Amount:           q.Amount,
+		Description:      q.Description,
+		DueDate:          req.DueDate,
+		SendNotification: req.SendNotification,
+		HasInvoice:       true,
+		Currency:         q.Currency,
+		DunningPolicyID:  req.DunningPolicyID,
+	}
+	if invoiceReq.Description == "" {
+		invoiceReq.Description = "Quote " + q.QuoteNumber
+	}
+	for _, item := range q.LineItems {
+		invoiceReq.LineItems = append(invoiceReq.LineItems, paystack.LineItem{
+			Name:     item.Name,
+			Amount:   item.UnitAmount,
+			Quantity: item.Quantity,
+		})
+	}
+
+	paymentRequest, invoiceNumber, err := createPaymentRequest(h.client, invoiceReq)
+	if err != nil {
+		// Release the claim so the quote can be accepted again
+		_, rerr := database.DB.Exec(
+			"UPDATE quotes SET status = ?, accepted_at = NULL, updated_at = ? WHERE id = ?",
+			q.Status, time.Now(), q.ID,
+		)
+		if rerr != nil {
+			log.Printf("Warning: failed to release quote %s after failed conversion: %v", number, rerr)
+		}
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+	paymentRequest["number"] = invoiceNumber
+
+	requestCode := mapString(paymentRequest, "request_code")
+	status := mapString(paymentRequest, "status")
+
+	if err := cacheInvoice(requestCode, invoiceNumber, invoiceReq, q.CustomerName, q.CustomerEmail, status, q.Currency, dueDate); err != nil {
+		log.Printf("Warning: Failed to cache invoice in database: %v", err)
+	}
+	if err := linkQuoteInvoice(q.ID, number, requestCode); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	q, err = getQuote(number)
+	if err != nil {
+		writeQuoteLookupError(w, number, err)
+		return
+	}
+
+	WriteJSONSuccess(w, AcceptQuoteResponse{Quote: q, PaymentRequest: paymentRequest})
+}
+
+// linkQuoteInvoice records the invoice created from a quote on both rows
+func linkQuoteInvoice(quoteID int, quoteNumber, invoiceCode string) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if _, err := tx.Exec("UPDATE quotes SET invoice_code = ?, updated_at = ? WHERE id = ?", invoiceCode, now, quoteID); err != nil {
+		return fmt.Errorf("failed to link quote %s to invoice %s: %w", quoteNumber, invoiceCode, err)
+	}
+	if _, err := tx.Exec("UPDATE invoices SET quote_number = ?, updated_at = ? WHERE invoice_code = ?", quoteNumber, now, invoiceCode); err != nil {
+		return fmt.Errorf("failed to link invoice %s to quote %s: %w", invoiceCode, quoteNumber, err)
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,425 @@
+// Package handlers implements HTTP handlers for the moniewave financial management system.
+//
+// Receivables Handler - Income Management
+//
+// OBJECTIVES:
+// Users need to see how much customers owe them and how late it is.
+//
+// PURPOSE:
+// - Report outstanding invoice balances grouped into aging buckets
+// - Total the buckets per customer and per currency
+// - Report as of any date, for month-end and audit views
+// - Export as JSON or CSV
+//
+// KEY WORKFLOW:
+// Load Invoices Issued By As-Of → Rebuild Status And Paid Amount As Of That Date →
+// Outstanding = Amount - Paid - Credited → Bucket By Days Past Due → Total Per Customer/Currency
+//
+// DESIGN DECISIONS:
+// - Paid amount as of a date is summed from the payments ledger by paid_at;
+//   status as of a date is replayed from invoice_events (cached status when
+//   there is no history)
+// - Credit notes reduce the outstanding balance from the day they were issued
+// - Invoices without a due date are treated as due on issue
+// - Cancelled, void, archived, failed and draft invoices are not receivables
+// - Currencies are never added together; every total is per currency
+// - JSON amounts are in kobo like the rest of the API; CSV amounts are decimals
+//   in major units so they open cleanly in a spreadsheet
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+	"paystack.mpc.proxy/internal/money"
+)
+
+// Aging buckets, in report order
+const (
+	AgingCurrent = "current"
+	Aging1To30   = "1_30"
+	Aging31To60  = "31_60"
+	Aging61To90  = "61_90"
+	Aging90Plus  = "90_plus"
+)
+
+var agingBuckets = []string{AgingCurrent, Aging1To30, Aging31To60, Aging61To90, Aging90Plus}
+
+type ReceivablesHandler struct{}
+
+func NewReceivablesHandler() *ReceivablesHandler {
+	return &ReceivablesHandler{}
+}
+
+// AgingTotals holds outstanding amounts per bucket (kobo)
+type AgingTotals struct {
+	Current int `json:"current"`
+	Days30  int `json:"1_30"`
+	Days60  int `json:"31_60"`
+	Days90  int `json:"61_90"`
+	Over90  int `json:"90_plus"`
+	Total   int `json:"total"`
+}
+
+func (t *AgingTotals) add(bucket string, amount int) {
+	switch bucket {
+	case AgingCurrent:
+		t.Current += amount
+	case Aging1To30:
+		t.Days30 += amount
+	case Aging31To60:
+		t.Days60 += amount
+	case Aging61To90:
+		t.Days90 += amount
+	default:
+		t.Over90 += amount
+	}
+	t.Total += amount
+}
+
+func (t *AgingTotals) csvValues() []string {
+	return []string{
+		money.Decimal(t.Current),
+		money.Decimal(t.Days30),
+		money.Decimal(t.Days60),
+		money.Decimal(t.Days90),
+		money.Decimal(t.Over90),
+		money.Decimal(t.Total),
+	}
+}
+
+// AgingInvoice is one outstanding invoice in the report
+type AgingInvoice struct {
+	InvoiceCode    string    `json:"invoice_code"`
+	CustomerID     string    `json:"customer_id"`
+	CustomerName   string    `json:"customer_name"`
+	Currency       string    `json:"currency"`
+	Amount         int       `json:"amount"`
+	PaidAmount     int       `json:"paid_amount"`
+	CreditedAmount int       `json:"credited_amount"`
+	Outstanding    int       `json:"outstanding"`
+	Status         string    `json:"status"`
+	IssuedAt       time.Time `json:"issued_at"`
+	DueDate        time.Time `json:"due_date"`
+	DaysPastDue    int       `json:"days_past_due"`
+	Bucket         string    `json:"bucket"`
+}
+
+// AgingCustomer totals one customer's outstanding balance in one currency
+type AgingCustomer struct {
+	CustomerID   string `json:"customer_id"`
+	CustomerName string `json:"customer_name"`
+	Currency     string `json:"currency"`
+	InvoiceCount int    `json:"invoice_count"`
+	AgingTotals
+}
+
+// AgingCurrency totals all outstanding balances in one currency
+type AgingCurrency struct {
+	Currency     string `json:"currency"`
+	InvoiceCount int    `json:"invoice_count"`
+	AgingTotals
+}
+
+// AgingReport is the receivables aging report
+type AgingReport struct {
+	AsOf       string           `json:"as_of"`
+	Buckets    []string         `json:"buckets"`
+	Currencies []*AgingCurrency `json:"currencies"`
+	Customers  []*AgingCustomer `json:"customers"`
+	Invoices   []AgingInvoice   `json:"invoices,omitempty"`
+}
+
+// agingBucket places an invoice by how many days past due it is
+func agingBucket(daysPastDue int) string {
+	switch {
+	case daysPastDue <= 0:
+		return AgingCurrent
+	case daysPastDue <= 30:
+		return Aging1To30
+	case daysPastDue <= 60:
+		return Aging31To60
+	case daysPastDue <= 90:
+		return Aging61To90
+	default:
+		return Aging90Plus
+	}
+}
+
+// Aging returns the receivables aging report.
+// Query params: as_of (YYYY-MM-DD, default today), currency, customer_id,
+// include_invoices=true, format=json|csv.
+func (h *ReceivablesHandler) Aging(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	asOf := time.Now().UTC()
+	if value := query.Get("as_of"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			WriteJSONBadRequest(w, "as_of must be a date (YYYY-MM-DD)")
+			return
+		}
+		asOf = parsed
+	}
+	asOfDate := time.Date(asOf.Year(), asOf.Month(), asOf.Day(), 0, 0, 0, 0, time.UTC)
+
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "csv" {
+		WriteJSONBadRequest(w, "format must be json or csv")
+		return
+	}
+
+	invoices, err := outstandingInvoices(asOfDate, query.Get("currency"), query.Get("customer_id"))
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	report := buildAgingReport(asOfDate, invoices)
+
+	if format == "csv" {
+		header := append([]string{"customer_id", "customer_name", "currency", "invoice_count"}, agingBuckets...)
+		header = append(header, "total")
+
+		rows := [][]string{}
+		for _, c := range report.Customers {
+			row := []string{c.CustomerID, c.CustomerName, c.Currency, strconv.Itoa(c.InvoiceCount)}
+			rows = append(rows, append(row, c.csvValues()...))
+		}
+		for _, c := range report.Currencies {
+			row := []string{"TOTAL", "", c.Currency, strconv.Itoa(c.InvoiceCount)}
+			rows = append(rows, append(row, c.csvValues()...))
+		}
+
+		WriteCSV(w, fmt.Sprintf("receivables-aging-%s.csv", report.AsOf), header, rows)
+		return
+	}
+
+	if query.Get("include_invoices") == "true" {
+		report.Invoices = invoices
+	}
+	WriteJSONSuccess(w, report)
+}
+
+// buildAgingReport totals outstanding invoices per customer and currency.
+// Customers are ordered by largest balance first within each currency.
+func buildAgingReport(asOf time.Time, invoices []AgingInvoice) *AgingReport {
+	report := &AgingReport{
+		AsOf:       asOf.Format("2006-01-02"),
+		Buckets:    agingBuckets,
+		Currencies: []*AgingCurrency{},
+		Customers:  []*AgingCustomer{},
+	}
+
+	currencies := map[string]*AgingCurrency{}
+	customers := map[string]*AgingCustomer{}
+
+	for _, inv := range invoices {
+		currency, ok := currencies[inv.Currency]
+		if !ok {
+			currency = &AgingCurrency{Currency: inv.Currency}
+			currencies[inv.Currency] = currency
+			report.Currencies = append(report.Currencies, currency)
+		}
+		currency.InvoiceCount++
+		currency.add(inv.Bucket, inv.Outstanding)
+
+		key := inv.Currency + "|" + inv.CustomerID
+		customer, ok := customers[key]
+		if !ok {
+			customer = &AgingCustomer{CustomerID: inv.CustomerID, CustomerName: inv.CustomerName, Currency: inv.Currency}
+			customers[key] = customer
+			report.Customers = append(report.Customers, customer)
+		}
+		customer.InvoiceCount++
+		customer.add(inv.Bucket, inv.Outstanding)
+	}
+
+	sort.Slice(report.Currencies, func(i, j int) bool {
+		return report.Currencies[i].Currency < report.Currencies[j].Currency
+	})
+	sort.Slice(report.Customers, func(i, j int) bool {
+		a, b := report.Customers[i], report.Customers[j]
+		if a.Currency != b.Currency {
+			return a.Currency < b.Currency
+		}
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		return a.CustomerID < b.CustomerID
+	})
+
+	return report
+}
+
+// invoiceStatusesAsOf replays invoice_events up to asOfEnd
+func invoiceStatusesAsOf(asOfEnd time.Time) (map[string]string, error) {
+	rows, err := database.DB.Query(`
+		SELECT invoice_code, to_status
+		FROM invoice_events
+		WHERE created_at < ? AND COALESCE(to_status, '') != ''
+		ORDER BY created_at, id
+	`, asOfEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invoice events: %w", err)
+	}
+	defer rows.Close()
+
+	statuses := map[string]string{}
+	for rows.Next() {
+		var code, status string
+		if err := rows.Scan(&code, &status); err != nil {
+			return nil, fmt.Errorf("failed to scan invoice event: %w", err)
+		}
+		statuses[code] = status
+	}
+
+	return statuses, rows.Err()
+}
+
+// invoicePaidAsOf sums the payments ledger per invoice up to asOfEnd
+func invoicePaidAsOf(asOfEnd time.Time) (map[string]int, error) {
+	rows, err := database.DB.Query(`
+		SELECT invoice_code, SUM(amount)
+		FROM invoice_payments
+		WHERE paid_at < ?
+		GROUP BY invoice_code
+	`, asOfEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum invoice payments: %w", err)
+	}
+	defer rows.Close()
+
+	paid := map[string]int{}
+	for rows.Next() {
+		var code string
+		var amount int
+		if err := rows.Scan(&code, &amount); err != nil {
+			return nil, fmt.Errorf("failed to scan invoice payments: %w", err)
+		}
+		paid[code] = amount
+	}
+
+	return paid, rows.Err()
+}
+
+// invoiceCreditedAsOf sums credit notes per invoice issued before asOfEnd
+func invoiceCreditedAsOf(asOfEnd time.Time) (map[string]int, error) {
+	rows, err := database.DB.Query(`
+		SELECT invoice_code, SUM(amount)
+		FROM credit_notes
+		WHERE created_at < ?
+		GROUP BY invoice_code
+	`, asOfEnd)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sum credit notes: %w", err)
+	}
+	defer rows.Close()
+
+	credited := map[string]int{}
+	for rows.Next() {
+		var code string
+		var amount int
+		if err := rows.Scan(&code, &amount); err != nil {
+			return nil, fmt.Errorf("failed to scan credit notes: %w", err)
+		}
+		credited[code] = amount
+	}
+
+	return credited, rows.Err()
+}
+
+// outstandingInvoices returns invoices issued by asOf with a balance due at
+// asOf, optionally filtered by currency and customer
+func outstandingInvoices(asOf time.Time, currency, customerID string) ([]AgingInvoice, error) {
+	asOfEnd := asOf.AddDate(0, 0, 1)
+
+	statuses, err := invoiceStatusesAsOf(asOfEnd)
+	if err != nil {
+		return nil, err
+	}
+	paid, err := invoicePaidAsOf(asOfEnd)
+	if err != nil {
+		return nil, err
+	}
+	credited, err := invoiceCreditedAsOf(asOfEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT invoice_code, customer_id, customer_name, COALESCE(currency, 'NGN'), amount,
+		       COALESCE(status, ''), due_date, created_at
+		FROM invoices
+		WHERE created_at < ?`
+	args := []interface{}{asOfEnd}
+
+	if currency != "" {
+		query += " AND COALESCE(currency, 'NGN') = ?"
+		args = append(args, currency)
+	}
+	if customerID != "" {
+		query += " AND customer_id = ?"
+		args = append(args, customerID)
+	}
+	query += " ORDER BY created_at"
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invoices: %w", err)
+	}
+	defer rows.Close()
+
+	invoices := []AgingInvoice{}
+	for rows.Next() {
+		var inv AgingInvoice
+		var dueDate sql.NullTime
+		err := rows.Scan(
+			&inv.InvoiceCode,
+			&inv.CustomerID,
+			&inv.CustomerName,
+			&inv.Currency,
+			&inv.Amount,
+			&inv.Status,
+			&dueDate,
+			&inv.IssuedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invoice: %w", err)
+		}
+
+		if status, ok := statuses[inv.InvoiceCode]; ok {
+			inv.Status = status
+		}
+		inv.PaidAmount = paid[inv.InvoiceCode]
+		inv.CreditedAmount = credited[inv.InvoiceCode]
+
+		if upstreamInvoiceStatuses[inv.Status] {
+			continue
+		}
+		inv.Outstanding = inv.Amount - inv.PaidAmount - inv.CreditedAmount
+		if inv.Outstanding <= 0 {
+			continue
+		}
+
+		inv.DueDate = inv.IssuedAt
+		if dueDate.Valid {
+			inv.DueDate = dueDate.Time
+		}
+		due := time.Date(inv.DueDate.Year(), inv.DueDate.Month(), inv.DueDate.Day(), 0, 0, 0, 0, time.UTC)
+		inv.DaysPastDue = max(int(asOf.Sub(due).Hours()/24), 0)
+		inv.Bucket = agingBucket(inv.DaysPastDue)
+
+		invoices = append(invoices, inv)
+	}
+
+	return invoices, rows.Err()
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+)
+
+func TestAgingBucket(t *testing.T) {
+	tests := map[int]string{
+		-5:  AgingCurrent,
+		0:   AgingCurrent,
+		1:   Aging1To30,
+		30:  Aging1To30,
+		31:  Aging31To60,
+		60:  Aging31To60,
+		61:  Aging61To90,
+		90:  Aging61To90,
+		91:  Aging90Plus,
+		400: Aging90Plus,
+	}
+	for days, want := range tests {
+		if got := agingBucket(days); got != want {
+			t.Errorf("agingBucket(%d) = %s, want %s", days, got, want)
+		}
+	}
+}
+
+func TestBuildAgingReport(t *testing.T) {
+	asOf := time.Date(2025, time.June, 30, 0, 0, 0, 0, time.UTC)
+	invoices := []AgingInvoice{
+		{CustomerID: "CUS_a", Currency: "NGN", Outstanding: 1000, Bucket: AgingCurrent},
+		{CustomerID: "CUS_a", Currency: "NGN", Outstanding: 500, Bucket: Aging90Plus},
+		{CustomerID: "CUS_b", Currency: "NGN", Outstanding: 2000, Bucket: Aging31To60},
+		{CustomerID: "CUS_a", Currency: "USD", Outstanding: 300, Bucket: Aging1To30},
+	}
+
+	report := buildAgingReport(asOf, invoices)
+
+	if report.AsOf != "2025-06-30" {
+		t.Errorf("Expected as_of 2025-06-30, got %s", report.AsOf)
+	}
+	if len(report.Currencies) != 2 || report.Currencies[0].Currency != "NGN" || report.Currencies[0].Total != 3500 {
+		t.Fatalf("Unexpected currency totals: %+v", report.Currencies)
+	}
+	if report.Currencies[1].Days30 != 300 {
+		t.Errorf("Expected USD 1-30 bucket of 300, got %d", report.Currencies[1].Days30)
+	}
+
+	// NGN customers ordered by balance, then USD
+	if len(report.Customers) != 3 {
+		t.Fatalf("Expected 3 customer rows, got %d", len(report.Customers))
+	}
+	first := report.Customers[0]
+	if first.CustomerID != "CUS_b" || first.Total != 2000 {
+		t.Errorf("Expected CUS_b first with 2000, got %s with %d", first.CustomerID, first.Total)
+	}
+	second := report.Customers[1]
+	if second.CustomerID != "CUS_a" || second.Current != 1000 || second.Over90 != 500 || second.InvoiceCount != 2 {
+		t.Errorf("Unexpected CUS_a NGN totals: %+v", second)
+	}
+	if report.Customers[2].Currency != "USD" {
+		t.Errorf("Expected USD row last, got %s", report.Customers[2].Currency)
+	}
+}
+
+func TestLegacyPaidInvoicesAreNotOutstanding(t *testing.T) {
+	path := openTestDB(t)
+	issued := time.Date(2025, time.January, 10, 0, 0, 0, 0, time.UTC)
+	due := issued.AddDate(0, 0, 14)
+
+	// Invoices cached before the payments ledger: a paid one with Paystack's
+	// status and no paid amount, and one still awaiting payment
+	for _, inv := range []struct{ code, status string }{{"PRQ_legacy", "success"}, {"PRQ_open", "pending"}} {
+		_, err := database.DB.Exec(`
+			INSERT INTO invoices (invoice_code, customer_id, customer_name, amount, currency, status, due_date, created_at, updated_at)
+			VALUES (?, 'CUS_a', 'Ada', 50000, 'NGN', ?, ?, ?, ?)
+		`, inv.code, inv.status, due, issued, issued)
+		if err != nil {
+			t.Fatalf("Failed to insert invoice: %v", err)
+		}
+	}
+
+	// Migrations run again on the next start
+	database.Close()
+	if err := database.Initialize(path); err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+
+	invoices, err := outstandingInvoices(time.Date(2025, time.June, 30, 0, 0, 0, 0, time.UTC), "", "")
+	if err != nil {
+		t.Fatalf("outstandingInvoices: %v", err)
+	}
+	if len(invoices) != 1 || invoices[0].InvoiceCode != "PRQ_open" || invoices[0].Outstanding != 50000 {
+		t.Fatalf("Expected only PRQ_open outstanding, got %+v", invoices)
+	}
+}
@@ -0,0 +1,337 @@
+// Package handlers implements HTTP handlers for the moniewave financial management system.
+//
+// Recipient Sync - Payment Infrastructure
+//
+// OBJECTIVES:
+// Keep the local recipients cache consistent with Paystack.
+//
+// PURPOSE:
+// - Import recipients created outside this server (dashboard, other integrations)
+// - Detect recipients deleted upstream
+// - Repair cache rows that failed to write or drifted from Paystack
+// - Report every difference found
+//
+// KEY WORKFLOW:
+// Page Through Paystack Recipients → Diff Against Local Cache →
+// Upsert Changes → Mark Missing As Deleted Upstream → Record Sync Run
+//
+// DESIGN DECISIONS:
+// - All pages are fetched before any write so the SQLite transaction stays short
+// - Only recipients that originated in Paystack can be marked deleted upstream;
+//   local placeholders such as RCP_serviceprovider are never touched
+// - Deleted recipients are kept (deleted_upstream = 1) so expense history still resolves
+// - Paystack's delete only deactivates a recipient; deactivated recipients drop
+//   out of the listing and are marked deleted upstream, which also blocks payment
+// - A mutex prevents the scheduled job and the endpoint from running concurrently
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+	"paystack.mpc.proxy/internal/paystack"
+)
+
+// recipientSyncPageSize is the Paystack page size used while syncing
+const recipientSyncPageSize = 100
+
+var recipientSyncMu sync.Mutex
+
+// RecipientSyncReport summarises the differences found by a recipient sync
+type RecipientSyncReport struct {
+	StartedAt       time.Time       `json:"started_at"`
+	FinishedAt      time.Time       `json:"finished_at"`
+	Pages           int             `json:"pages"`
+	UpstreamCount   int             `json:"upstream_count"`
+	Added           []string        `json:"added"`
+	Updated         []RecipientDiff `json:"updated"`
+	Restored        []string        `json:"restored"`
+	DeletedUpstream []string        `json:"deleted_upstream"`
+	Unchanged       int             `json:"unchanged"`
+}
+
+// RecipientDiff lists the fields that differed between the cache and Paystack
+type RecipientDiff struct {
+	RecipientCode string                 `json:"recipient_code"`
+	Changes       map[string]FieldChange `json:"changes"`
+}
+
+// FieldChange is a single field difference
+type FieldChange struct {
+	Local    string `json:"local"`
+	Upstream string `json:"upstream"`
+}
+
+// upstreamRecipient is a recipient as listed by Paystack
+type upstreamRecipient struct {
+	RecipientCode string
+	Type          string
+	Name          string
+	AccountNumber string
+	BankCode      string
+	BankName      string
+	Currency      string
+	Description   string
+	Email         string
+}
+
+// Sync pulls the Paystack recipient list into the local cache and reports differences
+func (h *RecipientHandler) Sync(w http.ResponseWriter, r *http.Request) {
+	report, err := SyncRecipients(h.client)
+	if err != nil {
+		if errors.Is(err, ErrSyncInProgress) {
+			WriteJSONError(w, err, http.StatusConflict)
+			return
+		}
+		WriteJSONError(w, fmt.Errorf("recipient sync failed: %w", err), http.StatusBadGateway)
+		return
+	}
+
+	WriteJSONSuccess(w, report)
+}
+
+// SyncRecipients runs a full recipient sync and records the run in sync_runs
+func SyncRecipients(client *paystack.Client) (*RecipientSyncReport, error) {
+	if !recipientSyncMu.TryLock() {
+		return nil, ErrSyncInProgress
+	}
+	defer recipientSyncMu.Unlock()
+
+	report := &RecipientSyncReport{
+		StartedAt:       time.Now(),
+		Added:           []string{},
+		Updated:         []RecipientDiff{},
+		Restored:        []string{},
+		DeletedUpstream: []string{},
+	}
+
+	err := syncRecipients(client, report)
+	report.FinishedAt = time.Now()
+	recordSyncRun("recipients", report.StartedAt, report, err)
+
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func syncRecipients(client *paystack.Client, report *RecipientSyncReport) error {
+	upstream, err := fetchUpstreamRecipients(client, report)
+	if err != nil {
+		return err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	type localRecipient struct {
+		upstreamRecipient
+		Origin          string
+		DeletedUpstream bool
+	}
+
+	rows, err := tx.Query(`
+		SELECT recipient_code, type, name, account_number, bank_code, bank_name, currency, description,
+		       email, origin, deleted_upstream
+		FROM recipients
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to load recipients: %w", err)
+	}
+
+	local := map[string]*localRecipient{}
+	for rows.Next() {
+		var rec localRecipient
+		var bankName, currency, description, email, origin sql.NullString
+		var deleted sql.NullInt64
+		if err := rows.Scan(
+			&rec.RecipientCode,
+			&rec.Type,
+			&rec.Name,
+			&rec.AccountNumber,
+			&rec.BankCode,
+			&bankName,
+			&currency,
+			&description,
+			&email,
+			&origin,
+			&deleted,
+		); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan recipient: %w", err)
+		}
+		rec.BankName = bankName.String
+		rec.Currency = currency.String
+		rec.Description = description.String
+		rec.Email = email.String
+		rec.Origin = origin.String
+		rec.DeletedUpstream = deleted.Int64 == 1
+		local[rec.RecipientCode] = &rec
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating recipients: %w", err)
+	}
+
+	now := time.Now()
+	seen := map[string]bool{}
+
+	for _, up := range upstream {
+		seen[up.RecipientCode] = true
+
+		existing, ok := local[up.RecipientCode]
+		if !ok {
+			_, err := tx.Exec(`
+				INSERT INTO recipients (
+					recipient_code, type, name, account_number, bank_code, bank_name, currency, description,
+					email, origin, deleted_upstream, last_synced_at, created_at, updated_at
+				)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 'paystack', 0, ?, ?, ?)
+			`, up.RecipientCode, up.Type, up.Name, up.AccountNumber, up.BankCode, up.BankName,
+				up.Currency, up.Description, up.Email, now, now, now)
+			if err != nil {
+				return fmt.Errorf("failed to insert recipient %s: %w", up.RecipientCode, err)
+			}
+			report.Added = append(report.Added, up.RecipientCode)
+			continue
+		}
+
+		changes := diffRecipient(existing.upstreamRecipient, up)
+		if len(changes) == 0 && !existing.DeletedUpstream {
+			report.Unchanged++
+			if _, err := tx.Exec("UPDATE recipients SET last_synced_at = ? WHERE recipient_code = ?", now, up.RecipientCode); err != nil {
+				return fmt.Errorf("failed to update recipient %s: %w", up.RecipientCode, err)
+			}
+			continue
+		}
+
+		// Keep the cached bank name if Paystack did not return one
+		bankName := up.BankName
+		if bankName == "" {
+			bankName = existing.BankName
+		}
+
+		_, err := tx.Exec(`
+			UPDATE recipients
+			SET type = ?, name = ?, account_number = ?, bank_code = ?, bank_name = ?, currency = ?,
+			    description = ?, email = ?, deleted_upstream = 0, last_synced_at = ?, updated_at = ?
+			WHERE recipient_code = ?
+		`, up.Type, up.Name, up.AccountNumber, up.BankCode, bankName, up.Currency, up.Description,
+			up.Email, now, now, up.RecipientCode)
+		if err != nil {
+			return fmt.Errorf("failed to update recipient %s: %w", up.RecipientCode, err)
+		}
+
+		if existing.DeletedUpstream {
+			report.Restored = append(report.Restored, up.RecipientCode)
+		}
+		if len(changes) > 0 {
+			report.Updated = append(report.Updated, RecipientDiff{
+				RecipientCode: up.RecipientCode,
+				Changes:       changes,
+			})
+		}
+	}
+
+	// Anything that came from Paystack but is no longer listed was deleted upstream
+	for code, rec := range local {
+		if seen[code] || rec.Origin == "local" || rec.DeletedUpstream {
+			continue
+		}
+
+		_, err := tx.Exec(
+			"UPDATE recipients SET deleted_upstream = 1, last_synced_at = ?, updated_at = ? WHERE recipient_code = ?",
+			now, now, code,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to mark recipient %s deleted: %w", code, err)
+		}
+		report.DeletedUpstream = append(report.DeletedUpstream, code)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit recipient sync: %w", err)
+	}
+
+	return nil
+}
+
+// fetchUpstreamRecipients pages through every Paystack transfer recipient
+func fetchUpstreamRecipients(client *paystack.Client, report *RecipientSyncReport) ([]upstreamRecipient, error) {
+	recipients := []upstreamRecipient{}
+
+	for page := 1; ; page++ {
+		resp, err := client.ListTransferRecipients(recipientSyncPageSize, page)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list recipients (page %d): %w", page, err)
+		}
+		report.Pages++
+
+		data, _ := resp["data"].([]interface{})
+		for _, item := range data {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			code := mapString(m, "recipient_code")
+			if code == "" || mapBool(m, "is_deleted") {
+				continue
+			}
+
+			details := mapObject(m, "details")
+			recipients = append(recipients, upstreamRecipient{
+				RecipientCode: code,
+				Type:          mapString(m, "type"),
+				Name:          mapString(m, "name"),
+				AccountNumber: mapString(details, "account_number"),
+				BankCode:      mapString(details, "bank_code"),
+				BankName:      mapString(details, "bank_name"),
+				Currency:      mapString(m, "currency"),
+				Description:   mapString(m, "description"),
+				Email:         mapString(m, "email"),
+			})
+		}
+
+		meta := mapObject(resp, "meta")
+		pageCount := mapInt(meta, "pageCount")
+		if len(data) < recipientSyncPageSize || (pageCount > 0 && page >= pageCount) {
+			break
+		}
+	}
+
+	report.UpstreamCount = len(recipients)
+	return recipients, nil
+}
+
+// diffRecipient returns the fields that differ between the cached and upstream recipient
+func diffRecipient(local, upstream upstreamRecipient) map[string]FieldChange {
+	changes := map[string]FieldChange{}
+
+	compare := func(field, l, u string) {
+		if l != u {
+			changes[field] = FieldChange{Local: l, Upstream: u}
+		}
+	}
+
+	compare("type", local.Type, upstream.Type)
+	compare("name", local.Name, upstream.Name)
+	compare("account_number", local.AccountNumber, upstream.AccountNumber)
+	compare("bank_code", local.BankCode, upstream.BankCode)
+	compare("currency", local.Currency, upstream.Currency)
+	compare("description", local.Description, upstream.Description)
+	compare("email", local.Email, upstream.Email)
+	if upstream.BankName != "" {
+		compare("bank_name", local.BankName, upstream.BankName)
+	}
+
+	return changes
+}
@@ -12,51 +12,185 @@
 // - Maintain default recipients (e.g., service providers)
 //
 // KEY WORKFLOW:
-// Create Recipient → Call Paystack API → Cache Locally →
-// Reference in Expenses → Validate Before Transfer
+// Create Recipient → Resolve Bank Account → Match Account Name →
+// Call Paystack API → Cache Locally → Reference in Expenses → Validate Before Transfer
 //
 // DESIGN DECISIONS:
 // - Recipients are cached to reduce API calls and improve performance
 // - Default recipient (RCP_serviceprovider) enables unified service provider payments
 // - Local cache ensures expenses can reference recipients that exist
 // - All recipient creation goes through Paystack first, then cached locally
-// - Bank name extracted from Paystack response for display purposes
+// - Bank name extracted from Paystack response for display purposes, falling back
+//   to the cached bank directory; unknown bank codes are rejected before any API call
+// - Accounts are resolved before creation; the resolved name is fuzzy-matched
+//   against the requested name and low scores are rejected or flagged
+// - Updates and deactivation go to Paystack first; the cache only changes on success
+// - Deactivated recipients stay cached for expense history but cannot be paid;
+//   hard deletes are refused while expenses still reference the recipient
 package handlers
 
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
 	"paystack.mpc.proxy/internal/database"
 	"paystack.mpc.proxy/internal/paystack"
 
 	paystackSDK "github.com/borderlesshq/paystack-go"
+	"github.com/go-chi/chi/v5"
 )
 
 type RecipientHandler struct {
-	client *paystack.Client
+	client    *paystack.Client
+	nameMatch NameMatchPolicy
+	banks     *BankDirectory
 }
 
-func NewRecipientHandler(client *paystack.Client) *RecipientHandler {
-	return &RecipientHandler{client: client}
+// NameMatchPolicy controls how a resolved account name is compared with the
+// name supplied when creating a recipient
+type NameMatchPolicy struct {
+	// VerifyThreshold is the minimum score for a recipient to be verified
+	VerifyThreshold float64
+	// RejectThreshold is the score below which creation is rejected
+	RejectThreshold float64
 }
 
+func NewRecipientHandler(client *paystack.Client, nameMatch NameMatchPolicy, banks *BankDirectory) *RecipientHandler {
+	return &RecipientHandler{client: client, nameMatch: nameMatch, banks: banks}
+}
+
+// Recipient verification statuses
+const (
+	RecipientUnverified = "unverified"
+	RecipientVerified   = "verified"
+	RecipientFlagged    = "flagged"
+	// RecipientRejected is never stored; creation stops before Paystack
+	RecipientRejected = "rejected"
+)
+
 // Recipient represents a cached transfer recipient
 type Recipient struct {
-	ID            int       `json:"id"`
-	RecipientCode string    `json:"recipient_code"`
-	Type          string    `json:"type"`
-	Name          string    `json:"name"`
-	AccountNumber string    `json:"account_number"`
-	BankCode      string    `json:"bank_code"`
-	BankName      string    `json:"bank_name"`
-	Currency      string    `json:"currency"`
-	Description   string    `json:"description"`
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ID                  int                    `json:"id"`
+	RecipientCode       string                 `json:"recipient_code"`
+	Type                string                 `json:"type"`
+	Name                string                 `json:"name"`
+	AccountNumber       string                 `json:"account_number"`
+	BankCode            string                 `json:"bank_code"`
+	BankName            string                 `json:"bank_name"`
+	Currency            string                 `json:"currency"`
+	Description         string                 `json:"description"`
+	Email               string                 `json:"email,omitempty"`
+	Metadata            map[string]interface{} `json:"metadata,omitempty"`
+	Active              bool                   `json:"active"`
+	DeactivatedAt       *time.Time             `json:"deactivated_at,omitempty"`
+	Origin              string                 `json:"origin"`
+	DeletedUpstream     bool                   `json:"deleted_upstream"`
+	LastSyncedAt        *time.Time             `json:"last_synced_at,omitempty"`
+	ResolvedAccountName string                 `json:"resolved_account_name,omitempty"`
+	VerificationStatus  string                 `json:"verification_status"`
+	NameMatchScore      *float64               `json:"name_match_score,omitempty"`
+	VerifiedAt          *time.Time             `json:"verified_at,omitempty"`
+	CreatedAt           time.Time              `json:"created_at"`
+	UpdatedAt           time.Time              `json:"updated_at"`
+}
+
+// RecipientVerification describes the outcome of resolving a recipient's bank account
+type RecipientVerification struct {
+	Status              string  `json:"status"`
+	RequestedName       string  `json:"requested_name"`
+	ResolvedAccountName string  `json:"resolved_account_name"`
+	NameMatchScore      float64 `json:"name_match_score"`
+	VerifyThreshold     float64 `json:"verify_threshold"`
+	RejectThreshold     float64 `json:"reject_threshold"`
+}
+
+// CreateRecipientResponse is the Paystack recipient plus local verification details
+type CreateRecipientResponse struct {
+	*paystackSDK.TransferRecipient
+	Verification RecipientVerification `json:"verification"`
+}
+
+// recipientColumns is the column list scanned by scanRecipient
+const recipientColumns = `id, recipient_code, type, name, account_number, bank_code, bank_name, currency, description,
+		       email, metadata, active, deactivated_at, origin, deleted_upstream, last_synced_at, resolved_account_name, verification_status, name_match_score, verified_at, created_at, updated_at`
+
+// scanRecipient scans a row selected with recipientColumns
+func scanRecipient(row rowScanner) (*Recipient, error) {
+	var recipient Recipient
+	var bankName, description, email, metadata, origin, resolvedName, verificationStatus sql.NullString
+	var active, deletedUpstream sql.NullInt64
+	var matchScore sql.NullFloat64
+	var deactivatedAt, lastSyncedAt, verifiedAt sql.NullTime
+
+	err := row.Scan(
+		&recipient.ID,
+		&recipient.RecipientCode,
+		&recipient.Type,
+		&recipient.Name,
+		&recipient.AccountNumber,
+		&recipient.BankCode,
+		&bankName,
+		&recipient.Currency,
+		&description,
+		&email,
+		&metadata,
+		&active,
+		&deactivatedAt,
+		&origin,
+		&deletedUpstream,
+		&lastSyncedAt,
+		&resolvedName,
+		&verificationStatus,
+		&matchScore,
+		&verifiedAt,
+		&recipient.CreatedAt,
+		&recipient.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	recipient.BankName = bankName.String
+	recipient.Description = description.String
+	recipient.Email = email.String
+	if metadata.String != "" {
+		if err := json.Unmarshal([]byte(metadata.String), &recipient.Metadata); err != nil {
+			return nil, fmt.Errorf("invalid metadata for recipient %s: %w", recipient.RecipientCode, err)
+		}
+	}
+	// Rows cached before the active column existed are active
+	recipient.Active = !active.Valid || active.Int64 == 1
+	if deactivatedAt.Valid {
+		recipient.DeactivatedAt = &deactivatedAt.Time
+	}
+	recipient.Origin = origin.String
+	recipient.DeletedUpstream = deletedUpstream.Int64 == 1
+	if lastSyncedAt.Valid {
+		recipient.LastSyncedAt = &lastSyncedAt.Time
+	}
+	recipient.ResolvedAccountName = resolvedName.String
+	recipient.VerificationStatus = verificationStatus.String
+	if recipient.VerificationStatus == "" {
+		recipient.VerificationStatus = RecipientUnverified
+	}
+	if matchScore.Valid {
+		recipient.NameMatchScore = &matchScore.Float64
+	}
+	if verifiedAt.Valid {
+		recipient.VerifiedAt = &verifiedAt.Time
+	}
+
+	return &recipient, nil
 }
 
 type CreateRecipientWithCacheRequest struct {
@@ -102,6 +236,43 @@ func (h *RecipientHandler) Create(w http.ResponseWriter, r *http.Request) {
 		req.Currency = "NGN"
 	}
 
+	// Check the bank code against the cached directory before calling Paystack
+	var cachedBank *CachedBank
+	if h.banks != nil {
+		bank, err := h.banks.LookupCode(req.BankCode, req.Currency)
+		if errors.Is(err, ErrUnknownBank) {
+			WriteJSONBadRequest(w, fmt.Sprintf("unknown bank_code %s for %s", req.BankCode, req.Currency))
+			return
+		}
+		if err != nil {
+			log.Printf("Warning: bank directory unavailable, skipping bank_code check: %v", err)
+		}
+		cachedBank = bank
+	}
+
+	// Resolve the bank account and compare the account name
+	resolved, err := h.client.Bank.ResolveAccountNumber(req.AccountNumber, req.BankCode)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to resolve bank account: %w", err), http.StatusBadRequest)
+		return
+	}
+
+	resolvedName, _ := resolved["account_name"].(string)
+	if resolvedName == "" {
+		WriteJSONError(w, fmt.Errorf("bank account %s could not be resolved to an account name", req.AccountNumber), http.StatusBadRequest)
+		return
+	}
+
+	verification := h.verifyAccountName(req.Name, resolvedName)
+	if verification.Status == RecipientRejected {
+		respondWithJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"status":  false,
+			"message": "Recipient name does not match the bank account name",
+			"data":    verification,
+		})
+		return
+	}
+
 	// Create recipient in Paystack
 	recipient := &paystackSDK.TransferRecipient{
 		Type:          req.Type,
@@ -125,13 +296,38 @@ func (h *RecipientHandler) Create(w http.ResponseWriter, r *http.Request) {
 			bankName = bn
 		}
 	}
+	if bankName == "" && cachedBank != nil {
+		bankName = cachedBank.Name
+	}
 
 	// Cache in SQLite
 	query := `
-		INSERT INTO recipients (recipient_code, type, name, account_number, bank_code, bank_name, currency, description, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO recipients (
+			recipient_code, type, name, account_number, bank_code, bank_name, currency, description,
+			resolved_account_name, verification_status, name_match_score, verified_at, created_at, updated_at
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(recipient_code) DO UPDATE SET
+			type = excluded.type,
+			name = excluded.name,
+			account_number = excluded.account_number,
+			bank_code = excluded.bank_code,
+			bank_name = excluded.bank_name,
+			currency = excluded.currency,
+			description = excluded.description,
+			resolved_account_name = excluded.resolved_account_name,
+			verification_status = excluded.verification_status,
+			name_match_score = excluded.name_match_score,
+			verified_at = excluded.verified_at,
+			deleted_upstream = 0,
+			updated_at = excluded.updated_at
 	`
 	now := time.Now()
+	// Only a verified name counts as verified; flagged recipients keep verified_at empty
+	var verifiedAt interface{}
+	if verification.Status == RecipientVerified {
+		verifiedAt = now
+	}
 	_, err = database.DB.Exec(
 		query,
 		recipientCode,
@@ -142,27 +338,98 @@ func (h *RecipientHandler) Create(w http.ResponseWriter, r *http.Request) {
 		bankName,
 		req.Currency,
 		req.Description,
+		resolvedName,
+		verification.Status,
+		verification.NameMatchScore,
+		verifiedAt,
 		now,
 		now,
 	)
 	if err != nil {
-		// Log error but still return Paystack response
-		fmt.Printf("Warning: Failed to cache recipient in database: %v\n", err)
+		// Log error but still return Paystack response; the next recipient sync repairs the cache
+		log.Printf("Warning: Failed to cache recipient %s in database: %v", recipientCode, err)
+	}
+
+	// Return Paystack response with verification details
+	WriteJSONSuccess(w, CreateRecipientResponse{
+		TransferRecipient: result,
+		Verification:      verification,
+	})
+}
+
+// recipientFilters turns list query parameters into a WHERE clause; shared by
+// List and the recipient export
+func recipientFilters(q url.Values) (string, []interface{}) {
+	where := " WHERE 1=1"
+	args := []interface{}{}
+
+	if search := strings.TrimSpace(q.Get("search")); search != "" {
+		like := "%" + escapeLike(search) + "%"
+		where += ` AND (name LIKE ? ESCAPE '\' OR account_number LIKE ? ESCAPE '\' OR recipient_code LIKE ? ESCAPE '\'` +
+			` OR bank_name LIKE ? ESCAPE '\' OR resolved_account_name LIKE ? ESCAPE '\')`
+		args = append(args, like, like, like, like, like)
+	}
+
+	if v := q.Get("type"); v != "" {
+		where += " AND type = ?"
+		args = append(args, v)
+	}
+
+	if v := q.Get("bank_code"); v != "" {
+		where += " AND bank_code = ?"
+		args = append(args, v)
 	}
 
-	// Return Paystack response
-	WriteJSONSuccess(w, result)
+	if v := q.Get("currency"); v != "" {
+		where += " AND currency = ?"
+		args = append(args, v)
+	}
+
+	if v := q.Get("verification_status"); v != "" {
+		where += " AND verification_status = ?"
+		args = append(args, v)
+	}
+
+	if q.Get("include_deleted") != "true" {
+		where += " AND COALESCE(deleted_upstream, 0) = 0"
+	}
+
+	return where, args
 }
 
-// List lists all cached recipients from SQLite
+// List lists cached recipients from SQLite with optional filters, search and pagination.
+//
+// Query parameters: search (name, account number, recipient code or bank name),
+// type, bank_code, currency, verification_status, include_deleted, page, per_page.
+// The response keeps the original plain-array shape; without page or per_page
+// every matching recipient is returned. The total match count is sent in the
+// X-Total-Count header.
 func (h *RecipientHandler) List(w http.ResponseWriter, r *http.Request) {
-	query := `
-		SELECT id, recipient_code, type, name, account_number, bank_code, bank_name, currency, description, created_at, updated_at
-		FROM recipients
-		ORDER BY created_at DESC
-	`
+	q := r.URL.Query()
+
+	where, args := recipientFilters(q)
+
+	var total int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM recipients"+where, args...).Scan(&total); err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to count recipients: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	query := "SELECT " + recipientColumns + " FROM recipients" + where + " ORDER BY created_at DESC"
+	if q.Get("page") != "" || q.Get("per_page") != "" {
+		page, _ := strconv.Atoi(q.Get("page"))
+		if page < 1 {
+			page = 1
+		}
+		perPage, _ := strconv.Atoi(q.Get("per_page"))
+		if perPage <= 0 {
+			perPage = 50
+		}
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, perPage, (page-1)*perPage)
+	}
 
-	rows, err := database.DB.Query(query)
+	rows, err := database.DB.Query(query, args...)
 	if err != nil {
 		WriteJSONError(w, fmt.Errorf("failed to query recipients: %w", err), http.StatusInternalServerError)
 		return
@@ -171,35 +438,12 @@ func (h *RecipientHandler) List(w http.ResponseWriter, r *http.Request) {
 
 	recipients := []Recipient{}
 	for rows.Next() {
-		var recipient Recipient
-		var bankName, description sql.NullString
-
-		err := rows.Scan(
-			&recipient.ID,
-			&recipient.RecipientCode,
-			&recipient.Type,
-			&recipient.Name,
-			&recipient.AccountNumber,
-			&recipient.BankCode,
-			&bankName,
-			&recipient.Currency,
-			&description,
-			&recipient.CreatedAt,
-			&recipient.UpdatedAt,
-		)
+		recipient, err := scanRecipient(rows)
 		if err != nil {
 			WriteJSONError(w, fmt.Errorf("failed to scan recipient: %w", err), http.StatusInternalServerError)
 			return
 		}
-
-		if bankName.Valid {
-			recipient.BankName = bankName.String
-		}
-		if description.Valid {
-			recipient.Description = description.String
-		}
-
-		recipients = append(recipients, recipient)
+		recipients = append(recipients, *recipient)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -207,6 +451,7 @@ func (h *RecipientHandler) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	w.Header().Set("X-Total-Count", strconv.Itoa(total))
 	WriteJSONSuccess(w, recipients)
 }
 
@@ -218,40 +463,314 @@ func (h *RecipientHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	query := `
-		SELECT id, recipient_code, type, name, account_number, bank_code, bank_name, currency, description, created_at, updated_at
-		FROM recipients
+	recipient, err := getRecipientByCode(recipientCode)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("recipient not found: %s", recipientCode), http.StatusNotFound)
+		return
+	}
+
+	WriteJSONSuccess(w, recipient)
+}
+
+// UpdateRecipientRequest holds the recipient fields that can be changed.
+// Omitted fields keep their current values; bank details cannot be changed,
+// create a new recipient and deactivate the old one instead.
+type UpdateRecipientRequest struct {
+	Name        *string                `json:"name,omitempty"`
+	Description *string                `json:"description,omitempty"`
+	Email       *string                `json:"email,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Update updates a recipient in Paystack and refreshes the local cache
+func (h *RecipientHandler) Update(w http.ResponseWriter, r *http.Request) {
+	recipientCode := chi.URLParam(r, "recipient_code")
+
+	var req UpdateRecipientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.Name == nil && req.Description == nil && req.Email == nil && req.Metadata == nil {
+		WriteJSONBadRequest(w, "at least one of name, description, email or metadata is required")
+		return
+	}
+
+	recipient, err := getRecipientByCode(recipientCode)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("recipient not found: %s", recipientCode), http.StatusNotFound)
+		return
+	}
+
+	if req.Name != nil {
+		if strings.TrimSpace(*req.Name) == "" {
+			WriteJSONBadRequest(w, "name cannot be empty")
+			return
+		}
+		recipient.Name = *req.Name
+	}
+	if req.Description != nil {
+		recipient.Description = *req.Description
+	}
+	if req.Email != nil {
+		recipient.Email = *req.Email
+	}
+	if req.Metadata != nil {
+		recipient.Metadata = req.Metadata
+	}
+
+	// Local-only recipients (e.g. RCP_serviceprovider) do not exist in Paystack
+	if !recipient.isLocalOnly() {
+		_, err = h.client.UpdateTransferRecipient(recipientCode, &paystack.UpdateTransferRecipientRequest{
+			Name:        recipient.Name,
+			Email:       recipient.Email,
+			Description: recipient.Description,
+			Metadata:    recipient.Metadata,
+		})
+		if err != nil {
+			WriteJSONError(w, fmt.Errorf("failed to update recipient in Paystack: %w", err), http.StatusBadGateway)
+			return
+		}
+	}
+
+	var metadataJSON interface{}
+	if recipient.Metadata != nil {
+		encoded, err := json.Marshal(recipient.Metadata)
+		if err != nil {
+			WriteJSONBadRequest(w, "metadata must be a JSON object")
+			return
+		}
+		metadataJSON = string(encoded)
+	}
+
+	_, err = database.DB.Exec(`
+		UPDATE recipients
+		SET name = ?, description = ?, email = ?, metadata = ?, updated_at = ?
 		WHERE recipient_code = ?
-	`
+	`, recipient.Name, recipient.Description, recipient.Email, metadataJSON, time.Now(), recipientCode)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("recipient updated in Paystack but cache update failed: %w", err), http.StatusInternalServerError)
+		return
+	}
 
-	var recipient Recipient
-	var bankName, description sql.NullString
+	updated, err := getRecipientByCode(recipientCode)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to load updated recipient: %w", err), http.StatusInternalServerError)
+		return
+	}
 
-	err := database.DB.QueryRow(query, recipientCode).Scan(
-		&recipient.ID,
-		&recipient.RecipientCode,
-		&recipient.Type,
-		&recipient.Name,
-		&recipient.AccountNumber,
-		&recipient.BankCode,
-		&bankName,
-		&recipient.Currency,
-		&description,
-		&recipient.CreatedAt,
-		&recipient.UpdatedAt,
+	WriteJSONSuccess(w, updated)
+}
+
+// Deactivate deactivates a recipient in Paystack and marks it inactive in the cache.
+// The cached row is kept so existing expenses still resolve.
+func (h *RecipientHandler) Deactivate(w http.ResponseWriter, r *http.Request) {
+	recipientCode := chi.URLParam(r, "recipient_code")
+
+	recipient, err := getRecipientByCode(recipientCode)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("recipient not found: %s", recipientCode), http.StatusNotFound)
+		return
+	}
+
+	if !recipient.Active {
+		WriteJSONSuccess(w, recipient)
+		return
+	}
+
+	if err := h.deactivateUpstream(recipient); err != nil {
+		WriteJSONError(w, err, http.StatusBadGateway)
+		return
+	}
+
+	now := time.Now()
+	_, err = database.DB.Exec(
+		"UPDATE recipients SET active = 0, deactivated_at = ?, updated_at = ? WHERE recipient_code = ?",
+		now, now, recipientCode,
 	)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("recipient deactivated in Paystack but cache update failed: %w", err), http.StatusInternalServerError)
+		return
+	}
 
+	updated, err := getRecipientByCode(recipientCode)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to load updated recipient: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, updated)
+}
+
+// Delete deletes a recipient in Paystack and removes it from the cache.
+// Recipients referenced by expenses cannot be deleted; deactivate them instead.
+func (h *RecipientHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	recipientCode := chi.URLParam(r, "recipient_code")
+
+	if recipientCode == "RCP_serviceprovider" {
+		WriteJSONBadRequest(w, "the default service provider recipient cannot be deleted")
+		return
+	}
+
+	recipient, err := getRecipientByCode(recipientCode)
 	if err != nil {
 		WriteJSONError(w, fmt.Errorf("recipient not found: %s", recipientCode), http.StatusNotFound)
 		return
 	}
 
-	if bankName.Valid {
-		recipient.BankName = bankName.String
+	var expenseCount int
+	err = database.DB.QueryRow("SELECT COUNT(*) FROM expenses WHERE recipient_code = ?", recipientCode).Scan(&expenseCount)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to check recipient expenses: %w", err), http.StatusInternalServerError)
+		return
 	}
-	if description.Valid {
-		recipient.Description = description.String
+	if expenseCount > 0 {
+		WriteJSONError(w, fmt.Errorf("recipient %s is referenced by %d expense(s); deactivate it instead", recipientCode, expenseCount), http.StatusConflict)
+		return
 	}
 
-	WriteJSONSuccess(w, recipient)
+	if recipient.Active {
+		if err := h.deactivateUpstream(recipient); err != nil {
+			WriteJSONError(w, err, http.StatusBadGateway)
+			return
+		}
+	}
+
+	if _, err := database.DB.Exec("DELETE FROM recipients WHERE recipient_code = ?", recipientCode); err != nil {
+		WriteJSONError(w, fmt.Errorf("recipient deleted in Paystack but cache delete failed: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, map[string]interface{}{
+		"recipient_code": recipientCode,
+		"deleted":        true,
+	})
+}
+
+// deactivateUpstream calls the Paystack delete recipient API, which sets the
+// recipient inactive. Local-only and already-deleted recipients are skipped.
+func (h *RecipientHandler) deactivateUpstream(recipient *Recipient) error {
+	if recipient.isLocalOnly() || recipient.DeletedUpstream {
+		return nil
+	}
+
+	if _, err := h.client.DeleteTransferRecipient(recipient.RecipientCode); err != nil {
+		return fmt.Errorf("failed to deactivate recipient in Paystack: %w", err)
+	}
+	return nil
+}
+
+// isLocalOnly reports whether the recipient exists only in the local cache
+func (r *Recipient) isLocalOnly() bool {
+	return r.Origin == "local"
+}
+
+// getRecipientByCode looks up a cached recipient by recipient_code
+func getRecipientByCode(recipientCode string) (*Recipient, error) {
+	query := "SELECT " + recipientColumns + " FROM recipients WHERE recipient_code = ?"
+	return scanRecipient(database.DB.QueryRow(query, recipientCode))
+}
+
+// verifyAccountName scores the requested name against the resolved account
+// name and classifies it as verified, flagged or rejected
+func (h *RecipientHandler) verifyAccountName(requestedName, resolvedName string) RecipientVerification {
+	score := nameMatchScore(requestedName, resolvedName)
+
+	status := RecipientVerified
+	if score < h.nameMatch.RejectThreshold {
+		status = RecipientRejected
+	} else if score < h.nameMatch.VerifyThreshold {
+		status = RecipientFlagged
+	}
+
+	return RecipientVerification{
+		Status:              status,
+		RequestedName:       requestedName,
+		ResolvedAccountName: resolvedName,
+		NameMatchScore:      score,
+		VerifyThreshold:     h.nameMatch.VerifyThreshold,
+		RejectThreshold:     h.nameMatch.RejectThreshold,
+	}
+}
+
+// nameMatchScore returns a 0-1 similarity score between two personal or
+// business names. Bank account names are often upper-case and in a different
+// order ("DOE JOHN A"), so names are normalised into sorted tokens and the
+// better of a token overlap score and an edit-distance score is used.
+func nameMatchScore(a, b string) float64 {
+	tokensA := nameTokens(a)
+	tokensB := nameTokens(b)
+	if len(tokensA) == 0 || len(tokensB) == 0 {
+		return 0
+	}
+
+	// Token overlap: share of the shorter name's tokens found in the longer
+	// one. A single shared token is measured against the longer name instead,
+	// so a lone first name cannot fully match a three-part account name.
+	setB := make(map[string]bool, len(tokensB))
+	for _, t := range tokensB {
+		setB[t] = true
+	}
+	common := 0
+	for _, t := range tokensA {
+		if setB[t] {
+			common++
+		}
+	}
+	shorter, longer := len(tokensA), len(tokensB)
+	if shorter > longer {
+		shorter, longer = longer, shorter
+	}
+	overlap := float64(common) / float64(shorter)
+	if common < 2 {
+		overlap = float64(common) / float64(longer)
+	}
+
+	// Edit distance over the sorted, joined tokens catches small typos
+	joinedA := strings.Join(tokensA, " ")
+	joinedB := strings.Join(tokensB, " ")
+	longest := len([]rune(joinedA))
+	if n := len([]rune(joinedB)); n > longest {
+		longest = n
+	}
+	similarity := 1 - float64(levenshtein(joinedA, joinedB))/float64(longest)
+
+	if overlap > similarity {
+		return overlap
+	}
+	return similarity
+}
+
+// nameTokens lower-cases a name, strips punctuation and returns sorted tokens
+func nameTokens(name string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	sort.Strings(fields)
+	return fields
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
 }
@@ -0,0 +1,51 @@
+package handlers
+
+import "testing"
+
+func TestNameMatchScore(t *testing.T) {
+	tests := []struct {
+		requested string
+		resolved  string
+		minScore  float64
+		maxScore  float64
+	}{
+		{"John Doe", "JOHN DOE", 1, 1},
+		{"John Doe", "DOE JOHN ADEWALE", 1, 1},
+		{"Jon Doe", "DOE JOHN", 0.85, 0.99},
+		{"Tech Innovations Ltd.", "TECH INNOVATIONS LIMITED", 0.6, 0.85},
+		{"Jane Smith", "MICHAEL JOHNSON", 0, 0.4},
+		{"John", "JOHN ADEBAYO OKAFOR", 0, 0.5},
+		{"John", "JOHN", 1, 1},
+		{"John", "JOHNSON", 0, 0.6},
+		{"Ade Okafor", "ADEBAYO OKAFOR", 0, 0.84},
+		{"", "JOHN DOE", 0, 0},
+	}
+
+	for _, tt := range tests {
+		score := nameMatchScore(tt.requested, tt.resolved)
+		if score < tt.minScore || score > tt.maxScore {
+			t.Errorf("nameMatchScore(%q, %q) = %.2f, want between %.2f and %.2f",
+				tt.requested, tt.resolved, score, tt.minScore, tt.maxScore)
+		}
+	}
+}
+
+func TestVerifyAccountName(t *testing.T) {
+	h := NewRecipientHandler(nil, NameMatchPolicy{VerifyThreshold: 0.85, RejectThreshold: 0.5}, nil)
+
+	if v := h.verifyAccountName("John Doe", "DOE JOHN"); v.Status != RecipientVerified {
+		t.Errorf("Expected verified, got %s (score %.2f)", v.Status, v.NameMatchScore)
+	}
+
+	if v := h.verifyAccountName("Tech Innovations Ltd", "TECH INNOVATIONS LIMITED"); v.Status != RecipientFlagged {
+		t.Errorf("Expected flagged, got %s (score %.2f)", v.Status, v.NameMatchScore)
+	}
+
+	if v := h.verifyAccountName("John", "JOHN ADEBAYO OKAFOR"); v.Status == RecipientVerified {
+		t.Errorf("Expected a single first name not to verify, got score %.2f", v.NameMatchScore)
+	}
+
+	if v := h.verifyAccountName("Jane Smith", "MICHAEL JOHNSON"); v.Status != "rejected" {
+		t.Errorf("Expected rejected, got %s (score %.2f)", v.Status, v.NameMatchScore)
+	}
+}
@@ -0,0 +1,1106 @@
+// Package handlers implements HTTP handlers for the moniewave financial management system.
+//
+// Reconciliation Handler - Financial Management Core
+//
+// OBJECTIVES:
+// Every local payment record should have a Paystack counterpart, and every Paystack movement a local record.
+//
+// PURPOSE:
+// - Match expenses and initiated transfers to Paystack transfers
+// - Match Paystack invoice payments to Paystack transactions (charges)
+// - Flag unmatched items on both sides, amount mismatches and status disagreements
+// - Let users match or unmatch records by hand when the matcher gets it wrong
+// - Keep a history of reconciliation runs and their findings
+//
+// KEY WORKFLOW:
+// Load Local Records And Ledger Entries For The Period → Apply Manual Matches →
+// Match By Reference → Match By Amount, Counterparty And Date → Compare Amounts And Statuses →
+// Flag Leftovers → Store Run And Items
+//
+// DESIGN DECISIONS:
+// - The Paystack side is the local transaction ledger, not the live API, so a
+//   run is fast and repeatable; run a ledger sync first (sync: true) for fresh data
+// - Each kind of local record is matched on its own: an expense and the transfer
+//   initiated for it may both match the same Paystack transfer
+// - A Paystack entry is unmatched only if no local record of any kind matched
+//   it; failed, abandoned and reversed Paystack entries are never flagged as unmatched
+// - Expenses from bank statement imports and offline invoice payments never
+//   went through Paystack and are left out
+// - Statuses are compared as settled, pending or failed, since each table
+//   uses its own words
+// - Manual matches win over every automatic match; an unmatch stops the matcher
+//   from pairing those two records again. Both re-run the reconciliation.
+// - Ledger entries just outside the period are still candidates for matching,
+//   so records near the edges are not reported as unmatched
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+	"paystack.mpc.proxy/internal/money"
+	"paystack.mpc.proxy/internal/paystack"
+)
+
+// reconcileMatchWindow is how far apart a local record and a Paystack entry
+// may be dated and still match on amount alone
+const reconcileMatchWindow = 48 * time.Hour
+
+// reconcileDefaultPeriod is how far back a run looks when no period is given
+const reconcileDefaultPeriod = 30 * 24 * time.Hour
+
+// reconcileKeepRuns is how many runs keep their items; older runs keep only
+// their summary
+const reconcileKeepRuns = 50
+
+var reconcileMu sync.Mutex
+
+// Local record types
+const (
+	ReconcileLocalExpense        = "expense"
+	ReconcileLocalTransfer       = "transfer"
+	ReconcileLocalInvoicePayment = "invoice_payment"
+)
+
+// reconcileLedgerType is the ledger entry type each local record type matches
+var reconcileLedgerType = map[string]string{
+	ReconcileLocalExpense:        LedgerTypeTransfer,
+	ReconcileLocalTransfer:       LedgerTypeTransfer,
+	ReconcileLocalInvoicePayment: LedgerTypeCharge,
+}
+
+// Reconciliation item kinds
+const (
+	ReconcileMatched           = "matched"
+	ReconcileUnmatchedLocal    = "unmatched_local"
+	ReconcileUnmatchedPaystack = "unmatched_paystack"
+	ReconcileAmountMismatch    = "amount_mismatch"
+	ReconcileStatusMismatch    = "status_mismatch"
+)
+
+// Match methods
+const (
+	ReconcileByManual    = "manual"
+	ReconcileByReference = "reference"
+	ReconcileByAmount    = "amount_date"
+)
+
+// Override actions
+const (
+	reconcileOverrideMatch   = "match"
+	reconcileOverrideUnmatch = "unmatch"
+)
+
+// Run triggers
+const (
+	ReconcileTriggerSchedule = "schedule"
+	ReconcileTriggerAPI      = "api"
+	ReconcileTriggerMatch    = "match"
+	ReconcileTriggerUnmatch  = "unmatch"
+)
+
+type ReconciliationHandler struct {
+	client *paystack.Client
+}
+
+func NewReconciliationHandler(client *paystack.Client) *ReconciliationHandler {
+	return &ReconciliationHandler{client: client}
+}
+
+// reconLocal is a local record being reconciled
+type reconLocal struct {
+	Type       string
+	ID         int
+	References []string
+	Amount     int
+	Currency   string
+	Status     string
+	Date       time.Time
+	// Counterparty is the recipient code (transfers) or customer email (charges)
+	Counterparty string
+}
+
+func (l *reconLocal) key() string {
+	return fmt.Sprintf("%s:%d", l.Type, l.ID)
+}
+
+func (l *reconLocal) reference() string {
+	if len(l.References) == 0 {
+		return ""
+	}
+	return l.References[0]
+}
+
+// reconLedger is a Paystack ledger entry being reconciled
+type reconLedger struct {
+	ID           int
+	Type         string
+	Reference    string
+	Amount       int
+	Currency     string
+	Status       string
+	Date         time.Time
+	Counterparty string
+}
+
+// reconOverrides are the manual decisions the matcher must respect
+type reconOverrides struct {
+	// Match maps a local record key to the ledger entry it was matched to
+	Match map[string]int
+	// Unmatch holds "local key|ledger id" pairs that must not be matched
+	Unmatch map[string]bool
+}
+
+func (o reconOverrides) blocked(l *reconLocal, e *reconLedger) bool {
+	return o.Unmatch[fmt.Sprintf("%s|%d", l.key(), e.ID)]
+}
+
+// reconPair is a local record and the ledger entry it matched
+type reconPair struct {
+	Local  *reconLocal
+	Ledger *reconLedger
+	Method string
+}
+
+// ReconciliationItem is one finding of a run
+type ReconciliationItem struct {
+	ID                int        `json:"id"`
+	RunID             int        `json:"run_id"`
+	Kind              string     `json:"kind"`
+	LocalType         string     `json:"local_type,omitempty"`
+	LocalID           *int       `json:"local_id,omitempty"`
+	LocalReference    string     `json:"local_reference,omitempty"`
+	LocalAmount       *int       `json:"local_amount,omitempty"`
+	LocalStatus       string     `json:"local_status,omitempty"`
+	LocalDate         *time.Time `json:"local_date,omitempty"`
+	LedgerID          *int       `json:"ledger_id,omitempty"`
+	PaystackType      string     `json:"paystack_type,omitempty"`
+	PaystackReference string     `json:"paystack_reference,omitempty"`
+	PaystackAmount    *int       `json:"paystack_amount,omitempty"`
+	PaystackStatus    string     `json:"paystack_status,omitempty"`
+	PaystackDate      *time.Time `json:"paystack_date,omitempty"`
+	Currency          string     `json:"currency"`
+	MatchMethod       string     `json:"match_method,omitempty"`
+	Details           string     `json:"details,omitempty"`
+}
+
+// ReconciliationSummary counts a run's findings
+type ReconciliationSummary struct {
+	LocalRecords      int            `json:"local_records"`
+	PaystackEntries   int            `json:"paystack_entries"`
+	Matched           int            `json:"matched"`
+	ByMethod          map[string]int `json:"by_method"`
+	UnmatchedLocal    int            `json:"unmatched_local"`
+	UnmatchedPaystack int            `json:"unmatched_paystack"`
+	AmountMismatches  int            `json:"amount_mismatches"`
+	StatusMismatches  int            `json:"status_mismatches"`
+}
+
+// ReconciliationRun is one reconciliation of a period
+type ReconciliationRun struct {
+	ID         int                    `json:"id"`
+	Trigger    string                 `json:"trigger"`
+	From       time.Time              `json:"from"`
+	To         time.Time              `json:"to"`
+	Status     string                 `json:"status"`
+	Summary    *ReconciliationSummary `json:"summary,omitempty"`
+	StartedAt  time.Time              `json:"started_at"`
+	FinishedAt *time.Time             `json:"finished_at,omitempty"`
+	Items      []*ReconciliationItem  `json:"items,omitempty"`
+}
+
+type RunReconciliationRequest struct {
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// Sync runs an incremental ledger sync before reconciling
+	Sync bool `json:"sync,omitempty"`
+}
+
+type ReconciliationMatchRequest struct {
+	LocalType string `json:"local_type"`
+	LocalID   int    `json:"local_id"`
+	LedgerID  int    `json:"ledger_id,omitempty"`
+	// PaystackReference can be given instead of ledger_id
+	PaystackReference string `json:"paystack_reference,omitempty"`
+	Note              string `json:"note,omitempty"`
+}
+
+// reconStatus reduces a status to settled, pending or failed ("" when unknown)
+func reconStatus(status string) string {
+	switch strings.ToLower(strings.TrimSpace(status)) {
+	case "":
+		return ""
+	case "success", "successful", "paid", "completed", "settled":
+		return "settled"
+	case "failed", "cancelled", "reversed", "rejected", "abandoned":
+		return "failed"
+	default:
+		return "pending"
+	}
+}
+
+// matchRecords pairs local records of one type with ledger entries: manual
+// matches first, then by reference, then by amount, currency, counterparty
+// and date within reconcileMatchWindow (closest date first). Each ledger
+// entry is matched at most once.
+func matchRecords(locals []*reconLocal, ledger []*reconLedger, overrides reconOverrides) []*reconPair {
+	byID := map[int]*reconLedger{}
+	byReference := map[string]*reconLedger{}
+	for _, e := range ledger {
+		byID[e.ID] = e
+		if e.Reference != "" {
+			byReference[e.Reference] = e
+		}
+	}
+
+	pairs := []*reconPair{}
+	used := map[int]bool{}
+	matched := map[string]bool{}
+	pair := func(l *reconLocal, e *reconLedger, method string) {
+		pairs = append(pairs, &reconPair{Local: l, Ledger: e, Method: method})
+		used[e.ID] = true
+		matched[l.key()] = true
+	}
+
+	for _, l := range locals {
+		if id, ok := overrides.Match[l.key()]; ok {
+			if e := byID[id]; e != nil && !used[e.ID] {
+				pair(l, e, ReconcileByManual)
+			}
+		}
+	}
+
+	for _, l := range locals {
+		if matched[l.key()] {
+			continue
+		}
+		for _, ref := range l.References {
+			if e := byReference[ref]; ref != "" && e != nil && !used[e.ID] && !overrides.blocked(l, e) {
+				pair(l, e, ReconcileByReference)
+				break
+			}
+		}
+	}
+
+	remaining := []*reconLocal{}
+	for _, l := range locals {
+		if !matched[l.key()] {
+			remaining = append(remaining, l)
+		}
+	}
+	sort.SliceStable(remaining, func(i, j int) bool { return remaining[i].Date.Before(remaining[j].Date) })
+
+	for _, l := range remaining {
+		var best *reconLedger
+		var bestGap time.Duration
+		for _, e := range ledger {
+			if used[e.ID] || e.Amount != l.Amount || !strings.EqualFold(e.Currency, l.Currency) || overrides.blocked(l, e) {
+				continue
+			}
+			if l.Counterparty != "" && e.Counterparty != "" && !strings.EqualFold(l.Counterparty, e.Counterparty) {
+				continue
+			}
+			gap := e.Date.Sub(l.Date)
+			if gap < 0 {
+				gap = -gap
+			}
+			if gap > reconcileMatchWindow {
+				continue
+			}
+			if best == nil || gap < bestGap {
+				best, bestGap = e, gap
+			}
+		}
+		if best != nil {
+			pair(l, best, ReconcileByAmount)
+		}
+	}
+
+	return pairs
+}
+
+// reconcile compares local records with ledger entries. Local records are
+// expected to lie within [from, to); ledger entries outside it are only used
+// for matching.
+func reconcile(locals []*reconLocal, ledger []*reconLedger, overrides reconOverrides, from, to time.Time) ([]*ReconciliationItem, *ReconciliationSummary) {
+	summary := &ReconciliationSummary{LocalRecords: len(locals), ByMethod: map[string]int{}}
+	items := []*ReconciliationItem{}
+
+	ledgerByType := map[string][]*reconLedger{}
+	for _, e := range ledger {
+		ledgerByType[e.Type] = append(ledgerByType[e.Type], e)
+		if !e.Date.Before(from) && e.Date.Before(to) {
+			summary.PaystackEntries++
+		}
+	}
+	localsByType := map[string][]*reconLocal{}
+	for _, l := range locals {
+		localsByType[l.Type] = append(localsByType[l.Type], l)
+	}
+
+	localTypes := []string{ReconcileLocalExpense, ReconcileLocalTransfer, ReconcileLocalInvoicePayment}
+	matchedLedger := map[int]bool{}
+	for _, localType := range localTypes {
+		pairs := matchRecords(localsByType[localType], ledgerByType[reconcileLedgerType[localType]], overrides)
+		paired := map[string]bool{}
+
+		for _, p := range pairs {
+			paired[p.Local.key()] = true
+			matchedLedger[p.Ledger.ID] = true
+			summary.Matched++
+			summary.ByMethod[p.Method]++
+
+			kinds := []string{}
+			details := []string{}
+			if p.Local.Amount != p.Ledger.Amount || !strings.EqualFold(p.Local.Currency, p.Ledger.Currency) {
+				kinds = append(kinds, ReconcileAmountMismatch)
+				details = append(details, fmt.Sprintf("local %s %s, Paystack %s %s",
+					p.Local.Currency, money.Decimal(p.Local.Amount), p.Ledger.Currency, money.Decimal(p.Ledger.Amount)))
+				summary.AmountMismatches++
+			}
+			localStatus, paystackStatus := reconStatus(p.Local.Status), reconStatus(p.Ledger.Status)
+			if localStatus != "" && paystackStatus != "" && localStatus != paystackStatus {
+				kinds = append(kinds, ReconcileStatusMismatch)
+				details = append(details, fmt.Sprintf("local record is %s, Paystack %s is %s", localStatus, p.Ledger.Type, paystackStatus))
+				summary.StatusMismatches++
+			}
+			if len(kinds) == 0 {
+				kinds = append(kinds, ReconcileMatched)
+				details = append(details, "")
+			}
+			for i, kind := range kinds {
+				item := reconItem(kind, p.Local, p.Ledger)
+				item.MatchMethod = p.Method
+				item.Details = details[i]
+				items = append(items, item)
+			}
+		}
+
+		for _, l := range localsByType[localType] {
+			if paired[l.key()] {
+				continue
+			}
+			item := reconItem(ReconcileUnmatchedLocal, l, nil)
+			item.Details = fmt.Sprintf("no Paystack %s matches this %s", reconcileLedgerType[localType], strings.ReplaceAll(localType, "_", " "))
+			items = append(items, item)
+			summary.UnmatchedLocal++
+		}
+	}
+
+	for _, e := range ledger {
+		if matchedLedger[e.ID] || e.Date.Before(from) || !e.Date.Before(to) || reconStatus(e.Status) == "failed" {
+			continue
+		}
+		item := reconItem(ReconcileUnmatchedPaystack, nil, e)
+		if e.Type == LedgerTypeTransfer {
+			item.Details = "no local expense or transfer matches this Paystack transfer"
+		} else {
+			item.Details = "no local invoice payment matches this Paystack transaction"
+		}
+		items = append(items, item)
+		summary.UnmatchedPaystack++
+	}
+
+	return items, summary
+}
+
+func reconItem(kind string, l *reconLocal, e *reconLedger) *ReconciliationItem {
+	item := &ReconciliationItem{Kind: kind}
+	if l != nil {
+		id, amount, date := l.ID, l.Amount, l.Date
+		item.LocalType = l.Type
+		item.LocalID = &id
+		item.LocalReference = l.reference()
+		item.LocalAmount = &amount
+		item.LocalStatus = l.Status
+		item.LocalDate = &date
+		item.Currency = l.Currency
+	}
+	if e != nil {
+		id, amount, date := e.ID, e.Amount, e.Date
+		item.LedgerID = &id
+		item.PaystackType = e.Type
+		item.PaystackReference = e.Reference
+		item.PaystackAmount = &amount
+		item.PaystackStatus = e.Status
+		item.PaystackDate = &date
+		if item.Currency == "" {
+			item.Currency = e.Currency
+		}
+	}
+	return item
+}
+
+// loadReconLocals reads the local records dated within [from, to)
+func loadReconLocals(from, to time.Time) ([]*reconLocal, error) {
+	locals := []*reconLocal{}
+
+	// Expenses: date is the payment date when set
+	rows, err := database.DB.Query(`
+		SELECT id, COALESCE(reference, ''), amount, COALESCE(currency, 'NGN'), COALESCE(status, ''),
+		       payment_date, created_at, COALESCE(recipient_code, '')
+		FROM expenses
+		WHERE COALESCE(source, 'manual') != ?
+		  AND COALESCE(payment_date, created_at) >= ? AND COALESCE(payment_date, created_at) < ?
+	`, ExpenseSourceBankImport, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query expenses: %w", err)
+	}
+	for rows.Next() {
+		l := &reconLocal{Type: ReconcileLocalExpense}
+		var reference string
+		var paymentDate, createdAt sql.NullTime
+		if err := rows.Scan(&l.ID, &reference, &l.Amount, &l.Currency, &l.Status, &paymentDate, &createdAt, &l.Counterparty); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan expense: %w", err)
+		}
+		l.References = []string{reference}
+		l.Date = createdAt.Time
+		if paymentDate.Valid {
+			l.Date = paymentDate.Time
+		}
+		locals = append(locals, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Transfers initiated through this server
+	rows, err = database.DB.Query(`
+		SELECT id, COALESCE(reference, ''), COALESCE(transfer_code, ''), amount, COALESCE(currency, 'NGN'),
+		       COALESCE(status, ''), created_at, recipient_code
+		FROM transfers
+		WHERE created_at >= ? AND created_at < ?
+	`, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transfers: %w", err)
+	}
+	for rows.Next() {
+		l := &reconLocal{Type: ReconcileLocalTransfer}
+		var reference, transferCode string
+		if err := rows.Scan(&l.ID, &reference, &transferCode, &l.Amount, &l.Currency, &l.Status, &l.Date, &l.Counterparty); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan transfer: %w", err)
+		}
+		// The ledger uses the transfer code when a transfer has no reference
+		l.References = []string{reference, transferCode}
+		locals = append(locals, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Paystack invoice payments; a recorded payment is settled by definition
+	rows, err = database.DB.Query(`
+		SELECT p.id, p.reference, p.amount, COALESCE(i.currency, 'NGN'), p.paid_at, COALESCE(i.customer_email, '')
+		FROM invoice_payments p
+		LEFT JOIN invoices i ON i.invoice_code = p.invoice_code
+		WHERE p.source = ? AND p.paid_at >= ? AND p.paid_at < ?
+	`, PaymentSourcePaystack, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query invoice payments: %w", err)
+	}
+	for rows.Next() {
+		l := &reconLocal{Type: ReconcileLocalInvoicePayment, Status: "success"}
+		var reference string
+		if err := rows.Scan(&l.ID, &reference, &l.Amount, &l.Currency, &l.Date, &l.Counterparty); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan invoice payment: %w", err)
+		}
+		l.References = []string{reference}
+		locals = append(locals, l)
+	}
+	rows.Close()
+	return locals, rows.Err()
+}
+
+// loadReconLedger reads the ledger entries dated within [from, to) plus any
+// entry a manual match points to
+func loadReconLedger(from, to time.Time, overrides reconOverrides) ([]*reconLedger, error) {
+	query := `
+		SELECT id, type, reference, amount, COALESCE(currency, 'NGN'), COALESCE(status, ''), occurred_at,
+		       CASE WHEN type = ? THEN COALESCE(recipient_code, '') ELSE COALESCE(customer_email, '') END
+		FROM transactions
+		WHERE (occurred_at >= ? AND occurred_at < ?)`
+	args := []interface{}{LedgerTypeTransfer, from.UTC(), to.UTC()}
+	if len(overrides.Match) > 0 {
+		placeholders := []string{}
+		for _, id := range overrides.Match {
+			placeholders = append(placeholders, "?")
+			args = append(args, id)
+		}
+		query += " OR id IN (" + joinStrings(placeholders, ", ") + ")"
+	}
+
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ledger: %w", err)
+	}
+	defer rows.Close()
+
+	ledger := []*reconLedger{}
+	for rows.Next() {
+		e := &reconLedger{}
+		if err := rows.Scan(&e.ID, &e.Type, &e.Reference, &e.Amount, &e.Currency, &e.Status, &e.Date, &e.Counterparty); err != nil {
+			return nil, fmt.Errorf("failed to scan ledger entry: %w", err)
+		}
+		ledger = append(ledger, e)
+	}
+	return ledger, rows.Err()
+}
+
+func loadReconOverrides() (reconOverrides, error) {
+	overrides := reconOverrides{Match: map[string]int{}, Unmatch: map[string]bool{}}
+	rows, err := database.DB.Query("SELECT local_type, local_id, ledger_id, action FROM reconciliation_overrides")
+	if err != nil {
+		return overrides, fmt.Errorf("failed to query reconciliation overrides: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var localType, action string
+		var localID, ledgerID int
+		if err := rows.Scan(&localType, &localID, &ledgerID, &action); err != nil {
+			return overrides, fmt.Errorf("failed to scan reconciliation override: %w", err)
+		}
+		key := fmt.Sprintf("%s:%d", localType, localID)
+		if action == reconcileOverrideMatch {
+			overrides.Match[key] = ledgerID
+		} else {
+			overrides.Unmatch[fmt.Sprintf("%s|%d", key, ledgerID)] = true
+		}
+	}
+	return overrides, rows.Err()
+}
+
+// RunReconciliation reconciles local records dated within [from, to) with the
+// ledger and stores the run. Only one run happens at a time.
+func RunReconciliation(from, to time.Time, trigger string) (*ReconciliationRun, error) {
+	if !reconcileMu.TryLock() {
+		return nil, ErrSyncInProgress
+	}
+	defer reconcileMu.Unlock()
+
+	run := &ReconciliationRun{Trigger: trigger, From: from, To: to, StartedAt: time.Now()}
+
+	overrides, err := loadReconOverrides()
+	if err != nil {
+		return nil, err
+	}
+	locals, err := loadReconLocals(from, to)
+	if err != nil {
+		return nil, err
+	}
+	ledger, err := loadReconLedger(from.Add(-reconcileMatchWindow), to.Add(reconcileMatchWindow), overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	run.Items, run.Summary = reconcile(locals, ledger, overrides, from, to)
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.Status = "success"
+
+	if err := saveReconciliationRun(run); err != nil {
+		return nil, fmt.Errorf("failed to save reconciliation run: %w", err)
+	}
+	return run, nil
+}
+
+func saveReconciliationRun(run *ReconciliationRun) error {
+	summary, err := json.Marshal(run.Summary)
+	if err != nil {
+		return err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO reconciliation_runs (triggered_by, period_from, period_to, status, summary, started_at, finished_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, run.Trigger, run.From, run.To, run.Status, string(summary), run.StartedAt, run.FinishedAt)
+	if err != nil {
+		return err
+	}
+	id, _ := result.LastInsertId()
+	run.ID = int(id)
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO reconciliation_items (
+			run_id, kind, local_type, local_id, local_reference, local_amount, local_status, local_date,
+			ledger_id, paystack_type, paystack_reference, paystack_amount, paystack_status, paystack_date,
+			currency, match_method, details
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, item := range run.Items {
+		item.RunID = run.ID
+		result, err := stmt.Exec(run.ID, item.Kind, item.LocalType, item.LocalID, item.LocalReference, item.LocalAmount,
+			item.LocalStatus, item.LocalDate, item.LedgerID, item.PaystackType, item.PaystackReference, item.PaystackAmount,
+			item.PaystackStatus, item.PaystackDate, item.Currency, item.MatchMethod, item.Details)
+		if err != nil {
+			return err
+		}
+		itemID, _ := result.LastInsertId()
+		item.ID = int(itemID)
+	}
+
+	// Older runs keep their summary but not their items
+	if _, err := tx.Exec(`
+		DELETE FROM reconciliation_items
+		WHERE run_id <= (SELECT id FROM reconciliation_runs ORDER BY id DESC LIMIT 1 OFFSET ?)
+	`, reconcileKeepRuns); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+const reconciliationRunColumns = "id, triggered_by, period_from, period_to, status, COALESCE(summary, ''), started_at, finished_at"
+
+func scanReconciliationRun(row rowScanner) (*ReconciliationRun, error) {
+	var run ReconciliationRun
+	var summary string
+	var finishedAt sql.NullTime
+	err := row.Scan(&run.ID, &run.Trigger, &run.From, &run.To, &run.Status, &summary, &run.StartedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+	if summary != "" {
+		run.Summary = &ReconciliationSummary{}
+		if err := json.Unmarshal([]byte(summary), run.Summary); err != nil {
+			return nil, fmt.Errorf("invalid summary for reconciliation run %d: %w", run.ID, err)
+		}
+	}
+	if finishedAt.Valid {
+		run.FinishedAt = &finishedAt.Time
+	}
+	return &run, nil
+}
+
+const reconciliationItemColumns = `id, run_id, kind, COALESCE(local_type, ''), local_id, COALESCE(local_reference, ''), local_amount,
+	COALESCE(local_status, ''), local_date, ledger_id, COALESCE(paystack_type, ''), COALESCE(paystack_reference, ''),
+	paystack_amount, COALESCE(paystack_status, ''), paystack_date, COALESCE(currency, ''), COALESCE(match_method, ''), COALESCE(details, '')`
+
+func scanReconciliationItem(row rowScanner) (*ReconciliationItem, error) {
+	var item ReconciliationItem
+	var localID, localAmount, ledgerID, paystackAmount sql.NullInt64
+	var localDate, paystackDate sql.NullTime
+	err := row.Scan(&item.ID, &item.RunID, &item.Kind, &item.LocalType, &localID, &item.LocalReference, &localAmount,
+		&item.LocalStatus, &localDate, &ledgerID, &item.PaystackType, &item.PaystackReference,
+		&paystackAmount, &item.PaystackStatus, &paystackDate, &item.Currency, &item.MatchMethod, &item.Details)
+	if err != nil {
+		return nil, err
+	}
+	item.LocalID = nullIntPtr(localID)
+	item.LocalAmount = nullIntPtr(localAmount)
+	item.LedgerID = nullIntPtr(ledgerID)
+	item.PaystackAmount = nullIntPtr(paystackAmount)
+	if localDate.Valid {
+		item.LocalDate = &localDate.Time
+	}
+	if paystackDate.Valid {
+		item.PaystackDate = &paystackDate.Time
+	}
+	return &item, nil
+}
+
+// reconcilePeriod parses a run's period: from and to are dates (YYYY-MM-DD),
+// to inclusive. The default is the last reconcileDefaultPeriod up to now.
+func reconcilePeriod(fromValue, toValue string) (time.Time, time.Time, error) {
+	to := time.Now().UTC()
+	if toValue != "" {
+		parsed, err := time.Parse("2006-01-02", toValue)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("to must be a date (YYYY-MM-DD)")
+		}
+		to = parsed.AddDate(0, 0, 1)
+	}
+	from := to.Add(-reconcileDefaultPeriod)
+	if fromValue != "" {
+		parsed, err := time.Parse("2006-01-02", fromValue)
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("from must be a date (YYYY-MM-DD)")
+		}
+		from = parsed
+	}
+	if !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New("from must not be after to")
+	}
+	return from, to, nil
+}
+
+// Run reconciles a period now. Body: from, to (dates, default the last 30
+// days) and sync (run a ledger sync first).
+func (h *ReconciliationHandler) Run(w http.ResponseWriter, r *http.Request) {
+	var req RunReconciliationRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJSONBadRequest(w, "Invalid request body")
+			return
+		}
+	}
+
+	from, to, err := reconcilePeriod(req.From, req.To)
+	if err != nil {
+		WriteJSONBadRequest(w, err.Error())
+		return
+	}
+
+	if req.Sync {
+		if _, err := SyncLedger(h.client, false); err != nil {
+			if errors.Is(err, ErrSyncInProgress) {
+				WriteJSONError(w, fmt.Errorf("ledger sync already in progress"), http.StatusConflict)
+				return
+			}
+			WriteJSONError(w, fmt.Errorf("ledger sync failed: %w", err), http.StatusBadGateway)
+			return
+		}
+	}
+
+	run, err := RunReconciliation(from, to, ReconcileTriggerAPI)
+	if err != nil {
+		if errors.Is(err, ErrSyncInProgress) {
+			WriteJSONError(w, fmt.Errorf("reconciliation already in progress"), http.StatusConflict)
+			return
+		}
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	run.Items = exceptionItems(run.Items)
+	WriteJSONSuccess(w, run)
+}
+
+// exceptionItems drops the items that need no attention
+func exceptionItems(items []*ReconciliationItem) []*ReconciliationItem {
+	exceptions := []*ReconciliationItem{}
+	for _, item := range items {
+		if item.Kind != ReconcileMatched {
+			exceptions = append(exceptions, item)
+		}
+	}
+	return exceptions
+}
+
+// Runs lists recent reconciliation runs without their items. Query params: limit (default 20).
+func (h *ReconciliationHandler) Runs(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 100 {
+			WriteJSONBadRequest(w, "limit must be between 1 and 100")
+			return
+		}
+		limit = parsed
+	}
+
+	rows, err := database.DB.Query("SELECT "+reconciliationRunColumns+" FROM reconciliation_runs ORDER BY id DESC LIMIT ?", limit)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to query reconciliation runs: %w", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	runs := []*ReconciliationRun{}
+	for rows.Next() {
+		run, err := scanReconciliationRun(rows)
+		if err != nil {
+			WriteJSONError(w, fmt.Errorf("failed to scan reconciliation run: %w", err), http.StatusInternalServerError)
+			return
+		}
+		runs = append(runs, run)
+	}
+
+	if err := rows.Err(); err != nil {
+		WriteJSONError(w, fmt.Errorf("error iterating reconciliation runs: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, runs)
+}
+
+// Report returns a run with its items, by default the latest run and only
+// the items that need attention. Query params: run_id, kind (a kind or "all"),
+// local_type.
+func (h *ReconciliationHandler) Report(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	var row *sql.Row
+	if value := query.Get("run_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			WriteJSONBadRequest(w, "run_id must be a number")
+			return
+		}
+		row = database.DB.QueryRow("SELECT "+reconciliationRunColumns+" FROM reconciliation_runs WHERE id = ?", id)
+	} else {
+		row = database.DB.QueryRow("SELECT " + reconciliationRunColumns + " FROM reconciliation_runs ORDER BY id DESC LIMIT 1")
+	}
+	run, err := scanReconciliationRun(row)
+	if err == sql.ErrNoRows {
+		WriteJSONError(w, fmt.Errorf("no reconciliation run found"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	where := " WHERE run_id = ?"
+	args := []interface{}{run.ID}
+	switch kind := query.Get("kind"); kind {
+	case "":
+		where += " AND kind != ?"
+		args = append(args, ReconcileMatched)
+	case "all":
+	case ReconcileMatched, ReconcileUnmatchedLocal, ReconcileUnmatchedPaystack, ReconcileAmountMismatch, ReconcileStatusMismatch:
+		where += " AND kind = ?"
+		args = append(args, kind)
+	default:
+		WriteJSONBadRequest(w, "kind must be matched, unmatched_local, unmatched_paystack, amount_mismatch, status_mismatch or all")
+		return
+	}
+	if localType := query.Get("local_type"); localType != "" {
+		if _, ok := reconcileLedgerType[localType]; !ok {
+			WriteJSONBadRequest(w, "local_type must be expense, transfer or invoice_payment")
+			return
+		}
+		where += " AND local_type = ?"
+		args = append(args, localType)
+	}
+
+	rows, err := database.DB.Query("SELECT "+reconciliationItemColumns+" FROM reconciliation_items"+where+" ORDER BY id", args...)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to query reconciliation items: %w", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	run.Items = []*ReconciliationItem{}
+	for rows.Next() {
+		item, err := scanReconciliationItem(rows)
+		if err != nil {
+			WriteJSONError(w, fmt.Errorf("failed to scan reconciliation item: %w", err), http.StatusInternalServerError)
+			return
+		}
+		run.Items = append(run.Items, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		WriteJSONError(w, fmt.Errorf("error iterating reconciliation items: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, run)
+}
+
+// resolveMatchRequest validates a match or unmatch request and returns the
+// ledger entry it names
+func resolveMatchRequest(req *ReconciliationMatchRequest, requireLedger bool) (int, error) {
+	ledgerType, ok := reconcileLedgerType[req.LocalType]
+	if !ok {
+		return 0, errors.New("local_type must be expense, transfer or invoice_payment")
+	}
+	if req.LocalID <= 0 {
+		return 0, errors.New("local_id is required")
+	}
+
+	table := map[string]string{
+		ReconcileLocalExpense:        "expenses",
+		ReconcileLocalTransfer:       "transfers",
+		ReconcileLocalInvoicePayment: "invoice_payments",
+	}[req.LocalType]
+	var exists int
+	if err := database.DB.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE id = ?", req.LocalID).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if exists == 0 {
+		return 0, fmt.Errorf("%s %d not found", strings.ReplaceAll(req.LocalType, "_", " "), req.LocalID)
+	}
+
+	if req.LedgerID == 0 && req.PaystackReference == "" {
+		if requireLedger {
+			return 0, errors.New("ledger_id or paystack_reference is required")
+		}
+		return 0, nil
+	}
+
+	var ledgerID int
+	var entryType string
+	var err error
+	if req.LedgerID > 0 {
+		err = database.DB.QueryRow("SELECT id, type FROM transactions WHERE id = ?", req.LedgerID).Scan(&ledgerID, &entryType)
+	} else {
+		err = database.DB.QueryRow("SELECT id, type FROM transactions WHERE reference = ? AND type = ?", req.PaystackReference, ledgerType).Scan(&ledgerID, &entryType)
+	}
+	if err == sql.ErrNoRows {
+		return 0, errors.New("Paystack ledger entry not found; sync the ledger first")
+	}
+	if err != nil {
+		return 0, err
+	}
+	if entryType != ledgerType {
+		return 0, fmt.Errorf("a %s can only be matched to a Paystack %s", strings.ReplaceAll(req.LocalType, "_", " "), ledgerType)
+	}
+	return ledgerID, nil
+}
+
+// rerunLatestPeriod reconciles the period of the latest run again, or the
+// default period when there has been none
+func rerunLatestPeriod(trigger string) (*ReconciliationRun, error) {
+	from, to, _ := reconcilePeriod("", "")
+	err := database.DB.QueryRow("SELECT period_from, period_to FROM reconciliation_runs ORDER BY id DESC LIMIT 1").Scan(&from, &to)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return RunReconciliation(from, to, trigger)
+}
+
+// itemsFor returns a run's items about one local record
+func itemsFor(run *ReconciliationRun, localType string, localID int) []*ReconciliationItem {
+	items := []*ReconciliationItem{}
+	for _, item := range run.Items {
+		if item.LocalType == localType && item.LocalID != nil && *item.LocalID == localID {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Match pairs a local record with a Paystack ledger entry by hand, replacing
+// whatever it was matched to, and re-runs the reconciliation
+func (h *ReconciliationHandler) Match(w http.ResponseWriter, r *http.Request) {
+	var req ReconciliationMatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+
+	ledgerID, err := resolveMatchRequest(&req, true)
+	if err != nil {
+		WriteJSONBadRequest(w, err.Error())
+		return
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	// One manual match per local record, and per ledger entry within a local type
+	if _, err := tx.Exec(`
+		DELETE FROM reconciliation_overrides
+		WHERE local_type = ? AND ((action = ? AND (local_id = ? OR ledger_id = ?)) OR (local_id = ? AND ledger_id = ?))
+	`, req.LocalType, reconcileOverrideMatch, req.LocalID, ledgerID, req.LocalID, ledgerID); err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to save match: %w", err), http.StatusInternalServerError)
+		return
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO reconciliation_overrides (local_type, local_id, ledger_id, action, note, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, req.LocalType, req.LocalID, ledgerID, reconcileOverrideMatch, req.Note, time.Now()); err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to save match: %w", err), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to save match: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	h.respondWithRerun(w, ReconcileTriggerMatch, req)
+}
+
+// Unmatch separates a local record from the Paystack entry it was matched to
+// and stops the matcher pairing them again. Without ledger_id the record's
+// current match in the latest run is used.
+func (h *ReconciliationHandler) Unmatch(w http.ResponseWriter, r *http.Request) {
+	var req ReconciliationMatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+
+	ledgerID, err := resolveMatchRequest(&req, false)
+	if err != nil {
+		WriteJSONBadRequest(w, err.Error())
+		return
+	}
+	if ledgerID == 0 {
+		err := database.DB.QueryRow(`
+			SELECT ledger_id FROM reconciliation_items
+			WHERE run_id = (SELECT MAX(id) FROM reconciliation_runs) AND local_type = ? AND local_id = ? AND ledger_id IS NOT NULL
+			LIMIT 1
+		`, req.LocalType, req.LocalID).Scan(&ledgerID)
+		if err == sql.ErrNoRows {
+			WriteJSONError(w, fmt.Errorf("%s %d is not matched in the latest reconciliation run", strings.ReplaceAll(req.LocalType, "_", " "), req.LocalID), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			WriteJSONError(w, err, http.StatusInternalServerError)
+			return
+		}
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM reconciliation_overrides WHERE local_type = ? AND local_id = ? AND ledger_id = ?",
+		req.LocalType, req.LocalID, ledgerID); err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to save unmatch: %w", err), http.StatusInternalServerError)
+		return
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO reconciliation_overrides (local_type, local_id, ledger_id, action, note, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, req.LocalType, req.LocalID, ledgerID, reconcileOverrideUnmatch, req.Note, time.Now()); err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to save unmatch: %w", err), http.StatusInternalServerError)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to save unmatch: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	h.respondWithRerun(w, ReconcileTriggerUnmatch, req)
+}
+
+// respondWithRerun re-runs the reconciliation after a manual change and
+// returns the run summary with the items about the changed record. The
+// change is saved even if the re-run fails; the next run picks it up.
+func (h *ReconciliationHandler) respondWithRerun(w http.ResponseWriter, trigger string, req ReconciliationMatchRequest) {
+	run, err := rerunLatestPeriod(trigger)
+	if err != nil {
+		log.Printf("Warning: reconciliation after %s failed: %v", trigger, err)
+		WriteJSONSuccess(w, map[string]interface{}{"saved": true, "rerun_error": err.Error()})
+		return
+	}
+	run.Items = itemsFor(run, req.LocalType, req.LocalID)
+	WriteJSONSuccess(w, map[string]interface{}{"saved": true, "run": run})
+}
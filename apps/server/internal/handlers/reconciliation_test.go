@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMatchRecords(t *testing.T) {
+	day := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	locals := []*reconLocal{
+		{Type: ReconcileLocalExpense, ID: 1, References: []string{"EXP_1"}, Amount: 5000, Currency: "NGN", Date: day},
+		{Type: ReconcileLocalExpense, ID: 2, References: []string{"EXP_2"}, Amount: 7000, Currency: "NGN", Date: day, Counterparty: "RCP_b"},
+		{Type: ReconcileLocalExpense, ID: 3, References: []string{"EXP_3"}, Amount: 7000, Currency: "NGN", Date: day, Counterparty: "RCP_c"},
+		{Type: ReconcileLocalExpense, ID: 4, References: []string{"EXP_4"}, Amount: 3000, Currency: "NGN", Date: day},
+	}
+	ledger := []*reconLedger{
+		{ID: 10, Type: LedgerTypeTransfer, Reference: "EXP_1", Amount: 5000, Currency: "NGN", Date: day},
+		{ID: 11, Type: LedgerTypeTransfer, Reference: "TRF_far", Amount: 7000, Currency: "NGN", Date: day.Add(72 * time.Hour), Counterparty: "RCP_b"},
+		{ID: 12, Type: LedgerTypeTransfer, Reference: "TRF_near", Amount: 7000, Currency: "NGN", Date: day.Add(2 * time.Hour), Counterparty: "RCP_b"},
+		{ID: 13, Type: LedgerTypeTransfer, Reference: "TRF_other", Amount: 9000, Currency: "NGN", Date: day},
+	}
+
+	pairs := matchRecords(locals, ledger, reconOverrides{Match: map[string]int{"expense:4": 13}})
+	got := map[int]string{}
+	for _, p := range pairs {
+		got[p.Local.ID] = p.Method + ":" + p.Ledger.Reference
+	}
+	want := map[int]string{
+		1: "reference:EXP_1",
+		2: "amount_date:TRF_near",
+		4: "manual:TRF_other",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("pairs = %v, want %v", got, want)
+	}
+	for id, w := range want {
+		if got[id] != w {
+			t.Errorf("expense %d matched %q, want %q", id, got[id], w)
+		}
+	}
+
+	blocked := reconOverrides{Unmatch: map[string]bool{"expense:1|10": true}}
+	for _, p := range matchRecords(locals[:1], ledger[:1], blocked) {
+		t.Errorf("unmatched pair was matched again by %s", p.Method)
+	}
+}
+
+func TestReconcileKinds(t *testing.T) {
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+	day := from.Add(24 * time.Hour)
+	locals := []*reconLocal{
+		{Type: ReconcileLocalTransfer, ID: 1, References: []string{"REF_1"}, Amount: 6500, Currency: "NGN", Status: "pending", Date: day},
+		{Type: ReconcileLocalInvoicePayment, ID: 1, References: []string{"PRQ_1-20000"}, Amount: 20000, Currency: "NGN", Status: "success", Date: day, Counterparty: "ada@example.com"},
+	}
+	ledger := []*reconLedger{
+		{ID: 1, Type: LedgerTypeTransfer, Reference: "REF_1", Amount: 6000, Currency: "NGN", Status: "success", Date: day},
+		{ID: 2, Type: LedgerTypeCharge, Reference: "T_1", Amount: 20000, Currency: "NGN", Status: "success", Date: day, Counterparty: "Ada@Example.com"},
+		{ID: 3, Type: LedgerTypeTransfer, Reference: "TRF_failed", Amount: 100, Currency: "NGN", Status: "failed", Date: day},
+		{ID: 4, Type: LedgerTypeCharge, Reference: "T_2", Amount: 100, Currency: "NGN", Status: "success", Date: day},
+		{ID: 5, Type: LedgerTypeCharge, Reference: "T_old", Amount: 100, Currency: "NGN", Status: "success", Date: from.Add(-time.Hour)},
+	}
+
+	items, summary := reconcile(locals, ledger, reconOverrides{}, from, to)
+	kinds := map[string]int{}
+	for _, item := range items {
+		kinds[item.Kind]++
+	}
+	if kinds[ReconcileAmountMismatch] != 1 || kinds[ReconcileStatusMismatch] != 1 || kinds[ReconcileMatched] != 1 {
+		t.Errorf("kinds = %v", kinds)
+	}
+	if kinds[ReconcileUnmatchedPaystack] != 1 || kinds[ReconcileUnmatchedLocal] != 0 {
+		t.Errorf("failed or out-of-period entries should not be flagged, kinds = %v", kinds)
+	}
+	if summary.Matched != 2 || summary.PaystackEntries != 4 || summary.ByMethod[ReconcileByAmount] != 1 {
+		t.Errorf("summary = %+v", summary)
+	}
+}
+
+func TestReconStatus(t *testing.T) {
+	cases := map[string]string{"paid": "settled", "SUCCESS": "settled", "reversed": "failed", "otp": "pending", "": ""}
+	for in, want := range cases {
+		if got := reconStatus(in); got != want {
+			t.Errorf("reconStatus(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
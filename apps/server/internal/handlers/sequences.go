@@ -0,0 +1,273 @@
+// Package handlers implements HTTP handlers for the moniewave financial management system.
+//
+// Number Sequences Handler - Document Numbering
+//
+// OBJECTIVES:
+// Invoices, quotes and credit notes need readable, unique, consecutive numbers
+// that the server hands out, not the caller.
+//
+// PURPOSE:
+// - Allocate the next number of a named sequence (invoice, quote, credit_note)
+// - Format numbers with a prefix and zero padding, e.g. INV-2025-00042
+// - Optionally restart a sequence at 1 every calendar year
+// - Let users view and change a sequence's prefix, padding and reset rule
+//
+// KEY WORKFLOW:
+// Begin Document Transaction → Advance Sequence → Format Number →
+// Insert Document → Commit (or Roll Back, Returning The Number)
+//
+// DESIGN DECISIONS:
+// - Numbers are allocated inside the caller's transaction, so a document that
+//   fails to save never consumes a number and the sequence stays gap-free
+// - Invoice numbers are sent to Paystack, so they are reserved before the
+//   Paystack call; a rejected call gives the number back if no later number was
+//   taken, otherwise it leaves a gap
+// - The counter is advanced in a single UPDATE before it is read, which takes
+//   SQLite's write lock first and keeps concurrent allocations from colliding
+// - "{year}" in the prefix is replaced with the allocation year; a yearly
+//   reset sequence must include it so numbers stay unique across years
+// - Sequences are created by migrations; only their format can be changed here
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// sequenceYearToken is replaced with the allocation year in a sequence prefix
+const sequenceYearToken = "{year}"
+
+type SequenceHandler struct{}
+
+func NewSequenceHandler() *SequenceHandler {
+	return &SequenceHandler{}
+}
+
+// NumberSequence is a named document number counter
+type NumberSequence struct {
+	Name        string `json:"name"`
+	Prefix      string `json:"prefix"`
+	Padding     int    `json:"padding"`
+	ResetYearly bool   `json:"reset_yearly"`
+	LastValue   int    `json:"last_value"`
+	// CurrentYear is the year last_value belongs to, for yearly reset sequences
+	CurrentYear *int `json:"current_year,omitempty"`
+	// Next is the number the next allocation will return
+	Next      string    `json:"next"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type UpdateSequenceRequest struct {
+	Prefix      *string `json:"prefix,omitempty"`
+	Padding     *int    `json:"padding,omitempty"`
+	ResetYearly *bool   `json:"reset_yearly,omitempty"`
+}
+
+// formatSequenceNumber renders a sequence value, e.g. ("INV-{year}-", 5, 42, 2025) → INV-2025-00042
+func formatSequenceNumber(prefix string, padding, value, year int) string {
+	number := strconv.Itoa(value)
+	if len(number) < padding {
+		number = strings.Repeat("0", padding-len(number)) + number
+	}
+	return strings.ReplaceAll(prefix, sequenceYearToken, strconv.Itoa(year)) + number
+}
+
+// sequenceNumber is a number taken from a sequence: Formatted is what
+// documents show (INV-2025-00042), Value the counter behind it (42)
+type sequenceNumber struct {
+	Formatted string
+	Value     int
+}
+
+// nextSequenceNumber takes the next number from a named sequence and formats
+// it with the sequence's prefix and zero padding (e.g. "CN-000042").
+// The increment happens in the caller's transaction, so a document that is
+// rolled back never consumes a number and the sequence stays gap-free.
+func nextSequenceNumber(tx *sql.Tx, name string) (string, error) {
+	number, err := advanceSequence(tx, name)
+	if err != nil {
+		return "", err
+	}
+	return number.Formatted, nil
+}
+
+// reserveSequenceNumber takes the next number from a named sequence in its own
+// transaction, for documents whose number is needed before they can be saved.
+// Give it back with releaseSequenceNumber if the document is never created.
+func reserveSequenceNumber(name string) (sequenceNumber, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return sequenceNumber{}, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	number, err := advanceSequence(tx, name)
+	if err != nil {
+		return sequenceNumber{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return sequenceNumber{}, fmt.Errorf("failed to reserve %s number: %w", name, err)
+	}
+	return number, nil
+}
+
+// releaseSequenceNumber gives back a reserved number when it is still the
+// latest one taken; otherwise it stays a gap in the sequence
+func releaseSequenceNumber(name string, number sequenceNumber) {
+	_, err := database.DB.Exec(
+		"UPDATE number_sequences SET last_value = last_value - 1, updated_at = ? WHERE name = ? AND last_value = ?",
+		time.Now(), name, number.Value,
+	)
+	if err != nil {
+		log.Printf("Warning: failed to release %s number %s: %v", name, number.Formatted, err)
+	}
+}
+
+// advanceSequence increments a named sequence in tx and returns the new number
+func advanceSequence(tx *sql.Tx, name string) (sequenceNumber, error) {
+	year := time.Now().Year()
+	result, err := tx.Exec(`
+		UPDATE number_sequences
+		SET last_value = CASE
+				WHEN COALESCE(reset_yearly, 0) = 1 AND COALESCE(current_year, 0) != ? THEN 1
+				ELSE last_value + 1
+			END,
+			current_year = ?,
+			updated_at = ?
+		WHERE name = ?
+	`, year, year, time.Now(), name)
+	if err != nil {
+		return sequenceNumber{}, fmt.Errorf("failed to advance sequence %s: %w", name, err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sequenceNumber{}, fmt.Errorf("sequence %s does not exist", name)
+	}
+
+	var prefix string
+	var padding, value int
+	err = tx.QueryRow("SELECT prefix, padding, last_value FROM number_sequences WHERE name = ?", name).Scan(&prefix, &padding, &value)
+	if err != nil {
+		return sequenceNumber{}, fmt.Errorf("failed to read sequence %s: %w", name, err)
+	}
+
+	return sequenceNumber{Formatted: formatSequenceNumber(prefix, padding, value, year), Value: value}, nil
+}
+
+const sequenceColumns = "name, prefix, padding, COALESCE(reset_yearly, 0), last_value, current_year, updated_at"
+
+func scanSequence(row rowScanner) (*NumberSequence, error) {
+	var seq NumberSequence
+	var currentYear sql.NullInt64
+	err := row.Scan(&seq.Name, &seq.Prefix, &seq.Padding, &seq.ResetYearly, &seq.LastValue, &currentYear, &seq.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	seq.CurrentYear = nullIntPtr(currentYear)
+
+	year := time.Now().Year()
+	next := seq.LastValue + 1
+	if seq.ResetYearly && (seq.CurrentYear == nil || *seq.CurrentYear != year) {
+		next = 1
+	}
+	seq.Next = formatSequenceNumber(seq.Prefix, seq.Padding, next, year)
+	return &seq, nil
+}
+
+// List returns all number sequences
+func (h *SequenceHandler) List(w http.ResponseWriter, r *http.Request) {
+	rows, err := database.DB.Query("SELECT " + sequenceColumns + " FROM number_sequences ORDER BY name")
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to query sequences: %w", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	sequences := []*NumberSequence{}
+	for rows.Next() {
+		seq, err := scanSequence(rows)
+		if err != nil {
+			WriteJSONError(w, fmt.Errorf("failed to scan sequence: %w", err), http.StatusInternalServerError)
+			return
+		}
+		sequences = append(sequences, seq)
+	}
+
+	if err := rows.Err(); err != nil {
+		WriteJSONError(w, fmt.Errorf("error iterating sequences: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, sequences)
+}
+
+// Update changes a sequence's prefix, padding or yearly reset. The counter
+// itself is never changed here, so numbers already issued cannot be reissued.
+func (h *SequenceHandler) Update(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	var req UpdateSequenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+
+	seq, err := scanSequence(database.DB.QueryRow("SELECT "+sequenceColumns+" FROM number_sequences WHERE name = ?", name))
+	if err == sql.ErrNoRows {
+		WriteJSONError(w, fmt.Errorf("sequence %s not found", name), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to load sequence: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	if req.Prefix != nil {
+		seq.Prefix = *req.Prefix
+	}
+	if req.Padding != nil {
+		seq.Padding = *req.Padding
+	}
+	if req.ResetYearly != nil {
+		seq.ResetYearly = *req.ResetYearly
+	}
+
+	if len(seq.Prefix) > 20 {
+		WriteJSONBadRequest(w, "prefix must be at most 20 characters")
+		return
+	}
+	if seq.Padding < 0 || seq.Padding > 12 {
+		WriteJSONBadRequest(w, "padding must be between 0 and 12")
+		return
+	}
+	if seq.ResetYearly && !strings.Contains(seq.Prefix, sequenceYearToken) {
+		WriteJSONBadRequest(w, "a sequence that resets yearly must include {year} in its prefix")
+		return
+	}
+
+	_, err = database.DB.Exec(
+		"UPDATE number_sequences SET prefix = ?, padding = ?, reset_yearly = ?, updated_at = ? WHERE name = ?",
+		seq.Prefix, seq.Padding, seq.ResetYearly, time.Now(), name,
+	)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to update sequence: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	seq, err = scanSequence(database.DB.QueryRow("SELECT "+sequenceColumns+" FROM number_sequences WHERE name = ?", name))
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to load sequence: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, seq)
+}
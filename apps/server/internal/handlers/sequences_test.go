@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"paystack.mpc.proxy/internal/paystack"
+)
+
+func TestFormatSequenceNumber(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		padding int
+		value   int
+		want    string
+	}{
+		{"INV-{year}-", 5, 42, "INV-2025-00042"},
+		{"CN-", 6, 7, "CN-000007"},
+		{"", 0, 12, "12"},
+		{"Q", 2, 1234, "Q1234"},
+	}
+
+	for _, tt := range tests {
+		if got := formatSequenceNumber(tt.prefix, tt.padding, tt.value, 2025); got != tt.want {
+			t.Errorf("formatSequenceNumber(%q, %d, %d) = %s, want %s", tt.prefix, tt.padding, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestInvoiceTemplateApply(t *testing.T) {
+	template := &InvoiceTemplate{
+		Description: "Monthly retainer",
+		Currency:    "USD",
+		DueInDays:   14,
+		Notes:       "Thank you",
+		LineItems:   []paystack.LineItem{{Name: "Retainer", Amount: 50000, Quantity: 2}, {Name: "Support", Amount: 10000}},
+	}
+	now := time.Date(2025, time.March, 1, 10, 0, 0, 0, time.UTC)
+
+	req := CreateInvoiceRequest{Customer: "CUS_1"}
+	template.apply(&req, now)
+	if req.Amount != 110000 || len(req.LineItems) != 2 || req.Currency != "USD" || req.DueDate != "2025-03-15" || req.Notes != "Thank you" {
+		t.Errorf("Unexpected request after apply: %+v", req)
+	}
+
+	// Fields on the request win over the template
+	req = CreateInvoiceRequest{Customer: "CUS_1", Amount: 5000, Currency: "NGN", DueDate: "2025-04-01", Description: "Custom"}
+	template.apply(&req, now)
+	if req.Amount != 5000 || req.Currency != "NGN" || req.DueDate != "2025-04-01" || req.Description != "Custom" {
+		t.Errorf("Template overrode request fields: %+v", req)
+	}
+}
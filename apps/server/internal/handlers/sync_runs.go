@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+)
+
+// ErrSyncInProgress is returned when a sync job is already running
+var ErrSyncInProgress = errors.New("sync already in progress")
+
+// getSyncCursor returns where an incremental sync job should resume, or ""
+// when the job has never completed
+func getSyncCursor(job string) (string, error) {
+	var cursor string
+	err := database.DB.QueryRow("SELECT cursor FROM sync_cursors WHERE job = ?", job).Scan(&cursor)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s sync cursor: %w", job, err)
+	}
+	return cursor, nil
+}
+
+// setSyncCursor saves a job's resume point. It takes the sync's transaction
+// so the cursor only moves when the synced rows are committed.
+func setSyncCursor(db sqlExecer, job, cursor string) error {
+	_, err := db.Exec(`
+		INSERT INTO sync_cursors (job, cursor, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(job) DO UPDATE SET cursor = excluded.cursor, updated_at = excluded.updated_at
+	`, job, cursor, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to save %s sync cursor: %w", job, err)
+	}
+	return nil
+}
+
+// recordSyncRun stores the outcome of a sync job in sync_runs. Failures to
+// record are logged; they never fail the sync itself.
+func recordSyncRun(job string, startedAt time.Time, summary interface{}, runErr error) {
+	status := "success"
+	errMessage := ""
+	if runErr != nil {
+		status = "failed"
+		errMessage = runErr.Error()
+	}
+
+	summaryJSON, err := json.Marshal(summary)
+	if err != nil {
+		log.Printf("Warning: failed to encode %s sync summary: %v", job, err)
+		summaryJSON = nil
+	}
+
+	_, err = database.DB.Exec(
+		"INSERT INTO sync_runs (job, status, summary, error, started_at, finished_at) VALUES (?, ?, ?, ?, ?, ?)",
+		job, status, string(summaryJSON), errMessage, startedAt, time.Now(),
+	)
+	if err != nil {
+		log.Printf("Warning: failed to record %s sync run: %v", job, err)
+	}
+}
@@ -0,0 +1,565 @@
+// Package handlers implements HTTP handlers for the moniewave financial management system.
+//
+// Transaction Ledger - Income Management
+//
+// OBJECTIVES:
+// Keep a local copy of Paystack money movement so reporting works offline and stays fast.
+//
+// PURPOSE:
+// - Pull Paystack transactions (money in) and transfers (money out) into SQLite
+// - Normalise amounts, fees, channel, customer and recipient into one table
+// - Upsert by reference, so re-syncing never duplicates an entry
+// - Sync incrementally from a saved cursor, on a schedule and on demand
+// - List ledger entries from the local table with filters
+//
+// KEY WORKFLOW:
+// Read Cursor → Page Through Paystack Created Since Cursor → Upsert Each Page →
+// Move Cursor Back To The Oldest Entry Still Pending → Save Cursor → Record Sync Run
+//
+// DESIGN DECISIONS:
+// - Charges and transfers share the transactions table, told apart by type;
+//   a reference is unique within a type
+// - Paystack lists by creation date only, so an entry that is still pending
+//   holds the cursor back until it settles (up to ledgerPendingLookback), so
+//   its final status is picked up by a later incremental sync
+// - Each page is written in its own transaction and the cursor is saved only
+//   after the last page; a failed sync re-reads pages, and the upsert makes
+//   that harmless
+// - All amounts are in kobo as Paystack reports them; timestamps are stored in UTC
+// - These are Paystack's records; limits still count only transfers initiated
+//   through this server (the transfers table)
+// - A mutex prevents the scheduled job and the endpoint from running concurrently
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"paystack.mpc.proxy/internal/database"
+	"paystack.mpc.proxy/internal/paystack"
+)
+
+// Ledger entry types
+const (
+	LedgerTypeCharge   = "charge"
+	LedgerTypeTransfer = "transfer"
+)
+
+// ledgerSyncPageSize is the Paystack page size used while syncing
+const ledgerSyncPageSize = 100
+
+// ledgerSyncOverlap is how far before the cursor each incremental sync starts
+const ledgerSyncOverlap = 10 * time.Minute
+
+// ledgerPendingLookback is how long a pending entry may hold the cursor back
+const ledgerPendingLookback = 7 * 24 * time.Hour
+
+// ledgerFinalStatuses are statuses that no longer change upstream
+const ledgerFinalStatuses = "'success', 'failed', 'abandoned', 'reversed'"
+
+var ledgerSyncMu sync.Mutex
+
+// LedgerEntry is a Paystack charge or transfer stored locally. Amounts are in kobo.
+type LedgerEntry struct {
+	ID            int             `json:"id"`
+	Type          string          `json:"type"`
+	Reference     string          `json:"reference"`
+	PaystackID    int             `json:"paystack_id,omitempty"`
+	Amount        int             `json:"amount"`
+	Fees          int             `json:"fees"`
+	Currency      string          `json:"currency"`
+	Status        string          `json:"status"`
+	Channel       string          `json:"channel,omitempty"`
+	CustomerCode  string          `json:"customer_code,omitempty"`
+	CustomerEmail string          `json:"customer_email,omitempty"`
+	RecipientCode string          `json:"recipient_code,omitempty"`
+	RecipientName string          `json:"recipient_name,omitempty"`
+	Description   string          `json:"description,omitempty"`
+	Metadata      json.RawMessage `json:"metadata,omitempty"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	CreatedAt     *time.Time      `json:"upstream_created_at,omitempty"`
+	SyncedAt      *time.Time      `json:"synced_at,omitempty"`
+}
+
+// LedgerSyncReport summarises one ledger sync
+type LedgerSyncReport struct {
+	StartedAt    time.Time        `json:"started_at"`
+	FinishedAt   time.Time        `json:"finished_at"`
+	Full         bool             `json:"full"`
+	Transactions *LedgerKindStats `json:"transactions"`
+	Transfers    *LedgerKindStats `json:"transfers"`
+}
+
+// LedgerKindStats summarises the sync of one entry type
+type LedgerKindStats struct {
+	From      string `json:"from,omitempty"`
+	Pages     int    `json:"pages"`
+	Fetched   int    `json:"fetched"`
+	Added     int    `json:"added"`
+	Updated   int    `json:"updated"`
+	Unchanged int    `json:"unchanged"`
+	Cursor    string `json:"cursor,omitempty"`
+}
+
+type SyncLedgerRequest struct {
+	// Full re-reads all history instead of resuming from the saved cursors
+	Full bool `json:"full,omitempty"`
+}
+
+// upstreamTime parses the first non-empty Paystack timestamp as UTC
+func upstreamTime(values ...string) *time.Time {
+	if t := firstTime(values...); !t.IsZero() {
+		t = t.UTC()
+		return &t
+	}
+	return nil
+}
+
+// ledgerMetadata keeps Paystack metadata as JSON; Paystack sends an object,
+// a JSON string or nothing
+func ledgerMetadata(value interface{}) json.RawMessage {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		if v == "" {
+			return nil
+		}
+		if json.Valid([]byte(v)) {
+			return json.RawMessage(v)
+		}
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// ledgerEntryFromTransaction normalises a listed Paystack transaction
+func ledgerEntryFromTransaction(m map[string]interface{}) *LedgerEntry {
+	customer := mapObject(m, "customer")
+	entry := &LedgerEntry{
+		Type:          LedgerTypeCharge,
+		Reference:     mapString(m, "reference"),
+		PaystackID:    mapInt(m, "id"),
+		Amount:        mapInt(m, "amount"),
+		Fees:          mapInt(m, "fees"),
+		Currency:      strings.ToUpper(mapString(m, "currency")),
+		Status:        mapString(m, "status"),
+		Channel:       mapString(m, "channel"),
+		CustomerCode:  mapString(customer, "customer_code"),
+		CustomerEmail: strings.ToLower(mapString(customer, "email")),
+		Description:   mapString(m, "gateway_response"),
+		Metadata:      ledgerMetadata(m["metadata"]),
+		CreatedAt:     upstreamTime(mapString(m, "created_at"), mapString(m, "createdAt")),
+	}
+	if occurred := upstreamTime(mapString(m, "paid_at"), mapString(m, "paidAt")); occurred != nil {
+		entry.OccurredAt = *occurred
+	} else if entry.CreatedAt != nil {
+		entry.OccurredAt = *entry.CreatedAt
+	}
+	return entry
+}
+
+// ledgerEntryFromTransfer normalises a listed Paystack transfer
+func ledgerEntryFromTransfer(m map[string]interface{}) *LedgerEntry {
+	recipient := mapObject(m, "recipient")
+	entry := &LedgerEntry{
+		Type:          LedgerTypeTransfer,
+		Reference:     mapString(m, "reference"),
+		PaystackID:    mapInt(m, "id"),
+		Amount:        mapInt(m, "amount"),
+		Fees:          mapInt(m, "fee_charged"),
+		Currency:      strings.ToUpper(mapString(m, "currency")),
+		Status:        mapString(m, "status"),
+		Channel:       mapString(recipient, "type"),
+		RecipientCode: mapString(recipient, "recipient_code"),
+		RecipientName: mapString(recipient, "name"),
+		CustomerEmail: strings.ToLower(mapString(recipient, "email")),
+		Description:   mapString(m, "reason"),
+		Metadata:      ledgerMetadata(m["metadata"]),
+		CreatedAt:     upstreamTime(mapString(m, "createdAt"), mapString(m, "created_at")),
+	}
+	if entry.Reference == "" {
+		// Transfers made from the dashboard may have no reference
+		entry.Reference = mapString(m, "transfer_code")
+	}
+	if occurred := upstreamTime(mapString(m, "transferred_at"), mapString(m, "transferredAt")); occurred != nil {
+		entry.OccurredAt = *occurred
+	} else if entry.CreatedAt != nil {
+		entry.OccurredAt = *entry.CreatedAt
+	}
+	return entry
+}
+
+// upsertLedgerEntry inserts or refreshes an entry by type and reference. It
+// returns "added", "updated" or "unchanged".
+func upsertLedgerEntry(tx *sql.Tx, e *LedgerEntry) (string, error) {
+	now := time.Now().UTC()
+	if e.Currency == "" {
+		e.Currency = "NGN"
+	}
+	var metadata interface{}
+	if len(e.Metadata) > 0 {
+		metadata = string(e.Metadata)
+	}
+
+	var status sql.NullString
+	var amount, fees int
+	var occurredAt time.Time
+	err := tx.QueryRow(
+		"SELECT status, amount, COALESCE(fees, 0), occurred_at FROM transactions WHERE type = ? AND reference = ?",
+		e.Type, e.Reference,
+	).Scan(&status, &amount, &fees, &occurredAt)
+	if err == sql.ErrNoRows {
+		_, err := tx.Exec(`
+			INSERT INTO transactions (type, reference, paystack_id, amount, fees, currency, status, channel,
+			                          customer_code, customer_email, recipient_code, recipient_name, description,
+			                          metadata, occurred_at, upstream_created_at, synced_at, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, e.Type, e.Reference, e.PaystackID, e.Amount, e.Fees, e.Currency, e.Status, e.Channel,
+			e.CustomerCode, e.CustomerEmail, e.RecipientCode, e.RecipientName, e.Description,
+			metadata, e.OccurredAt, e.CreatedAt, now, now, now)
+		if err != nil {
+			return "", fmt.Errorf("failed to insert %s %s: %w", e.Type, e.Reference, err)
+		}
+		return "added", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load %s %s: %w", e.Type, e.Reference, err)
+	}
+
+	if status.String == e.Status && amount == e.Amount && fees == e.Fees && occurredAt.Equal(e.OccurredAt) {
+		if _, err := tx.Exec("UPDATE transactions SET synced_at = ? WHERE type = ? AND reference = ?", now, e.Type, e.Reference); err != nil {
+			return "", fmt.Errorf("failed to update %s %s: %w", e.Type, e.Reference, err)
+		}
+		return "unchanged", nil
+	}
+
+	_, err = tx.Exec(`
+		UPDATE transactions
+		SET paystack_id = ?, amount = ?, fees = ?, currency = ?, status = ?, channel = ?,
+		    customer_code = ?, customer_email = ?, recipient_code = ?, recipient_name = ?, description = ?,
+		    metadata = ?, occurred_at = ?, upstream_created_at = COALESCE(?, upstream_created_at),
+		    synced_at = ?, updated_at = ?
+		WHERE type = ? AND reference = ?
+	`, e.PaystackID, e.Amount, e.Fees, e.Currency, e.Status, e.Channel,
+		e.CustomerCode, e.CustomerEmail, e.RecipientCode, e.RecipientName, e.Description,
+		metadata, e.OccurredAt, e.CreatedAt, now, now, e.Type, e.Reference)
+	if err != nil {
+		return "", fmt.Errorf("failed to update %s %s: %w", e.Type, e.Reference, err)
+	}
+	return "updated", nil
+}
+
+// Sync pulls Paystack transactions and transfers into the local ledger
+func (h *TransactionHandler) Sync(w http.ResponseWriter, r *http.Request) {
+	var req SyncLedgerRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJSONBadRequest(w, "Invalid request body")
+			return
+		}
+	}
+
+	report, err := SyncLedger(h.client, req.Full)
+	if err != nil {
+		if errors.Is(err, ErrSyncInProgress) {
+			WriteJSONError(w, err, http.StatusConflict)
+			return
+		}
+		WriteJSONError(w, fmt.Errorf("ledger sync failed: %w", err), http.StatusBadGateway)
+		return
+	}
+
+	WriteJSONSuccess(w, report)
+}
+
+// SyncLedger syncs transactions and then transfers, and records the run in
+// sync_runs. The first sync of each type is always full.
+func SyncLedger(client *paystack.Client, full bool) (*LedgerSyncReport, error) {
+	if !ledgerSyncMu.TryLock() {
+		return nil, ErrSyncInProgress
+	}
+	defer ledgerSyncMu.Unlock()
+
+	report := &LedgerSyncReport{StartedAt: time.Now(), Full: full}
+
+	var err error
+	report.Transactions, err = syncLedgerType(LedgerTypeCharge, "transactions", full,
+		func(page int, from string) (map[string]interface{}, error) {
+			return client.ListTransactions(ledgerSyncPageSize, page, paystack.TransactionFilter{From: from})
+		}, ledgerEntryFromTransaction)
+	if err == nil {
+		report.Transfers, err = syncLedgerType(LedgerTypeTransfer, "transfers", full,
+			func(page int, from string) (map[string]interface{}, error) {
+				return client.ListTransfers(ledgerSyncPageSize, page, paystack.TransferFilter{From: from})
+			}, ledgerEntryFromTransfer)
+	}
+
+	report.FinishedAt = time.Now()
+	recordSyncRun("ledger", report.StartedAt, report, err)
+
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// syncLedgerType pages through one Paystack list from its cursor (job) and
+// upserts every entry
+func syncLedgerType(entryType, job string, full bool, list func(page int, from string) (map[string]interface{}, error), parse func(map[string]interface{}) *LedgerEntry) (*LedgerKindStats, error) {
+	stats := &LedgerKindStats{}
+
+	cursor, err := getSyncCursor(job)
+	if err != nil {
+		return stats, err
+	}
+	var latest time.Time
+	if t, err := time.Parse(time.RFC3339, cursor); err == nil {
+		latest = t
+		if !full {
+			stats.From = t.Add(-ledgerSyncOverlap).UTC().Format(time.RFC3339)
+		}
+	}
+
+	for page := 1; ; page++ {
+		resp, err := list(page, stats.From)
+		if err != nil {
+			return stats, fmt.Errorf("failed to list %s (page %d): %w", job, page, err)
+		}
+		stats.Pages++
+
+		data, _ := resp["data"].([]interface{})
+		entries := []*LedgerEntry{}
+		for _, item := range data {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if entry := parse(m); entry.Reference != "" && !entry.OccurredAt.IsZero() {
+				entries = append(entries, entry)
+			}
+		}
+		stats.Fetched += len(entries)
+
+		if err := writeLedgerPage(entries, stats); err != nil {
+			return stats, err
+		}
+		for _, entry := range entries {
+			if entry.CreatedAt != nil && entry.CreatedAt.After(latest) {
+				latest = *entry.CreatedAt
+			}
+		}
+
+		meta := mapObject(resp, "meta")
+		pageCount := mapInt(meta, "pageCount")
+		if len(data) < ledgerSyncPageSize || (pageCount > 0 && page >= pageCount) {
+			break
+		}
+	}
+
+	if latest.IsZero() {
+		return stats, nil
+	}
+
+	// Resume from the oldest entry that can still change, so its final status is seen
+	var pendingSince time.Time
+	err = database.DB.QueryRow(`
+		SELECT upstream_created_at FROM transactions
+		WHERE type = ? AND COALESCE(status, '') NOT IN (`+ledgerFinalStatuses+`)
+		  AND upstream_created_at >= ?
+		ORDER BY upstream_created_at LIMIT 1
+	`, entryType, time.Now().UTC().Add(-ledgerPendingLookback)).Scan(&pendingSince)
+	if err != nil && err != sql.ErrNoRows {
+		return stats, fmt.Errorf("failed to find pending %s: %w", job, err)
+	}
+	if err == nil && pendingSince.Before(latest) {
+		latest = pendingSince
+	}
+
+	stats.Cursor = latest.UTC().Format(time.RFC3339)
+	if err := setSyncCursor(database.DB, job, stats.Cursor); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// writeLedgerPage upserts one page of entries in a single transaction
+func writeLedgerPage(entries []*LedgerEntry, stats *LedgerKindStats) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	added, updated, unchanged := 0, 0, 0
+	for _, entry := range entries {
+		outcome, err := upsertLedgerEntry(tx, entry)
+		if err != nil {
+			return err
+		}
+		switch outcome {
+		case "added":
+			added++
+		case "updated":
+			updated++
+		default:
+			unchanged++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit ledger page: %w", err)
+	}
+	stats.Added += added
+	stats.Updated += updated
+	stats.Unchanged += unchanged
+	return nil
+}
+
+const ledgerEntryColumns = `id, type, reference, COALESCE(paystack_id, 0), amount, COALESCE(fees, 0), COALESCE(currency, 'NGN'),
+	COALESCE(status, ''), COALESCE(channel, ''), COALESCE(customer_code, ''), COALESCE(customer_email, ''),
+	COALESCE(recipient_code, ''), COALESCE(recipient_name, ''), COALESCE(description, ''), metadata,
+	occurred_at, upstream_created_at, synced_at`
+
+func scanLedgerEntry(row rowScanner) (*LedgerEntry, error) {
+	var e LedgerEntry
+	var metadata sql.NullString
+	var createdAt, syncedAt sql.NullTime
+	err := row.Scan(&e.ID, &e.Type, &e.Reference, &e.PaystackID, &e.Amount, &e.Fees, &e.Currency,
+		&e.Status, &e.Channel, &e.CustomerCode, &e.CustomerEmail, &e.RecipientCode, &e.RecipientName,
+		&e.Description, &metadata, &e.OccurredAt, &createdAt, &syncedAt)
+	if err != nil {
+		return nil, err
+	}
+	if metadata.Valid && metadata.String != "" {
+		e.Metadata = json.RawMessage(metadata.String)
+	}
+	if createdAt.Valid {
+		e.CreatedAt = &createdAt.Time
+	}
+	if syncedAt.Valid {
+		e.SyncedAt = &syncedAt.Time
+	}
+	return &e, nil
+}
+
+// ledgerFilters turns ledger query parameters into " AND ..." conditions;
+// shared by Ledger and the transaction export. Errors are user-facing.
+func ledgerFilters(query url.Values) (string, []interface{}, error) {
+	where := ""
+	args := []interface{}{}
+
+	if v := query.Get("type"); v != "" {
+		if v != LedgerTypeCharge && v != LedgerTypeTransfer {
+			return "", nil, errors.New("type must be charge or transfer")
+		}
+		where += " AND type = ?"
+		args = append(args, v)
+	}
+	if v := query.Get("status"); v != "" {
+		where += " AND status = ?"
+		args = append(args, v)
+	}
+	if v := query.Get("currency"); v != "" {
+		where += " AND currency = ?"
+		args = append(args, strings.ToUpper(v))
+	}
+	if v := query.Get("customer"); v != "" {
+		where += " AND (customer_code = ? OR customer_email = ?)"
+		args = append(args, v, strings.ToLower(v))
+	}
+	if v := query.Get("recipient_code"); v != "" {
+		where += " AND recipient_code = ?"
+		args = append(args, v)
+	}
+	if v := query.Get("from"); v != "" {
+		from, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return "", nil, errors.New("from must be a date (YYYY-MM-DD)")
+		}
+		where += " AND occurred_at >= ?"
+		args = append(args, from)
+	}
+	if v := query.Get("to"); v != "" {
+		to, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return "", nil, errors.New("to must be a date (YYYY-MM-DD)")
+		}
+		where += " AND occurred_at < ?"
+		args = append(args, to.AddDate(0, 0, 1))
+	}
+	return where, args, nil
+}
+
+// Ledger lists entries from the local ledger, newest first.
+// Query params: type (charge|transfer), status, currency, customer (code or
+// email), recipient_code, from and to (YYYY-MM-DD), limit (default 100), offset.
+func (h *TransactionHandler) Ledger(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	where, args, err := ledgerFilters(query)
+	if err != nil {
+		WriteJSONBadRequest(w, err.Error())
+		return
+	}
+	sqlQuery := "SELECT " + ledgerEntryColumns + " FROM transactions WHERE 1=1" + where
+
+	limit := 100
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 1000 {
+			WriteJSONBadRequest(w, "limit must be between 1 and 1000")
+			return
+		}
+		limit = n
+	}
+	offset := 0
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			WriteJSONBadRequest(w, "offset must be a non-negative number")
+			return
+		}
+		offset = n
+	}
+	sqlQuery += " ORDER BY occurred_at DESC, id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	rows, err := database.DB.Query(sqlQuery, args...)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to query ledger: %w", err), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	entries := []*LedgerEntry{}
+	for rows.Next() {
+		entry, err := scanLedgerEntry(rows)
+		if err != nil {
+			WriteJSONError(w, fmt.Errorf("failed to scan ledger entry: %w", err), http.StatusInternalServerError)
+			return
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		WriteJSONError(w, fmt.Errorf("error iterating ledger: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	WriteJSONSuccess(w, entries)
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLedgerEntryFromTransaction(t *testing.T) {
+	entry := ledgerEntryFromTransaction(map[string]interface{}{
+		"id":         float64(42),
+		"reference":  "T_1",
+		"amount":     float64(500000),
+		"fees":       float64(7500),
+		"currency":   "ngn",
+		"status":     "success",
+		"channel":    "card",
+		"paid_at":    "2025-03-01T10:00:00.000Z",
+		"created_at": "2025-03-01T09:58:00.000Z",
+		"customer":   map[string]interface{}{"customer_code": "CUS_a", "email": "Ada@Example.com"},
+		"metadata":   `{"invoice":"INV-1"}`,
+	})
+
+	if entry.Type != LedgerTypeCharge || entry.Amount != 500000 || entry.Fees != 7500 || entry.Currency != "NGN" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if entry.CustomerEmail != "ada@example.com" || entry.CustomerCode != "CUS_a" {
+		t.Errorf("customer = %s/%s, want CUS_a/ada@example.com", entry.CustomerCode, entry.CustomerEmail)
+	}
+	if want := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC); !entry.OccurredAt.Equal(want) {
+		t.Errorf("OccurredAt = %v, want the paid_at time %v", entry.OccurredAt, want)
+	}
+	if string(entry.Metadata) != `{"invoice":"INV-1"}` {
+		t.Errorf("Metadata = %s", entry.Metadata)
+	}
+}
+
+func TestLedgerEntryFromTransfer(t *testing.T) {
+	entry := ledgerEntryFromTransfer(map[string]interface{}{
+		"transfer_code": "TRF_1",
+		"amount":        float64(100000),
+		"fee_charged":   float64(1075),
+		"status":        "pending",
+		"reason":        "Rent",
+		"createdAt":     "2025-03-02T08:00:00.000Z",
+		"recipient":     map[string]interface{}{"recipient_code": "RCP_1", "name": "Landlord", "type": "nuban"},
+	})
+
+	// Dashboard transfers have no reference; the transfer code stands in
+	if entry.Reference != "TRF_1" || entry.RecipientCode != "RCP_1" || entry.Channel != "nuban" || entry.Fees != 1075 {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if entry.CreatedAt == nil || !entry.OccurredAt.Equal(*entry.CreatedAt) {
+		t.Errorf("OccurredAt = %v, want the creation time while not yet transferred", entry.OccurredAt)
+	}
+	if entry.Metadata != nil {
+		t.Errorf("Metadata = %s, want none", entry.Metadata)
+	}
+}
@@ -8,7 +8,8 @@
 // PURPOSE:
 // - Initialize payment transactions
 // - Verify payment completion
-// - List transaction history
+// - List transaction history (live from Paystack, or from the local ledger)
+// - Sync transactions and transfers into the local ledger (see transaction_ledger.go)
 // - Process customer charges
 //
 // KEY WORKFLOW:
@@ -16,7 +17,7 @@
 // Verify Transaction → Update Status → Record Revenue
 //
 // DESIGN DECISIONS:
-// - All transactions go through Paystack API (no local storage)
+// - Initialize and verify always go to Paystack; reporting reads the synced ledger
 // - Reference is used to track transaction state
 // - Verification is required before considering payment complete
 // - List supports pagination for large transaction histories
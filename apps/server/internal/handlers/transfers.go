@@ -3,46 +3,77 @@
 // Transfers Handler - Paystack Integration Layer
 //
 // OBJECTIVES:
-// Initiate bank transfers to recipients.
+// Initiate bank transfers to recipients and see them through to completion.
 //
 // PURPOSE:
 // - Create transfer recipients with bank account details
 // - Initiate transfers from Paystack balance to bank accounts
-// - Track transfer status and history
+// - Pay several recipients at once with bulk transfers
+// - Finalize transfers held for OTP, and resend the OTP when it does not arrive
+// - Fetch, verify and list transfers to track status and history
 //
 // KEY WORKFLOW:
-// Create Recipient → Initiate Transfer → Verify Transfer → Complete Transaction
+// Create Recipient → Initiate Transfer → Check Account And Beneficiary Limits → Record Locally →
+// Finalize With OTP (if required) → Verify Transfer → Update Local Status
 //
 // DESIGN DECISIONS:
-// - Recipients created via Paystack API before transfers
+// - Recipients created via Paystack API before transfers, after the same
+//   account-name verification as the recipients endpoints
 // - All transfers go through Paystack (no direct bank integration)
 // - Currency defaults to NGN (Nigerian Naira)
 // - Reason field for transfer narration and tracking
+// - Transfers are reserved locally before Paystack is called, so account and
+//   per-recipient limits count them; the reservation is confirmed with the
+//   Paystack response or released if Paystack rejects the transfer
+// - The outflow lock covers only the limit check and the reservation, never
+//   the Paystack round trip
+// - A bulk transfer is checked as a whole; if any limit fails, nothing is sent
+// - Limits are checked once, at initiation; finalizing an OTP transfer does not
+//   check them again because the transfer already counts towards them
+// - Finalize, fetch and verify copy Paystack's status onto the local record so
+//   failed and reversed transfers stop counting towards limits
 package handlers
 
 import (
 	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 
+	"paystack.mpc.proxy/internal/dto"
 	"paystack.mpc.proxy/internal/paystack"
 
 	paystackSDK "github.com/borderlesshq/paystack-go"
+	"github.com/go-chi/chi/v5"
+)
+
+// OTP resend reasons accepted by Paystack
+const (
+	TransferOTPReasonResend   = "resend_otp"
+	TransferOTPReasonTransfer = "transfer"
 )
 
 type TransferHandler struct {
-	client *paystack.Client
+	client     *paystack.Client
+	recipients *RecipientHandler
 }
 
-func NewTransferHandler(client *paystack.Client) *TransferHandler {
-	return &TransferHandler{client: client}
+func NewTransferHandler(client *paystack.Client, recipients *RecipientHandler) *TransferHandler {
+	return &TransferHandler{client: client, recipients: recipients}
 }
 
-type CreateRecipientRequest struct {
-	Type          string `json:"type"`
-	Name          string `json:"name"`
-	AccountNumber string `json:"account_number"`
-	BankCode      string `json:"bank_code"`
-	Currency      string `json:"currency,omitempty"`
+type BulkTransferItem struct {
+	Amount    int    `json:"amount"`
+	Recipient string `json:"recipient"`
+	Reason    string `json:"reason,omitempty"`
+	Reference string `json:"reference,omitempty"`
+}
+
+type BulkTransferRequest struct {
+	Source    string             `json:"source"`
+	Currency  string             `json:"currency,omitempty"`
+	Transfers []BulkTransferItem `json:"transfers"`
 }
 
 type InitiateTransferRequest struct {
@@ -50,17 +81,40 @@ type InitiateTransferRequest struct {
 	Amount    float32 `json:"amount"`
 	Recipient string  `json:"recipient"`
 	Reason    string  `json:"reason,omitempty"`
+	Currency  string  `json:"currency,omitempty"`
+	Reference string  `json:"reference,omitempty"`
+}
+
+type FinalizeTransferRequest struct {
+	TransferCode string `json:"transfer_code"`
+	OTP          string `json:"otp"`
+}
+
+type ResendTransferOTPRequest struct {
+	TransferCode string `json:"transfer_code"`
+	// Reason is resend_otp (default) or transfer
+	Reason string `json:"reason,omitempty"`
+}
+
+type VerifyTransferRequest struct {
+	Reference string `json:"reference"`
 }
 
+// CreateRecipient creates a transfer recipient. It goes through the same
+// account resolution, name check and local caching as RecipientHandler.Create.
 func (h *TransferHandler) CreateRecipient(w http.ResponseWriter, r *http.Request) {
-	var req CreateRecipientRequest
+	h.recipients.Create(w, r)
+}
+
+func (h *TransferHandler) Initiate(w http.ResponseWriter, r *http.Request) {
+	var req InitiateTransferRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		WriteJSONBadRequest(w, "Invalid request body")
 		return
 	}
 
-	if req.Type == "" || req.Name == "" || req.AccountNumber == "" || req.BankCode == "" {
-		WriteJSONBadRequest(w, "type, name, account_number, and bank_code are required")
+	if req.Source == "" || req.Amount == 0 || req.Recipient == "" {
+		WriteJSONBadRequest(w, "source, amount, and recipient are required")
 		return
 	}
 
@@ -68,45 +122,294 @@ func (h *TransferHandler) CreateRecipient(w http.ResponseWriter, r *http.Request
 		req.Currency = "NGN"
 	}
 
-	recipient := &paystackSDK.TransferRecipient{
-		Type:          req.Type,
-		Name:          req.Name,
-		AccountNumber: req.AccountNumber,
-		BankCode:      req.BankCode,
-		Currency:      req.Currency,
+	ids, accountCheck, limitCheck, err := reserveTransfers(req.Currency, []localTransfer{{
+		Reference:     req.Reference,
+		RecipientCode: req.Recipient,
+		Amount:        int(req.Amount),
+		Source:        req.Source,
+		Reason:        req.Reason,
+	}})
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if accountCheck != nil {
+		writeAccountLimitExceeded(w, accountCheck)
+		return
+	}
+	if limitCheck != nil {
+		writeBeneficiaryLimitExceeded(w, limitCheck)
+		return
+	}
+
+	transferReq := &paystackSDK.TransferRequest{
+		Source:    req.Source,
+		Amount:    req.Amount,
+		Currency:  req.Currency,
+		Recipient: req.Recipient,
+		Reason:    req.Reason,
+		Reference: req.Reference,
 	}
 
-	result, err := h.client.Transfer.CreateRecipient(recipient)
+	result, err := h.client.Transfer.Initiate(transferReq)
 	if err != nil {
+		releaseTransfers(ids)
 		WriteJSONError(w, err, http.StatusInternalServerError)
 		return
 	}
+
+	confirmTransfer(ids[0], result.TransferCode, result.Status)
+
 	WriteJSONSuccess(w, result)
 }
 
-func (h *TransferHandler) Initiate(w http.ResponseWriter, r *http.Request) {
-	var req InitiateTransferRequest
+// InitiateBulk pays several recipients in one Paystack bulk transfer.
+// The whole batch is checked against account and beneficiary limits first.
+func (h *TransferHandler) InitiateBulk(w http.ResponseWriter, r *http.Request) {
+	var req BulkTransferRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		WriteJSONBadRequest(w, "Invalid request body")
 		return
 	}
 
-	if req.Source == "" || req.Amount == 0 || req.Recipient == "" {
-		WriteJSONBadRequest(w, "source, amount, and recipient are required")
+	if req.Source == "" || len(req.Transfers) == 0 {
+		WriteJSONBadRequest(w, "source and transfers are required")
 		return
 	}
 
-	transferReq := &paystackSDK.TransferRequest{
+	if req.Currency == "" {
+		req.Currency = "NGN"
+	}
+
+	reservations := make([]localTransfer, len(req.Transfers))
+	for i, item := range req.Transfers {
+		if item.Amount <= 0 || item.Recipient == "" {
+			WriteJSONBadRequest(w, fmt.Sprintf("transfers[%d]: amount and recipient are required", i))
+			return
+		}
+		reservations[i] = localTransfer{
+			Reference:     item.Reference,
+			RecipientCode: item.Recipient,
+			Amount:        item.Amount,
+			Source:        req.Source,
+			Reason:        item.Reason,
+		}
+	}
+
+	// The batch is checked and reserved as a whole
+	ids, accountCheck, limitCheck, err := reserveTransfers(req.Currency, reservations)
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+	if accountCheck != nil {
+		writeAccountLimitExceeded(w, accountCheck)
+		return
+	}
+	if limitCheck != nil {
+		writeBeneficiaryLimitExceeded(w, limitCheck)
+		return
+	}
+
+	transfers := make([]map[string]interface{}, len(req.Transfers))
+	for i, item := range req.Transfers {
+		transfer := map[string]interface{}{
+			"amount":    item.Amount,
+			"recipient": item.Recipient,
+			"reason":    item.Reason,
+		}
+		if item.Reference != "" {
+			transfer["reference"] = item.Reference
+		}
+		transfers[i] = transfer
+	}
+
+	result, err := h.client.InitiateBulkTransfer(&paystackSDK.BulkTransfer{
+		Currency:  req.Currency,
 		Source:    req.Source,
-		Amount:    req.Amount,
-		Recipient: req.Recipient,
-		Reason:    req.Reason,
+		Transfers: transfers,
+	})
+	if err != nil {
+		releaseTransfers(ids)
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
 	}
 
-	result, err := h.client.Transfer.Initiate(transferReq)
+	// Paystack returns one entry per queued transfer, in request order
+	queued, _ := result["data"].([]interface{})
+	for i := range req.Transfers {
+		status, transferCode := "pending", ""
+		if i < len(queued) {
+			if m, ok := queued[i].(map[string]interface{}); ok {
+				status = firstNonEmpty(mapString(m, "status"), status)
+				transferCode = mapString(m, "transfer_code")
+			}
+		}
+		confirmTransfer(ids[i], transferCode, status)
+	}
+
+	WriteJSONSuccess(w, queued)
+}
+
+// Finalize completes a transfer that Paystack is holding for an OTP
+func (h *TransferHandler) Finalize(w http.ResponseWriter, r *http.Request) {
+	var req FinalizeTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.TransferCode == "" || req.OTP == "" {
+		WriteJSONBadRequest(w, "transfer_code and otp are required")
+		return
+	}
+
+	result, err := h.client.FinalizeTransfer(req.TransferCode, req.OTP)
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	syncTransferStatus(result)
+
+	WriteJSONSuccess(w, result)
+}
+
+// ResendOTP asks Paystack to send a new OTP for a transfer awaiting one
+func (h *TransferHandler) ResendOTP(w http.ResponseWriter, r *http.Request) {
+	var req ResendTransferOTPRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.TransferCode == "" {
+		WriteJSONBadRequest(w, "transfer_code is required")
+		return
+	}
+
+	if req.Reason == "" {
+		req.Reason = TransferOTPReasonResend
+	}
+	if req.Reason != TransferOTPReasonResend && req.Reason != TransferOTPReasonTransfer {
+		WriteJSONBadRequest(w, "reason must be resend_otp or transfer")
+		return
+	}
+
+	result, err := h.client.ResendTransferOTP(req.TransferCode, req.Reason)
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+	WriteJSONSuccess(w, result)
+}
+
+// Fetch gets a transfer by transfer code or ID
+func (h *TransferHandler) Fetch(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+	if code == "" {
+		WriteJSONBadRequest(w, "transfer code is required")
+		return
+	}
+
+	result, err := h.client.FetchTransfer(code)
+	if err != nil {
+		WriteJSONError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	syncTransferStatus(result)
+
+	WriteJSONSuccess(w, result)
+}
+
+// Verify gets a transfer by the reference it was initiated with
+func (h *TransferHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	var req VerifyTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSONBadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.Reference == "" {
+		WriteJSONBadRequest(w, "reference is required")
+		return
+	}
+
+	result, err := h.client.VerifyTransfer(req.Reference)
 	if err != nil {
 		WriteJSONError(w, err, http.StatusInternalServerError)
 		return
 	}
+
+	syncTransferStatus(result)
+
 	WriteJSONSuccess(w, result)
 }
+
+// List lists transfers from Paystack, newest first.
+//
+// Query parameters: status, recipient (Paystack recipient ID), from, to
+// (RFC3339 or YYYY-MM-DD), page, per_page (default 50, at most 100).
+func (h *TransferHandler) List(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	perPage, _ := strconv.Atoi(q.Get("per_page"))
+	if perPage <= 0 {
+		perPage = 50
+	}
+	if perPage > 100 {
+		perPage = 100
+	}
+
+	filter := paystack.TransferFilter{
+		Status: q.Get("status"),
+		From:   q.Get("from"),
+		To:     q.Get("to"),
+	}
+	if recipient := q.Get("recipient"); recipient != "" {
+		id, err := strconv.Atoi(recipient)
+		if err != nil {
+			WriteJSONBadRequest(w, "recipient must be the numeric Paystack recipient ID")
+			return
+		}
+		filter.RecipientID = id
+	}
+
+	result, err := h.client.ListTransfers(perPage, page, filter)
+	if err != nil {
+		WriteJSONError(w, fmt.Errorf("failed to list transfers: %w", err), http.StatusInternalServerError)
+		return
+	}
+
+	transfers, _ := result["data"].([]interface{})
+	if transfers == nil {
+		transfers = []interface{}{}
+	}
+	meta := mapObject(result, "meta")
+
+	respondWithJSON(w, http.StatusOK, dto.PaginatedResponse{
+		Status:  true,
+		Message: "Success",
+		Data:    transfers,
+		Meta: dto.MetaData{
+			Total:     mapInt(meta, "total"),
+			Page:      page,
+			PageCount: mapInt(meta, "pageCount"),
+		},
+	})
+}
+
+// syncTransferStatus copies the status of a transfer returned by Paystack
+// onto the local record. Failures are logged; the caller still gets the
+// Paystack response.
+func syncTransferStatus(transfer map[string]interface{}) {
+	code, reference := mapString(transfer, "transfer_code"), mapString(transfer, "reference")
+	if err := updateTransferStatus(code, reference, mapString(transfer, "status")); err != nil {
+		log.Printf("Warning: Failed to update status of transfer %s: %v", firstNonEmpty(code, reference), err)
+	}
+}
@@ -17,6 +17,8 @@
 //
 // DESIGN DECISIONS:
 // - Mock credit profiles enable testing without real credit bureaus
+// - An optional CreditBureau provider refreshes profiles from external reports
+//   (see credit_bureau.go); lookups fall back to local data when it is unavailable
 // - Verdict system considers multiple factors (income, debt, payment history)
 // - Risk levels (low, medium, high) inform lending decisions
 // - Seeded data includes diverse profiles (approved, review, denied)
@@ -25,39 +27,115 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
+	"paystack.mpc.proxy/internal/bureau"
 	"paystack.mpc.proxy/internal/database"
 )
 
-type VerdictHandler struct{}
+type VerdictHandler struct {
+	bureau    bureau.CreditBureau
+	reportTTL time.Duration
+}
 
-func NewVerdictHandler() *VerdictHandler {
-	return &VerdictHandler{}
+// NewVerdictHandler creates a verdict handler. creditBureau may be nil, in
+// which case only local credit profiles are used.
+func NewVerdictHandler(creditBureau bureau.CreditBureau, reportTTL time.Duration) *VerdictHandler {
+	return &VerdictHandler{
+		bureau:    creditBureau,
+		reportTTL: reportTTL,
+	}
 }
 
 // CreditProfile represents a credit profile from the database
 type CreditProfile struct {
-	ID                  int       `json:"id"`
-	Name                string    `json:"name"`
-	Email               string    `json:"email"`
-	Phone               string    `json:"phone"`
-	ProfileType         string    `json:"profile_type"`
-	CreditScore         int       `json:"credit_score"`
-	MonthlyIncome       int       `json:"monthly_income"`
-	TotalDebt           int       `json:"total_debt"`
-	EmploymentStatus    string    `json:"employment_status"`
-	PaymentHistoryScore int       `json:"payment_history_score"`
-	AccountAgeMonths    int       `json:"account_age_months"`
-	Verdict             string    `json:"verdict"`
-	RiskLevel           string    `json:"risk_level"`
-	MaxAffordableAmount int       `json:"max_affordable_amount"`
-	Notes               string    `json:"notes"`
-	CreatedAt           time.Time `json:"created_at"`
-	UpdatedAt           time.Time `json:"updated_at"`
+	ID                  int        `json:"id"`
+	Name                string     `json:"name"`
+	Email               string     `json:"email"`
+	Phone               string     `json:"phone"`
+	ProfileType         string     `json:"profile_type"`
+	CreditScore         int        `json:"credit_score"`
+	MonthlyIncome       int        `json:"monthly_income"`
+	TotalDebt           int        `json:"total_debt"`
+	EmploymentStatus    string     `json:"employment_status"`
+	PaymentHistoryScore int        `json:"payment_history_score"`
+	AccountAgeMonths    int        `json:"account_age_months"`
+	Verdict             string     `json:"verdict"`
+	RiskLevel           string     `json:"risk_level"`
+	MaxAffordableAmount int        `json:"max_affordable_amount"`
+	Notes               string     `json:"notes"`
+	Source              string     `json:"source"`
+	BureauRefreshedAt   *time.Time `json:"bureau_refreshed_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// creditProfileColumns is the column list scanned by scanCreditProfile
+const creditProfileColumns = `id, name, email, phone, profile_type, credit_score, monthly_income,
+		       total_debt, employment_status, payment_history_score, account_age_months,
+		       verdict, risk_level, max_affordable_amount, notes, source, bureau_refreshed_at,
+		       created_at, updated_at`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanCreditProfile scans a row selected with creditProfileColumns
+func scanCreditProfile(row rowScanner) (*CreditProfile, error) {
+	var profile CreditProfile
+	var phone, employmentStatus, notes, source sql.NullString
+	var refreshedAt sql.NullTime
+
+	err := row.Scan(
+		&profile.ID,
+		&profile.Name,
+		&profile.Email,
+		&phone,
+		&profile.ProfileType,
+		&profile.CreditScore,
+		&profile.MonthlyIncome,
+		&profile.TotalDebt,
+		&employmentStatus,
+		&profile.PaymentHistoryScore,
+		&profile.AccountAgeMonths,
+		&profile.Verdict,
+		&profile.RiskLevel,
+		&profile.MaxAffordableAmount,
+		&notes,
+		&source,
+		&refreshedAt,
+		&profile.CreatedAt,
+		&profile.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	profile.Phone = phone.String
+	profile.EmploymentStatus = employmentStatus.String
+	profile.Notes = notes.String
+	profile.Source = source.String
+	if profile.Source == "" {
+		profile.Source = "local"
+	}
+	if refreshedAt.Valid {
+		profile.BureauRefreshedAt = &refreshedAt.Time
+	}
+
+	return &profile, nil
+}
+
+// getCreditProfileByEmail looks up a credit profile in the local table.
+// Emails are compared case-insensitively; bureau profiles are stored lowercased.
+func getCreditProfileByEmail(email string) (*CreditProfile, error) {
+	query := "SELECT " + creditProfileColumns + " FROM credit_profiles WHERE lower(email) = ?"
+	return scanCreditProfile(database.DB.QueryRow(query, strings.ToLower(strings.TrimSpace(email))))
 }
 
 // AffordabilityCheckRequest represents a request to check affordability
@@ -101,36 +179,11 @@ func (h *VerdictHandler) CheckAffordability(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// Query credit profile by email
-	query := `
-		SELECT id, name, email, phone, profile_type, credit_score, monthly_income,
-		       total_debt, employment_status, payment_history_score, account_age_months,
-		       verdict, risk_level, max_affordable_amount, notes, created_at, updated_at
-		FROM credit_profiles
-		WHERE email = ?
-	`
-
-	var profile CreditProfile
-	err := database.DB.QueryRow(query, req.Email).Scan(
-		&profile.ID,
-		&profile.Name,
-		&profile.Email,
-		&profile.Phone,
-		&profile.ProfileType,
-		&profile.CreditScore,
-		&profile.MonthlyIncome,
-		&profile.TotalDebt,
-		&profile.EmploymentStatus,
-		&profile.PaymentHistoryScore,
-		&profile.AccountAgeMonths,
-		&profile.Verdict,
-		&profile.RiskLevel,
-		&profile.MaxAffordableAmount,
-		&profile.Notes,
-		&profile.CreatedAt,
-		&profile.UpdatedAt,
-	)
+	// Refresh from the credit bureau if the cached report is stale
+	h.ensureFreshProfile(req.Email)
 
+	// Query credit profile by email
+	profile, err := getCreditProfileByEmail(req.Email)
 	if err != nil {
 		WriteJSONError(w, fmt.Errorf("credit profile not found for email: %s", req.Email), http.StatusNotFound)
 		return
@@ -172,35 +225,9 @@ func (h *VerdictHandler) GetFinancialProfile(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	query := `
-		SELECT id, name, email, phone, profile_type, credit_score, monthly_income,
-		       total_debt, employment_status, payment_history_score, account_age_months,
-		       verdict, risk_level, max_affordable_amount, notes, created_at, updated_at
-		FROM credit_profiles
-		WHERE email = ?
-	`
-
-	var profile CreditProfile
-	err := database.DB.QueryRow(query, email).Scan(
-		&profile.ID,
-		&profile.Name,
-		&profile.Email,
-		&profile.Phone,
-		&profile.ProfileType,
-		&profile.CreditScore,
-		&profile.MonthlyIncome,
-		&profile.TotalDebt,
-		&profile.EmploymentStatus,
-		&profile.PaymentHistoryScore,
-		&profile.AccountAgeMonths,
-		&profile.Verdict,
-		&profile.RiskLevel,
-		&profile.MaxAffordableAmount,
-		&profile.Notes,
-		&profile.CreatedAt,
-		&profile.UpdatedAt,
-	)
+	h.ensureFreshProfile(email)
 
+	profile, err := getCreditProfileByEmail(email)
 	if err != nil {
 		WriteJSONError(w, fmt.Errorf("credit profile not found for email: %s", email), http.StatusNotFound)
 		return
@@ -211,13 +238,7 @@ func (h *VerdictHandler) GetFinancialProfile(w http.ResponseWriter, r *http.Requ
 
 // ListProfiles lists all credit profiles (for testing/admin purposes)
 func (h *VerdictHandler) ListProfiles(w http.ResponseWriter, r *http.Request) {
-	query := `
-		SELECT id, name, email, phone, profile_type, credit_score, monthly_income,
-		       total_debt, employment_status, payment_history_score, account_age_months,
-		       verdict, risk_level, max_affordable_amount, notes, created_at, updated_at
-		FROM credit_profiles
-		ORDER BY created_at DESC
-	`
+	query := "SELECT " + creditProfileColumns + " FROM credit_profiles ORDER BY created_at DESC"
 
 	rows, err := database.DB.Query(query)
 	if err != nil {
@@ -228,31 +249,12 @@ func (h *VerdictHandler) ListProfiles(w http.ResponseWriter, r *http.Request) {
 
 	profiles := []CreditProfile{}
 	for rows.Next() {
-		var profile CreditProfile
-		err := rows.Scan(
-			&profile.ID,
-			&profile.Name,
-			&profile.Email,
-			&profile.Phone,
-			&profile.ProfileType,
-			&profile.CreditScore,
-			&profile.MonthlyIncome,
-			&profile.TotalDebt,
-			&profile.EmploymentStatus,
-			&profile.PaymentHistoryScore,
-			&profile.AccountAgeMonths,
-			&profile.Verdict,
-			&profile.RiskLevel,
-			&profile.MaxAffordableAmount,
-			&profile.Notes,
-			&profile.CreatedAt,
-			&profile.UpdatedAt,
-		)
+		profile, err := scanCreditProfile(rows)
 		if err != nil {
 			WriteJSONError(w, fmt.Errorf("failed to scan profile: %w", err), http.StatusInternalServerError)
 			return
 		}
-		profiles = append(profiles, profile)
+		profiles = append(profiles, *profile)
 	}
 
 	if err = rows.Err(); err != nil {
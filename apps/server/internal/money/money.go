@@ -0,0 +1,42 @@
+// Package money formats amounts for display and export.
+//
+// Every amount in this server is an int in the currency's minor unit (kobo
+// for NGN). Decimal is for files other programs read (CSV, XLSX, OFX, API
+// messages); Format is for documents people read (invoices, statements,
+// reminders).
+package money
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Decimal formats an amount in minor units as a plain decimal ("1234.56")
+func Decimal(amount int) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
+
+// Format formats an amount in minor units with its currency and thousands
+// separators ("NGN 1,234.56"). An empty currency is NGN.
+func Format(amount int, currency string) string {
+	decimal := Decimal(amount)
+	sign := ""
+	if strings.HasPrefix(decimal, "-") {
+		sign, decimal = "-", decimal[1:]
+	}
+
+	units, minor := decimal[:len(decimal)-3], decimal[len(decimal)-3:]
+	for i := len(units) - 3; i > 0; i -= 3 {
+		units = units[:i] + "," + units[i:]
+	}
+
+	if currency == "" {
+		currency = "NGN"
+	}
+	return fmt.Sprintf("%s %s%s%s", strings.ToUpper(currency), sign, units, minor)
+}
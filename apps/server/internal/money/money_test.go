@@ -0,0 +1,32 @@
+package money
+
+import "testing"
+
+func TestDecimal(t *testing.T) {
+	tests := map[int]string{
+		0:       "0.00",
+		5:       "0.05",
+		-120050: "-1200.50",
+		123456:  "1234.56",
+	}
+	for amount, want := range tests {
+		if got := Decimal(amount); got != want {
+			t.Errorf("Decimal(%d) = %q, want %q", amount, got, want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := map[int]string{
+		0:          "NGN 0.00",
+		5:          "NGN 0.05",
+		123456:     "NGN 1,234.56",
+		100000000:  "NGN 1,000,000.00",
+		-250000050: "NGN -2,500,000.50",
+	}
+	for amount, want := range tests {
+		if got := Format(amount, "ngn"); got != want {
+			t.Errorf("Format(%d) = %q, want %q", amount, got, want)
+		}
+	}
+}
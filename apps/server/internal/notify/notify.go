@@ -0,0 +1,95 @@
+// Package notify defines the notifier interface used by the dunning scheduler
+// to send invoice reminders, with Paystack, SMTP and log implementations.
+//
+// Notifiers only deliver a reminder. Deciding when a reminder is due and
+// recording what was sent are the dunning scheduler's responsibility.
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"paystack.mpc.proxy/internal/money"
+)
+
+// Reminder stages
+const (
+	StageBeforeDue = "before_due"
+	StageDue       = "due"
+	StageOverdue   = "overdue"
+)
+
+// ErrNotDelivered is returned by a notifier that handled a reminder without
+// delivering it to the customer, such as the log-only stand-in
+var ErrNotDelivered = errors.New("reminder was logged, not delivered")
+
+// Notifier delivers invoice reminders
+type Notifier interface {
+	// Name identifies the channel in the reminder history and logs
+	Name() string
+	// SendInvoiceReminder delivers one reminder
+	SendInvoiceReminder(reminder Reminder) error
+}
+
+// Reminder is one invoice reminder. Amounts are in kobo.
+type Reminder struct {
+	InvoiceCode  string
+	CustomerID   string
+	CustomerName string
+	// CustomerEmail is empty when the customer's email is not known locally
+	CustomerEmail string
+	Currency      string
+	Amount        int
+	BalanceDue    int
+	DueDate       time.Time
+	Stage         string
+	// OffsetDays is the reminder's position relative to the due date
+	// (negative before, positive after)
+	OffsetDays int
+}
+
+// Subject returns a short subject line for the reminder
+func (r Reminder) Subject() string {
+	switch r.Stage {
+	case StageBeforeDue:
+		return fmt.Sprintf("Invoice %s is due on %s", r.InvoiceCode, r.DueDate.Format("2 Jan 2006"))
+	case StageDue:
+		return fmt.Sprintf("Invoice %s is due today", r.InvoiceCode)
+	default:
+		return fmt.Sprintf("Invoice %s is overdue (was due on %s)", r.InvoiceCode, r.DueDate.Format("2 Jan 2006"))
+	}
+}
+
+// Body returns a plain-text reminder message
+func (r Reminder) Body() string {
+	name := r.CustomerName
+	if name == "" {
+		name = "there"
+	}
+	return fmt.Sprintf(
+		"Hello %s,\n\nThis is a reminder that invoice %s has an outstanding balance of %s, due on %s.\n\nThank you.\n",
+		name, r.InvoiceCode, money.Format(r.BalanceDue, r.Currency), r.DueDate.Format("2 Jan 2006"),
+	)
+}
+
+// LogNotifier is an offline stand-in that writes reminders to the server log
+type LogNotifier struct{}
+
+// NewLogNotifier creates a notifier that only logs reminders
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+// Name returns the channel name
+func (n *LogNotifier) Name() string {
+	return "log"
+}
+
+// SendInvoiceReminder logs the reminder. It returns ErrNotDelivered so the
+// reminder is not recorded as sent.
+func (n *LogNotifier) SendInvoiceReminder(reminder Reminder) error {
+	log.Printf("Invoice reminder to %s (%s): %s", reminder.CustomerName, reminder.CustomerID, reminder.Subject())
+	return ErrNotDelivered
+}
@@ -0,0 +1,29 @@
+package notify
+
+import (
+	"paystack.mpc.proxy/internal/paystack"
+)
+
+// PaystackNotifier asks Paystack to email the customer about the payment
+// request. Paystack writes the message itself, so the reminder stage is not
+// reflected in the email.
+type PaystackNotifier struct {
+	client *paystack.Client
+}
+
+// NewPaystackNotifier creates a notifier that uses Paystack's payment request
+// notify endpoint
+func NewPaystackNotifier(client *paystack.Client) *PaystackNotifier {
+	return &PaystackNotifier{client: client}
+}
+
+// Name returns the channel name
+func (n *PaystackNotifier) Name() string {
+	return "paystack"
+}
+
+// SendInvoiceReminder triggers Paystack's reminder email for the invoice
+func (n *PaystackNotifier) SendInvoiceReminder(reminder Reminder) error {
+	_, err := n.client.NotifyPaymentRequest(reminder.InvoiceCode)
+	return err
+}
@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// ErrNoEmail is returned when a reminder has no customer email to send to
+var ErrNoEmail = errors.New("customer email is not known")
+
+// SMTPConfig holds the mail server settings for SMTPNotifier
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPNotifier emails reminders through an SMTP server
+type SMTPNotifier struct {
+	config SMTPConfig
+}
+
+// NewSMTPNotifier creates a notifier that sends plain-text email
+func NewSMTPNotifier(config SMTPConfig) *SMTPNotifier {
+	if config.Port == "" {
+		config.Port = "587"
+	}
+	return &SMTPNotifier{config: config}
+}
+
+// Name returns the channel name
+func (n *SMTPNotifier) Name() string {
+	return "smtp"
+}
+
+// SendInvoiceReminder emails the reminder to the customer
+func (n *SMTPNotifier) SendInvoiceReminder(reminder Reminder) error {
+	if reminder.CustomerEmail == "" {
+		return ErrNoEmail
+	}
+
+	var auth smtp.Auth
+	if n.config.Username != "" {
+		auth = smtp.PlainAuth("", n.config.Username, n.config.Password, n.config.Host)
+	}
+
+	message := strings.Join([]string{
+		"From: " + n.config.From,
+		"To: " + reminder.CustomerEmail,
+		"Subject: " + reminder.Subject(),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=utf-8",
+		"",
+		strings.ReplaceAll(reminder.Body(), "\n", "\r\n"),
+	}, "\r\n")
+
+	addr := net.JoinHostPort(n.config.Host, n.config.Port)
+	if err := smtp.SendMail(addr, auth, n.config.From, []string{reminder.CustomerEmail}, []byte(message)); err != nil {
+		return fmt.Errorf("failed to send reminder email: %w", err)
+	}
+	return nil
+}
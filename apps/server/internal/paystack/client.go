@@ -2,6 +2,7 @@ package paystack
 
 import (
 	"fmt"
+	"net/url"
 	"time"
 
 	"github.com/borderlesshq/paystack-go"
@@ -56,6 +57,30 @@ func (c *Client) SafeCheckBalance() (paystack.Response, error) {
 	}
 }
 
+// CheckBalances returns every currency balance on the account.
+// SafeCheckBalance only returns the first one.
+func (c *Client) CheckBalances() ([]map[string]interface{}, error) {
+	resp := paystack.Response{}
+	if err := c.Call("GET", "balance", nil, &resp); err != nil {
+		return nil, err
+	}
+
+	switch v := resp["data"].(type) {
+	case []interface{}:
+		balances := []map[string]interface{}{}
+		for _, item := range v {
+			if balance, ok := item.(map[string]interface{}); ok {
+				balances = append(balances, balance)
+			}
+		}
+		return balances, nil
+	case map[string]interface{}:
+		return []map[string]interface{}{v}, nil
+	default:
+		return nil, fmt.Errorf("invalid response: missing or malformed 'data' field")
+	}
+}
+
 // PaymentRequest represents a Paystack payment request (invoice)
 type PaymentRequest struct {
 	Customer        string      `json:"customer"`
@@ -124,3 +149,248 @@ func (c *Client) VerifyPaymentRequest(code string) (paystack.Response, error) {
 	// The SDK Call method already unwraps the response and returns just the data field
 	return resp, nil
 }
+
+// NotifyPaymentRequest asks Paystack to email the customer a reminder for a
+// payment request
+func (c *Client) NotifyPaymentRequest(code string) (paystack.Response, error) {
+	resp := paystack.Response{}
+	err := c.Call("POST", fmt.Sprintf("paymentrequest/notify/%s", code), nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// ArchivePaymentRequest archives a payment request so it can no longer be paid
+func (c *Client) ArchivePaymentRequest(code string) (paystack.Response, error) {
+	resp := paystack.Response{}
+	err := c.Call("POST", fmt.Sprintf("paymentrequest/archive/%s", code), nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// ListTransferRecipients fetches one page of transfer recipients.
+// The response contains the raw "data" array and pagination "meta".
+func (c *Client) ListTransferRecipients(perPage, page int) (paystack.Response, error) {
+	resp := paystack.Response{}
+	err := c.Call("GET", fmt.Sprintf("transferrecipient?perPage=%d&page=%d", perPage, page), nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	// List endpoints return a data array, so the SDK maps the full response
+	return resp, nil
+}
+
+// ListCustomers fetches one page of customers, newest first. from (RFC3339,
+// optional) limits the page to customers created at or after that time.
+// The response contains the raw "data" array and pagination "meta".
+func (c *Client) ListCustomers(perPage, page int, from string) (paystack.Response, error) {
+	resp := paystack.Response{}
+	path := fmt.Sprintf("customer?perPage=%d&page=%d", perPage, page)
+	if from != "" {
+		path += "&from=" + url.QueryEscape(from)
+	}
+	err := c.Call("GET", path, nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// TransactionFilter narrows a transaction list. Zero values are not sent.
+type TransactionFilter struct {
+	// CustomerID is the Paystack customer's numeric ID
+	CustomerID int
+	// Status is success, failed or abandoned
+	Status string
+	// From and To (RFC3339 or YYYY-MM-DD) bound the transaction date
+	From string
+	To   string
+}
+
+// ListTransactions fetches one page of transactions, newest first.
+// The response contains the raw "data" array and pagination "meta".
+func (c *Client) ListTransactions(perPage, page int, filter TransactionFilter) (paystack.Response, error) {
+	params := url.Values{}
+	params.Set("perPage", fmt.Sprintf("%d", perPage))
+	params.Set("page", fmt.Sprintf("%d", page))
+	if filter.CustomerID != 0 {
+		params.Set("customer", fmt.Sprintf("%d", filter.CustomerID))
+	}
+	if filter.Status != "" {
+		params.Set("status", filter.Status)
+	}
+	if filter.From != "" {
+		params.Set("from", filter.From)
+	}
+	if filter.To != "" {
+		params.Set("to", filter.To)
+	}
+
+	resp := paystack.Response{}
+	err := c.Call("GET", "transaction?"+params.Encode(), nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// TransferFilter narrows a transfer list. Zero values are not sent.
+type TransferFilter struct {
+	// RecipientID is the Paystack recipient's numeric ID
+	RecipientID int
+	// Status is otp, pending, success, failed, reversed or abandoned
+	Status string
+	// From and To (RFC3339 or YYYY-MM-DD) bound the transfer creation date
+	From string
+	To   string
+}
+
+// ListTransfers fetches one page of transfers, newest first.
+// The response contains the raw "data" array and pagination "meta".
+func (c *Client) ListTransfers(perPage, page int, filter TransferFilter) (paystack.Response, error) {
+	params := url.Values{}
+	params.Set("perPage", fmt.Sprintf("%d", perPage))
+	params.Set("page", fmt.Sprintf("%d", page))
+	if filter.RecipientID != 0 {
+		params.Set("recipient", fmt.Sprintf("%d", filter.RecipientID))
+	}
+	if filter.Status != "" {
+		params.Set("status", filter.Status)
+	}
+	if filter.From != "" {
+		params.Set("from", filter.From)
+	}
+	if filter.To != "" {
+		params.Set("to", filter.To)
+	}
+
+	resp := paystack.Response{}
+	err := c.Call("GET", "transfer?"+params.Encode(), nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// FetchTransfer fetches a transfer by ID or transfer code.
+// The SDK's Transfer.Get drops the reference, so this calls the API directly.
+func (c *Client) FetchTransfer(idOrCode string) (paystack.Response, error) {
+	resp := paystack.Response{}
+	err := c.Call("GET", fmt.Sprintf("transfer/%s", url.PathEscape(idOrCode)), nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// VerifyTransfer fetches a transfer by the reference it was initiated with
+func (c *Client) VerifyTransfer(reference string) (paystack.Response, error) {
+	resp := paystack.Response{}
+	err := c.Call("GET", fmt.Sprintf("transfer/verify/%s", url.PathEscape(reference)), nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// FinalizeTransfer completes a transfer that is waiting for an OTP.
+// The SDK's Transfer.Finalize sends url.Values, which encode to JSON arrays,
+// so this posts a plain object instead.
+func (c *Client) FinalizeTransfer(transferCode, otp string) (paystack.Response, error) {
+	resp := paystack.Response{}
+	body := map[string]string{"transfer_code": transferCode, "otp": otp}
+	err := c.Call("POST", "transfer/finalize_transfer", body, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// ResendTransferOTP sends a new OTP for a transfer. reason is
+// "resend_otp" or "transfer". Like FinalizeTransfer, this avoids the SDK's
+// url.Values body.
+func (c *Client) ResendTransferOTP(transferCode, reason string) (paystack.Response, error) {
+	resp := paystack.Response{}
+	body := map[string]string{"transfer_code": transferCode, "reason": reason}
+	err := c.Call("POST", "transfer/resend_otp", body, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// UpdateTransferRecipientRequest is the body for updating a transfer recipient
+type UpdateTransferRecipientRequest struct {
+	Name        string                 `json:"name"`
+	Email       string                 `json:"email,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// UpdateTransferRecipient updates a transfer recipient by ID or code
+func (c *Client) UpdateTransferRecipient(idOrCode string, req *UpdateTransferRecipientRequest) (paystack.Response, error) {
+	resp := paystack.Response{}
+	err := c.Call("PUT", fmt.Sprintf("transferrecipient/%s", idOrCode), req, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// DeleteTransferRecipient deletes a transfer recipient by ID or code.
+// Paystack keeps the recipient but sets it inactive.
+func (c *Client) DeleteTransferRecipient(idOrCode string) (paystack.Response, error) {
+	resp := paystack.Response{}
+	err := c.Call("DELETE", fmt.Sprintf("transferrecipient/%s", idOrCode), nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// InitiateBulkTransfer queues several transfers in one request.
+// The SDK's MakeBulkTransfer posts to the single-transfer endpoint, so this
+// calls transfer/bulk directly. The response contains the raw "data" array.
+func (c *Client) InitiateBulkTransfer(req *paystack.BulkTransfer) (paystack.Response, error) {
+	resp := paystack.Response{}
+	err := c.Call("POST", "transfer/bulk", req, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// ListBanks fetches one page of banks for a country (e.g. "nigeria", "ghana")
+// using cursor pagination; next is the cursor from the previous page's meta.next,
+// empty for the first page. The SDK's Bank.List drops country, currency and type,
+// so this calls the API directly. The response contains the raw "data" array and
+// pagination "meta".
+func (c *Client) ListBanks(country string, perPage int, next string) (paystack.Response, error) {
+	resp := paystack.Response{}
+	path := fmt.Sprintf("bank?country=%s&perPage=%d&use_cursor=true", url.QueryEscape(country), perPage)
+	if next != "" {
+		path += "&next=" + url.QueryEscape(next)
+	}
+	err := c.Call("GET", path, nil, &resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
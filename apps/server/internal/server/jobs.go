@@ -0,0 +1,83 @@
+package server
+
+import (
+	"log"
+	"time"
+
+	"paystack.mpc.proxy/internal/handlers"
+)
+
+// startJobs finishes work cut off by the last shutdown and launches the
+// background jobs enabled in config
+func (s *Server) startJobs() {
+	if err := handlers.FailInterruptedInvoiceBatches(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	if s.config.RecipientSyncInterval > 0 {
+		go runPeriodically("recipient sync", s.config.RecipientSyncInterval, func() error {
+			_, err := handlers.SyncRecipients(s.client)
+			return err
+		})
+	}
+
+	if s.config.CustomerSyncInterval > 0 {
+		go runPeriodically("customer sync", s.config.CustomerSyncInterval, func() error {
+			_, err := handlers.SyncCustomers(s.client, false)
+			return err
+		})
+	}
+
+	if s.config.LedgerSyncInterval > 0 {
+		go runPeriodically("ledger sync", s.config.LedgerSyncInterval, func() error {
+			_, err := handlers.SyncLedger(s.client, false)
+			return err
+		})
+	}
+
+	if s.config.BankDirectoryRefreshInterval > 0 {
+		go runPeriodically("bank directory refresh", s.config.BankDirectoryRefreshInterval, s.banks.EnsureFresh)
+	}
+
+	if s.config.ReconcileInterval > 0 {
+		go runPeriodically("reconciliation", s.config.ReconcileInterval, func() error {
+			to := time.Now().UTC()
+			_, err := handlers.RunReconciliation(to.Add(-30*24*time.Hour), to, handlers.ReconcileTriggerSchedule)
+			return err
+		})
+	}
+
+	if s.config.InvoicePollInterval > 0 {
+		policy := handlers.InvoicePollPolicy{
+			Interval:   s.config.InvoicePollInterval,
+			MaxBackoff: s.config.InvoicePollMaxBackoff,
+		}
+		go runPeriodically("invoice status poll", s.config.InvoicePollInterval, func() error {
+			_, err := handlers.PollInvoices(s.client, policy)
+			return err
+		})
+	}
+
+	if s.config.DunningInterval > 0 {
+		go runPeriodically("invoice dunning", s.config.DunningInterval, func() error {
+			_, err := handlers.RunDunning(s.notifier, time.Now())
+			return err
+		})
+	}
+}
+
+// runPeriodically runs job immediately and then on every interval tick,
+// logging failures. It never returns.
+func runPeriodically(name string, interval time.Duration, job func() error) {
+	log.Printf("Scheduling %s every %s", name, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job(); err != nil {
+			log.Printf("Warning: %s failed: %v", name, err)
+		}
+		<-ticker.C
+	}
+}
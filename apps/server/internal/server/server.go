@@ -5,8 +5,11 @@ import (
 	"net/http"
 	"time"
 
+	"paystack.mpc.proxy/internal/bureau"
 	"paystack.mpc.proxy/internal/config"
+	"paystack.mpc.proxy/internal/document"
 	"paystack.mpc.proxy/internal/handlers"
+	"paystack.mpc.proxy/internal/notify"
 	"paystack.mpc.proxy/internal/paystack"
 
 	"github.com/go-chi/chi/v5"
@@ -16,8 +19,11 @@ import (
 
 // Server wraps the HTTP server
 type Server struct {
-	router *chi.Mux
-	config *config.Config
+	router   *chi.Mux
+	config   *config.Config
+	client   *paystack.Client
+	notifier notify.Notifier
+	banks    *handlers.BankDirectory
 }
 
 // New creates a new HTTP server instance with Chi router
@@ -25,6 +31,46 @@ func New(cfg *config.Config) *Server {
 	// Create Paystack client
 	client := paystack.NewClient(cfg.PaystackSecretKey)
 
+	// Create credit bureau provider (optional, file-backed stand-in)
+	var creditBureau bureau.CreditBureau
+	if cfg.CreditBureauFile != "" {
+		creditBureau = bureau.NewFileBureau(cfg.CreditBureauFile)
+		log.Printf("Using file-backed credit bureau: %s", cfg.CreditBureauFile)
+	}
+
+	// Create cached bank directory
+	bankDirectory := handlers.NewBankDirectory(client, cfg.BankDirectoryTTL, cfg.BankDirectoryCountries)
+
+	// Create offline invoice renderer
+	invoiceRenderer := document.NewRenderer(document.Business{
+		Name:    cfg.BusinessName,
+		Address: cfg.BusinessAddress,
+		Email:   cfg.BusinessEmail,
+		Phone:   cfg.BusinessPhone,
+		Footer:  cfg.InvoiceFooter,
+	}, cfg.InvoiceTemplateFile)
+
+	// Create invoice reminder notifier
+	var notifier notify.Notifier
+	switch cfg.DunningNotifier {
+	case "paystack":
+		notifier = notify.NewPaystackNotifier(client)
+	case "smtp":
+		notifier = notify.NewSMTPNotifier(notify.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		})
+	default:
+		if cfg.DunningNotifier != "log" {
+			log.Printf("Unknown DUNNING_NOTIFIER %q, logging reminders instead", cfg.DunningNotifier)
+		}
+		notifier = notify.NewLogNotifier()
+	}
+	log.Printf("Sending invoice reminders via %s", notifier.Name())
+
 	// Create Chi router
 	r := chi.NewRouter()
 
@@ -49,36 +95,71 @@ func New(cfg *config.Config) *Server {
 	coreHandler := handlers.NewCoreHandler(client)
 	customerHandler := handlers.NewCustomerHandler(client)
 	transactionHandler := handlers.NewTransactionHandler(client)
-	transferHandler := handlers.NewTransferHandler(client)
 	planHandler := handlers.NewPlanHandler(client)
 	subscriptionHandler := handlers.NewSubscriptionHandler(client)
-	bankHandler := handlers.NewBankHandler(client)
+	bankHandler := handlers.NewBankHandler(client, bankDirectory)
 	subAccountHandler := handlers.NewSubAccountHandler(client)
-	invoiceHandler := handlers.NewInvoiceHandler(client)
-	verdictHandler := handlers.NewVerdictHandler()
-	recipientHandler := handlers.NewRecipientHandler(client)
+	invoiceHandler := handlers.NewInvoiceHandler(client, invoiceRenderer)
+	verdictHandler := handlers.NewVerdictHandler(creditBureau, cfg.CreditBureauTTL)
+	recipientHandler := handlers.NewRecipientHandler(client, handlers.NameMatchPolicy{
+		VerifyThreshold: cfg.RecipientNameVerifyThreshold,
+		RejectThreshold: cfg.RecipientNameRejectThreshold,
+	}, bankDirectory)
+	transferHandler := handlers.NewTransferHandler(client, recipientHandler)
 	expenseHandler := handlers.NewExpenseHandler()
 	budgetHandler := handlers.NewBudgetHandler()
 	goalHandler := handlers.NewGoalHandler()
 	serviceProviderHandler := handlers.NewServiceProviderHandler()
+	beneficiaryLimitHandler := handlers.NewBeneficiaryLimitHandler()
+	accountLimitHandler := handlers.NewAccountLimitHandler()
+	dunningHandler := handlers.NewDunningHandler(notifier)
+	receivablesHandler := handlers.NewReceivablesHandler()
+	creditNoteHandler := handlers.NewCreditNoteHandler(client)
+	quoteHandler := handlers.NewQuoteHandler(client)
+	invoiceTemplateHandler := handlers.NewInvoiceTemplateHandler()
+	sequenceHandler := handlers.NewSequenceHandler()
+	invoiceBatchHandler := handlers.NewInvoiceBatchHandler(client)
+	statementHandler := handlers.NewStatementHandler(client, invoiceRenderer)
+	aggregateHandler := handlers.NewAggregateHandler()
+	snapshotHandler := handlers.NewSnapshotHandler(client, cfg.SnapshotCacheTTL)
+	exportHandler := handlers.NewExportHandler()
+	bankImportHandler := handlers.NewBankImportHandler()
+	importRuleHandler := handlers.NewImportRuleHandler()
+	reconciliationHandler := handlers.NewReconciliationHandler(client)
 
 	// Routes
 	r.Route("/api/v1", func(r chi.Router) {
 		// Core routes
 		r.Post("/balance", coreHandler.CheckBalance)
+		r.Post("/account/snapshot", snapshotHandler.Snapshot)
 
 		// Customer routes
 		r.Post("/customers/create", customerHandler.Create)
 		r.Post("/customers/list", customerHandler.List)
+		r.Post("/customers/sync", customerHandler.Sync)
+		r.Get("/customers/search", customerHandler.Search)
+		r.Get("/customers/local/{code}", customerHandler.GetLocal)
+		r.Put("/customers/local/{code}", customerHandler.UpdateProfile)
+		r.Get("/customers/statement/{code}", statementHandler.Get)
 
 		// Transaction routes
 		r.Post("/transactions/initialize", transactionHandler.Initialize)
 		r.Post("/transactions/verify", transactionHandler.Verify)
 		r.Post("/transactions/list", transactionHandler.List)
+		r.Post("/transactions/sync", transactionHandler.Sync)
+		r.Get("/transactions/ledger", transactionHandler.Ledger)
+		r.Post("/transactions/aggregate", aggregateHandler.Aggregate)
+		r.Get("/transactions/export", exportHandler.Transactions)
 
 		// Transfer routes
 		r.Post("/transfers/recipient/create", transferHandler.CreateRecipient)
 		r.Post("/transfers/initiate", transferHandler.Initiate)
+		r.Post("/transfers/bulk", transferHandler.InitiateBulk)
+		r.Post("/transfers/finalize", transferHandler.Finalize)
+		r.Post("/transfers/resend-otp", transferHandler.ResendOTP)
+		r.Post("/transfers/verify", transferHandler.Verify)
+		r.Get("/transfers/list", transferHandler.List)
+		r.Get("/transfers/get/{code}", transferHandler.Fetch)
 
 		// Plan routes
 		r.Post("/plans/list", planHandler.List)
@@ -88,7 +169,10 @@ func New(cfg *config.Config) *Server {
 
 		// Bank routes
 		r.Post("/banks/list", bankHandler.List)
+		r.Post("/banks/search", bankHandler.Search)
 		r.Post("/banks/resolve", bankHandler.ResolveAccount)
+		r.Get("/banks/lookup", bankHandler.Lookup)
+		r.Post("/banks/refresh", bankHandler.Refresh)
 
 		// SubAccount routes
 		r.Post("/subaccounts/list", subAccountHandler.List)
@@ -96,25 +180,107 @@ func New(cfg *config.Config) *Server {
 		// Invoice routes
 		r.Post("/invoices/create", invoiceHandler.Create)
 		r.Post("/invoices/list", invoiceHandler.List)
+		r.Post("/invoices/export", exportHandler.Invoices)
 		r.Post("/invoices/get/{id_or_code}", invoiceHandler.Get)
 		r.Post("/invoices/verify/{code}", invoiceHandler.Verify)
+		r.Get("/invoices/render/{code}", invoiceHandler.Render)
+		r.Get("/invoices/payments/{code}", invoiceHandler.Payments)
+		r.Post("/invoices/payments/{code}", invoiceHandler.RecordPayment)
+		r.Post("/invoices/archive/{code}", invoiceHandler.Archive)
+
+		// Invoice template routes
+		r.Post("/invoices/templates/create", invoiceTemplateHandler.Create)
+		r.Post("/invoices/templates/list", invoiceTemplateHandler.List)
+		r.Get("/invoices/templates/{id}", invoiceTemplateHandler.Get)
+		r.Put("/invoices/templates/{id}", invoiceTemplateHandler.Update)
+		r.Delete("/invoices/templates/{id}", invoiceTemplateHandler.Delete)
+
+		// Invoice batch routes (bulk invoicing and bill splitting)
+		r.Post("/invoices/batches/create", invoiceBatchHandler.Create)
+		r.Post("/invoices/batches/list", invoiceBatchHandler.List)
+		r.Get("/invoices/batches/{batch_id}", invoiceBatchHandler.Get)
+
+		// Number sequence routes (invoice, quote and credit note numbering)
+		r.Get("/sequences/list", sequenceHandler.List)
+		r.Put("/sequences/{name}", sequenceHandler.Update)
+
+		// Credit note routes (invoice adjustments)
+		r.Post("/credit_notes/create", creditNoteHandler.Create)
+		r.Get("/credit_notes/list", creditNoteHandler.List)
+		r.Get("/credit_notes/{number}", creditNoteHandler.Get)
+
+		// Quote routes (estimates that convert to invoices)
+		r.Post("/quotes/create", quoteHandler.Create)
+		r.Post("/quotes/list", quoteHandler.List)
+		r.Get("/quotes/{number}", quoteHandler.Get)
+		r.Post("/quotes/{number}/send", quoteHandler.Send)
+		r.Post("/quotes/{number}/accept", quoteHandler.Accept)
+		r.Post("/quotes/{number}/decline", quoteHandler.Decline)
+
+		// Dunning routes (invoice reminder schedules)
+		r.Post("/dunning/policies/create", dunningHandler.CreatePolicy)
+		r.Post("/dunning/policies/list", dunningHandler.ListPolicies)
+		r.Put("/dunning/policies/{id}", dunningHandler.UpdatePolicy)
+		r.Put("/dunning/invoices/{invoice_code}", dunningHandler.SetInvoice)
+		r.Post("/dunning/run", dunningHandler.Run)
+		r.Get("/dunning/reminders", dunningHandler.Reminders)
+
+		// Report routes
+		r.Get("/reports/receivables/aging", receivablesHandler.Aging)
 
 		// Verdict routes (credit check / affordability)
 		r.Post("/verdict/check", verdictHandler.CheckAffordability)
 		r.Get("/verdict/profile", verdictHandler.GetFinancialProfile)
 		r.Get("/verdict/profiles", verdictHandler.ListProfiles)
+		r.Post("/verdict/refresh", verdictHandler.RefreshCreditProfile)
 
 		// Recipient routes (transfer recipients)
 		r.Post("/recipients/create", recipientHandler.Create)
 		r.Get("/recipients/list", recipientHandler.List)
+		r.Get("/recipients/export", exportHandler.Recipients)
 		r.Get("/recipients/get", recipientHandler.Get)
+		r.Post("/recipients/sync", recipientHandler.Sync)
+		r.Put("/recipients/update/{recipient_code}", recipientHandler.Update)
+		r.Post("/recipients/deactivate/{recipient_code}", recipientHandler.Deactivate)
+		r.Delete("/recipients/delete/{recipient_code}", recipientHandler.Delete)
+
+		// Beneficiary limit routes (per-recipient payment caps)
+		r.Post("/beneficiary_limits/set", beneficiaryLimitHandler.Set)
+		r.Post("/beneficiary_limits/list", beneficiaryLimitHandler.List)
+		r.Get("/beneficiary_limits/{recipient_code}", beneficiaryLimitHandler.Get)
+		r.Delete("/beneficiary_limits/{recipient_code}", beneficiaryLimitHandler.Delete)
+
+		// Account limit routes (account-wide outflow caps)
+		r.Post("/account_limits/set", accountLimitHandler.Set)
+		r.Post("/account_limits/list", accountLimitHandler.List)
+		r.Get("/account_limits/utilisation", accountLimitHandler.Utilisation)
 
 		// Expense routes
 		r.Post("/expenses/create", expenseHandler.Create)
 		r.Post("/expenses/list", expenseHandler.List)
+		r.Post("/expenses/export", exportHandler.Expenses)
 		r.Get("/expenses/get/{id}", expenseHandler.Get)
 		r.Put("/expenses/update/{id}", expenseHandler.Update)
 
+		// Bank statement import routes
+		r.Post("/imports/statements/upload", bankImportHandler.Upload)
+		r.Get("/imports/statements/list", bankImportHandler.List)
+		r.Get("/imports/statements/{id}", bankImportHandler.Preview)
+		r.Put("/imports/statements/{id}/lines/{line_no}", bankImportHandler.UpdateLine)
+		r.Post("/imports/statements/{id}/commit", bankImportHandler.Commit)
+		r.Delete("/imports/statements/{id}", bankImportHandler.Discard)
+		r.Post("/imports/rules/create", importRuleHandler.Create)
+		r.Get("/imports/rules/list", importRuleHandler.List)
+		r.Put("/imports/rules/{id}", importRuleHandler.Update)
+		r.Delete("/imports/rules/{id}", importRuleHandler.Delete)
+
+		// Reconciliation routes
+		r.Post("/reconciliation/run", reconciliationHandler.Run)
+		r.Get("/reconciliation/runs", reconciliationHandler.Runs)
+		r.Get("/reconciliation/report", reconciliationHandler.Report)
+		r.Post("/reconciliation/match", reconciliationHandler.Match)
+		r.Post("/reconciliation/unmatch", reconciliationHandler.Unmatch)
+
 		// Budget routes
 		r.Post("/budgets/create", budgetHandler.Create)
 		r.Post("/budgets/list", budgetHandler.List)
@@ -141,8 +307,11 @@ func New(cfg *config.Config) *Server {
 	})
 
 	return &Server{
-		router: r,
-		config: cfg,
+		router:   r,
+		config:   cfg,
+		client:   client,
+		notifier: notifier,
+		banks:    bankDirectory,
 	}
 }
 
@@ -154,6 +323,8 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // Start starts the HTTP server
 func (s *Server) Start() error {
 	addr := ":" + s.config.ServerPort
+	s.startJobs()
+
 	log.Printf("Starting Paystack HTTP Server on %s", addr)
 	return http.ListenAndServe(addr, s.router)
 }
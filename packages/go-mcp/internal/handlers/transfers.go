@@ -65,3 +65,89 @@ func (h *TransferHandler) Initiate(_ context.Context, request mcp.CallToolReques
 	}
 	return SuccessResult(result)
 }
+
+func (h *TransferHandler) Finalize(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	transferCode := request.GetString("transfer_code", "")
+	otp := request.GetString("otp", "")
+
+	if transferCode == "" || otp == "" {
+		return ErrorResult(fmt.Errorf("transfer_code and otp are required")), nil
+	}
+
+	result, err := h.client.FinalizeTransfer(transferCode, otp)
+	if err != nil {
+		return ErrorResult(err), nil
+	}
+	return SuccessResult(result)
+}
+
+func (h *TransferHandler) ResendOTP(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	transferCode := request.GetString("transfer_code", "")
+	reason := request.GetString("reason", "resend_otp")
+
+	if transferCode == "" {
+		return ErrorResult(fmt.Errorf("transfer_code is required")), nil
+	}
+	if reason != "resend_otp" && reason != "transfer" {
+		return ErrorResult(fmt.Errorf("reason must be resend_otp or transfer")), nil
+	}
+
+	result, err := h.client.ResendTransferOTP(transferCode, reason)
+	if err != nil {
+		return ErrorResult(err), nil
+	}
+	return SuccessResult(result)
+}
+
+func (h *TransferHandler) Fetch(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	transferCode := request.GetString("transfer_code", "")
+	if transferCode == "" {
+		return ErrorResult(fmt.Errorf("transfer_code is required")), nil
+	}
+
+	result, err := h.client.FetchTransfer(transferCode)
+	if err != nil {
+		return ErrorResult(err), nil
+	}
+	return SuccessResult(result)
+}
+
+func (h *TransferHandler) Verify(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	reference := request.GetString("reference", "")
+	if reference == "" {
+		return ErrorResult(fmt.Errorf("reference is required")), nil
+	}
+
+	result, err := h.client.VerifyTransfer(reference)
+	if err != nil {
+		return ErrorResult(err), nil
+	}
+	return SuccessResult(result)
+}
+
+func (h *TransferHandler) List(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	perPage := request.GetInt("per_page", 50)
+	if perPage < 1 {
+		perPage = 1
+	}
+	if perPage > 100 {
+		perPage = 100
+	}
+	page := request.GetInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+
+	filter := paystack.TransferFilter{
+		RecipientID: request.GetInt("recipient", 0),
+		Status:      request.GetString("status", ""),
+		From:        request.GetString("from", ""),
+		To:          request.GetString("to", ""),
+	}
+
+	result, err := h.client.ListTransfers(perPage, page, filter)
+	if err != nil {
+		return ErrorResult(err), nil
+	}
+	return SuccessResult(result)
+}
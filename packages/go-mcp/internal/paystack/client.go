@@ -2,6 +2,7 @@ package paystack
 
 import (
 	"fmt"
+	"net/url"
 
 	"github.com/borderlesshq/paystack-go"
 )
@@ -54,3 +55,83 @@ func (c *Client) SafeCheckBalance() (paystack.Response, error) {
 		return nil, fmt.Errorf("invalid response: unexpected data type")
 	}
 }
+
+// TransferFilter narrows a transfer list. Zero values are not sent.
+type TransferFilter struct {
+	// RecipientID is the Paystack recipient's numeric ID
+	RecipientID int
+	// Status is otp, pending, success, failed, reversed or abandoned
+	Status string
+	// From and To (RFC3339 or YYYY-MM-DD) bound the transfer creation date
+	From string
+	To   string
+}
+
+// ListTransfers fetches one page of transfers, newest first.
+// The response contains the raw "data" array and pagination "meta".
+func (c *Client) ListTransfers(perPage, page int, filter TransferFilter) (paystack.Response, error) {
+	params := url.Values{}
+	params.Set("perPage", fmt.Sprintf("%d", perPage))
+	params.Set("page", fmt.Sprintf("%d", page))
+	if filter.RecipientID != 0 {
+		params.Set("recipient", fmt.Sprintf("%d", filter.RecipientID))
+	}
+	if filter.Status != "" {
+		params.Set("status", filter.Status)
+	}
+	if filter.From != "" {
+		params.Set("from", filter.From)
+	}
+	if filter.To != "" {
+		params.Set("to", filter.To)
+	}
+
+	resp := paystack.Response{}
+	if err := c.Call("GET", "transfer?"+params.Encode(), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// FetchTransfer fetches a transfer by ID or transfer code.
+// The SDK's Transfer.Get drops the reference, so this calls the API directly.
+func (c *Client) FetchTransfer(idOrCode string) (paystack.Response, error) {
+	resp := paystack.Response{}
+	if err := c.Call("GET", fmt.Sprintf("transfer/%s", url.PathEscape(idOrCode)), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// VerifyTransfer fetches a transfer by the reference it was initiated with
+func (c *Client) VerifyTransfer(reference string) (paystack.Response, error) {
+	resp := paystack.Response{}
+	if err := c.Call("GET", fmt.Sprintf("transfer/verify/%s", url.PathEscape(reference)), nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// FinalizeTransfer completes a transfer that is waiting for an OTP.
+// The SDK's Transfer.Finalize sends url.Values, which encode to JSON arrays,
+// so this posts a plain object instead.
+func (c *Client) FinalizeTransfer(transferCode, otp string) (paystack.Response, error) {
+	resp := paystack.Response{}
+	body := map[string]string{"transfer_code": transferCode, "otp": otp}
+	if err := c.Call("POST", "transfer/finalize_transfer", body, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ResendTransferOTP sends a new OTP for a transfer. reason is
+// "resend_otp" or "transfer". Like FinalizeTransfer, this avoids the SDK's
+// url.Values body.
+func (c *Client) ResendTransferOTP(transferCode, reason string) (paystack.Response, error) {
+	resp := paystack.Response{}
+	body := map[string]string{"transfer_code": transferCode, "reason": reason}
+	if err := c.Call("POST", "transfer/resend_otp", body, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
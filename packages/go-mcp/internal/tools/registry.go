@@ -228,6 +228,108 @@ func (r *Registry) registerTransferTools(s *server.MCPServer) {
 			Required: []string{"source", "amount", "recipient"},
 		},
 	}, r.transferHandler.Initiate)
+
+	s.AddTool(mcp.Tool{
+		Name:        "paystack_transfer_finalize",
+		Description: "Finalize a transfer that is waiting for an OTP",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"transfer_code": map[string]interface{}{
+					"type":        "string",
+					"description": "Transfer code (TRF_...)",
+				},
+				"otp": map[string]interface{}{
+					"type":        "string",
+					"description": "OTP sent to the business phone",
+				},
+			},
+			Required: []string{"transfer_code", "otp"},
+		},
+	}, r.transferHandler.Finalize)
+
+	s.AddTool(mcp.Tool{
+		Name:        "paystack_transfer_resend_otp",
+		Description: "Resend the OTP for a transfer",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"transfer_code": map[string]interface{}{
+					"type":        "string",
+					"description": "Transfer code (TRF_...)",
+				},
+				"reason": map[string]interface{}{
+					"type":        "string",
+					"description": "resend_otp (default) or transfer",
+				},
+			},
+			Required: []string{"transfer_code"},
+		},
+	}, r.transferHandler.ResendOTP)
+
+	s.AddTool(mcp.Tool{
+		Name:        "paystack_transfer_fetch",
+		Description: "Fetch a transfer by transfer code",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"transfer_code": map[string]interface{}{
+					"type":        "string",
+					"description": "Transfer code (TRF_...) or ID",
+				},
+			},
+			Required: []string{"transfer_code"},
+		},
+	}, r.transferHandler.Fetch)
+
+	s.AddTool(mcp.Tool{
+		Name:        "paystack_transfer_verify",
+		Description: "Verify a transfer by reference",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"reference": map[string]interface{}{
+					"type":        "string",
+					"description": "Reference the transfer was initiated with",
+				},
+			},
+			Required: []string{"reference"},
+		},
+	}, r.transferHandler.Verify)
+
+	s.AddTool(mcp.Tool{
+		Name:        "paystack_transfer_list",
+		Description: "List transfers with optional filters and pagination",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"status": map[string]interface{}{
+					"type":        "string",
+					"description": "Transfer status (otp, pending, success, failed, reversed) (optional)",
+				},
+				"recipient": map[string]interface{}{
+					"type":        "number",
+					"description": "Paystack recipient ID (optional)",
+				},
+				"from": map[string]interface{}{
+					"type":        "string",
+					"description": "Start date, RFC3339 or YYYY-MM-DD (optional)",
+				},
+				"to": map[string]interface{}{
+					"type":        "string",
+					"description": "End date, RFC3339 or YYYY-MM-DD (optional)",
+				},
+				"page": map[string]interface{}{
+					"type":        "number",
+					"description": "Page number (optional)",
+				},
+				"per_page": map[string]interface{}{
+					"type":        "number",
+					"description": "Number of transfers per page, 1-100 (optional, default 50)",
+				},
+			},
+		},
+	}, r.transferHandler.List)
 }
 
 func (r *Registry) registerPlanTools(s *server.MCPServer) {